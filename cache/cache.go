@@ -0,0 +1,27 @@
+package cache
+
+// Cache 查询结果缓存，值为序列化后的字节，便于替换为 Redis 等外部实现
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	// Purge 清空缓存，时刻表重新加载后调用
+	Purge()
+	Stats() Stats
+}
+
+// Stats 缓存命中情况
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+	Bytes     int64  `json:"bytes"` // 键与值的总字节数
+}
+
+// HitRatio 命中率，没有请求时为 0
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU 带过期时间的内存 LRU 缓存，条目数超过 capacity 或键与值的总字节数超过 maxBytes 时淘汰最久未使用的条目，
+// 两者为 0 时不限制对应的上限
+type LRU struct {
+	mu        sync.Mutex
+	capacity  int
+	maxBytes  int64
+	bytes     int64
+	ttl       time.Duration
+	items     map[string]*list.Element
+	order     *list.List
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type entry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+var _ Cache = (*LRU)(nil)
+
+func NewLRU(capacity int, maxBytes int64, ttl time.Duration) *LRU {
+	return &LRU{
+		capacity: capacity,
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	e := element.Value.(*entry)
+	if time.Now().After(e.expireAt) {
+		c.removeElement(element)
+		c.misses++
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	c.hits++
+	return e.value, true
+}
+
+// Set 写入或替换 key；单个条目超过 maxBytes 时不缓存
+func (c *LRU) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+	e := &entry{key: key, value: value, expireAt: time.Now().Add(c.ttl)}
+	if c.maxBytes > 0 && e.size() > c.maxBytes {
+		return
+	}
+	c.items[key] = c.order.PushFront(e)
+	c.bytes = c.bytes + e.size()
+	for (c.capacity > 0 && c.order.Len() > c.capacity) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.removeElement(c.order.Back())
+		c.evictions++
+	}
+}
+
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.bytes = 0
+}
+
+func (c *LRU) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      c.order.Len(),
+		Bytes:     c.bytes,
+	}
+}
+
+func (c *LRU) removeElement(element *list.Element) {
+	e := element.Value.(*entry)
+	c.order.Remove(element)
+	delete(c.items, e.key)
+	c.bytes = c.bytes - e.size()
+}
+
+// size 条目占用的字节数，按键与值的长度计，不含 map 与链表节点的固定开销
+func (e *entry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLRUMaxBytes 总字节数超过上限时淘汰最久未使用的条目，超过上限的单个条目不缓存
+func TestLRUMaxBytes(t *testing.T) {
+	c := NewLRU(0, 30, time.Minute)
+	c.Set("a", make([]byte, 9))
+	c.Set("b", make([]byte, 9))
+	c.Set("c", make([]byte, 9))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a evicted before the budget was exceeded")
+	}
+	c.Set("d", make([]byte, 9))
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("least recently used b not evicted")
+	}
+	stats := c.Stats()
+	if stats.Bytes > 30 || stats.Size != 3 || stats.Evictions != 1 {
+		t.Fatalf("stats = %+v, want 3 entries within 30 bytes and 1 eviction", stats)
+	}
+	c.Set("a", make([]byte, 4))
+	if got := c.Stats().Bytes; got != 25 {
+		t.Fatalf("bytes after replacing a = %d, want 25", got)
+	}
+	c.Set("big", make([]byte, 40))
+	if _, ok := c.Get("big"); ok {
+		t.Fatal("entry larger than the budget was cached")
+	}
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Fatalf("stats = %+v, want 1 hit and 2 misses", stats)
+	}
+	c.Purge()
+	if stats := c.Stats(); stats.Bytes != 0 || stats.Size != 0 {
+		t.Fatalf("stats after purge = %+v", stats)
+	}
+}
@@ -0,0 +1,83 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"log/slog"
+	"railway/logging"
+	"railway/metrics"
+	"time"
+)
+
+// APIKey 客户端凭证，只保存 key 的 SHA-256，明文只在创建时返回一次
+type APIKey struct {
+	ID            int       `gorm:"primaryKey;autoIncrement"`
+	Name          string    `gorm:"size:100" json:"name"`    // 使用方
+	Prefix        string    `gorm:"size:16" json:"prefix"`   // key 的前几位，便于在日志中识别
+	KeyHash       string    `gorm:"unique;size:64" json:"-"` // key 的 SHA-256（十六进制）
+	RatePerSecond float64   `json:"rate_per_second"`         // 令牌桶每秒补充的令牌数
+	Burst         float64   `json:"burst"`                   // 令牌桶容量
+	DailyQuota    int64     `json:"daily_quota"`             // 每日可消耗的令牌总数，0 表示不限
+	Disabled      bool      `json:"disabled"`                // 停用后立即拒绝
+	Admin         bool      `json:"admin"`                   // 可以访问 /admin 下的接口
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type APIKeyDAO interface {
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	GetAllAPIKeys(ctx context.Context) ([]APIKey, error)
+	UpdateAPIKey(ctx context.Context, key *APIKey) error
+}
+
+type APIKeyDAOImpl struct {
+	DB     *gorm.DB
+	Logger *slog.Logger
+}
+
+// NewAPIKeyDAO 创建新的 APIKeyDAO 实例
+func NewAPIKeyDAO(db *gorm.DB, logger *slog.Logger) APIKeyDAO {
+	return &APIKeyDAOImpl{
+		DB:     db.Session(&gorm.Session{Logger: logging.NewGormLogger(logger)}),
+		Logger: logger,
+	}
+}
+
+var _ APIKeyDAO = (*APIKeyDAOImpl)(nil)
+
+// CreateAPIKey 创建一条 key 记录
+func (dao *APIKeyDAOImpl) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	defer metrics.ObserveDB("CreateAPIKey", time.Now())
+	return dao.DB.WithContext(ctx).Create(key).Error
+}
+
+// GetAPIKeyByHash 根据 key 的哈希查询，不存在时返回 nil
+func (dao *APIKeyDAOImpl) GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	defer metrics.ObserveDB("GetAPIKeyByHash", time.Now())
+	var keys []APIKey
+	result := dao.DB.WithContext(ctx).Where("key_hash = ?", keyHash).Limit(1).Find(&keys)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return &keys[0], nil
+}
+
+// GetAllAPIKeys 获取全部 key
+func (dao *APIKeyDAOImpl) GetAllAPIKeys(ctx context.Context) ([]APIKey, error) {
+	defer metrics.ObserveDB("GetAllAPIKeys", time.Now())
+	var keys []APIKey
+	result := dao.DB.WithContext(ctx).Find(&keys)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return keys, nil
+}
+
+// UpdateAPIKey 更新 key 的配额或停用状态
+func (dao *APIKeyDAOImpl) UpdateAPIKey(ctx context.Context, key *APIKey) error {
+	defer metrics.ObserveDB("UpdateAPIKey", time.Now())
+	return dao.DB.WithContext(ctx).Save(key).Error
+}
@@ -0,0 +1,125 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log/slog"
+	"railway/logging"
+	"railway/metrics"
+	"time"
+)
+
+// City 城市数据模型，以 12306 的城市代码为主键
+type City struct {
+	CityCode string `gorm:"primaryKey;size:10" json:"city_code"` // 城市代码
+	CityName string `gorm:"size:100;index" json:"city_name"`     // 城市名
+}
+
+// CityStation 城市与车站的从属关系，一个城市可以有名称与城市不同的车站（如上海的虹桥）
+type CityStation struct {
+	CityCode    string `gorm:"primaryKey;size:10" json:"city_code"`
+	StationCode string `gorm:"primaryKey;size:20" json:"station_code"`
+}
+
+func (CityStation) TableName() string {
+	return "city_station"
+}
+
+type CityDAO interface {
+	SaveCities(ctx context.Context, cities []City) error
+	SaveCityStations(ctx context.Context, members []CityStation) error
+	GetAllCities(ctx context.Context) ([]City, error)
+	GetCityByCode(ctx context.Context, cityCode string) (*City, error)
+	GetCityByName(ctx context.Context, cityName string) (*City, error)
+	GetStationsByCityCode(ctx context.Context, cityCode string) ([]Station, error)
+}
+
+type CityDAOImpl struct {
+	DB     *gorm.DB
+	Logger *slog.Logger
+}
+
+// NewCityDAO 创建新的 CityDAO 实例
+func NewCityDAO(db *gorm.DB, logger *slog.Logger) CityDAO {
+	return &CityDAOImpl{
+		DB:     db.Session(&gorm.Session{Logger: logging.NewGormLogger(logger)}),
+		Logger: logger,
+	}
+}
+
+var _ CityDAO = (*CityDAOImpl)(nil)
+
+// SaveCities 批量写入城市，已存在的城市更新名称
+func (dao *CityDAOImpl) SaveCities(ctx context.Context, cities []City) error {
+	defer metrics.ObserveDB("SaveCities", time.Now())
+	if len(cities) == 0 {
+		return nil
+	}
+	return dao.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "city_code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"city_name"}),
+	}).CreateInBatches(cities, 200).Error
+}
+
+// SaveCityStations 批量写入从属关系，已存在的记录跳过
+func (dao *CityDAOImpl) SaveCityStations(ctx context.Context, members []CityStation) error {
+	defer metrics.ObserveDB("SaveCityStations", time.Now())
+	if len(members) == 0 {
+		return nil
+	}
+	return dao.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(members, 200).Error
+}
+
+// GetAllCities 获取所有城市
+func (dao *CityDAOImpl) GetAllCities(ctx context.Context) ([]City, error) {
+	defer metrics.ObserveDB("GetAllCities", time.Now())
+	var cities []City
+	result := dao.DB.WithContext(ctx).Order("city_code").Find(&cities)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return cities, nil
+}
+
+// GetCityByCode 根据城市代码查询，不存在时返回 nil
+func (dao *CityDAOImpl) GetCityByCode(ctx context.Context, cityCode string) (*City, error) {
+	defer metrics.ObserveDB("GetCityByCode", time.Now())
+	var cities []City
+	result := dao.DB.WithContext(ctx).Where("city_code = ?", cityCode).Limit(1).Find(&cities)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(cities) == 0 {
+		return nil, nil
+	}
+	return &cities[0], nil
+}
+
+// GetCityByName 根据城市名查询，不存在时返回 nil
+func (dao *CityDAOImpl) GetCityByName(ctx context.Context, cityName string) (*City, error) {
+	defer metrics.ObserveDB("GetCityByName", time.Now())
+	var cities []City
+	result := dao.DB.WithContext(ctx).Where("city_name = ?", cityName).Limit(1).Find(&cities)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(cities) == 0 {
+		return nil, nil
+	}
+	return &cities[0], nil
+}
+
+// GetStationsByCityCode 获取城市下的所有车站
+func (dao *CityDAOImpl) GetStationsByCityCode(ctx context.Context, cityCode string) ([]Station, error) {
+	defer metrics.ObserveDB("GetStationsByCityCode", time.Now())
+	var stations []Station
+	result := dao.DB.WithContext(ctx).
+		Joins("JOIN city_station ON city_station.station_code = stations.station_code").
+		Where("city_station.city_code = ?", cityCode).
+		Find(&stations)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return stations, nil
+}
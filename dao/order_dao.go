@@ -0,0 +1,155 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"log/slog"
+	"railway/logging"
+	"railway/metrics"
+	"time"
+)
+
+// 订单状态：held 占座待支付，之后转为 confirmed、cancelled 或 expired；confirmed 退票后为 refunded
+const (
+	OrderHeld      = "held"
+	OrderConfirmed = "confirmed"
+	OrderCancelled = "cancelled"
+	OrderExpired   = "expired"
+	OrderRefunded  = "refunded"
+)
+
+// ErrOrderStateChanged 状态转换时订单已不处于预期状态，通常是并发的确认、取消或超时释放
+var ErrOrderStateChanged = errors.New("orderStateChanged")
+
+// Order 一个订单占用行程中每一段、每位乘客各一个座位
+type Order struct {
+	ID           string           `gorm:"primaryKey;size:32" json:"id"`
+	Status       string           `gorm:"size:16;index" json:"status"`
+	APIKeyID     int              `gorm:"index" json:"-"` // 创建订单的 API key，未开启鉴权时为 0
+	TotalAmount  float64          `json:"total_amount"`   // 全部乘客的应付金额
+	RefundFee    float64          `json:"refund_fee"`     // 退票手续费
+	RefundAmount float64          `json:"refund_amount"`  // 实际退款金额
+	HoldUntil    time.Time        `gorm:"index" json:"hold_until"`
+	ConfirmedAt  *time.Time       `json:"confirmed_at,omitempty"`
+	ClosedAt     *time.Time       `json:"closed_at,omitempty"` // 取消、超时或退票的时间
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+	Legs         []OrderLeg       `gorm:"foreignKey:OrderID" json:"legs"`
+	Passengers   []OrderPassenger `gorm:"foreignKey:OrderID" json:"passengers"`
+}
+
+func (Order) TableName() string {
+	return "orders"
+}
+
+// OrderLeg 订单中乘坐同一车次的一段，Date 为在 From 上车的日期
+type OrderLeg struct {
+	ID            int     `gorm:"primaryKey;autoIncrement" json:"-"`
+	OrderID       string  `gorm:"size:32;index" json:"-"`
+	Seq           int     `json:"seq"`
+	TrainNo       string  `gorm:"size:20" json:"train_no"`
+	TrainNumber   string  `gorm:"size:10" json:"train_number"`
+	Date          string  `gorm:"size:10" json:"date"`
+	From          string  `gorm:"column:from_station;size:20" json:"from"`
+	To            string  `gorm:"column:to_station;size:20" json:"to"`
+	DepartureTime string  `gorm:"size:5" json:"departure_time"`
+	ArrivalTime   string  `gorm:"size:5" json:"arrival_time"`
+	SeatClass     string  `gorm:"size:5" json:"seat_class"`
+	Price         float64 `json:"price"` // 该席别的公布票价
+}
+
+func (OrderLeg) TableName() string {
+	return "order_legs"
+}
+
+// OrderPassenger 乘车人，Amount 为按旅客类型计算的全程应付金额
+type OrderPassenger struct {
+	ID       int     `gorm:"primaryKey;autoIncrement" json:"-"`
+	OrderID  string  `gorm:"size:32;index" json:"-"`
+	Name     string  `gorm:"size:50" json:"name"`
+	IDNumber string  `gorm:"size:32" json:"id_number"`
+	Type     string  `gorm:"size:16" json:"type"`
+	Amount   float64 `json:"amount"`
+}
+
+func (OrderPassenger) TableName() string {
+	return "order_passengers"
+}
+
+type OrderDAO interface {
+	CreateOrder(ctx context.Context, order *Order) error
+	GetOrder(ctx context.Context, id string) (*Order, error)
+	UpdateOrderStatus(ctx context.Context, id, from, to string, updates map[string]any) error
+	GetExpiredOrders(ctx context.Context, now time.Time, limit int) ([]Order, error)
+}
+
+type OrderDAOImpl struct {
+	DB     *gorm.DB
+	Logger *slog.Logger
+}
+
+// NewOrderDAO 创建新的 OrderDAO 实例
+func NewOrderDAO(db *gorm.DB, logger *slog.Logger) OrderDAO {
+	return &OrderDAOImpl{
+		DB:     db.Session(&gorm.Session{Logger: logging.NewGormLogger(logger)}),
+		Logger: logger,
+	}
+}
+
+var _ OrderDAO = (*OrderDAOImpl)(nil)
+
+// CreateOrder 在一个事务中写入订单、各段与乘车人
+func (dao *OrderDAOImpl) CreateOrder(ctx context.Context, order *Order) error {
+	defer metrics.ObserveDB("CreateOrder", time.Now())
+	return dao.DB.WithContext(ctx).Create(order).Error
+}
+
+// GetOrder 根据订单号查询订单及其各段与乘车人，不存在时返回 nil
+func (dao *OrderDAOImpl) GetOrder(ctx context.Context, id string) (*Order, error) {
+	defer metrics.ObserveDB("GetOrder", time.Now())
+	var orders []Order
+	result := dao.DB.WithContext(ctx).
+		Preload("Legs", func(db *gorm.DB) *gorm.DB { return db.Order("seq") }).
+		Preload("Passengers", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("id = ?", id).Limit(1).Find(&orders)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	return &orders[0], nil
+}
+
+// UpdateOrderStatus 只有订单仍处于 from 状态时才转为 to 并写入 updates，否则返回 ErrOrderStateChanged，
+// 保证同一订单的座位只被退还一次
+func (dao *OrderDAOImpl) UpdateOrderStatus(ctx context.Context, id, from, to string, updates map[string]any) error {
+	defer metrics.ObserveDB("UpdateOrderStatus", time.Now())
+	values := map[string]any{"status": to}
+	for column, value := range updates {
+		values[column] = value
+	}
+	result := dao.DB.WithContext(ctx).Model(&Order{}).Where("id = ? AND status = ?", id, from).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrderStateChanged
+	}
+	return nil
+}
+
+// GetExpiredOrders 获取占座已超时但仍为 held 的订单
+func (dao *OrderDAOImpl) GetExpiredOrders(ctx context.Context, now time.Time, limit int) ([]Order, error) {
+	defer metrics.ObserveDB("GetExpiredOrders", time.Now())
+	var orders []Order
+	result := dao.DB.WithContext(ctx).
+		Preload("Legs").Preload("Passengers").
+		Where("status = ? AND hold_until < ?", OrderHeld, now).
+		Order("hold_until").Limit(limit).Find(&orders)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return orders, nil
+}
@@ -1,178 +1,240 @@
 package dao
 
-import "gorm.io/gorm"
+import (
+	"context"
+	"gorm.io/gorm"
+	"log/slog"
+	"railway/logging"
+	"railway/metrics"
+	"time"
+)
 
 type RailWay struct {
-	ID               uint    `gorm:"primaryKey" json:"id"`
-	TrainNumber      string  `gorm:"size:20" json:"train_number"`
-	TrainNo          string  `gorm:"size:20" json:"train_no"`
-	DepartureStation string  `gorm:"size:20" json:"departure_station"`
-	DepartureTime    string  `gorm:"size:20" json:"departure_time"`
-	ArrivalStation   string  `gorm:"size:20" json:"arrival_station"`
-	ArrivalTime      string  `gorm:"size:20" json:"arrival_time"`
-	RunningTime      string  `gorm:"size:20" json:"running_time"`
-	Price            float64 `gorm:"size:1" json:"price"`
-	YWPrice          float64 `gorm:"size:1" json:"yw_price"`  //硬卧
-	YZPrice          float64 `gorm:"size:1" json:"yz_price"`  //硬座
-	RWPrice          float64 `gorm:"size:1" json:"rw_price"`  //软卧
-	ZEPrice          float64 `gorm:"size:1" json:"ze_price"`  //二等座
-	ZYPrice          float64 `gorm:"size:1" json:"zy_price"`  //一等座
-	SWZPrice         float64 `gorm:"size:1" json:"swz_price"` //商务座
-	TZPrice          float64 `gorm:"size:1" json:"tz_price"`  //特等座
-	GRPrice          float64 `gorm:"size:1" json:"gr_price"`  //高软
-	ArrivalDay       uint    `gorm:"size:1" json:"arrival_day"`
-	IsHighSpeed      uint    `gorm:"size:1" json:"is_high_speed"` //1为高速列车，0为普速列车
+	ID               uint     `gorm:"primaryKey" json:"id"`
+	TrainNumber      string   `gorm:"size:20" json:"train_number"`
+	TrainNo          string   `gorm:"size:20" json:"train_no"`
+	DepartureStation string   `gorm:"size:20" json:"departure_station"`
+	DepartureTime    string   `gorm:"size:20" json:"departure_time"`
+	ArrivalStation   string   `gorm:"size:20" json:"arrival_station"`
+	ArrivalTime      string   `gorm:"size:20" json:"arrival_time"`
+	RunningTime      string   `gorm:"size:20" json:"running_time"`
+	Price            float64  `gorm:"size:1" json:"price"`
+	YWPrice          float64  `gorm:"size:1" json:"yw_price"`  //硬卧
+	YZPrice          float64  `gorm:"size:1" json:"yz_price"`  //硬座
+	RWPrice          float64  `gorm:"size:1" json:"rw_price"`  //软卧
+	ZEPrice          float64  `gorm:"size:1" json:"ze_price"`  //二等座
+	ZYPrice          float64  `gorm:"size:1" json:"zy_price"`  //一等座
+	SWZPrice         float64  `gorm:"size:1" json:"swz_price"` //商务座
+	TZPrice          float64  `gorm:"size:1" json:"tz_price"`  //特等座
+	GRPrice          float64  `gorm:"size:1" json:"gr_price"`  //高软
+	ArrivalDay       uint     `gorm:"size:1" json:"arrival_day"`
+	IsHighSpeed      uint     `gorm:"size:1" json:"is_high_speed"`        //1为高速列车，0为普速列车
+	SeatClass        string   `gorm:"-" json:"seat_class,omitempty"`      //Price 对应的席别，查询时按席别偏好填写
+	Payable          float64  `gorm:"-" json:"payable"`                   //按旅客类型与优惠规则计算的应付金额
+	FareRules        []string `gorm:"-" json:"fare_rules,omitempty"`      //生效的优惠规则
+	DepartureDelay   int64    `gorm:"-" json:"departure_delay,omitempty"` //按实时动态预计的发车晚点分钟数，时刻已按晚点修正
+	ArrivalDelay     int64    `gorm:"-" json:"arrival_delay,omitempty"`   //按实时动态预计的到站晚点分钟数
 }
 
 type RailWayDAO interface {
-	CreateRailWay(railway *RailWay) error
-	BatchCreateRailWays(railways []RailWay) error
-	GetRailWayByID(id int) (*RailWay, error)
-	GetRailWayByTrainNumber(trainNumber string) ([]RailWay, error)
-	GetRailWayByDepartureStation(name string) ([]RailWay, error)
-	GetRailWayByArrivalStation(name string) ([]RailWay, error)
-	GetRailWayByDepartureStationWithoutArrivalStation(departureName, arrivalName string) ([]RailWay, error)
-	GetRailWayByArrivalStationWithoutDepartureStation(departureName, arrivalName string) ([]RailWay, error)
-	GetRailWayByDepartureStationAndArrivalStation(departureName, arrivalName string) ([]RailWay, error)
-	GetRailWayByDepartureStationAndArrivalStationAndTrainNo(departureName, arrivalName, trainNo string) (*RailWay, error)
-	GetRailWayByDepartureStationAndArrivalStationOnlyHighSpeed(departureName, arrivalName string) ([]RailWay, error)
-	GetRailWayByDepartureStationAndArrivalStationOnlyLowSpeed(departureName, arrivalName string) ([]RailWay, error)
-	GetAllRailWays() ([]RailWay, error)
-	UpdateRailWays(station *RailWay) error
-	DeleteRailWays(id int) error
+	CreateRailWay(ctx context.Context, railway *RailWay) error
+	BatchCreateRailWays(ctx context.Context, railways []RailWay) error
+	GetRailWayByID(ctx context.Context, id int) (*RailWay, error)
+	GetRailWayByTrainNumber(ctx context.Context, trainNumber string) ([]RailWay, error)
+	GetRailWayByTrainNo(ctx context.Context, trainNo string) ([]RailWay, error)
+	GetRailWayByDepartureStation(ctx context.Context, name string) ([]RailWay, error)
+	GetRailWayByArrivalStation(ctx context.Context, name string) ([]RailWay, error)
+	GetRailWayByDepartureStationWithoutArrivalStation(ctx context.Context, departureName, arrivalName string) ([]RailWay, error)
+	GetRailWayByArrivalStationWithoutDepartureStation(ctx context.Context, departureName, arrivalName string) ([]RailWay, error)
+	GetRailWayByDepartureStationAndArrivalStation(ctx context.Context, departureName, arrivalName string) ([]RailWay, error)
+	GetRailWayByDepartureStationAndArrivalStationAndTrainNo(ctx context.Context, departureName, arrivalName, trainNo string) (*RailWay, error)
+	GetRailWayByDepartureStationAndArrivalStationOnlyHighSpeed(ctx context.Context, departureName, arrivalName string) ([]RailWay, error)
+	GetRailWayByDepartureStationAndArrivalStationOnlyLowSpeed(ctx context.Context, departureName, arrivalName string) ([]RailWay, error)
+	GetAllRailWays(ctx context.Context) ([]RailWay, error)
+	CountTrainsByStation(ctx context.Context) (map[string]int64, error)
+	UpdateRailWays(ctx context.Context, station *RailWay) error
+	DeleteRailWays(ctx context.Context, id int) error
 }
 
 type RailWayDAOImpl struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Logger *slog.Logger
 }
 
 func (RailWay) TableName() string {
 	return "railway"
 }
 
-func NewRailWayDAO(db *gorm.DB) RailWayDAO {
+func NewRailWayDAO(db *gorm.DB, logger *slog.Logger) RailWayDAO {
 	return &RailWayDAOImpl{
-		DB: db,
+		DB:     db.Session(&gorm.Session{Logger: logging.NewGormLogger(logger)}),
+		Logger: logger,
 	}
 }
 
 var _ RailWayDAO = (*RailWayDAOImpl)(nil)
 
-func (dao *RailWayDAOImpl) CreateRailWay(railWay *RailWay) error {
-	return dao.DB.Create(railWay).Error
+func (dao *RailWayDAOImpl) CreateRailWay(ctx context.Context, railWay *RailWay) error {
+	defer metrics.ObserveDB("CreateRailWay", time.Now())
+	return dao.DB.WithContext(ctx).Create(railWay).Error
 }
 
-func (dao *RailWayDAOImpl) BatchCreateRailWays(railways []RailWay) error {
+func (dao *RailWayDAOImpl) BatchCreateRailWays(ctx context.Context, railways []RailWay) error {
+	defer metrics.ObserveDB("BatchCreateRailWays", time.Now())
 	if len(railways) == 0 {
 		return nil
 	}
 	batchSize := 100
-	return dao.DB.CreateInBatches(&railways, batchSize).Error
+	return dao.DB.WithContext(ctx).CreateInBatches(&railways, batchSize).Error
 }
 
-func (dao *RailWayDAOImpl) GetRailWayByID(id int) (*RailWay, error) {
+func (dao *RailWayDAOImpl) GetRailWayByID(ctx context.Context, id int) (*RailWay, error) {
+	defer metrics.ObserveDB("GetRailWayByID", time.Now())
 	var railWay RailWay
-	result := dao.DB.Find(&railWay, id)
+	result := dao.DB.WithContext(ctx).Find(&railWay, id)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return &railWay, nil
 }
 
-func (dao *RailWayDAOImpl) GetRailWayByTrainNumber(trainNumber string) ([]RailWay, error) {
+func (dao *RailWayDAOImpl) GetRailWayByTrainNumber(ctx context.Context, trainNumber string) ([]RailWay, error) {
+	defer metrics.ObserveDB("GetRailWayByTrainNumber", time.Now())
 	railWays := make([]RailWay, 0)
-	result := dao.DB.Where("train_number = ?", trainNumber).Find(&railWays)
+	result := dao.DB.WithContext(ctx).Where("train_number = ?", trainNumber).Find(&railWays)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return railWays, nil
 }
 
-func (dao *RailWayDAOImpl) GetRailWayByDepartureStation(name string) ([]RailWay, error) {
+func (dao *RailWayDAOImpl) GetRailWayByTrainNo(ctx context.Context, trainNo string) ([]RailWay, error) {
+	defer metrics.ObserveDB("GetRailWayByTrainNo", time.Now())
 	railWays := make([]RailWay, 0)
-	result := dao.DB.Where("departure_station = ?", name).Find(&railWays)
+	result := dao.DB.WithContext(ctx).Where("train_no = ?", trainNo).Find(&railWays)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return railWays, nil
 }
 
-func (dao *RailWayDAOImpl) GetRailWayByArrivalStation(name string) ([]RailWay, error) {
+// CountTrainsByStation 统计每个车站出发的不同车次数，用于衡量车站的重要程度
+func (dao *RailWayDAOImpl) CountTrainsByStation(ctx context.Context) (map[string]int64, error) {
+	defer metrics.ObserveDB("CountTrainsByStation", time.Now())
+	var rows []struct {
+		DepartureStation string
+		Trains           int64
+	}
+	result := dao.DB.WithContext(ctx).Model(&RailWay{}).
+		Select("departure_station, COUNT(DISTINCT train_no) AS trains").
+		Group("departure_station").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.DepartureStation] = row.Trains
+	}
+	return counts, nil
+}
+
+func (dao *RailWayDAOImpl) GetRailWayByDepartureStation(ctx context.Context, name string) ([]RailWay, error) {
+	defer metrics.ObserveDB("GetRailWayByDepartureStation", time.Now())
+	railWays := make([]RailWay, 0)
+	result := dao.DB.WithContext(ctx).Where("departure_station = ?", name).Find(&railWays)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return railWays, nil
+}
+
+func (dao *RailWayDAOImpl) GetRailWayByArrivalStation(ctx context.Context, name string) ([]RailWay, error) {
+	defer metrics.ObserveDB("GetRailWayByArrivalStation", time.Now())
 	railWays := make([]RailWay, 0)
-	result := dao.DB.Where("arrival_station = ?", name).Find(&railWays)
+	result := dao.DB.WithContext(ctx).Where("arrival_station = ?", name).Find(&railWays)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return railWays, nil
 }
 
-func (dao *RailWayDAOImpl) GetRailWayByDepartureStationWithoutArrivalStation(departureName, arrivalName string) ([]RailWay, error) {
+func (dao *RailWayDAOImpl) GetRailWayByDepartureStationWithoutArrivalStation(ctx context.Context, departureName, arrivalName string) ([]RailWay, error) {
+	defer metrics.ObserveDB("GetRailWayByDepartureStationWithoutArrivalStation", time.Now())
 	railWays := make([]RailWay, 0)
-	result := dao.DB.Where("departure_station = ? and arrival_station != ?", departureName, arrivalName).Find(&railWays)
+	result := dao.DB.WithContext(ctx).Where("departure_station = ? and arrival_station != ?", departureName, arrivalName).Find(&railWays)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return railWays, nil
 }
 
-func (dao *RailWayDAOImpl) GetRailWayByArrivalStationWithoutDepartureStation(departureName, arrivalName string) ([]RailWay, error) {
+func (dao *RailWayDAOImpl) GetRailWayByArrivalStationWithoutDepartureStation(ctx context.Context, departureName, arrivalName string) ([]RailWay, error) {
+	defer metrics.ObserveDB("GetRailWayByArrivalStationWithoutDepartureStation", time.Now())
 	railWays := make([]RailWay, 0)
-	result := dao.DB.Where("departure_station != ? and arrival_station = ?", departureName, arrivalName).Find(&railWays)
+	result := dao.DB.WithContext(ctx).Where("departure_station != ? and arrival_station = ?", departureName, arrivalName).Find(&railWays)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return railWays, nil
 }
 
-func (dao *RailWayDAOImpl) GetRailWayByDepartureStationAndArrivalStation(departureName, arrivalName string) ([]RailWay, error) {
+func (dao *RailWayDAOImpl) GetRailWayByDepartureStationAndArrivalStation(ctx context.Context, departureName, arrivalName string) ([]RailWay, error) {
+	defer metrics.ObserveDB("GetRailWayByDepartureStationAndArrivalStation", time.Now())
 	railWays := make([]RailWay, 0)
-	result := dao.DB.Where("departure_station = ? and arrival_station = ?", departureName, arrivalName).Find(&railWays)
+	result := dao.DB.WithContext(ctx).Where("departure_station = ? and arrival_station = ?", departureName, arrivalName).Find(&railWays)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return railWays, nil
 }
 
-func (dao *RailWayDAOImpl) GetRailWayByDepartureStationAndArrivalStationAndTrainNo(departureName, arrivalName, trainNo string) (*RailWay, error) {
+func (dao *RailWayDAOImpl) GetRailWayByDepartureStationAndArrivalStationAndTrainNo(ctx context.Context, departureName, arrivalName, trainNo string) (*RailWay, error) {
+	defer metrics.ObserveDB("GetRailWayByDepartureStationAndArrivalStationAndTrainNo", time.Now())
 	var railWay RailWay
-	result := dao.DB.Where("departure_station = ? and arrival_station = ? and train_no = ?", departureName, arrivalName, trainNo).Find(&railWay)
+	result := dao.DB.WithContext(ctx).Where("departure_station = ? and arrival_station = ? and train_no = ?", departureName, arrivalName, trainNo).Find(&railWay)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return &railWay, nil
 }
 
-func (dao *RailWayDAOImpl) GetRailWayByDepartureStationAndArrivalStationOnlyHighSpeed(departureName, arrivalName string) ([]RailWay, error) {
+func (dao *RailWayDAOImpl) GetRailWayByDepartureStationAndArrivalStationOnlyHighSpeed(ctx context.Context, departureName, arrivalName string) ([]RailWay, error) {
+	defer metrics.ObserveDB("GetRailWayByDepartureStationAndArrivalStationOnlyHighSpeed", time.Now())
 	railWays := make([]RailWay, 0)
-	result := dao.DB.Where("departure_station = ? and arrival_station = ? and is_high_speed = 1", departureName, arrivalName).Find(&railWays)
+	result := dao.DB.WithContext(ctx).Where("departure_station = ? and arrival_station = ? and is_high_speed = 1", departureName, arrivalName).Find(&railWays)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return railWays, nil
 }
 
-func (dao *RailWayDAOImpl) GetRailWayByDepartureStationAndArrivalStationOnlyLowSpeed(departureName, arrivalName string) ([]RailWay, error) {
+func (dao *RailWayDAOImpl) GetRailWayByDepartureStationAndArrivalStationOnlyLowSpeed(ctx context.Context, departureName, arrivalName string) ([]RailWay, error) {
+	defer metrics.ObserveDB("GetRailWayByDepartureStationAndArrivalStationOnlyLowSpeed", time.Now())
 	railWays := make([]RailWay, 0)
-	result := dao.DB.Where("departure_station = ? and arrival_station = ? and is_high_speed = 0", departureName, arrivalName).Find(&railWays)
+	result := dao.DB.WithContext(ctx).Where("departure_station = ? and arrival_station = ? and is_high_speed = 0", departureName, arrivalName).Find(&railWays)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return railWays, nil
 }
 
-func (dao *RailWayDAOImpl) GetAllRailWays() ([]RailWay, error) {
+func (dao *RailWayDAOImpl) GetAllRailWays(ctx context.Context) ([]RailWay, error) {
+	defer metrics.ObserveDB("GetAllRailWays", time.Now())
 	railWays := make([]RailWay, 0)
-	result := dao.DB.Find(&railWays)
+	result := dao.DB.WithContext(ctx).Find(&railWays)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return railWays, nil
 }
 
-func (dao *RailWayDAOImpl) UpdateRailWays(railWay *RailWay) error {
-	return dao.DB.Save(railWay).Error
+func (dao *RailWayDAOImpl) UpdateRailWays(ctx context.Context, railWay *RailWay) error {
+	defer metrics.ObserveDB("UpdateRailWays", time.Now())
+	return dao.DB.WithContext(ctx).Save(railWay).Error
 }
 
-func (dao *RailWayDAOImpl) DeleteRailWays(id int) error {
-	return dao.DB.Delete(&RailWay{}, id).Error
+func (dao *RailWayDAOImpl) DeleteRailWays(ctx context.Context, id int) error {
+	defer metrics.ObserveDB("DeleteRailWays", time.Now())
+	return dao.DB.WithContext(ctx).Delete(&RailWay{}, id).Error
 }
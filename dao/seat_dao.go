@@ -0,0 +1,127 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log/slog"
+	"railway/logging"
+	"railway/metrics"
+	"time"
+)
+
+// ErrSeatsSoldOut 区间内某一段的余票不足
+var ErrSeatsSoldOut = errors.New("seatsSoldOut")
+
+// SeatInventory 某车次某天某席别在一个区间的余票，区间为相邻两个经停站之间，
+// 乘车从第 i 站到第 j 站需要占用 [i, j) 的每个区间
+type SeatInventory struct {
+	TrainNo     string `gorm:"primaryKey;size:20" json:"train_no"`
+	Date        string `gorm:"primaryKey;size:10" json:"date"` // 始发日期，如 2024-10-01
+	SeatClass   string `gorm:"primaryKey;size:5" json:"seat_class"`
+	Segment     int    `gorm:"primaryKey;autoIncrement:false" json:"segment"` // 区间序号，0 为始发站到第二站
+	FromStation string `gorm:"size:20" json:"from_station"`
+	ToStation   string `gorm:"size:20" json:"to_station"`
+	Capacity    int    `json:"capacity"`
+	Remaining   int    `json:"remaining"`
+}
+
+func (SeatInventory) TableName() string {
+	return "seat_inventory"
+}
+
+// TrainDate 某车次在某个始发日期的一趟车
+type TrainDate struct {
+	TrainNo string
+	Date    string
+}
+
+type SeatDAO interface {
+	CreateSeatInventories(ctx context.Context, inventories []SeatInventory) error
+	GetSeatInventories(ctx context.Context, trainNo, date string) ([]SeatInventory, error)
+	GetSeatInventoriesByTrains(ctx context.Context, trains []TrainDate) ([]SeatInventory, error)
+	DecrementSeats(ctx context.Context, trainNo, date, seatClass string, fromSegment, toSegment, count int) error
+	IncrementSeats(ctx context.Context, trainNo, date, seatClass string, fromSegment, toSegment, count int) error
+}
+
+type SeatDAOImpl struct {
+	DB     *gorm.DB
+	Logger *slog.Logger
+}
+
+// NewSeatDAO 创建新的 SeatDAO 实例
+func NewSeatDAO(db *gorm.DB, logger *slog.Logger) SeatDAO {
+	return &SeatDAOImpl{
+		DB:     db.Session(&gorm.Session{Logger: logging.NewGormLogger(logger)}),
+		Logger: logger,
+	}
+}
+
+var _ SeatDAO = (*SeatDAOImpl)(nil)
+
+// CreateSeatInventories 放票：批量写入区间余票，已存在的区间保持不变
+func (dao *SeatDAOImpl) CreateSeatInventories(ctx context.Context, inventories []SeatInventory) error {
+	defer metrics.ObserveDB("CreateSeatInventories", time.Now())
+	if len(inventories) == 0 {
+		return nil
+	}
+	return dao.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(inventories, 200).Error
+}
+
+// GetSeatInventories 获取车次某天全部席别、全部区间的余票
+func (dao *SeatDAOImpl) GetSeatInventories(ctx context.Context, trainNo, date string) ([]SeatInventory, error) {
+	defer metrics.ObserveDB("GetSeatInventories", time.Now())
+	var inventories []SeatInventory
+	result := dao.DB.WithContext(ctx).Where("train_no = ? AND date = ?", trainNo, date).Order("seat_class, segment").Find(&inventories)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return inventories, nil
+}
+
+// GetSeatInventoriesByTrains 一次查询多趟车全部席别、全部区间的余票，尚未放票的车没有记录
+func (dao *SeatDAOImpl) GetSeatInventoriesByTrains(ctx context.Context, trains []TrainDate) ([]SeatInventory, error) {
+	defer metrics.ObserveDB("GetSeatInventoriesByTrains", time.Now())
+	if len(trains) == 0 {
+		return nil, nil
+	}
+	query := dao.DB.WithContext(ctx).Where("1 = 0")
+	for _, train := range trains {
+		query = query.Or("train_no = ? AND date = ?", train.TrainNo, train.Date)
+	}
+	var inventories []SeatInventory
+	result := query.Order("train_no, date, seat_class, segment").Find(&inventories)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return inventories, nil
+}
+
+// DecrementSeats 在一个事务中扣减区间 [fromSegment, toSegment) 的每一段，
+// 任一段余票不足时整体回滚并返回 ErrSeatsSoldOut
+func (dao *SeatDAOImpl) DecrementSeats(ctx context.Context, trainNo, date, seatClass string, fromSegment, toSegment, count int) error {
+	defer metrics.ObserveDB("DecrementSeats", time.Now())
+	return dao.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&SeatInventory{}).
+			Where("train_no = ? AND date = ? AND seat_class = ? AND segment >= ? AND segment < ? AND remaining >= ?",
+				trainNo, date, seatClass, fromSegment, toSegment, count).
+			Update("remaining", gorm.Expr("remaining - ?", count))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(toSegment-fromSegment) {
+			return ErrSeatsSoldOut
+		}
+		return nil
+	})
+}
+
+// IncrementSeats 退还区间 [fromSegment, toSegment) 的每一段，余票不超过 Capacity
+func (dao *SeatDAOImpl) IncrementSeats(ctx context.Context, trainNo, date, seatClass string, fromSegment, toSegment, count int) error {
+	defer metrics.ObserveDB("IncrementSeats", time.Now())
+	return dao.DB.WithContext(ctx).Model(&SeatInventory{}).
+		Where("train_no = ? AND date = ? AND seat_class = ? AND segment >= ? AND segment < ?",
+			trainNo, date, seatClass, fromSegment, toSegment).
+		Update("remaining", gorm.Expr("CASE WHEN remaining + ? > capacity THEN capacity ELSE remaining + ? END", count, count)).Error
+}
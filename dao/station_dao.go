@@ -1,57 +1,71 @@
 package dao
 
 import (
+	"context"
 	"gorm.io/gorm"
+	"log/slog"
+	"railway/logging"
+	"railway/metrics"
+	"time"
 )
 
 // Station 车站数据模型
 type Station struct {
-	ID                 int    `gorm:"primaryKey;autoIncrement"`
-	StationAbbr        string `gorm:"size:20" json:"station_abbr"`         // 车站简称
-	StationName        string `gorm:"size:100" json:"station_name"`        // 车站名
-	StationCode        string `gorm:"unique;size:20" json:"station_code"`  // 车站代号
-	StationPinyin      string `gorm:"size:100" json:"station_pinyin"`      // 车站拼音
-	StationFirstLetter string `gorm:"size:10" json:"station_first_letter"` // 车站首字母
-	StationNumber      string `gorm:"size:20" json:"station_number"`       // 车站标号
-	CityCode           string `gorm:"size:10" json:"city_code"`            // 城市代码
-	CityName           string `gorm:"size:100" json:"city_name"`           // 车站所属城市
-	IsKeyStation       int    ` json:"is_key_station"`                     //是否是二次转乘选择站点
+	ID                 int     `gorm:"primaryKey;autoIncrement"`
+	StationAbbr        string  `gorm:"size:20" json:"station_abbr"`         // 车站简称
+	StationName        string  `gorm:"size:100" json:"station_name"`        // 车站名
+	StationCode        string  `gorm:"unique;size:20" json:"station_code"`  // 车站代号
+	StationPinyin      string  `gorm:"size:100" json:"station_pinyin"`      // 车站拼音
+	StationFirstLetter string  `gorm:"size:10" json:"station_first_letter"` // 车站首字母
+	StationNumber      string  `gorm:"size:20" json:"station_number"`       // 车站标号
+	CityCode           string  `gorm:"size:10" json:"city_code"`            // 城市代码
+	CityName           string  `gorm:"size:100" json:"city_name"`           // 车站所属城市
+	IsKeyStation       int     ` json:"is_key_station"`                     //是否是二次转乘选择站点
+	Latitude           float64 `json:"latitude"`                            // 纬度，未导入坐标时为 0
+	Longitude          float64 `json:"longitude"`                           // 经度，未导入坐标时为 0
+	Province           string  `gorm:"size:50" json:"province"`             // 所属省份
 }
 
 type StationDAO interface {
-	CreateStation(station *Station) error
-	GetStationByID(id int) (*Station, error)
-	GetStationByName(name string) (*Station, error)
-	GetStationByCityName(cityName string) ([]Station, error)
-	GetStationByPrefixName(cityName string) ([]Station, error)
-	GetCityByPrefixName(cityName string) ([]Station, error)
-	GetAllStations() ([]Station, error)
-	UpdateStation(station *Station) error
-	DeleteStation(id int) error
+	CreateStation(ctx context.Context, station *Station) error
+	GetStationByID(ctx context.Context, id int) (*Station, error)
+	GetStationByName(ctx context.Context, name string) (*Station, error)
+	GetStationByCityName(ctx context.Context, cityName string) ([]Station, error)
+	GetStationByPrefixName(ctx context.Context, cityName string) ([]Station, error)
+	GetCityByPrefixName(ctx context.Context, cityName string) ([]Station, error)
+	GetAllStations(ctx context.Context) ([]Station, error)
+	UpdateStation(ctx context.Context, station *Station) error
+	UpdateStationLocation(ctx context.Context, stationCode string, latitude, longitude float64, province string) (int64, error)
+	DeleteStation(ctx context.Context, id int) error
+	Ping(ctx context.Context) error
 }
 
 type StationDAOImpl struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Logger *slog.Logger
 }
 
 // NewStationDAO 创建新的 StationDAO 实例
-func NewStationDAO(db *gorm.DB) StationDAO {
+func NewStationDAO(db *gorm.DB, logger *slog.Logger) StationDAO {
 	return &StationDAOImpl{
-		DB: db,
+		DB:     db.Session(&gorm.Session{Logger: logging.NewGormLogger(logger)}),
+		Logger: logger,
 	}
 }
 
 var _ StationDAO = (*StationDAOImpl)(nil)
 
 // CreateStation 创建一个新的车站记录
-func (dao *StationDAOImpl) CreateStation(station *Station) error {
-	return dao.DB.Create(station).Error
+func (dao *StationDAOImpl) CreateStation(ctx context.Context, station *Station) error {
+	defer metrics.ObserveDB("CreateStation", time.Now())
+	return dao.DB.WithContext(ctx).Create(station).Error
 }
 
 // GetStationByID 根据 ID 获取车站信息
-func (dao *StationDAOImpl) GetStationByID(id int) (*Station, error) {
+func (dao *StationDAOImpl) GetStationByID(ctx context.Context, id int) (*Station, error) {
+	defer metrics.ObserveDB("GetStationByID", time.Now())
 	var station Station
-	result := dao.DB.First(&station, id)
+	result := dao.DB.WithContext(ctx).First(&station, id)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -59,9 +73,10 @@ func (dao *StationDAOImpl) GetStationByID(id int) (*Station, error) {
 }
 
 // GetAllStations 获取所有车站信息
-func (dao *StationDAOImpl) GetAllStations() ([]Station, error) {
+func (dao *StationDAOImpl) GetAllStations(ctx context.Context) ([]Station, error) {
+	defer metrics.ObserveDB("GetAllStations", time.Now())
 	var stations []Station
-	result := dao.DB.Find(&stations)
+	result := dao.DB.WithContext(ctx).Find(&stations)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -69,8 +84,9 @@ func (dao *StationDAOImpl) GetAllStations() ([]Station, error) {
 }
 
 // UpdateStation 更新车站信息
-func (dao *StationDAOImpl) UpdateStation(station *Station) error {
-	result := dao.DB.Save(station)
+func (dao *StationDAOImpl) UpdateStation(ctx context.Context, station *Station) error {
+	defer metrics.ObserveDB("UpdateStation", time.Now())
+	result := dao.DB.WithContext(ctx).Save(station)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -78,45 +94,70 @@ func (dao *StationDAOImpl) UpdateStation(station *Station) error {
 }
 
 // DeleteStation 删除车站信息
-func (dao *StationDAOImpl) DeleteStation(id int) error {
-	result := dao.DB.Delete(&Station{}, id)
+func (dao *StationDAOImpl) DeleteStation(ctx context.Context, id int) error {
+	defer metrics.ObserveDB("DeleteStation", time.Now())
+	result := dao.DB.WithContext(ctx).Delete(&Station{}, id)
 	if result.Error != nil {
 		return result.Error
 	}
 	return nil
 }
 
-func (dao *StationDAOImpl) GetStationByName(name string) (*Station, error) {
+func (dao *StationDAOImpl) GetStationByName(ctx context.Context, name string) (*Station, error) {
+	defer metrics.ObserveDB("GetStationByName", time.Now())
 	var station Station
-	result := dao.DB.Where("station_name = ?", name).Find(&station)
+	result := dao.DB.WithContext(ctx).Where("station_name = ?", name).Find(&station)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return &station, nil
 }
 
-func (dao *StationDAOImpl) GetStationByCityName(cityName string) ([]Station, error) {
+func (dao *StationDAOImpl) GetStationByCityName(ctx context.Context, cityName string) ([]Station, error) {
+	defer metrics.ObserveDB("GetStationByCityName", time.Now())
 	var stations []Station
-	result := dao.DB.Where("city_name = ?", cityName).Find(&stations)
+	result := dao.DB.WithContext(ctx).Where("city_name = ?", cityName).Find(&stations)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return stations, nil
 }
 
-func (dao *StationDAOImpl) GetStationByPrefixName(station string) ([]Station, error) {
+func (dao *StationDAOImpl) GetStationByPrefixName(ctx context.Context, station string) ([]Station, error) {
+	defer metrics.ObserveDB("GetStationByPrefixName", time.Now())
 	var stations []Station
-	result := dao.DB.Where("station_name Like ?", station+"%").Find(&stations)
+	result := dao.DB.WithContext(ctx).Where("station_name Like ?", station+"%").Find(&stations)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return stations, nil
 }
-func (dao *StationDAOImpl) GetCityByPrefixName(cityName string) ([]Station, error) {
+func (dao *StationDAOImpl) GetCityByPrefixName(ctx context.Context, cityName string) ([]Station, error) {
+	defer metrics.ObserveDB("GetCityByPrefixName", time.Now())
 	var stations []Station
-	result := dao.DB.Where("city_name Like ?", cityName+"%").Find(&stations)
+	result := dao.DB.WithContext(ctx).Where("city_name Like ?", cityName+"%").Find(&stations)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return stations, nil
 }
+
+// Ping 检查数据库连接是否可用
+func (dao *StationDAOImpl) Ping(ctx context.Context) error {
+	sqlDB, err := dao.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// UpdateStationLocation 根据电报码更新车站坐标与省份，返回更新的行数
+func (dao *StationDAOImpl) UpdateStationLocation(ctx context.Context, stationCode string, latitude, longitude float64, province string) (int64, error) {
+	defer metrics.ObserveDB("UpdateStationLocation", time.Now())
+	result := dao.DB.WithContext(ctx).Model(&Station{}).Where("station_code = ?", stationCode).Updates(map[string]interface{}{
+		"latitude":  latitude,
+		"longitude": longitude,
+		"province":  province,
+	})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,151 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"log/slog"
+	"railway/logging"
+	"railway/metrics"
+	"time"
+)
+
+// 候补状态：waiting 排队中，之后转为 fulfilled（已生成订单）、expired（截止前未兑现）或 cancelled
+const (
+	WaitlistWaiting   = "waiting"
+	WaitlistFulfilled = "fulfilled"
+	WaitlistExpired   = "expired"
+	WaitlistCancelled = "cancelled"
+)
+
+// ErrWaitlistStateChanged 状态转换时候补已不处于预期状态
+var ErrWaitlistStateChanged = errors.New("waitlistStateChanged")
+
+// WaitlistEntry 一条候补，同一车次、日期、席别与区间为一个队列，
+// 队列内 Priority 大的在前，相同 Priority 按 CreatedAt 先后
+type WaitlistEntry struct {
+	ID          string              `gorm:"primaryKey;size:32" json:"id"`
+	Status      string              `gorm:"size:16;index" json:"status"`
+	APIKeyID    int                 `gorm:"index" json:"-"`
+	TrainNo     string              `gorm:"size:20;index:idx_waitlist_queue" json:"train_no"`
+	Date        string              `gorm:"size:10;index:idx_waitlist_queue" json:"date"` // 在 From 上车的日期
+	SeatClass   string              `gorm:"size:5;index:idx_waitlist_queue" json:"seat_class"`
+	From        string              `gorm:"column:from_station;size:20;index:idx_waitlist_queue" json:"from"`
+	To          string              `gorm:"column:to_station;size:20;index:idx_waitlist_queue" json:"to"`
+	Priority    int                 `json:"priority"`
+	Deadline    time.Time           `json:"deadline"`                // 截止时间，之后不再兑现
+	OrderID     string              `gorm:"size:32" json:"order_id"` // 兑现后生成的订单
+	FulfilledAt *time.Time          `json:"fulfilled_at,omitempty"`
+	ClosedAt    *time.Time          `json:"closed_at,omitempty"` // 超时或取消的时间
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	Passengers  []WaitlistPassenger `gorm:"foreignKey:EntryID" json:"passengers"`
+}
+
+func (WaitlistEntry) TableName() string {
+	return "waitlist_entries"
+}
+
+type WaitlistPassenger struct {
+	ID       int    `gorm:"primaryKey;autoIncrement" json:"-"`
+	EntryID  string `gorm:"size:32;index" json:"-"`
+	Name     string `gorm:"size:50" json:"name"`
+	IDNumber string `gorm:"size:32" json:"id_number"`
+	Type     string `gorm:"size:16" json:"type"`
+}
+
+func (WaitlistPassenger) TableName() string {
+	return "waitlist_passengers"
+}
+
+type WaitlistDAO interface {
+	CreateWaitlistEntry(ctx context.Context, entry *WaitlistEntry) error
+	GetWaitlistEntry(ctx context.Context, id string) (*WaitlistEntry, error)
+	GetWaitingEntries(ctx context.Context, limit int) ([]WaitlistEntry, error)
+	CountWaitlistAhead(ctx context.Context, entry *WaitlistEntry) (int64, error)
+	UpdateWaitlistStatus(ctx context.Context, id, from, to string, updates map[string]any) error
+}
+
+type WaitlistDAOImpl struct {
+	DB     *gorm.DB
+	Logger *slog.Logger
+}
+
+// NewWaitlistDAO 创建新的 WaitlistDAO 实例
+func NewWaitlistDAO(db *gorm.DB, logger *slog.Logger) WaitlistDAO {
+	return &WaitlistDAOImpl{
+		DB:     db.Session(&gorm.Session{Logger: logging.NewGormLogger(logger)}),
+		Logger: logger,
+	}
+}
+
+var _ WaitlistDAO = (*WaitlistDAOImpl)(nil)
+
+// CreateWaitlistEntry 写入候补及其乘车人
+func (dao *WaitlistDAOImpl) CreateWaitlistEntry(ctx context.Context, entry *WaitlistEntry) error {
+	defer metrics.ObserveDB("CreateWaitlistEntry", time.Now())
+	return dao.DB.WithContext(ctx).Create(entry).Error
+}
+
+// GetWaitlistEntry 根据候补单号查询，不存在时返回 nil
+func (dao *WaitlistDAOImpl) GetWaitlistEntry(ctx context.Context, id string) (*WaitlistEntry, error) {
+	defer metrics.ObserveDB("GetWaitlistEntry", time.Now())
+	var entries []WaitlistEntry
+	result := dao.DB.WithContext(ctx).
+		Preload("Passengers", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("id = ?", id).Limit(1).Find(&entries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return &entries[0], nil
+}
+
+// GetWaitingEntries 获取排队中的候补，按 Priority 从大到小、同一 Priority 按先后排列
+func (dao *WaitlistDAOImpl) GetWaitingEntries(ctx context.Context, limit int) ([]WaitlistEntry, error) {
+	defer metrics.ObserveDB("GetWaitingEntries", time.Now())
+	var entries []WaitlistEntry
+	result := dao.DB.WithContext(ctx).
+		Preload("Passengers", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("status = ?", WaitlistWaiting).
+		Order("priority DESC, created_at, id").Limit(limit).Find(&entries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return entries, nil
+}
+
+// CountWaitlistAhead 同一队列中排在 entry 之前的候补数量
+func (dao *WaitlistDAOImpl) CountWaitlistAhead(ctx context.Context, entry *WaitlistEntry) (int64, error) {
+	defer metrics.ObserveDB("CountWaitlistAhead", time.Now())
+	var count int64
+	result := dao.DB.WithContext(ctx).Model(&WaitlistEntry{}).
+		Where("status = ? AND train_no = ? AND date = ? AND seat_class = ? AND from_station = ? AND to_station = ?",
+			WaitlistWaiting, entry.TrainNo, entry.Date, entry.SeatClass, entry.From, entry.To).
+		Where("priority > ? OR (priority = ? AND (created_at < ? OR (created_at = ? AND id < ?)))",
+			entry.Priority, entry.Priority, entry.CreatedAt, entry.CreatedAt, entry.ID).
+		Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return count, nil
+}
+
+// UpdateWaitlistStatus 只有候补仍处于 from 状态时才转为 to 并写入 updates，否则返回 ErrWaitlistStateChanged
+func (dao *WaitlistDAOImpl) UpdateWaitlistStatus(ctx context.Context, id, from, to string, updates map[string]any) error {
+	defer metrics.ObserveDB("UpdateWaitlistStatus", time.Now())
+	values := map[string]any{"status": to}
+	for column, value := range updates {
+		values[column] = value
+	}
+	result := dao.DB.WithContext(ctx).Model(&WaitlistEntry{}).Where("id = ? AND status = ?", id, from).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWaitlistStateChanged
+	}
+	return nil
+}
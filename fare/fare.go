@@ -0,0 +1,254 @@
+package fare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// PassengerType 旅客类型
+type PassengerType string
+
+const (
+	Adult    PassengerType = "adult"
+	Child    PassengerType = "child"
+	Student  PassengerType = "student"
+	Senior   PassengerType = "senior"
+	Disabled PassengerType = "disabled" // 残疾军人、伤残人民警察等
+)
+
+// PassengerTypes 全部旅客类型
+var PassengerTypes = []PassengerType{Adult, Child, Student, Senior, Disabled}
+
+// ParsePassengerType 为空时视为成人
+func ParsePassengerType(input string) (PassengerType, error) {
+	input = strings.ToLower(strings.TrimSpace(input))
+	if input == "" {
+		return Adult, nil
+	}
+	for _, passenger := range PassengerTypes {
+		if input == string(passenger) {
+			return passenger, nil
+		}
+	}
+	return "", errors.New("unknown passenger type: " + input)
+}
+
+// 舍入方式
+const (
+	RoundNearest = "nearest"
+	RoundUp      = "up"
+	RoundDown    = "down"
+)
+
+// Rounding 每张票折后金额按 Unit 元舍入，Unit 为 0 时保留到分
+type Rounding struct {
+	Mode string  `json:"mode"`
+	Unit float64 `json:"unit"`
+}
+
+// Rule 一条优惠规则，列表类条件为空表示不限；Amount = 票价 × Rate - Off，最低为 0
+type Rule struct {
+	Name          string          `json:"name"`
+	Passengers    []PassengerType `json:"passengers"`
+	SeatClasses   []string        `json:"seat_classes"`  // 席别代码，如 yz、ze
+	Trains        []string        `json:"trains"`        // 车次号前缀，如 G、D
+	From          []string        `json:"from"`          // 乘车站
+	To            []string        `json:"to"`            // 下车站
+	Bidirectional bool            `json:"bidirectional"` // 为 true 时 To → From 方向同样适用
+	Rate          float64         `json:"rate"`
+	Off           float64         `json:"off"`
+	Stackable     bool            `json:"stackable"` // 为 true 时继续匹配后面的规则，在折后金额上叠加
+}
+
+// Rules 规则文件的内容，规则按文件中的顺序匹配，第一条不可叠加的规则生效后停止
+type Rules struct {
+	Rounding Rounding `json:"rounding"`
+	Rules    []Rule   `json:"rules"`
+}
+
+// Leg 计价的一段，BaseFare 为所选席别的公布票价
+type Leg struct {
+	TrainNumber string
+	From        string
+	To          string
+	SeatClass   string
+	BaseFare    float64
+}
+
+// LegFare 一段的应付金额，Rules 为生效的规则名
+type LegFare struct {
+	BaseFare float64
+	Amount   float64
+	Rules    []string
+}
+
+// Quote 一个行程的应付金额
+type Quote struct {
+	Passenger PassengerType
+	Legs      []LegFare
+	Total     float64
+}
+
+// Engine 按规则计算应付金额，创建后只读，可并发使用
+type Engine struct {
+	rules Rules
+}
+
+// DefaultRules 没有规则文件时使用：儿童、残疾军人半价，学生硬座、二等座、硬卧 75%，
+// 尾数不足 0.5 元的舍去
+func DefaultRules() Rules {
+	return Rules{
+		Rounding: Rounding{Mode: RoundDown, Unit: 0.5},
+		Rules: []Rule{
+			{Name: "child", Passengers: []PassengerType{Child}, Rate: 0.5},
+			{Name: "student", Passengers: []PassengerType{Student}, SeatClasses: []string{"yz", "ze", "yw"}, Rate: 0.75},
+			{Name: "disabled", Passengers: []PassengerType{Disabled}, Rate: 0.5},
+		},
+	}
+}
+
+// Default 使用 DefaultRules 的计价引擎
+func Default() *Engine {
+	engine, _ := NewEngine(DefaultRules())
+	return engine
+}
+
+// NewEngine 校验规则后创建计价引擎
+func NewEngine(rules Rules) (*Engine, error) {
+	switch rules.Rounding.Mode {
+	case "":
+		rules.Rounding.Mode = RoundNearest
+	case RoundNearest, RoundUp, RoundDown:
+	default:
+		return nil, fmt.Errorf("rounding.mode: unknown mode %q", rules.Rounding.Mode)
+	}
+	if rules.Rounding.Unit < 0 {
+		return nil, errors.New("rounding.unit: must not be negative")
+	}
+	for i, rule := range rules.Rules {
+		if rule.Rate < 0 || rule.Off < 0 {
+			return nil, fmt.Errorf("rules[%d] %s: rate and off must not be negative", i, rule.Name)
+		}
+		if rule.Rate == 0 && rule.Off == 0 {
+			return nil, fmt.Errorf("rules[%d] %s: one of rate and off is required", i, rule.Name)
+		}
+		for _, passenger := range rule.Passengers {
+			if _, err := ParsePassengerType(string(passenger)); err != nil || passenger == "" {
+				return nil, fmt.Errorf("rules[%d] %s: unknown passenger type %q", i, rule.Name, passenger)
+			}
+		}
+	}
+	return &Engine{rules: rules}, nil
+}
+
+// Load 读取 JSON 格式的规则文件，path 为空时使用 DefaultRules
+func Load(path string) (*Engine, error) {
+	if path == "" {
+		return Default(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	engine, err := NewEngine(rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return engine, nil
+}
+
+// Price 计算 passenger 乘坐各段的应付金额，每段单独舍入后相加
+func (e *Engine) Price(passenger PassengerType, legs []Leg) Quote {
+	quote := Quote{Passenger: passenger, Legs: make([]LegFare, 0, len(legs))}
+	for _, leg := range legs {
+		legFare := e.priceLeg(passenger, leg)
+		quote.Legs = append(quote.Legs, legFare)
+		quote.Total = quote.Total + legFare.Amount
+	}
+	quote.Total = math.Round(quote.Total*100) / 100
+	return quote
+}
+
+func (e *Engine) priceLeg(passenger PassengerType, leg Leg) LegFare {
+	legFare := LegFare{BaseFare: leg.BaseFare, Amount: leg.BaseFare}
+	for _, rule := range e.rules.Rules {
+		if !rule.matches(passenger, leg) {
+			continue
+		}
+		rate := rule.Rate
+		if rate == 0 {
+			rate = 1
+		}
+		legFare.Amount = math.Max(legFare.Amount*rate-rule.Off, 0)
+		legFare.Rules = append(legFare.Rules, rule.Name)
+		if !rule.Stackable {
+			break
+		}
+	}
+	legFare.Amount = e.rules.Rounding.apply(legFare.Amount)
+	return legFare
+}
+
+func (rule Rule) matches(passenger PassengerType, leg Leg) bool {
+	if len(rule.Passengers) > 0 && !contains(rule.Passengers, passenger) {
+		return false
+	}
+	if len(rule.SeatClasses) > 0 && !contains(rule.SeatClasses, leg.SeatClass) {
+		return false
+	}
+	if len(rule.Trains) > 0 && !hasPrefix(leg.TrainNumber, rule.Trains) {
+		return false
+	}
+	if rule.routeMatches(leg.From, leg.To) {
+		return true
+	}
+	return rule.Bidirectional && rule.routeMatches(leg.To, leg.From)
+}
+
+func (rule Rule) routeMatches(from, to string) bool {
+	return (len(rule.From) == 0 || contains(rule.From, from)) && (len(rule.To) == 0 || contains(rule.To, to))
+}
+
+func (r Rounding) apply(amount float64) float64 {
+	unit := r.Unit
+	if unit == 0 {
+		unit = 0.01
+	}
+	// 先按分取整，避免 0.1 + 0.2 这类浮点误差影响向上、向下舍入
+	steps := math.Round(amount*100) / 100 / unit
+	switch r.Mode {
+	case RoundUp:
+		steps = math.Ceil(steps - 1e-9)
+	case RoundDown:
+		steps = math.Floor(steps + 1e-9)
+	default:
+		steps = math.Round(steps)
+	}
+	return math.Round(steps*unit*100) / 100
+}
+
+func contains[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package fare
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRounding(t *testing.T) {
+	tests := []struct {
+		name     string
+		rounding Rounding
+		amount   float64
+		want     float64
+	}{
+		{"nearest half down", Rounding{Mode: RoundNearest, Unit: 0.5}, 10.24, 10},
+		{"nearest half up", Rounding{Mode: RoundNearest, Unit: 0.5}, 10.25, 10.5},
+		{"nearest cents", Rounding{Mode: RoundNearest}, 12.344, 12.34},
+		{"up", Rounding{Mode: RoundUp, Unit: 1}, 10.01, 11},
+		{"up exact", Rounding{Mode: RoundUp, Unit: 1}, 10, 10},
+		{"up float error", Rounding{Mode: RoundUp, Unit: 0.1}, 0.1 + 0.2, 0.3},
+		{"down", Rounding{Mode: RoundDown, Unit: 0.5}, 10.49, 10},
+		{"down exact", Rounding{Mode: RoundDown, Unit: 0.5}, 10.5, 10.5},
+		{"down float error", Rounding{Mode: RoundDown, Unit: 0.1}, 0.7 * 3, 2.1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.rounding.apply(test.amount); got != test.want {
+				t.Fatalf("apply(%v) = %v, want %v", test.amount, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPriceRules(t *testing.T) {
+	leg := Leg{TrainNumber: "G1", From: "北京南", To: "上海虹桥", SeatClass: "ze", BaseFare: 100}
+	reversed := Leg{TrainNumber: "G2", From: "上海虹桥", To: "北京南", SeatClass: "ze", BaseFare: 100}
+	tests := []struct {
+		name       string
+		rules      []Rule
+		passenger  PassengerType
+		leg        Leg
+		wantAmount float64
+		wantRules  []string
+	}{
+		{
+			name: "stacked",
+			rules: []Rule{
+				{Name: "g", Trains: []string{"G"}, Rate: 0.9, Stackable: true},
+				{Name: "off", Off: 5},
+			},
+			passenger: Adult, leg: leg, wantAmount: 85, wantRules: []string{"g", "off"},
+		},
+		{
+			name: "exclusive",
+			rules: []Rule{
+				{Name: "half", Rate: 0.5},
+				{Name: "off", Off: 5},
+			},
+			passenger: Adult, leg: leg, wantAmount: 50, wantRules: []string{"half"},
+		},
+		{
+			name: "stackable without a second match",
+			rules: []Rule{
+				{Name: "g", Trains: []string{"G"}, Rate: 0.9, Stackable: true},
+				{Name: "d", Trains: []string{"D"}, Off: 5},
+			},
+			passenger: Adult, leg: leg, wantAmount: 90, wantRules: []string{"g"},
+		},
+		{
+			name:      "first matching rule wins",
+			rules:     []Rule{{Name: "student", Passengers: []PassengerType{Student}, Rate: 0.75}, {Name: "all", Rate: 0.9}},
+			passenger: Student, leg: leg, wantAmount: 75, wantRules: []string{"student"},
+		},
+		{
+			name:      "passenger does not match",
+			rules:     []Rule{{Name: "child", Passengers: []PassengerType{Child}, Rate: 0.5}},
+			passenger: Adult, leg: leg, wantAmount: 100,
+		},
+		{
+			name:      "off not below zero",
+			rules:     []Rule{{Name: "free", Off: 200}},
+			passenger: Adult, leg: leg, wantAmount: 0, wantRules: []string{"free"},
+		},
+		{
+			name:      "route",
+			rules:     []Rule{{Name: "route", From: []string{"北京南"}, To: []string{"上海虹桥"}, Rate: 0.8}},
+			passenger: Adult, leg: leg, wantAmount: 80, wantRules: []string{"route"},
+		},
+		{
+			name:      "one way route does not match the return",
+			rules:     []Rule{{Name: "route", From: []string{"北京南"}, To: []string{"上海虹桥"}, Rate: 0.8}},
+			passenger: Adult, leg: reversed, wantAmount: 100,
+		},
+		{
+			name:      "bidirectional route matches the return",
+			rules:     []Rule{{Name: "route", From: []string{"北京南"}, To: []string{"上海虹桥"}, Bidirectional: true, Rate: 0.8}},
+			passenger: Adult, leg: reversed, wantAmount: 80, wantRules: []string{"route"},
+		},
+		{
+			name:      "seat class",
+			rules:     []Rule{{Name: "sleeper", SeatClasses: []string{"yw"}, Rate: 0.8}},
+			passenger: Adult, leg: leg, wantAmount: 100,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(Rules{Rules: test.rules})
+			if err != nil {
+				t.Fatal(err)
+			}
+			quote := engine.Price(test.passenger, []Leg{test.leg})
+			got := quote.Legs[0]
+			if got.Amount != test.wantAmount || !reflect.DeepEqual(got.Rules, test.wantRules) || quote.Total != test.wantAmount {
+				t.Fatalf("fare = %+v total %v, want %v with rules %v", got, quote.Total, test.wantAmount, test.wantRules)
+			}
+		})
+	}
+}
+
+// TestLoadRulesFile 仓库中的 fare_rules.json 与 DefaultRules 一致
+func TestLoadRulesFile(t *testing.T) {
+	engine, err := Load(filepath.Join("..", "fare_rules.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legs := []Leg{
+		{TrainNumber: "G1", SeatClass: "ze", BaseFare: 100},
+		{TrainNumber: "K1", SeatClass: "yz", BaseFare: 33.3},
+		{TrainNumber: "G1", SeatClass: "zy", BaseFare: 160},
+	}
+	tests := []struct {
+		passenger PassengerType
+		amounts   []float64
+		total     float64
+	}{
+		{Adult, []float64{100, 33, 160}, 293}, // 没有优惠也按 0.5 元向下舍入
+		{Child, []float64{50, 16.5, 80}, 146.5},
+		{Student, []float64{75, 24.5, 160}, 259.5},
+		{Disabled, []float64{50, 16.5, 80}, 146.5},
+	}
+	for _, test := range tests {
+		t.Run(string(test.passenger), func(t *testing.T) {
+			quote := engine.Price(test.passenger, legs)
+			want := Default().Price(test.passenger, legs)
+			if !reflect.DeepEqual(quote, want) {
+				t.Fatalf("file quote = %+v, default quote = %+v", quote, want)
+			}
+			for index, legFare := range quote.Legs {
+				if legFare.Amount != test.amounts[index] {
+					t.Fatalf("leg %d amount = %v, want %v", index, legFare.Amount, test.amounts[index])
+				}
+			}
+			if quote.Total != test.total {
+				t.Fatalf("total = %v, want %v", quote.Total, test.total)
+			}
+		})
+	}
+}
+
+func TestLoadInvalidRules(t *testing.T) {
+	tests := map[string]string{
+		"unknown mode":      `{"rounding": {"mode": "bankers"}, "rules": []}`,
+		"negative unit":     `{"rounding": {"unit": -1}, "rules": []}`,
+		"negative rate":     `{"rules": [{"name": "bad", "rate": -0.5}]}`,
+		"no discount":       `{"rules": [{"name": "bad"}]}`,
+		"unknown passenger": `{"rules": [{"name": "bad", "passengers": ["pet"], "rate": 0.5}]}`,
+		"not json":          `rules`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Fatal("Load succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package geo
+
+import (
+	"math"
+	"sort"
+)
+
+// EarthRadiusKm 地球平均半径
+const EarthRadiusKm = 6371.0
+
+// Point 经纬度坐标，单位为度
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Valid 纬度在 [-90, 90]、经度在 [-180, 180] 且不是零值时视为有效坐标
+func (p Point) Valid() bool {
+	if p.Lat == 0 && p.Lon == 0 {
+		return false
+	}
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+// Distance 两点间的大圆距离（千米）
+func Distance(a, b Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Item 放入索引的点，ID 由调用方定义
+type Item struct {
+	Point
+	ID int
+}
+
+// Result Within 的查询结果
+type Result struct {
+	Item
+	DistanceKm float64
+}
+
+// KDTree 将经纬度转换为单位球面上的三维坐标后建立 k-d 树，
+// 球面上的弦长与大圆距离单调对应，因此半径查询不受经度收敛与日期变更线的影响
+type KDTree struct {
+	nodes []kdNode
+	root  int
+}
+
+type kdNode struct {
+	item        Item
+	xyz         [3]float64
+	axis        int
+	left, right int
+}
+
+func toXYZ(p Point) [3]float64 {
+	lat, lon := p.Lat*math.Pi/180, p.Lon*math.Pi/180
+	return [3]float64{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+}
+
+// NewKDTree 构建索引，items 中的无效坐标会被跳过
+func NewKDTree(items []Item) *KDTree {
+	tree := &KDTree{nodes: make([]kdNode, 0, len(items)), root: -1}
+	for _, item := range items {
+		if item.Valid() {
+			tree.nodes = append(tree.nodes, kdNode{item: item, xyz: toXYZ(item.Point), left: -1, right: -1})
+		}
+	}
+	order := make([]int, len(tree.nodes))
+	for i := range order {
+		order[i] = i
+	}
+	tree.root = tree.build(order, 0)
+	return tree
+}
+
+func (t *KDTree) build(order []int, depth int) int {
+	if len(order) == 0 {
+		return -1
+	}
+	axis := depth % 3
+	sort.Slice(order, func(i, j int) bool {
+		return t.nodes[order[i]].xyz[axis] < t.nodes[order[j]].xyz[axis]
+	})
+	middle := len(order) / 2
+	node := order[middle]
+	t.nodes[node].axis = axis
+	// 子切片会被递归排序，先复制一份避免影响父节点的划分
+	left := append([]int(nil), order[:middle]...)
+	right := append([]int(nil), order[middle+1:]...)
+	t.nodes[node].left = t.build(left, depth+1)
+	t.nodes[node].right = t.build(right, depth+1)
+	return node
+}
+
+// Len 索引中的有效点数
+func (t *KDTree) Len() int {
+	return len(t.nodes)
+}
+
+// Within 返回距 center 不超过 radiusKm 的点，按距离由近到远排序
+func (t *KDTree) Within(center Point, radiusKm float64) []Result {
+	results := make([]Result, 0)
+	if t.root < 0 || radiusKm <= 0 {
+		return results
+	}
+	target := toXYZ(center)
+	chord := 2 * math.Sin(math.Min(radiusKm/EarthRadiusKm, math.Pi)/2)
+	t.search(t.root, target, chord*chord, chord, &results)
+	for i := range results {
+		results[i].DistanceKm = Distance(center, results[i].Point)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].DistanceKm < results[j].DistanceKm
+	})
+	return results
+}
+
+func (t *KDTree) search(index int, target [3]float64, chordSquared, chord float64, results *[]Result) {
+	if index < 0 {
+		return
+	}
+	node := t.nodes[index]
+	distanceSquared := 0.0
+	for axis := 0; axis < 3; axis++ {
+		delta := node.xyz[axis] - target[axis]
+		distanceSquared = distanceSquared + delta*delta
+	}
+	if distanceSquared <= chordSquared {
+		*results = append(*results, Result{Item: node.item})
+	}
+	delta := target[node.axis] - node.xyz[node.axis]
+	near, far := node.left, node.right
+	if delta > 0 {
+		near, far = node.right, node.left
+	}
+	t.search(near, target, chordSquared, chord, results)
+	if math.Abs(delta) <= chord {
+		t.search(far, target, chordSquared, chord, results)
+	}
+}
@@ -0,0 +1,75 @@
+package geo
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestWithinMatchesBruteForce 随机点上的半径查询与逐点计算大圆距离的结果一致，包括两极附近与日期变更线两侧
+func TestWithinMatchesBruteForce(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	items := make([]Item, 0, 2000)
+	for i := 0; i < cap(items); i++ {
+		items = append(items, Item{Point: Point{Lat: random.Float64()*180 - 90, Lon: random.Float64()*360 - 180}, ID: i})
+	}
+	tree := NewKDTree(items)
+	if tree.Len() != len(items) {
+		t.Fatalf("Len = %d, want %d", tree.Len(), len(items))
+	}
+	centers := []Point{{Lat: 39.9, Lon: 116.4}, {Lat: 89.5, Lon: 10}, {Lat: -10, Lon: 179.9}, {Lat: 0.1, Lon: -179.9}}
+	for i := 0; i < 50; i++ {
+		centers = append(centers, Point{Lat: random.Float64()*180 - 90, Lon: random.Float64()*360 - 180})
+	}
+	for _, center := range centers {
+		for _, radius := range []float64{50, 500, 2000, 8000, 25000} {
+			want := make(map[int]float64)
+			for _, item := range items {
+				if distance := Distance(center, item.Point); distance <= radius {
+					want[item.ID] = distance
+				}
+			}
+			results := tree.Within(center, radius)
+			for i, result := range results {
+				distance, ok := want[result.ID]
+				if !ok {
+					// 恰好落在边界上的点允许浮点误差
+					if math.Abs(Distance(center, result.Point)-radius) > 1e-6 {
+						t.Fatalf("Within(%v, %v) returned %d at %.3f km", center, radius, result.ID, result.DistanceKm)
+					}
+					continue
+				}
+				if math.Abs(result.DistanceKm-distance) > 1e-9 {
+					t.Fatalf("Within(%v, %v) distance of %d = %v, want %v", center, radius, result.ID, result.DistanceKm, distance)
+				}
+				if i > 0 && results[i-1].DistanceKm > result.DistanceKm {
+					t.Fatalf("Within(%v, %v) results are not sorted by distance", center, radius)
+				}
+				delete(want, result.ID)
+			}
+			for id, distance := range want {
+				if math.Abs(distance-radius) > 1e-6 {
+					t.Fatalf("Within(%v, %v) missed %d at %.3f km", center, radius, id, distance)
+				}
+			}
+		}
+	}
+}
+
+func TestWithinSkipsInvalidPoints(t *testing.T) {
+	tree := NewKDTree([]Item{
+		{Point: Point{Lat: 0, Lon: 0}, ID: 1},
+		{Point: Point{Lat: 91, Lon: 0}, ID: 2},
+		{Point: Point{Lat: 39.9, Lon: 116.4}, ID: 3},
+	})
+	if tree.Len() != 1 {
+		t.Fatalf("Len = %d, want 1", tree.Len())
+	}
+	if results := tree.Within(Point{Lat: 39.9, Lon: 116.4}, 0); len(results) != 0 {
+		t.Fatalf("Within radius 0 = %v, want none", results)
+	}
+	results := tree.Within(Point{Lat: 40, Lon: 116.4}, 20)
+	if len(results) != 1 || results[0].ID != 3 {
+		t.Fatalf("Within = %v, want item 3", results)
+	}
+}
@@ -0,0 +1,43 @@
+package geo
+
+// FeatureCollection GeoJSON（RFC 7946）要素集合，坐标顺序为 [经度, 纬度]
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Type       string         `json:"type"`
+	Geometry   *Geometry      `json:"geometry"` // 没有坐标时为 null
+	Properties map[string]any `json:"properties"`
+}
+
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+func NewFeatureCollection() *FeatureCollection {
+	return &FeatureCollection{Type: "FeatureCollection", Features: make([]Feature, 0)}
+}
+
+// Add 追加一个要素
+func (fc *FeatureCollection) Add(geometry *Geometry, properties map[string]any) {
+	fc.Features = append(fc.Features, Feature{Type: "Feature", Geometry: geometry, Properties: properties})
+}
+
+func NewPoint(p Point) *Geometry {
+	return &Geometry{Type: "Point", Coordinates: []float64{p.Lon, p.Lat}}
+}
+
+// NewLineString 少于两个点时返回 nil
+func NewLineString(points []Point) *Geometry {
+	if len(points) < 2 {
+		return nil
+	}
+	coordinates := make([][]float64, 0, len(points))
+	for _, p := range points {
+		coordinates = append(coordinates, []float64{p.Lon, p.Lat})
+	}
+	return &Geometry{Type: "LineString", Coordinates: coordinates}
+}
@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"log/slog"
+	"time"
+)
+
+// SlowQueryThreshold 超过该耗时的 SQL 以 warn 级别记录
+const SlowQueryThreshold = 200 * time.Millisecond
+
+// GormLogger 将 gorm 的日志写入 slog，并带上 ctx 中的请求 ID
+type GormLogger struct {
+	Logger *slog.Logger
+}
+
+var _ gormlogger.Interface = (*GormLogger)(nil)
+
+func NewGormLogger(logger *slog.Logger) *GormLogger {
+	return &GormLogger{Logger: logger}
+}
+
+func (l *GormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx, l.Logger).InfoContext(ctx, msg, "args", args)
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx, l.Logger).WarnContext(ctx, msg, "args", args)
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx, l.Logger).ErrorContext(ctx, msg, "args", args)
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	elapsed := time.Since(begin)
+	logger := FromContext(ctx, l.Logger)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		logger.ErrorContext(ctx, "sql error", "sql", sql, "rows", rows, "elapsed", elapsed, "err", err)
+	case elapsed > SlowQueryThreshold:
+		sql, rows := fc()
+		logger.WarnContext(ctx, "slow sql", "sql", sql, "rows", rows, "elapsed", elapsed)
+	case logger.Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		logger.DebugContext(ctx, "sql", "sql", sql, "rows", rows, "elapsed", elapsed)
+	}
+}
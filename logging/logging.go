@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type contextKey struct{}
+
+// New 创建 logger，level 为 debug / info / warn / error，format 为 json 或 text
+func New(level, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: ParseLevel(level)}
+	if strings.EqualFold(format, "text") {
+		return slog.New(slog.NewTextHandler(os.Stdout, options))
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, options))
+}
+
+// FromEnv 按环境变量 LOG_LEVEL 与 LOG_FORMAT 创建 logger
+func FromEnv() *slog.Logger {
+	return New(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+}
+
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WithRequestID 将请求 ID 放入 ctx，之后的 FromContext 会带上该 ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(contextKey{}).(string)
+	return requestID
+}
+
+// FromContext 返回带有请求 ID 的 logger，logger 为 nil 时使用 slog.Default()
+func FromContext(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if requestID := RequestID(ctx); requestID != "" {
+		return logger.With("request_id", requestID)
+	}
+	return logger
+}
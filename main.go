@@ -1,98 +1,183 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"google.golang.org/grpc"
+	"log/slog"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"railway/config"
+	"railway/fare"
+	"railway/logging"
 	"railway/mssql"
+	"railway/rpc"
 	"railway/service"
 	"railway/web"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func init() {
+	slog.SetDefault(logging.FromEnv())
+}
+
+// initDB 按配置连接数据库并初始化各 DAO 与服务
+func initDB(cfg config.DatabaseConfig) error {
+	logger := slog.Default()
+	if err := mssql.Configure(cfg.DSN); err != nil {
+		return err
+	}
 	//mssql.DropDB()
-	mssql.InitStation()
-	mssql.InitRailWay()
-	err := service.DownLoadKeyStation()
-	if err != nil {
-		fmt.Println(err)
+	for _, initDAO := range []func(*slog.Logger) error{
+		mssql.InitStation,
+		mssql.InitRailWay,
+		mssql.InitAPIKey,
+		mssql.InitCity,
+		mssql.InitSeat,
+		mssql.InitOrder,
+		mssql.InitWaitlist,
+	} {
+		if err := initDAO(logger); err != nil {
+			return err
+		}
+	}
+	if err := service.DownLoadKeyStation(); err != nil {
+		return fmt.Errorf("load key stations: %w", err)
 	}
 	//err = service.DownLoadStation()
-	//if err != nil {
-	//	fmt.Println(err)
-	//}
 	//err = service.DownLoadRailWay()
-	if err != nil {
-		fmt.Println(err)
+	if err := service.DownLoadCity(); err != nil {
+		return fmt.Errorf("load cities: %w", err)
 	}
-	service.R = service.NewRailwayService(service.RailWayDAO, service.StationService)
-	web.H = web.NewHandler(service.R)
+	service.R = service.NewRailwayService(service.RailWayDAO, service.StationService, service.CityDAO, service.SeatDAO, logger)
+	service.K = service.NewAPIKeyService(service.APIKeyDAO, logger)
+	return nil
 }
 
 // 车站模型
 
-func main() {
-	//resultMap, err := service.R.SearchWithOneTrans("北京南", "杭州东", service.Default, service.LowRunningTimeFirst, service.DefaultStopTime, 0)
-	//if err != nil {
-	//	fmt.Println(err)
-	//}
-	//for key, value := range resultMap {
-	//	fmt.Println(key, value)
-	//}
+var (
+	createAPIKey      = flag.String("create-api-key", "", "为指定的使用方创建 API key，输出明文后退出")
+	adminAPIKey       = flag.Bool("admin", false, "与 -create-api-key 一起使用，创建可访问 /admin 接口的 key")
+	importCoordinates = flag.String("import-coordinates", "", "导入车站坐标 CSV 后退出；运行中的服务需调用 POST /admin/reload 才能看到新坐标")
+	mockRealtime      = flag.String("mock-realtime", "", "为今天从指定车站发车的车次随机生成实时动态，写入投递目录（未配置时输出到标准输出）后退出")
+)
 
-	err := service.R.InitBuildGraph()
+func main() {
+	flag.Parse()
+	cfg, err := config.FromEnv()
 	if err != nil {
-		fmt.Println(err)
-	} else {
-		sum := 0
-		st := 0
-		for _, value := range service.Graph {
-			sum = sum + len(value)
-			st = st + 1
-		}
-		fmt.Println(sum)
-		fmt.Println(st)
+		slog.Error("load config failed", "err", err)
+		os.Exit(1)
+	}
+	if err := initDB(cfg.Database); err != nil {
+		slog.Error("init database failed", "err", err)
+		os.Exit(1)
 	}
-	result, err := service.R.SearchDirectly("杭州南", "上海松江", "all", service.LowPriceFirst)
+	service.FareEngine, err = fare.Load(cfg.Fare.RulesFile)
 	if err != nil {
-		fmt.Println(err)
+		slog.Error("load fare rules failed", "err", err)
+		os.Exit(1)
 	}
-	for _, record := range result {
-		fmt.Println(record)
+	service.O = service.NewOrderService(service.OrderDAO, &service.R, cfg.Order.HoldTimeout.Duration, slog.Default())
+	service.W = service.NewWaitlistService(service.WaitlistDAO, &service.R, &service.O, slog.Default())
+	service.O.SeatsReleased = service.W.Notify
+	web.H = web.NewHandler(service.R, service.K, service.O, service.W, slog.Default())
+	web.H.Cache = web.NewResultCache(cfg.Cache.MaxBytes)
+	ctx := context.Background()
+	if *importCoordinates != "" {
+		if err := service.DownLoadStationCoordinates(*importCoordinates); err != nil {
+			slog.Error("import coordinates failed", "err", err)
+			os.Exit(1)
+		}
+		return
 	}
-	resultMap, err := service.R.SearchWithOneTrans("太原南", "上海", service.Default, 0, service.DefaultStopTime, service.DefaultStopTime)
-	for key, value := range resultMap {
-		fmt.Println(key, value)
+	if *mockRealtime != "" {
+		feed, err := service.R.MockRealtimeFeed(ctx, *mockRealtime, "", rand.New(rand.NewSource(time.Now().UnixNano())))
+		if err != nil {
+			slog.Error("mock realtime failed", "err", err)
+			os.Exit(1)
+		}
+		data, err := json.MarshalIndent(feed, "", "  ")
+		if err != nil {
+			slog.Error("mock realtime failed", "err", err)
+			os.Exit(1)
+		}
+		if cfg.Realtime.FeedDir == "" {
+			fmt.Println(string(data))
+			return
+		}
+		// 先写临时文件再改名，避免投递目录的导入读到写了一半的文件
+		name := filepath.Join(cfg.Realtime.FeedDir, fmt.Sprintf("mock-%d.json", time.Now().UnixNano()))
+		if err := os.WriteFile(name+".tmp", data, 0o644); err != nil {
+			slog.Error("mock realtime failed", "err", err)
+			os.Exit(1)
+		}
+		if err := os.Rename(name+".tmp", name); err != nil {
+			slog.Error("mock realtime failed", "err", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%d updates written to %s\n", len(feed.Updates), name)
+		return
 	}
-	result, err = service.R.SearchDirectly("淮安东", "太原南", service.Default, 0)
-	if err != nil {
-		fmt.Println(err)
+	if *createAPIKey != "" {
+		plain, key, err := service.K.CreateAPIKey(ctx, *createAPIKey, cfg.Auth.DefaultRate, cfg.Auth.DefaultBurst, cfg.Auth.DefaultDailyQuota, *adminAPIKey)
+		if err != nil {
+			slog.Error("create api key failed", "err", err)
+			os.Exit(1)
+		}
+		fmt.Printf("name: %s\nkey: %s\nrate: %v/s burst: %v daily quota: %d admin: %v\n", key.Name, plain, key.RatePerSecond, key.Burst, key.DailyQuota, key.Admin)
+		return
 	}
-	for _, record := range result {
-		fmt.Println(record)
+	// 换乘图的点数与边数见 GraphNodes、GraphEdges 指标
+	if err := service.R.InitBuildGraph(ctx); err != nil {
+		slog.Error("build graph failed", "err", err)
+		os.Exit(1)
 	}
-	result, err = service.R.SearchDirectly("杭州", "沈阳北", service.Default, 0)
-	if err != nil {
-		fmt.Println(err)
+	// 收到 SIGTERM 或 Ctrl+C 后优雅退出：先停止 HTTP(S) 与 gRPC 接收新请求，再等后台任务返回
+	serverCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	var grpcServer *grpc.Server
+	if cfg.RPC.Addr != "" {
+		// 与 HTTP 共用同一个限流器，同一个 key 的令牌与每日配额合并计算
+		interceptor := rpc.AuthInterceptor(&web.H.APIKeyService, web.H.Limiter, cfg.Auth)
+		grpcServer, err = rpc.Start(cfg.RPC.Addr, service.R, grpc.UnaryInterceptor(interceptor))
+		if err != nil {
+			slog.Error("rpc listen failed", "addr", cfg.RPC.Addr, "err", err)
+			os.Exit(1)
+		}
 	}
-	for _, record := range result {
-		fmt.Println(record)
+	var background sync.WaitGroup
+	run := func(task func(context.Context)) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			task(serverCtx)
+		}()
 	}
-	//resultMap, err = RailwayService.SearchWithOneTrans("太原南", "上海", service.Default, service.Default, service.DefaultStopTime, service.GetAllResult)
-	//for key, value := range resultMap {
-	//	fmt.Println(key, value)
-	//}
-	resultMap, err = service.R.SearchWithTwoTrans("杭州东", "长白山", service.Default, 4, 5, service.LowPriceFirst)
-	if err != nil {
-		fmt.Println(err)
+	run(func(ctx context.Context) { service.O.RunExpiry(ctx, cfg.Order.SweepInterval.Duration) })
+	run(func(ctx context.Context) { service.W.RunWaitlist(ctx, cfg.Order.SweepInterval.Duration) })
+	if cfg.Realtime.FeedDir != "" {
+		run(func(ctx context.Context) {
+			service.R.RunRealtimeFeed(ctx, cfg.Realtime.FeedDir, cfg.Realtime.PollInterval.Duration)
+		})
 	}
-	for key, value := range resultMap {
-		fmt.Println(key, value)
+	err = web.Run(serverCtx, cfg.Server, cfg.Auth)
+	// HTTP(S) 因监听失败提前返回时也要让后台任务退出
+	stop()
+	if grpcServer != nil {
+		rpc.Stop(grpcServer, cfg.Server.ShutdownTimeout.Duration)
 	}
-	resultMap, err = service.R.SearchWithTwoTrans("乌鲁木齐", "海口", service.Default, 4, service.DefaultResultNumber, service.LowRunningTimeFirst)
+	background.Wait()
 	if err != nil {
-		fmt.Println(err)
-	}
-	for key, value := range resultMap {
-		fmt.Println(key, value)
+		slog.Error("server stopped", "err", err)
+		os.Exit(1)
 	}
-	//web.StartNgork()
+	slog.Info("server stopped")
 }
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"railway/cache"
+	"time"
+)
+
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "railway_http_request_duration_seconds",
+		Help:    "HTTP 请求耗时，按路由、方法和状态码区分",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint", "method", "status"})
+
+	SearchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "railway_search_duration_seconds",
+		Help:    "子查询耗时，type 为 direct / via / one_transfer / multi_transfer",
+		Buckets: []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"type", "status"})
+
+	GraphNodes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "railway_graph_nodes",
+		Help: "换乘图中的点数",
+	})
+
+	GraphEdges = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "railway_graph_edges",
+		Help: "换乘图中的边数",
+	})
+
+	DijkstraNodesExpanded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "railway_dijkstra_nodes_expanded_total",
+		Help: "最短路搜索弹出的点数",
+	}, []string{"algorithm"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "railway_db_query_duration_seconds",
+		Help:    "数据库查询耗时，按 DAO 方法区分",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"method"})
+)
+
+// ObserveDB 记录一次 DAO 调用的耗时，用法：defer metrics.ObserveDB("GetStationByName", time.Now())
+func ObserveDB(method string, start time.Time) {
+	DBQueryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// RegisterCacheStats 注册查询结果缓存的命中、未命中与淘汰次数，以及命中率、条目数和字节数；stats 在每次采集时调用
+func RegisterCacheStats(stats func() cache.Stats) {
+	prometheus.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "railway_cache_hits_total",
+			Help: "查询结果缓存命中次数",
+		}, func() float64 { return float64(stats().Hits) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "railway_cache_misses_total",
+			Help: "查询结果缓存未命中次数",
+		}, func() float64 { return float64(stats().Misses) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "railway_cache_evictions_total",
+			Help: "查询结果缓存因容量淘汰的条目数",
+		}, func() float64 { return float64(stats().Evictions) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "railway_cache_hit_ratio",
+			Help: "查询结果缓存命中率",
+		}, func() float64 { return stats().HitRatio() }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "railway_cache_entries",
+			Help: "查询结果缓存的条目数",
+		}, func() float64 { return float64(stats().Size) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "railway_cache_bytes",
+			Help: "查询结果缓存中键与值的总字节数",
+		}, func() float64 { return float64(stats().Bytes) }),
+	)
+}
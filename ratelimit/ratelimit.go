@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter 为每个 key 维护一个令牌桶和当日已消耗的令牌数。计数只保存在本进程内存中：
+// 进程重启后清零，多副本部署时每个副本各自计数，实际每日总量最多为 DailyQuota 乘以副本数
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens   float64
+	last     time.Time
+	day      string
+	consumed int64
+}
+
+// Limit 令牌桶参数，DailyQuota 为 0 时不限制每日总量；每日总量按进程计数，见 Limiter
+type Limit struct {
+	Rate       float64 // 每秒补充的令牌数
+	Burst      float64 // 桶容量
+	DailyQuota int64
+}
+
+// Result Allowed 为 false 时 RetryAfter 为建议的重试等待时间，QuotaExceeded 表示当日配额已用完
+type Result struct {
+	Allowed       bool
+	Remaining     float64
+	RetryAfter    time.Duration
+	QuotaExceeded bool
+}
+
+func New() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Allow 尝试为 key 扣除 cost 个令牌；cost 超过桶容量时按桶容量扣除，避免永远无法通过
+func (l *Limiter) Allow(key string, limit Limit, cost float64) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	day := now.Format("2006-01-02")
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit.Burst, last: now, day: day}
+		l.buckets[key] = b
+	}
+	if b.day != day {
+		b.day = day
+		b.consumed = 0
+	}
+	if cost > limit.Burst {
+		cost = limit.Burst
+	}
+	b.tokens = math.Min(limit.Burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if limit.DailyQuota > 0 && b.consumed+int64(math.Ceil(cost)) > limit.DailyQuota {
+		year, month, date := now.Date()
+		midnight := time.Date(year, month, date+1, 0, 0, 0, 0, now.Location())
+		return Result{Remaining: b.tokens, RetryAfter: midnight.Sub(now), QuotaExceeded: true}
+	}
+	if b.tokens < cost {
+		if limit.Rate <= 0 {
+			return Result{Remaining: b.tokens, RetryAfter: time.Hour}
+		}
+		wait := time.Duration((cost - b.tokens) / limit.Rate * float64(time.Second))
+		return Result{Remaining: b.tokens, RetryAfter: wait}
+	}
+	b.tokens = b.tokens - cost
+	b.consumed = b.consumed + int64(math.Ceil(cost))
+	return Result{Allowed: true, Remaining: b.tokens}
+}
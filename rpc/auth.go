@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"math"
+	"railway/config"
+	"railway/dao"
+	"railway/ratelimit"
+	"railway/rpc/pb"
+	"railway/service"
+	"strconv"
+	"strings"
+)
+
+// APIKeyMetadata 携带 API key 的 metadata 键，也接受 authorization: Bearer
+const APIKeyMetadata = "x-api-key"
+
+// methodCosts 各方法对应 config.AuthConfig.Costs 中的接口类别，与 HTTP 接口的划分一致
+var methodCosts = map[string]string{
+	pb.RailwayService_LookupStations_FullMethodName:      "lookup",
+	pb.RailwayService_SearchDirect_FullMethodName:        "search",
+	pb.RailwayService_SearchOneTransfer_FullMethodName:   "search",
+	pb.RailwayService_SearchMultiTransfer_FullMethodName: "graph_search",
+	pb.RailwayService_GetTrain_FullMethodName:            "lookup",
+}
+
+// apiKey 优先读取 x-api-key，其次为 authorization: Bearer
+func apiKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(APIKeyMetadata); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	for _, authorization := range md.Get("authorization") {
+		if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+			return strings.TrimSpace(authorization[7:])
+		}
+	}
+	return ""
+}
+
+// AuthInterceptor 按方法鉴权并限流的拦截器，与 HTTP 共用 limiter 时同一个 key 的令牌与每日配额合并计算；
+// auth.Enabled 为 false 时直接放行
+func AuthInterceptor(keys service.APIKeyService, limiter *ratelimit.Limiter, auth config.AuthConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !auth.Enabled {
+			return handler(ctx, req)
+		}
+		key, err := keys.Authenticate(ctx, apiKey(ctx))
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidAPIKey) || errors.Is(err, service.ErrDisabledAPIKey) {
+				return nil, status.Error(codes.Unauthenticated, "invalid or missing API key")
+			}
+			return nil, status.Error(codes.Internal, "error checking API key")
+		}
+		category := methodCosts[info.FullMethod]
+		tokens, ok := auth.Costs[category]
+		if !ok {
+			tokens = 1
+		}
+		result := limiter.Allow(key.KeyHash, rateLimitOf(*key), tokens)
+		if !result.Allowed {
+			retryAfter := int64(math.Ceil(result.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.FormatInt(retryAfter, 10)))
+			message := "rate limit exceeded"
+			if result.QuotaExceeded {
+				message = "daily quota exceeded"
+			}
+			slog.Info("request throttled", "api_key", key.Prefix, "method", info.FullMethod, "category", category, "quota_exceeded", result.QuotaExceeded)
+			return nil, status.Error(codes.ResourceExhausted, message)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func rateLimitOf(key dao.APIKey) ratelimit.Limit {
+	return ratelimit.Limit{Rate: key.RatePerSecond, Burst: key.Burst, DailyQuota: key.DailyQuota}
+}
@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"railway/config"
+	"railway/dao"
+	"railway/ratelimit"
+	"railway/rpc/pb"
+	"railway/service"
+	"testing"
+	"time"
+)
+
+// stubAPIKeyService 只认识 keys 中的明文
+type stubAPIKeyService struct {
+	service.APIKeyService
+	keys map[string]dao.APIKey
+}
+
+func (s *stubAPIKeyService) Authenticate(_ context.Context, plain string) (*dao.APIKey, error) {
+	key, ok := s.keys[plain]
+	if !ok {
+		return nil, service.ErrInvalidAPIKey
+	}
+	return &key, nil
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	keys := &stubAPIKeyService{keys: map[string]dao.APIKey{
+		"rk_test": {KeyHash: "test", Prefix: "rk_tes", RatePerSecond: 0.001, Burst: 25, DailyQuota: 1000},
+	}}
+	auth := config.Default().Auth
+	client := newTestClient(t, grpc.UnaryInterceptor(AuthInterceptor(keys, ratelimit.New(), auth)))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	search := &pb.SearchRequest{From: "北京南", To: "上海虹桥", MaxTransfers: 2}
+
+	if _, err := client.SearchDirect(ctx, search); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("missing key: got %v, want Unauthenticated", err)
+	}
+	wrong := metadata.AppendToOutgoingContext(ctx, APIKeyMetadata, "rk_wrong")
+	if _, err := client.SearchDirect(wrong, search); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("wrong key: got %v, want Unauthenticated", err)
+	}
+	authorized := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer rk_test")
+	// 桶容量 25：一次图搜索消耗 20，之后一次 5 的查询正好用完，再查询被限流
+	if _, err := client.SearchMultiTransfer(authorized, search); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SearchDirect(authorized, search); err != nil {
+		t.Fatal(err)
+	}
+	var header metadata.MD
+	_, err := client.SearchDirect(authorized, search, grpc.Header(&header))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("over limit: got %v, want ResourceExhausted", err)
+	}
+	if len(header.Get("retry-after")) == 0 {
+		t.Fatal("missing retry-after header")
+	}
+}
@@ -0,0 +1,989 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: railway.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SortOption int32
+
+const (
+	SortOption_SORT_OPTION_UNSPECIFIED       SortOption = 0
+	SortOption_SORT_OPTION_LOW_PRICE         SortOption = 1
+	SortOption_SORT_OPTION_HIGH_PRICE        SortOption = 2
+	SortOption_SORT_OPTION_LOW_RUNNING_TIME  SortOption = 3
+	SortOption_SORT_OPTION_HIGH_RUNNING_TIME SortOption = 4
+	SortOption_SORT_OPTION_EARLY_DEPARTURE   SortOption = 5
+	SortOption_SORT_OPTION_LATE_DEPARTURE    SortOption = 6
+)
+
+// Enum value maps for SortOption.
+var (
+	SortOption_name = map[int32]string{
+		0: "SORT_OPTION_UNSPECIFIED",
+		1: "SORT_OPTION_LOW_PRICE",
+		2: "SORT_OPTION_HIGH_PRICE",
+		3: "SORT_OPTION_LOW_RUNNING_TIME",
+		4: "SORT_OPTION_HIGH_RUNNING_TIME",
+		5: "SORT_OPTION_EARLY_DEPARTURE",
+		6: "SORT_OPTION_LATE_DEPARTURE",
+	}
+	SortOption_value = map[string]int32{
+		"SORT_OPTION_UNSPECIFIED":       0,
+		"SORT_OPTION_LOW_PRICE":         1,
+		"SORT_OPTION_HIGH_PRICE":        2,
+		"SORT_OPTION_LOW_RUNNING_TIME":  3,
+		"SORT_OPTION_HIGH_RUNNING_TIME": 4,
+		"SORT_OPTION_EARLY_DEPARTURE":   5,
+		"SORT_OPTION_LATE_DEPARTURE":    6,
+	}
+)
+
+func (x SortOption) Enum() *SortOption {
+	p := new(SortOption)
+	*p = x
+	return p
+}
+
+func (x SortOption) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SortOption) Descriptor() protoreflect.EnumDescriptor {
+	return file_railway_proto_enumTypes[0].Descriptor()
+}
+
+func (SortOption) Type() protoreflect.EnumType {
+	return &file_railway_proto_enumTypes[0]
+}
+
+func (x SortOption) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SortOption.Descriptor instead.
+func (SortOption) EnumDescriptor() ([]byte, []int) {
+	return file_railway_proto_rawDescGZIP(), []int{0}
+}
+
+type TrainType int32
+
+const (
+	TrainType_TRAIN_TYPE_ALL        TrainType = 0
+	TrainType_TRAIN_TYPE_HIGH_SPEED TrainType = 1
+	TrainType_TRAIN_TYPE_NORMAL     TrainType = 2
+)
+
+// Enum value maps for TrainType.
+var (
+	TrainType_name = map[int32]string{
+		0: "TRAIN_TYPE_ALL",
+		1: "TRAIN_TYPE_HIGH_SPEED",
+		2: "TRAIN_TYPE_NORMAL",
+	}
+	TrainType_value = map[string]int32{
+		"TRAIN_TYPE_ALL":        0,
+		"TRAIN_TYPE_HIGH_SPEED": 1,
+		"TRAIN_TYPE_NORMAL":     2,
+	}
+)
+
+func (x TrainType) Enum() *TrainType {
+	p := new(TrainType)
+	*p = x
+	return p
+}
+
+func (x TrainType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TrainType) Descriptor() protoreflect.EnumDescriptor {
+	return file_railway_proto_enumTypes[1].Descriptor()
+}
+
+func (TrainType) Type() protoreflect.EnumType {
+	return &file_railway_proto_enumTypes[1]
+}
+
+func (x TrainType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TrainType.Descriptor instead.
+func (TrainType) EnumDescriptor() ([]byte, []int) {
+	return file_railway_proto_rawDescGZIP(), []int{1}
+}
+
+type Station struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Code          string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Pinyin        string                 `protobuf:"bytes,3,opt,name=pinyin,proto3" json:"pinyin,omitempty"`
+	CityName      string                 `protobuf:"bytes,4,opt,name=city_name,json=cityName,proto3" json:"city_name,omitempty"`
+	IsCity        bool                   `protobuf:"varint,5,opt,name=is_city,json=isCity,proto3" json:"is_city,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Station) Reset() {
+	*x = Station{}
+	mi := &file_railway_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Station) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Station) ProtoMessage() {}
+
+func (x *Station) ProtoReflect() protoreflect.Message {
+	mi := &file_railway_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Station.ProtoReflect.Descriptor instead.
+func (*Station) Descriptor() ([]byte, []int) {
+	return file_railway_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Station) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Station) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Station) GetPinyin() string {
+	if x != nil {
+		return x.Pinyin
+	}
+	return ""
+}
+
+func (x *Station) GetCityName() string {
+	if x != nil {
+		return x.CityName
+	}
+	return ""
+}
+
+func (x *Station) GetIsCity() bool {
+	if x != nil {
+		return x.IsCity
+	}
+	return false
+}
+
+type LookupStationsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Keyword       string                 `protobuf:"bytes,1,opt,name=keyword,proto3" json:"keyword,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupStationsRequest) Reset() {
+	*x = LookupStationsRequest{}
+	mi := &file_railway_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupStationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupStationsRequest) ProtoMessage() {}
+
+func (x *LookupStationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_railway_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupStationsRequest.ProtoReflect.Descriptor instead.
+func (*LookupStationsRequest) Descriptor() ([]byte, []int) {
+	return file_railway_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LookupStationsRequest) GetKeyword() string {
+	if x != nil {
+		return x.Keyword
+	}
+	return ""
+}
+
+type LookupStationsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stations      []*Station             `protobuf:"bytes,1,rep,name=stations,proto3" json:"stations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupStationsResponse) Reset() {
+	*x = LookupStationsResponse{}
+	mi := &file_railway_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupStationsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupStationsResponse) ProtoMessage() {}
+
+func (x *LookupStationsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_railway_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupStationsResponse.ProtoReflect.Descriptor instead.
+func (*LookupStationsResponse) Descriptor() ([]byte, []int) {
+	return file_railway_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *LookupStationsResponse) GetStations() []*Station {
+	if x != nil {
+		return x.Stations
+	}
+	return nil
+}
+
+type SearchRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	From      string                 `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To        string                 `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	Via       string                 `protobuf:"bytes,3,opt,name=via,proto3" json:"via,omitempty"`
+	TrainType TrainType              `protobuf:"varint,4,opt,name=train_type,json=trainType,proto3,enum=railway.v1.TrainType" json:"train_type,omitempty"`
+	Sort      SortOption             `protobuf:"varint,5,opt,name=sort,proto3,enum=railway.v1.SortOption" json:"sort,omitempty"`
+	// 多次中转时的最多换乘次数，默认 2
+	MaxTransfers int64 `protobuf:"varint,6,opt,name=max_transfers,json=maxTransfers,proto3" json:"max_transfers,omitempty"`
+	// 多次中转时返回的方案数，默认 10
+	ResultNumber  int64 `protobuf:"varint,7,opt,name=result_number,json=resultNumber,proto3" json:"result_number,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_railway_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_railway_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_railway_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SearchRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetVia() string {
+	if x != nil {
+		return x.Via
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetTrainType() TrainType {
+	if x != nil {
+		return x.TrainType
+	}
+	return TrainType_TRAIN_TYPE_ALL
+}
+
+func (x *SearchRequest) GetSort() SortOption {
+	if x != nil {
+		return x.Sort
+	}
+	return SortOption_SORT_OPTION_UNSPECIFIED
+}
+
+func (x *SearchRequest) GetMaxTransfers() int64 {
+	if x != nil {
+		return x.MaxTransfers
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetResultNumber() int64 {
+	if x != nil {
+		return x.ResultNumber
+	}
+	return 0
+}
+
+type Leg struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	TrainNumber      string                 `protobuf:"bytes,1,opt,name=train_number,json=trainNumber,proto3" json:"train_number,omitempty"`
+	TrainNo          string                 `protobuf:"bytes,2,opt,name=train_no,json=trainNo,proto3" json:"train_no,omitempty"`
+	DepartureStation string                 `protobuf:"bytes,3,opt,name=departure_station,json=departureStation,proto3" json:"departure_station,omitempty"`
+	DepartureTime    string                 `protobuf:"bytes,4,opt,name=departure_time,json=departureTime,proto3" json:"departure_time,omitempty"`
+	ArrivalStation   string                 `protobuf:"bytes,5,opt,name=arrival_station,json=arrivalStation,proto3" json:"arrival_station,omitempty"`
+	ArrivalTime      string                 `protobuf:"bytes,6,opt,name=arrival_time,json=arrivalTime,proto3" json:"arrival_time,omitempty"`
+	RunningMinutes   int64                  `protobuf:"varint,7,opt,name=running_minutes,json=runningMinutes,proto3" json:"running_minutes,omitempty"`
+	ArrivalDay       uint32                 `protobuf:"varint,8,opt,name=arrival_day,json=arrivalDay,proto3" json:"arrival_day,omitempty"`
+	HighSpeed        bool                   `protobuf:"varint,9,opt,name=high_speed,json=highSpeed,proto3" json:"high_speed,omitempty"`
+	Price            float64                `protobuf:"fixed64,10,opt,name=price,proto3" json:"price,omitempty"`
+	Fares            map[string]float64     `protobuf:"bytes,11,rep,name=fares,proto3" json:"fares,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Leg) Reset() {
+	*x = Leg{}
+	mi := &file_railway_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Leg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Leg) ProtoMessage() {}
+
+func (x *Leg) ProtoReflect() protoreflect.Message {
+	mi := &file_railway_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Leg.ProtoReflect.Descriptor instead.
+func (*Leg) Descriptor() ([]byte, []int) {
+	return file_railway_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Leg) GetTrainNumber() string {
+	if x != nil {
+		return x.TrainNumber
+	}
+	return ""
+}
+
+func (x *Leg) GetTrainNo() string {
+	if x != nil {
+		return x.TrainNo
+	}
+	return ""
+}
+
+func (x *Leg) GetDepartureStation() string {
+	if x != nil {
+		return x.DepartureStation
+	}
+	return ""
+}
+
+func (x *Leg) GetDepartureTime() string {
+	if x != nil {
+		return x.DepartureTime
+	}
+	return ""
+}
+
+func (x *Leg) GetArrivalStation() string {
+	if x != nil {
+		return x.ArrivalStation
+	}
+	return ""
+}
+
+func (x *Leg) GetArrivalTime() string {
+	if x != nil {
+		return x.ArrivalTime
+	}
+	return ""
+}
+
+func (x *Leg) GetRunningMinutes() int64 {
+	if x != nil {
+		return x.RunningMinutes
+	}
+	return 0
+}
+
+func (x *Leg) GetArrivalDay() uint32 {
+	if x != nil {
+		return x.ArrivalDay
+	}
+	return 0
+}
+
+func (x *Leg) GetHighSpeed() bool {
+	if x != nil {
+		return x.HighSpeed
+	}
+	return false
+}
+
+func (x *Leg) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Leg) GetFares() map[string]float64 {
+	if x != nil {
+		return x.Fares
+	}
+	return nil
+}
+
+type Journey struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	TotalMinutes  int64                  `protobuf:"varint,2,opt,name=total_minutes,json=totalMinutes,proto3" json:"total_minutes,omitempty"`
+	TotalPrice    float64                `protobuf:"fixed64,3,opt,name=total_price,json=totalPrice,proto3" json:"total_price,omitempty"`
+	Legs          []*Leg                 `protobuf:"bytes,4,rep,name=legs,proto3" json:"legs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Journey) Reset() {
+	*x = Journey{}
+	mi := &file_railway_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Journey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Journey) ProtoMessage() {}
+
+func (x *Journey) ProtoReflect() protoreflect.Message {
+	mi := &file_railway_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Journey.ProtoReflect.Descriptor instead.
+func (*Journey) Descriptor() ([]byte, []int) {
+	return file_railway_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Journey) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Journey) GetTotalMinutes() int64 {
+	if x != nil {
+		return x.TotalMinutes
+	}
+	return 0
+}
+
+func (x *Journey) GetTotalPrice() float64 {
+	if x != nil {
+		return x.TotalPrice
+	}
+	return 0
+}
+
+func (x *Journey) GetLegs() []*Leg {
+	if x != nil {
+		return x.Legs
+	}
+	return nil
+}
+
+type SearchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Journeys      []*Journey             `protobuf:"bytes,1,rep,name=journeys,proto3" json:"journeys,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	mi := &file_railway_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_railway_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_railway_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SearchResponse) GetJourneys() []*Journey {
+	if x != nil {
+		return x.Journeys
+	}
+	return nil
+}
+
+type GetTrainRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	TrainNo string                 `protobuf:"bytes,1,opt,name=train_no,json=trainNo,proto3" json:"train_no,omitempty"`
+	// train_no 为空时按车次号查询
+	TrainNumber   string `protobuf:"bytes,2,opt,name=train_number,json=trainNumber,proto3" json:"train_number,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTrainRequest) Reset() {
+	*x = GetTrainRequest{}
+	mi := &file_railway_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTrainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTrainRequest) ProtoMessage() {}
+
+func (x *GetTrainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_railway_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTrainRequest.ProtoReflect.Descriptor instead.
+func (*GetTrainRequest) Descriptor() ([]byte, []int) {
+	return file_railway_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetTrainRequest) GetTrainNo() string {
+	if x != nil {
+		return x.TrainNo
+	}
+	return ""
+}
+
+func (x *GetTrainRequest) GetTrainNumber() string {
+	if x != nil {
+		return x.TrainNumber
+	}
+	return ""
+}
+
+type Stop struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Station         string                 `protobuf:"bytes,1,opt,name=station,proto3" json:"station,omitempty"`
+	ArrivalTime     string                 `protobuf:"bytes,2,opt,name=arrival_time,json=arrivalTime,proto3" json:"arrival_time,omitempty"`
+	DepartureTime   string                 `protobuf:"bytes,3,opt,name=departure_time,json=departureTime,proto3" json:"departure_time,omitempty"`
+	Day             uint32                 `protobuf:"varint,4,opt,name=day,proto3" json:"day,omitempty"`
+	PriceFromOrigin float64                `protobuf:"fixed64,5,opt,name=price_from_origin,json=priceFromOrigin,proto3" json:"price_from_origin,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Stop) Reset() {
+	*x = Stop{}
+	mi := &file_railway_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stop) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stop) ProtoMessage() {}
+
+func (x *Stop) ProtoReflect() protoreflect.Message {
+	mi := &file_railway_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stop.ProtoReflect.Descriptor instead.
+func (*Stop) Descriptor() ([]byte, []int) {
+	return file_railway_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Stop) GetStation() string {
+	if x != nil {
+		return x.Station
+	}
+	return ""
+}
+
+func (x *Stop) GetArrivalTime() string {
+	if x != nil {
+		return x.ArrivalTime
+	}
+	return ""
+}
+
+func (x *Stop) GetDepartureTime() string {
+	if x != nil {
+		return x.DepartureTime
+	}
+	return ""
+}
+
+func (x *Stop) GetDay() uint32 {
+	if x != nil {
+		return x.Day
+	}
+	return 0
+}
+
+func (x *Stop) GetPriceFromOrigin() float64 {
+	if x != nil {
+		return x.PriceFromOrigin
+	}
+	return 0
+}
+
+type Train struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TrainNumber   string                 `protobuf:"bytes,1,opt,name=train_number,json=trainNumber,proto3" json:"train_number,omitempty"`
+	TrainNo       string                 `protobuf:"bytes,2,opt,name=train_no,json=trainNo,proto3" json:"train_no,omitempty"`
+	HighSpeed     bool                   `protobuf:"varint,3,opt,name=high_speed,json=highSpeed,proto3" json:"high_speed,omitempty"`
+	Stops         []*Stop                `protobuf:"bytes,4,rep,name=stops,proto3" json:"stops,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Train) Reset() {
+	*x = Train{}
+	mi := &file_railway_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Train) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Train) ProtoMessage() {}
+
+func (x *Train) ProtoReflect() protoreflect.Message {
+	mi := &file_railway_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Train.ProtoReflect.Descriptor instead.
+func (*Train) Descriptor() ([]byte, []int) {
+	return file_railway_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Train) GetTrainNumber() string {
+	if x != nil {
+		return x.TrainNumber
+	}
+	return ""
+}
+
+func (x *Train) GetTrainNo() string {
+	if x != nil {
+		return x.TrainNo
+	}
+	return ""
+}
+
+func (x *Train) GetHighSpeed() bool {
+	if x != nil {
+		return x.HighSpeed
+	}
+	return false
+}
+
+func (x *Train) GetStops() []*Stop {
+	if x != nil {
+		return x.Stops
+	}
+	return nil
+}
+
+var File_railway_proto protoreflect.FileDescriptor
+
+const file_railway_proto_rawDesc = "" +
+	"\n" +
+	"\rrailway.proto\x12\n" +
+	"railway.v1\"\x7f\n" +
+	"\aStation\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12\x16\n" +
+	"\x06pinyin\x18\x03 \x01(\tR\x06pinyin\x12\x1b\n" +
+	"\tcity_name\x18\x04 \x01(\tR\bcityName\x12\x17\n" +
+	"\ais_city\x18\x05 \x01(\bR\x06isCity\"1\n" +
+	"\x15LookupStationsRequest\x12\x18\n" +
+	"\akeyword\x18\x01 \x01(\tR\akeyword\"I\n" +
+	"\x16LookupStationsResponse\x12/\n" +
+	"\bstations\x18\x01 \x03(\v2\x13.railway.v1.StationR\bstations\"\xf1\x01\n" +
+	"\rSearchRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\tR\x02to\x12\x10\n" +
+	"\x03via\x18\x03 \x01(\tR\x03via\x124\n" +
+	"\n" +
+	"train_type\x18\x04 \x01(\x0e2\x15.railway.v1.TrainTypeR\ttrainType\x12*\n" +
+	"\x04sort\x18\x05 \x01(\x0e2\x16.railway.v1.SortOptionR\x04sort\x12#\n" +
+	"\rmax_transfers\x18\x06 \x01(\x03R\fmaxTransfers\x12#\n" +
+	"\rresult_number\x18\a \x01(\x03R\fresultNumber\"\xce\x03\n" +
+	"\x03Leg\x12!\n" +
+	"\ftrain_number\x18\x01 \x01(\tR\vtrainNumber\x12\x19\n" +
+	"\btrain_no\x18\x02 \x01(\tR\atrainNo\x12+\n" +
+	"\x11departure_station\x18\x03 \x01(\tR\x10departureStation\x12%\n" +
+	"\x0edeparture_time\x18\x04 \x01(\tR\rdepartureTime\x12'\n" +
+	"\x0farrival_station\x18\x05 \x01(\tR\x0earrivalStation\x12!\n" +
+	"\farrival_time\x18\x06 \x01(\tR\varrivalTime\x12'\n" +
+	"\x0frunning_minutes\x18\a \x01(\x03R\x0erunningMinutes\x12\x1f\n" +
+	"\varrival_day\x18\b \x01(\rR\n" +
+	"arrivalDay\x12\x1d\n" +
+	"\n" +
+	"high_speed\x18\t \x01(\bR\thighSpeed\x12\x14\n" +
+	"\x05price\x18\n" +
+	" \x01(\x01R\x05price\x120\n" +
+	"\x05fares\x18\v \x03(\v2\x1a.railway.v1.Leg.FaresEntryR\x05fares\x1a8\n" +
+	"\n" +
+	"FaresEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\"\x84\x01\n" +
+	"\aJourney\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12#\n" +
+	"\rtotal_minutes\x18\x02 \x01(\x03R\ftotalMinutes\x12\x1f\n" +
+	"\vtotal_price\x18\x03 \x01(\x01R\n" +
+	"totalPrice\x12#\n" +
+	"\x04legs\x18\x04 \x03(\v2\x0f.railway.v1.LegR\x04legs\"A\n" +
+	"\x0eSearchResponse\x12/\n" +
+	"\bjourneys\x18\x01 \x03(\v2\x13.railway.v1.JourneyR\bjourneys\"O\n" +
+	"\x0fGetTrainRequest\x12\x19\n" +
+	"\btrain_no\x18\x01 \x01(\tR\atrainNo\x12!\n" +
+	"\ftrain_number\x18\x02 \x01(\tR\vtrainNumber\"\xa8\x01\n" +
+	"\x04Stop\x12\x18\n" +
+	"\astation\x18\x01 \x01(\tR\astation\x12!\n" +
+	"\farrival_time\x18\x02 \x01(\tR\varrivalTime\x12%\n" +
+	"\x0edeparture_time\x18\x03 \x01(\tR\rdepartureTime\x12\x10\n" +
+	"\x03day\x18\x04 \x01(\rR\x03day\x12*\n" +
+	"\x11price_from_origin\x18\x05 \x01(\x01R\x0fpriceFromOrigin\"\x8c\x01\n" +
+	"\x05Train\x12!\n" +
+	"\ftrain_number\x18\x01 \x01(\tR\vtrainNumber\x12\x19\n" +
+	"\btrain_no\x18\x02 \x01(\tR\atrainNo\x12\x1d\n" +
+	"\n" +
+	"high_speed\x18\x03 \x01(\bR\thighSpeed\x12&\n" +
+	"\x05stops\x18\x04 \x03(\v2\x10.railway.v1.StopR\x05stops*\xe6\x01\n" +
+	"\n" +
+	"SortOption\x12\x1b\n" +
+	"\x17SORT_OPTION_UNSPECIFIED\x10\x00\x12\x19\n" +
+	"\x15SORT_OPTION_LOW_PRICE\x10\x01\x12\x1a\n" +
+	"\x16SORT_OPTION_HIGH_PRICE\x10\x02\x12 \n" +
+	"\x1cSORT_OPTION_LOW_RUNNING_TIME\x10\x03\x12!\n" +
+	"\x1dSORT_OPTION_HIGH_RUNNING_TIME\x10\x04\x12\x1f\n" +
+	"\x1bSORT_OPTION_EARLY_DEPARTURE\x10\x05\x12\x1e\n" +
+	"\x1aSORT_OPTION_LATE_DEPARTURE\x10\x06*Q\n" +
+	"\tTrainType\x12\x12\n" +
+	"\x0eTRAIN_TYPE_ALL\x10\x00\x12\x19\n" +
+	"\x15TRAIN_TYPE_HIGH_SPEED\x10\x01\x12\x15\n" +
+	"\x11TRAIN_TYPE_NORMAL\x10\x022\x86\x03\n" +
+	"\x0eRailwayService\x12W\n" +
+	"\x0eLookupStations\x12!.railway.v1.LookupStationsRequest\x1a\".railway.v1.LookupStationsResponse\x12E\n" +
+	"\fSearchDirect\x12\x19.railway.v1.SearchRequest\x1a\x1a.railway.v1.SearchResponse\x12J\n" +
+	"\x11SearchOneTransfer\x12\x19.railway.v1.SearchRequest\x1a\x1a.railway.v1.SearchResponse\x12L\n" +
+	"\x13SearchMultiTransfer\x12\x19.railway.v1.SearchRequest\x1a\x1a.railway.v1.SearchResponse\x12:\n" +
+	"\bGetTrain\x12\x1b.railway.v1.GetTrainRequest\x1a\x11.railway.v1.TrainB\x13Z\x11railway/rpc/pb;pbb\x06proto3"
+
+var (
+	file_railway_proto_rawDescOnce sync.Once
+	file_railway_proto_rawDescData []byte
+)
+
+func file_railway_proto_rawDescGZIP() []byte {
+	file_railway_proto_rawDescOnce.Do(func() {
+		file_railway_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_railway_proto_rawDesc), len(file_railway_proto_rawDesc)))
+	})
+	return file_railway_proto_rawDescData
+}
+
+var file_railway_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_railway_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_railway_proto_goTypes = []any{
+	(SortOption)(0),                // 0: railway.v1.SortOption
+	(TrainType)(0),                 // 1: railway.v1.TrainType
+	(*Station)(nil),                // 2: railway.v1.Station
+	(*LookupStationsRequest)(nil),  // 3: railway.v1.LookupStationsRequest
+	(*LookupStationsResponse)(nil), // 4: railway.v1.LookupStationsResponse
+	(*SearchRequest)(nil),          // 5: railway.v1.SearchRequest
+	(*Leg)(nil),                    // 6: railway.v1.Leg
+	(*Journey)(nil),                // 7: railway.v1.Journey
+	(*SearchResponse)(nil),         // 8: railway.v1.SearchResponse
+	(*GetTrainRequest)(nil),        // 9: railway.v1.GetTrainRequest
+	(*Stop)(nil),                   // 10: railway.v1.Stop
+	(*Train)(nil),                  // 11: railway.v1.Train
+	nil,                            // 12: railway.v1.Leg.FaresEntry
+}
+var file_railway_proto_depIdxs = []int32{
+	2,  // 0: railway.v1.LookupStationsResponse.stations:type_name -> railway.v1.Station
+	1,  // 1: railway.v1.SearchRequest.train_type:type_name -> railway.v1.TrainType
+	0,  // 2: railway.v1.SearchRequest.sort:type_name -> railway.v1.SortOption
+	12, // 3: railway.v1.Leg.fares:type_name -> railway.v1.Leg.FaresEntry
+	6,  // 4: railway.v1.Journey.legs:type_name -> railway.v1.Leg
+	7,  // 5: railway.v1.SearchResponse.journeys:type_name -> railway.v1.Journey
+	10, // 6: railway.v1.Train.stops:type_name -> railway.v1.Stop
+	3,  // 7: railway.v1.RailwayService.LookupStations:input_type -> railway.v1.LookupStationsRequest
+	5,  // 8: railway.v1.RailwayService.SearchDirect:input_type -> railway.v1.SearchRequest
+	5,  // 9: railway.v1.RailwayService.SearchOneTransfer:input_type -> railway.v1.SearchRequest
+	5,  // 10: railway.v1.RailwayService.SearchMultiTransfer:input_type -> railway.v1.SearchRequest
+	9,  // 11: railway.v1.RailwayService.GetTrain:input_type -> railway.v1.GetTrainRequest
+	4,  // 12: railway.v1.RailwayService.LookupStations:output_type -> railway.v1.LookupStationsResponse
+	8,  // 13: railway.v1.RailwayService.SearchDirect:output_type -> railway.v1.SearchResponse
+	8,  // 14: railway.v1.RailwayService.SearchOneTransfer:output_type -> railway.v1.SearchResponse
+	8,  // 15: railway.v1.RailwayService.SearchMultiTransfer:output_type -> railway.v1.SearchResponse
+	11, // 16: railway.v1.RailwayService.GetTrain:output_type -> railway.v1.Train
+	12, // [12:17] is the sub-list for method output_type
+	7,  // [7:12] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_railway_proto_init() }
+func file_railway_proto_init() {
+	if File_railway_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_railway_proto_rawDesc), len(file_railway_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_railway_proto_goTypes,
+		DependencyIndexes: file_railway_proto_depIdxs,
+		EnumInfos:         file_railway_proto_enumTypes,
+		MessageInfos:      file_railway_proto_msgTypes,
+	}.Build()
+	File_railway_proto = out.File
+	file_railway_proto_goTypes = nil
+	file_railway_proto_depIdxs = nil
+}
@@ -0,0 +1,287 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: railway.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	RailwayService_LookupStations_FullMethodName      = "/railway.v1.RailwayService/LookupStations"
+	RailwayService_SearchDirect_FullMethodName        = "/railway.v1.RailwayService/SearchDirect"
+	RailwayService_SearchOneTransfer_FullMethodName   = "/railway.v1.RailwayService/SearchOneTransfer"
+	RailwayService_SearchMultiTransfer_FullMethodName = "/railway.v1.RailwayService/SearchMultiTransfer"
+	RailwayService_GetTrain_FullMethodName            = "/railway.v1.RailwayService/GetTrain"
+)
+
+// RailwayServiceClient is the client API for RailwayService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RailwayService 行程查询的 gRPC 接口，与 HTTP 接口共用 RailWayServiceImpl
+type RailwayServiceClient interface {
+	// 车站与城市联想
+	LookupStations(ctx context.Context, in *LookupStationsRequest, opts ...grpc.CallOption) (*LookupStationsResponse, error)
+	// 直达查询
+	SearchDirect(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// 一次中转查询，via 不为空时只在指定车站换乘
+	SearchOneTransfer(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// 基于图的多次中转查询
+	SearchMultiTransfer(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// 车次详情（经停站）
+	GetTrain(ctx context.Context, in *GetTrainRequest, opts ...grpc.CallOption) (*Train, error)
+}
+
+type railwayServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRailwayServiceClient(cc grpc.ClientConnInterface) RailwayServiceClient {
+	return &railwayServiceClient{cc}
+}
+
+func (c *railwayServiceClient) LookupStations(ctx context.Context, in *LookupStationsRequest, opts ...grpc.CallOption) (*LookupStationsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LookupStationsResponse)
+	err := c.cc.Invoke(ctx, RailwayService_LookupStations_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *railwayServiceClient) SearchDirect(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, RailwayService_SearchDirect_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *railwayServiceClient) SearchOneTransfer(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, RailwayService_SearchOneTransfer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *railwayServiceClient) SearchMultiTransfer(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, RailwayService_SearchMultiTransfer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *railwayServiceClient) GetTrain(ctx context.Context, in *GetTrainRequest, opts ...grpc.CallOption) (*Train, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Train)
+	err := c.cc.Invoke(ctx, RailwayService_GetTrain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RailwayServiceServer is the server API for RailwayService service.
+// All implementations must embed UnimplementedRailwayServiceServer
+// for forward compatibility.
+//
+// RailwayService 行程查询的 gRPC 接口，与 HTTP 接口共用 RailWayServiceImpl
+type RailwayServiceServer interface {
+	// 车站与城市联想
+	LookupStations(context.Context, *LookupStationsRequest) (*LookupStationsResponse, error)
+	// 直达查询
+	SearchDirect(context.Context, *SearchRequest) (*SearchResponse, error)
+	// 一次中转查询，via 不为空时只在指定车站换乘
+	SearchOneTransfer(context.Context, *SearchRequest) (*SearchResponse, error)
+	// 基于图的多次中转查询
+	SearchMultiTransfer(context.Context, *SearchRequest) (*SearchResponse, error)
+	// 车次详情（经停站）
+	GetTrain(context.Context, *GetTrainRequest) (*Train, error)
+	mustEmbedUnimplementedRailwayServiceServer()
+}
+
+// UnimplementedRailwayServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRailwayServiceServer struct{}
+
+func (UnimplementedRailwayServiceServer) LookupStations(context.Context, *LookupStationsRequest) (*LookupStationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupStations not implemented")
+}
+func (UnimplementedRailwayServiceServer) SearchDirect(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchDirect not implemented")
+}
+func (UnimplementedRailwayServiceServer) SearchOneTransfer(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchOneTransfer not implemented")
+}
+func (UnimplementedRailwayServiceServer) SearchMultiTransfer(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchMultiTransfer not implemented")
+}
+func (UnimplementedRailwayServiceServer) GetTrain(context.Context, *GetTrainRequest) (*Train, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTrain not implemented")
+}
+func (UnimplementedRailwayServiceServer) mustEmbedUnimplementedRailwayServiceServer() {}
+func (UnimplementedRailwayServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeRailwayServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RailwayServiceServer will
+// result in compilation errors.
+type UnsafeRailwayServiceServer interface {
+	mustEmbedUnimplementedRailwayServiceServer()
+}
+
+func RegisterRailwayServiceServer(s grpc.ServiceRegistrar, srv RailwayServiceServer) {
+	// If the following call pancis, it indicates UnimplementedRailwayServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RailwayService_ServiceDesc, srv)
+}
+
+func _RailwayService_LookupStations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupStationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RailwayServiceServer).LookupStations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RailwayService_LookupStations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RailwayServiceServer).LookupStations(ctx, req.(*LookupStationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RailwayService_SearchDirect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RailwayServiceServer).SearchDirect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RailwayService_SearchDirect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RailwayServiceServer).SearchDirect(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RailwayService_SearchOneTransfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RailwayServiceServer).SearchOneTransfer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RailwayService_SearchOneTransfer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RailwayServiceServer).SearchOneTransfer(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RailwayService_SearchMultiTransfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RailwayServiceServer).SearchMultiTransfer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RailwayService_SearchMultiTransfer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RailwayServiceServer).SearchMultiTransfer(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RailwayService_GetTrain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTrainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RailwayServiceServer).GetTrain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RailwayService_GetTrain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RailwayServiceServer).GetTrain(ctx, req.(*GetTrainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RailwayService_ServiceDesc is the grpc.ServiceDesc for RailwayService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RailwayService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "railway.v1.RailwayService",
+	HandlerType: (*RailwayServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "LookupStations",
+			Handler:    _RailwayService_LookupStations_Handler,
+		},
+		{
+			MethodName: "SearchDirect",
+			Handler:    _RailwayService_SearchDirect_Handler,
+		},
+		{
+			MethodName: "SearchOneTransfer",
+			Handler:    _RailwayService_SearchOneTransfer_Handler,
+		},
+		{
+			MethodName: "SearchMultiTransfer",
+			Handler:    _RailwayService_SearchMultiTransfer_Handler,
+		},
+		{
+			MethodName: "GetTrain",
+			Handler:    _RailwayService_GetTrain_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "railway.proto",
+}
@@ -0,0 +1,283 @@
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative -I pb pb/railway.proto
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net"
+	"railway/dao"
+	"railway/rpc/pb"
+	"railway/service"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAddr 默认只监听本机，见 config.RPCConfig
+const DefaultAddr = "127.0.0.1:50051"
+
+// Server 基于 RailWayServiceImpl 的 gRPC 实现，与 web 层共用同一份服务
+type Server struct {
+	pb.UnimplementedRailwayServiceServer
+	RailWayServiceImpl service.RailWayServiceImpl
+}
+
+var _ pb.RailwayServiceServer = (*Server)(nil)
+
+func NewServer(RailWayServiceImpl service.RailWayServiceImpl) *Server {
+	return &Server{
+		RailWayServiceImpl: RailWayServiceImpl,
+	}
+}
+
+// NewGRPCServer 创建注册了 RailwayService 的 gRPC 服务，opts 用于添加拦截器等
+func NewGRPCServer(RailWayServiceImpl service.RailWayServiceImpl, opts ...grpc.ServerOption) *grpc.Server {
+	grpcServer := grpc.NewServer(opts...)
+	pb.RegisterRailwayServiceServer(grpcServer, NewServer(RailWayServiceImpl))
+	return grpcServer
+}
+
+// Start 在 addr 上监听并在后台提供服务，返回的 *grpc.Server 由调用方 GracefulStop
+func Start(addr string, RailWayServiceImpl service.RailWayServiceImpl, opts ...grpc.ServerOption) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	grpcServer := NewGRPCServer(RailWayServiceImpl, opts...)
+	go func() {
+		if err := Serve(grpcServer, listener); err != nil {
+			slog.Error("rpc server stopped", "addr", addr, "err", err)
+		}
+	}()
+	return grpcServer, nil
+}
+
+// Serve 在给定的 listener 上阻塞提供服务，便于使用进程内 listener；GracefulStop 或 Stop 之后返回 nil
+func Serve(grpcServer *grpc.Server, listener net.Listener) error {
+	slog.Info("rpc listening", "addr", listener.Addr().String())
+	return grpcServer.Serve(listener)
+}
+
+// Stop 等待进行中的调用完成，超过 timeout 时强制关闭连接
+func Stop(grpcServer *grpc.Server, timeout time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		slog.Warn("rpc graceful stop timed out", "timeout", timeout)
+		grpcServer.Stop()
+	}
+}
+
+func (s *Server) LookupStations(ctx context.Context, req *pb.LookupStationsRequest) (*pb.LookupStationsResponse, error) {
+	if req.GetKeyword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "keyword is required")
+	}
+	cities, stations, err := s.RailWayServiceImpl.LookupStations(ctx, req.GetKeyword())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &pb.LookupStationsResponse{}
+	for _, city := range cities {
+		resp.Stations = append(resp.Stations, &pb.Station{Name: city.CityName, Code: city.CityCode, CityName: city.CityName, IsCity: true})
+	}
+	for _, station := range stations {
+		resp.Stations = append(resp.Stations, &pb.Station{
+			Name:     station.StationName,
+			Code:     station.StationCode,
+			Pinyin:   station.StationPinyin,
+			CityName: station.CityName,
+		})
+	}
+	return resp, nil
+}
+
+func (s *Server) SearchDirect(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	if err := checkSearchRequest(req); err != nil {
+		return nil, err
+	}
+	results, err := s.RailWayServiceImpl.SearchDirectly(ctx, req.GetFrom(), req.GetTo(), trainType(req.GetTrainType()), int(req.GetSort()), nil, "")
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toSearchResponse(results, req.GetSort()), nil
+}
+
+func (s *Server) SearchOneTransfer(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	if err := checkSearchRequest(req); err != nil {
+		return nil, err
+	}
+	var (
+		results map[string][]dao.RailWay
+		err     error
+	)
+	if req.GetVia() != "" {
+		results, err = s.RailWayServiceImpl.SearchWithOneSpecificTrans(ctx, req.GetFrom(), req.GetVia(), req.GetTo(), trainType(req.GetTrainType()), int(req.GetSort()), service.DefaultStopTime, nil, "")
+	} else {
+		results, err = s.RailWayServiceImpl.SearchWithOneTrans(ctx, req.GetFrom(), req.GetTo(), trainType(req.GetTrainType()), int(req.GetSort()), service.DefaultStopTime, 0, nil, "")
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toSearchResponse(results, req.GetSort()), nil
+}
+
+func (s *Server) SearchMultiTransfer(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	if err := checkSearchRequest(req); err != nil {
+		return nil, err
+	}
+	maxTransfers := req.GetMaxTransfers()
+	if maxTransfers <= 0 {
+		maxTransfers = 2
+	}
+	resultNumber := req.GetResultNumber()
+	if resultNumber <= 0 {
+		resultNumber = service.DefaultResultNumber
+	}
+	sortOption := int(req.GetSort())
+	if sortOption != service.LowPriceFirst {
+		sortOption = service.LowRunningTimeFirst
+	}
+	results, err := s.RailWayServiceImpl.SearchWithTwoTrans(ctx, req.GetFrom(), req.GetTo(), trainType(req.GetTrainType()), maxTransfers+1, resultNumber, sortOption, nil, "")
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toSearchResponse(results, pb.SortOption(sortOption)), nil
+}
+
+func (s *Server) GetTrain(ctx context.Context, req *pb.GetTrainRequest) (*pb.Train, error) {
+	var (
+		detail *service.TrainDetail
+		err    error
+	)
+	switch {
+	case req.GetTrainNo() != "":
+		detail, err = s.RailWayServiceImpl.GetTrain(ctx, req.GetTrainNo())
+	case req.GetTrainNumber() != "":
+		detail, err = s.RailWayServiceImpl.GetTrainByNumber(ctx, req.GetTrainNumber())
+	default:
+		return nil, status.Error(codes.InvalidArgument, "train_no or train_number is required")
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	train := &pb.Train{
+		TrainNumber: detail.TrainNumber,
+		TrainNo:     detail.TrainNo,
+		HighSpeed:   detail.IsHighSpeed == 1,
+	}
+	for _, stop := range detail.Stops {
+		train.Stops = append(train.Stops, &pb.Stop{
+			Station:         stop.Station,
+			ArrivalTime:     stop.ArrivalTime,
+			DepartureTime:   stop.DepartureTime,
+			Day:             uint32(stop.Day),
+			PriceFromOrigin: stop.PriceFromOrigin,
+		})
+	}
+	return train, nil
+}
+
+func checkSearchRequest(req *pb.SearchRequest) error {
+	if req.GetFrom() == "" || req.GetTo() == "" {
+		return status.Error(codes.InvalidArgument, "from and to are required")
+	}
+	return nil
+}
+
+func trainType(t pb.TrainType) string {
+	switch t {
+	case pb.TrainType_TRAIN_TYPE_HIGH_SPEED:
+		return service.OnlyHighSpeed
+	case pb.TrainType_TRAIN_TYPE_NORMAL:
+		return service.OnlyLowSpeed
+	default:
+		return service.Default
+	}
+}
+
+// toStatus 将服务层的错误转换为 gRPC 状态码
+func toStatus(err error) error {
+	var unresolved *service.UnresolvedStationError
+	if errors.As(err, &unresolved) {
+		names := make([]string, 0, len(unresolved.Suggestions))
+		for _, suggestion := range unresolved.Suggestions {
+			names = append(names, suggestion.Name)
+		}
+		return status.Errorf(codes.NotFound, "station not found: %s, suggestions: %s", unresolved.Input, strings.Join(names, ","))
+	}
+	switch err.Error() {
+	case "trainNotFind":
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// toSearchResponse 结果的键形如 “G1/G2/总耗时”，按总耗时或总票价排序后输出
+func toSearchResponse(results map[string][]dao.RailWay, sortOption pb.SortOption) *pb.SearchResponse {
+	resp := &pb.SearchResponse{}
+	for key, railWays := range results {
+		if len(railWays) == 0 {
+			continue
+		}
+		keyStrings := strings.Split(key, "/")
+		totalMinutes, _ := strconv.ParseInt(keyStrings[len(keyStrings)-1], 10, 64)
+		journey := &pb.Journey{Id: key, TotalMinutes: totalMinutes}
+		for _, railWay := range railWays {
+			journey.TotalPrice = journey.TotalPrice + railWay.Price
+			journey.Legs = append(journey.Legs, toLeg(railWay))
+		}
+		resp.Journeys = append(resp.Journeys, journey)
+	}
+	sort.Slice(resp.Journeys, func(i, j int) bool {
+		a, b := resp.Journeys[i], resp.Journeys[j]
+		switch sortOption {
+		case pb.SortOption_SORT_OPTION_LOW_PRICE:
+			return a.TotalPrice < b.TotalPrice
+		case pb.SortOption_SORT_OPTION_HIGH_PRICE:
+			return a.TotalPrice > b.TotalPrice
+		case pb.SortOption_SORT_OPTION_HIGH_RUNNING_TIME:
+			return a.TotalMinutes > b.TotalMinutes
+		case pb.SortOption_SORT_OPTION_EARLY_DEPARTURE, pb.SortOption_SORT_OPTION_LATE_DEPARTURE:
+			aTime, _ := service.GetTime(a.Legs[0].DepartureTime)
+			bTime, _ := service.GetTime(b.Legs[0].DepartureTime)
+			if sortOption == pb.SortOption_SORT_OPTION_LATE_DEPARTURE {
+				return aTime > bTime
+			}
+			return aTime < bTime
+		default:
+			return a.TotalMinutes < b.TotalMinutes
+		}
+	})
+	return resp
+}
+
+func toLeg(railWay dao.RailWay) *pb.Leg {
+	runningMinutes, _ := service.GetTime(railWay.RunningTime)
+	return &pb.Leg{
+		TrainNumber:      railWay.TrainNumber,
+		TrainNo:          railWay.TrainNo,
+		DepartureStation: railWay.DepartureStation,
+		DepartureTime:    railWay.DepartureTime,
+		ArrivalStation:   railWay.ArrivalStation,
+		ArrivalTime:      railWay.ArrivalTime,
+		RunningMinutes:   runningMinutes,
+		ArrivalDay:       uint32(railWay.ArrivalDay),
+		HighSpeed:        railWay.IsHighSpeed == 1,
+		Price:            railWay.Price,
+		Fares:            service.Fares(railWay),
+	}
+}
@@ -0,0 +1,281 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"log/slog"
+	"net"
+	"railway/dao"
+	"railway/rpc/pb"
+	"railway/service"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubRailWayDAO 内存中的时刻表，只实现查询用到的方法
+type stubRailWayDAO struct {
+	dao.RailWayDAO
+	railWays []dao.RailWay
+}
+
+func (s *stubRailWayDAO) filter(match func(dao.RailWay) bool) []dao.RailWay {
+	result := make([]dao.RailWay, 0)
+	for _, railWay := range s.railWays {
+		if match(railWay) {
+			result = append(result, railWay)
+		}
+	}
+	return result
+}
+
+func (s *stubRailWayDAO) GetRailWayByTrainNumber(_ context.Context, trainNumber string) ([]dao.RailWay, error) {
+	return s.filter(func(r dao.RailWay) bool { return r.TrainNumber == trainNumber }), nil
+}
+
+func (s *stubRailWayDAO) GetRailWayByTrainNo(_ context.Context, trainNo string) ([]dao.RailWay, error) {
+	return s.filter(func(r dao.RailWay) bool { return r.TrainNo == trainNo }), nil
+}
+
+func (s *stubRailWayDAO) GetRailWayByDepartureStation(_ context.Context, name string) ([]dao.RailWay, error) {
+	return s.filter(func(r dao.RailWay) bool { return r.DepartureStation == name }), nil
+}
+
+func (s *stubRailWayDAO) GetRailWayByArrivalStation(_ context.Context, name string) ([]dao.RailWay, error) {
+	return s.filter(func(r dao.RailWay) bool { return r.ArrivalStation == name }), nil
+}
+
+func (s *stubRailWayDAO) GetRailWayByDepartureStationWithoutArrivalStation(_ context.Context, departureName, arrivalName string) ([]dao.RailWay, error) {
+	return s.filter(func(r dao.RailWay) bool {
+		return r.DepartureStation == departureName && r.ArrivalStation != arrivalName
+	}), nil
+}
+
+func (s *stubRailWayDAO) GetRailWayByArrivalStationWithoutDepartureStation(_ context.Context, departureName, arrivalName string) ([]dao.RailWay, error) {
+	return s.filter(func(r dao.RailWay) bool {
+		return r.DepartureStation != departureName && r.ArrivalStation == arrivalName
+	}), nil
+}
+
+func (s *stubRailWayDAO) GetRailWayByDepartureStationAndArrivalStation(_ context.Context, departureName, arrivalName string) ([]dao.RailWay, error) {
+	return s.filter(func(r dao.RailWay) bool {
+		return r.DepartureStation == departureName && r.ArrivalStation == arrivalName
+	}), nil
+}
+
+func (s *stubRailWayDAO) GetRailWayByDepartureStationAndArrivalStationAndTrainNo(_ context.Context, departureName, arrivalName, trainNo string) (*dao.RailWay, error) {
+	for _, railWay := range s.railWays {
+		if railWay.DepartureStation == departureName && railWay.ArrivalStation == arrivalName && railWay.TrainNo == trainNo {
+			return &railWay, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *stubRailWayDAO) CountTrainsByStation(context.Context) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for _, railWay := range s.railWays {
+		counts[railWay.DepartureStation]++
+	}
+	return counts, nil
+}
+
+type stubStationDAO struct {
+	dao.StationDAO
+	stations []dao.Station
+}
+
+func (s *stubStationDAO) GetAllStations(context.Context) ([]dao.Station, error) {
+	return s.stations, nil
+}
+
+func leg(trainNumber, from, to, departure, arrival, running string, price float64) dao.RailWay {
+	return dao.RailWay{
+		TrainNumber:      trainNumber,
+		TrainNo:          trainNumber + "00",
+		DepartureStation: from,
+		ArrivalStation:   to,
+		DepartureTime:    departure,
+		ArrivalTime:      arrival,
+		RunningTime:      running,
+		Price:            price,
+		ZEPrice:          price,
+		ZYPrice:          price * 1.6,
+		IsHighSpeed:      1,
+	}
+}
+
+// newTestClient 在 bufconn 上启动 Serve，返回连接到它的客户端，opts 用于添加拦截器
+func newTestClient(t *testing.T, opts ...grpc.ServerOption) pb.RailwayServiceClient {
+	t.Helper()
+	railWays := []dao.RailWay{
+		leg("G1", "北京南", "济南西", "08:00", "09:30", "01:30", 200),
+		leg("G1", "北京南", "上海虹桥", "08:00", "12:30", "04:30", 600),
+		leg("G1", "济南西", "上海虹桥", "09:35", "12:30", "02:55", 400),
+		leg("G3", "北京南", "济南西", "10:00", "11:30", "01:30", 210),
+		leg("G5", "济南西", "上海虹桥", "12:00", "15:00", "03:00", 390),
+	}
+	stations := []dao.Station{
+		{StationName: "北京南", StationCode: "VNP", StationPinyin: "beijingnan", StationFirstLetter: "bjn", CityName: "北京"},
+		{StationName: "济南西", StationCode: "JGK", StationPinyin: "jinanxi", StationFirstLetter: "jnx", CityName: "济南"},
+		{StationName: "上海虹桥", StationCode: "AOH", StationPinyin: "shanghaihongqiao", StationFirstLetter: "shhq", CityName: "上海"},
+	}
+	service.KeyStation = make(map[string]dao.Station)
+	for _, station := range stations {
+		service.KeyStation[station.StationName] = station
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	impl := service.NewRailwayService(&stubRailWayDAO{railWays: railWays}, &stubStationDAO{stations: stations}, nil, nil, logger)
+	if err := impl.InitBuildGraph(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := NewGRPCServer(impl, opts...)
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(grpcServer, listener)
+	}()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		grpcServer.GracefulStop()
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	return pb.NewRailwayServiceClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("LookupStations", func(t *testing.T) {
+		resp, err := client.LookupStations(ctx, &pb.LookupStationsRequest{Keyword: "jnx"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.GetStations()) == 0 || resp.GetStations()[0].GetName() != "济南西" {
+			t.Fatalf("stations = %v, want 济南西 first", resp.GetStations())
+		}
+		_, err = client.LookupStations(ctx, &pb.LookupStationsRequest{})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("empty keyword: got %v, want InvalidArgument", err)
+		}
+	})
+
+	t.Run("SearchDirect", func(t *testing.T) {
+		resp, err := client.SearchDirect(ctx, &pb.SearchRequest{From: "北京南", To: "上海虹桥"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.GetJourneys()) != 1 || resp.GetJourneys()[0].GetLegs()[0].GetTrainNumber() != "G1" {
+			t.Fatalf("journeys = %v, want G1", resp.GetJourneys())
+		}
+		_, err = client.SearchDirect(ctx, &pb.SearchRequest{From: "北京南", To: "不存在的站"})
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("unknown station: got %v, want NotFound", err)
+		}
+	})
+
+	t.Run("SearchOneTransfer", func(t *testing.T) {
+		resp, err := client.SearchOneTransfer(ctx, &pb.SearchRequest{From: "北京南", To: "上海虹桥"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, journey := range resp.GetJourneys() {
+			if len(journey.GetLegs()) == 2 && journey.GetLegs()[0].GetTrainNumber() == "G3" && journey.GetLegs()[1].GetTrainNumber() == "G5" {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("journeys = %v, want G3 then G5", resp.GetJourneys())
+		}
+	})
+
+	t.Run("SearchMultiTransfer", func(t *testing.T) {
+		resp, err := client.SearchMultiTransfer(ctx, &pb.SearchRequest{From: "北京南", To: "上海虹桥", MaxTransfers: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.GetJourneys()) == 0 {
+			t.Fatal("no journeys")
+		}
+		fastest := resp.GetJourneys()[0]
+		if fastest.GetLegs()[0].GetTrainNumber() != "G1" || fastest.GetTotalMinutes() != 270 {
+			t.Fatalf("fastest = %v, want G1 in 270 minutes", fastest)
+		}
+	})
+
+	t.Run("GetTrain", func(t *testing.T) {
+		train, err := client.GetTrain(ctx, &pb.GetTrainRequest{TrainNumber: "G1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stops := make([]string, 0, len(train.GetStops()))
+		for _, stop := range train.GetStops() {
+			stops = append(stops, stop.GetStation())
+		}
+		if want := []string{"北京南", "济南西", "上海虹桥"}; !reflect.DeepEqual(stops, want) {
+			t.Fatalf("stops = %v, want %v", stops, want)
+		}
+		_, err = client.GetTrain(ctx, &pb.GetTrainRequest{TrainNo: "Z100"})
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("unknown train: got %v, want NotFound", err)
+		}
+	})
+}
+
+// TestConcurrentSearch 多个换乘图搜索同时进行，结果与单独搜索一致
+func TestConcurrentSearch(t *testing.T) {
+	client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.SearchMultiTransfer(ctx, &pb.SearchRequest{From: "北京南", To: "上海虹桥", MaxTransfers: 2})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if len(resp.GetJourneys()) == 0 || resp.GetJourneys()[0].GetTotalMinutes() != 270 {
+				errs <- fmt.Errorf("journeys = %v, want G1 in 270 minutes first", resp.GetJourneys())
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestLegFares gRPC 与 HTTP 的各席别票价都来自 service.Fares
+func TestLegFares(t *testing.T) {
+	railWay := leg("G1", "北京南", "上海虹桥", "08:00", "12:30", "04:30", 600)
+	if got, want := toLeg(railWay).GetFares(), service.Fares(railWay); !reflect.DeepEqual(got, want) {
+		t.Fatalf("fares = %v, want %v", got, want)
+	}
+}
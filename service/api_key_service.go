@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"railway/dao"
+	"railway/logging"
+	"sync"
+	"time"
+)
+
+// APIKeyCacheTTL 校验通过的 key 在内存中缓存的时间，停用或修改配额最迟在该时间后生效
+const APIKeyCacheTTL = time.Minute
+
+// UnknownAPIKeyCacheTTL 数据库中不存在的 key 在内存中记住的时间，期间重复使用不再访问数据库
+const UnknownAPIKeyCacheTTL = 30 * time.Second
+
+// maxUnknownAPIKeys 记住的不存在的 key 的上限，超过时先清理过期项，仍超过则全部清空，避免随机 key 撑大内存
+const maxUnknownAPIKeys = 10000
+
+// APIKeyPrefix 生成的 key 均以该前缀开头
+const APIKeyPrefix = "rk_"
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalidAPIKey")
+	ErrDisabledAPIKey = errors.New("disabledAPIKey")
+
+	APIKeyDAO dao.APIKeyDAO
+	K         APIKeyServiceImpl
+)
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, name string, ratePerSecond, burst float64, dailyQuota int64, admin bool) (string, *dao.APIKey, error)
+	Authenticate(ctx context.Context, plain string) (*dao.APIKey, error)
+}
+
+type APIKeyServiceImpl struct {
+	APIKeyDAO dao.APIKeyDAO
+	Logger    *slog.Logger
+
+	mu      *sync.Mutex
+	cache   map[string]cachedAPIKey
+	unknown map[string]time.Time //不存在的 key 的哈希及其过期时间
+}
+
+type cachedAPIKey struct {
+	key       dao.APIKey
+	expiresAt time.Time
+}
+
+var _ APIKeyService = (*APIKeyServiceImpl)(nil)
+
+func NewAPIKeyService(APIKeyDAO dao.APIKeyDAO, logger *slog.Logger) APIKeyServiceImpl {
+	return APIKeyServiceImpl{
+		APIKeyDAO: APIKeyDAO,
+		Logger:    logger,
+		mu:        &sync.Mutex{},
+		cache:     make(map[string]cachedAPIKey),
+		unknown:   make(map[string]time.Time),
+	}
+}
+
+// HashAPIKey 数据库中只保存 key 的 SHA-256
+func HashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey 生成新 key 并保存其哈希，返回的明文只有这一次机会取得；admin 为 true 时可访问 /admin 接口
+func (s *APIKeyServiceImpl) CreateAPIKey(ctx context.Context, name string, ratePerSecond, burst float64, dailyQuota int64, admin bool) (string, *dao.APIKey, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	plain := APIKeyPrefix + hex.EncodeToString(buf)
+	key := &dao.APIKey{
+		Name:          name,
+		Prefix:        plain[:len(APIKeyPrefix)+6],
+		KeyHash:       HashAPIKey(plain),
+		RatePerSecond: ratePerSecond,
+		Burst:         burst,
+		DailyQuota:    dailyQuota,
+		Admin:         admin,
+	}
+	if err := s.APIKeyDAO.CreateAPIKey(ctx, key); err != nil {
+		logging.FromContext(ctx, s.Logger).Error("create api key failed", "name", name, "err", err)
+		return "", nil, err
+	}
+	return plain, key, nil
+}
+
+// Authenticate 校验 key，命中缓存时不访问数据库；不存在的 key 在 UnknownAPIKeyCacheTTL 内直接拒绝
+func (s *APIKeyServiceImpl) Authenticate(ctx context.Context, plain string) (*dao.APIKey, error) {
+	if plain == "" {
+		return nil, ErrInvalidAPIKey
+	}
+	keyHash := HashAPIKey(plain)
+	now := time.Now()
+	s.mu.Lock()
+	cached, ok := s.cache[keyHash]
+	unknownUntil, unknown := s.unknown[keyHash]
+	s.mu.Unlock()
+	if unknown && now.Before(unknownUntil) {
+		return nil, ErrInvalidAPIKey
+	}
+	if !ok || now.After(cached.expiresAt) {
+		key, err := s.APIKeyDAO.GetAPIKeyByHash(ctx, keyHash)
+		if err != nil {
+			logging.FromContext(ctx, s.Logger).Error("query failed", "method", "Authenticate", "err", err)
+			return nil, err
+		}
+		s.mu.Lock()
+		if key == nil {
+			delete(s.cache, keyHash)
+			s.rememberUnknown(keyHash, now)
+		} else {
+			// 存在的 key 才放进 cache，不存在的 key 只在 unknown 中短暂记住且有数量上限
+			s.cache[keyHash] = cachedAPIKey{key: *key, expiresAt: time.Now().Add(APIKeyCacheTTL)}
+			delete(s.unknown, keyHash)
+		}
+		s.mu.Unlock()
+		if key == nil {
+			return nil, ErrInvalidAPIKey
+		}
+		cached.key = *key
+	}
+	if cached.key.Disabled {
+		return nil, ErrDisabledAPIKey
+	}
+	key := cached.key
+	return &key, nil
+}
+
+// rememberUnknown 需持有 s.mu
+func (s *APIKeyServiceImpl) rememberUnknown(keyHash string, now time.Time) {
+	if len(s.unknown) >= maxUnknownAPIKeys {
+		for hash, expiresAt := range s.unknown {
+			if now.After(expiresAt) {
+				delete(s.unknown, hash)
+			}
+		}
+		if len(s.unknown) >= maxUnknownAPIKeys {
+			clear(s.unknown)
+		}
+	}
+	s.unknown[keyHash] = now.Add(UnknownAPIKeyCacheTTL)
+}
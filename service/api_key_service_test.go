@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"railway/dao"
+	"testing"
+)
+
+// countingAPIKeyDAO 记录按哈希查询的次数
+type countingAPIKeyDAO struct {
+	dao.APIKeyDAO
+	keys    map[string]dao.APIKey
+	lookups int
+}
+
+func (c *countingAPIKeyDAO) GetAPIKeyByHash(_ context.Context, keyHash string) (*dao.APIKey, error) {
+	c.lookups++
+	key, ok := c.keys[keyHash]
+	if !ok {
+		return nil, nil
+	}
+	return &key, nil
+}
+
+// TestAuthenticateUnknownKey 不存在的 key 在 UnknownAPIKeyCacheTTL 内只查询一次数据库
+func TestAuthenticateUnknownKey(t *testing.T) {
+	keys := &countingAPIKeyDAO{keys: map[string]dao.APIKey{HashAPIKey("rk_known"): {Name: "known"}}}
+	keyService := NewAPIKeyService(keys, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for i := 0; i < 3; i++ {
+		if _, err := keyService.Authenticate(context.Background(), "rk_unknown"); err != ErrInvalidAPIKey {
+			t.Fatalf("err = %v, want %v", err, ErrInvalidAPIKey)
+		}
+	}
+	if keys.lookups != 1 {
+		t.Fatalf("lookups = %d, want 1", keys.lookups)
+	}
+	for i := 0; i < 3; i++ {
+		if key, err := keyService.Authenticate(context.Background(), "rk_known"); err != nil || key.Name != "known" {
+			t.Fatalf("key = %v, err = %v", key, err)
+		}
+	}
+	if keys.lookups != 2 {
+		t.Fatalf("lookups = %d, want 2", keys.lookups)
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"railway/dao"
+)
+
+var CityDAO dao.CityDAO
+
+// DownLoadCity 由车站表中的城市代码生成城市与城市-车站从属关系，可重复执行
+func DownLoadCity() error {
+	stations, err := StationService.GetAllStations(context.Background())
+	if err != nil {
+		slog.Error("get stations failed", "err", err)
+		return err
+	}
+	cities := make([]dao.City, 0)
+	members := make([]dao.CityStation, 0, len(stations))
+	seen := make(map[string]bool)
+	for _, station := range stations {
+		if station.CityCode == "" || station.StationCode == "" {
+			continue
+		}
+		if !seen[station.CityCode] {
+			seen[station.CityCode] = true
+			cities = append(cities, dao.City{CityCode: station.CityCode, CityName: station.CityName})
+		}
+		members = append(members, dao.CityStation{CityCode: station.CityCode, StationCode: station.StationCode})
+	}
+	if err := CityDAO.SaveCities(context.Background(), cities); err != nil {
+		slog.Error("save cities failed", "err", err)
+		return err
+	}
+	if err := CityDAO.SaveCityStations(context.Background(), members); err != nil {
+		slog.Error("save city stations failed", "err", err)
+		return err
+	}
+	slog.Info("DownLoadCity success", "cities", len(cities), "stations", len(members))
+	bumpTimetableVersion()
+	return nil
+}
+
+// ListCities keyword 为空时返回全部城市，否则按城市名、全拼或首字母前缀联想
+func (r *RailWayServiceImpl) ListCities(ctx context.Context, keyword string) ([]dao.City, error) {
+	if keyword == "" {
+		cities, err := r.CityDAO.GetAllCities(ctx)
+		if err != nil {
+			r.logger(ctx).Error("query failed", "method", "ListCities", "err", err)
+			return nil, err
+		}
+		return cities, nil
+	}
+	suggestions, err := r.Autocomplete(ctx, keyword, 0)
+	if err != nil {
+		return nil, err
+	}
+	cities := make([]dao.City, 0)
+	for _, suggestion := range suggestions {
+		if suggestion.IsCity {
+			cities = append(cities, dao.City{CityCode: suggestion.CityCode, CityName: suggestion.Name})
+		}
+	}
+	return cities, nil
+}
+
+// GetCity 返回城市及其下属车站
+func (r *RailWayServiceImpl) GetCity(ctx context.Context, cityCode string) (*dao.City, []dao.Station, error) {
+	city, err := r.CityDAO.GetCityByCode(ctx, cityCode)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "GetCity", "city_code", cityCode, "err", err)
+		return nil, nil, err
+	}
+	if city == nil {
+		return nil, nil, errors.New("cityNotFind")
+	}
+	stations, err := r.CityDAO.GetStationsByCityCode(ctx, cityCode)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "GetCity", "city_code", cityCode, "err", err)
+		return nil, nil, err
+	}
+	return city, stations, nil
+}
+
+// GetCityByName 供仍使用城市名的旧接口将城市名转换为城市代码
+func (r *RailWayServiceImpl) GetCityByName(ctx context.Context, cityName string) (*dao.City, error) {
+	city, err := r.CityDAO.GetCityByName(ctx, cityName)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "GetCityByName", "city", cityName, "err", err)
+		return nil, err
+	}
+	if city == nil {
+		return nil, errors.New("cityNotFind")
+	}
+	return city, nil
+}
+
+// CityStationNames 城市下属车站的站名，用于将城市展开为车站对
+func (r *RailWayServiceImpl) CityStationNames(ctx context.Context, cityCode string) ([]string, error) {
+	_, stations, err := r.GetCity(ctx, cityCode)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(stations))
+	for _, station := range stations {
+		names = append(names, station.StationName)
+	}
+	return names, nil
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"railway/dao"
+	"railway/fare"
+)
+
+// FareEngine 计算应付金额的规则引擎，启动时按配置中的规则文件替换
+var FareEngine = fare.Default()
+
+// ApplyFares 按旅客类型计算每段的应付金额写入 Payable 与 FareRules，返回行程合计。
+// 各段的 Price 需已按席别计价（见 ApplySeatClasses）
+func ApplyFares(railways []dao.RailWay, passenger fare.PassengerType) float64 {
+	legs := make([]fare.Leg, 0, len(railways))
+	for _, railway := range railways {
+		legs = append(legs, fare.Leg{
+			TrainNumber: railway.TrainNumber,
+			From:        railway.DepartureStation,
+			To:          railway.ArrivalStation,
+			SeatClass:   railway.SeatClass,
+			BaseFare:    railway.Price,
+		})
+	}
+	quote := FareEngine.Price(passenger, legs)
+	for i := range railways {
+		railways[i].Payable = quote.Legs[i].Amount
+		railways[i].FareRules = quote.Legs[i].Rules
+	}
+	return quote.Total
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"railway/dao"
+)
+
+// FareTable 同一车次任意两站之间的各席别票价：有 O/D 记录时直接使用，
+// 否则用始发站到两站的累计票价相减
+type FareTable struct {
+	od         map[[2]string]dao.RailWay
+	origin     string
+	fromOrigin map[string]dao.RailWay
+}
+
+// TrainFares 建图时记录的各车次关键站点之间的票价，键为 TrainNo；临时加入图中的车次的票价记在 searchGraph 中
+var TrainFares = make(map[string]*FareTable)
+
+// NewFareTable 由同一 TrainNo 的全部 O/D 记录构建，能还原始发站时同时记录累计票价
+func NewFareTable(railWays []dao.RailWay) *FareTable {
+	table := &FareTable{od: make(map[[2]string]dao.RailWay)}
+	for _, railWay := range railWays {
+		table.Add(railWay)
+	}
+	detail, err := buildTrainDetail("", railWays)
+	if err != nil {
+		return table
+	}
+	table.origin = detail.Stops[0].Station
+	table.fromOrigin = make(map[string]dao.RailWay)
+	for _, railWay := range railWays {
+		if railWay.DepartureStation == table.origin {
+			table.fromOrigin[railWay.ArrivalStation] = railWay
+		}
+	}
+	return table
+}
+
+// Add 记录一条 O/D 票价
+func (t *FareTable) Add(railWay dao.RailWay) {
+	t.od[[2]string{railWay.DepartureStation, railWay.ArrivalStation}] = railWay
+}
+
+// Fare 返回 from 到 to 的各席别票价，Price 为其中的最低价
+func (t *FareTable) Fare(from, to string) (dao.RailWay, bool) {
+	if railWay, ok := t.od[[2]string{from, to}]; ok {
+		return railWay, true
+	}
+	if t.fromOrigin == nil {
+		return dao.RailWay{}, false
+	}
+	end, ok := t.fromOrigin[to]
+	if !ok {
+		return dao.RailWay{}, false
+	}
+	if from == t.origin {
+		return end, true
+	}
+	start, ok := t.fromOrigin[from]
+	if !ok {
+		return dao.RailWay{}, false
+	}
+	fare := dao.RailWay{TrainNumber: end.TrainNumber, TrainNo: end.TrainNo, DepartureStation: from, ArrivalStation: to, IsHighSpeed: end.IsHighSpeed}
+	sold := false
+	for _, seatClass := range SeatClasses {
+		startPrice, endPrice := seatFare(start, seatClass), seatFare(end, seatClass)
+		if startPrice < 0.5 || endPrice <= startPrice {
+			continue
+		}
+		setSeatFare(&fare, seatClass, endPrice-startPrice)
+		sold = true
+	}
+	// to 在 from 之前或两站之间没有共同的席别
+	if !sold {
+		return dao.RailWay{}, false
+	}
+	fare.Price = GetLowPrice(fare)
+	return fare, true
+}
+
+// addTrainFare 建图时记录一条关键站点之间的票价
+func addTrainFare(tables map[string]*FareTable, railWay dao.RailWay) {
+	table, ok := tables[railWay.TrainNo]
+	if !ok {
+		table = &FareTable{od: make(map[[2]string]dao.RailWay)}
+		tables[railWay.TrainNo] = table
+	}
+	table.Add(railWay)
+}
+
+// throughPrice 同一车次从 from 乘到 to 按一张 O/D 车票计价，查不到票价时返回 fallback
+func (s *searchGraph) throughPrice(trainNo, from, to string, seatClasses []string, fallback float64) float64 {
+	for _, tables := range []map[string]*FareTable{s.templateTrainFares, TrainFares} {
+		if table, ok := tables[trainNo]; ok {
+			if fare, ok := table.Fare(from, to); ok {
+				price, _ := SeatPrice(fare, seatClasses)
+				return price
+			}
+		}
+	}
+	return fallback
+}
+
+// throughLeg 同一车次从 from 到 to 的一段：优先使用 O/D 记录，没有时由经停站时刻与累计票价还原，
+// 车次不经过两站时返回 nil
+func (r *RailWayServiceImpl) throughLeg(ctx context.Context, trainNo, from, to string) (*dao.RailWay, error) {
+	railWay, err := r.RailWayDAO.GetRailWayByDepartureStationAndArrivalStationAndTrainNo(ctx, from, to, trainNo)
+	if err != nil || railWay != nil {
+		return railWay, err
+	}
+	railWays, err := r.RailWayDAO.GetRailWayByTrainNo(ctx, trainNo)
+	if err != nil {
+		return nil, err
+	}
+	detail, err := buildTrainDetail(trainNo, railWays)
+	if err != nil {
+		return nil, nil
+	}
+	stops := detail.Between(from, to)
+	fare, ok := NewFareTable(railWays).Fare(from, to)
+	if len(stops) < 2 || !ok {
+		return nil, nil
+	}
+	first, last := stops[0], stops[len(stops)-1]
+	departure := stopMinutes(first.Day, first.DepartureTime)
+	if arrival := stopMinutes(first.Day, first.ArrivalTime); first.ArrivalTime != "" && departure < arrival {
+		departure = departure + 1440
+	}
+	runningTime := stopMinutes(last.Day, last.ArrivalTime) - departure
+	leg := fare
+	leg.ID = 0
+	leg.TrainNumber = detail.TrainNumber
+	leg.TrainNo = trainNo
+	leg.DepartureStation = from
+	leg.ArrivalStation = to
+	leg.DepartureTime = first.DepartureTime
+	leg.ArrivalTime = last.ArrivalTime
+	leg.RunningTime = fmt.Sprintf("%02d:%02d", runningTime/60, runningTime%60)
+	leg.ArrivalDay = uint((departure%1440 + runningTime) / 1440)
+	leg.IsHighSpeed = detail.IsHighSpeed
+	return &leg, nil
+}
+
+// GetLeg 同一车次从 from 到 to 的一段及其各席别票价，车次不经过两站时返回 nil
+func (r *RailWayServiceImpl) GetLeg(ctx context.Context, trainNo, from, to string) (*dao.RailWay, error) {
+	leg, err := r.throughLeg(ctx, trainNo, from, to)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "GetLeg", "train_no", trainNo, "err", err)
+	}
+	return leg, err
+}
+
+// throughTickets 行程中连续乘坐同一车次的几段合并为一张 O/D 车票，并按席别偏好重新计价
+func (r *RailWayServiceImpl) throughTickets(ctx context.Context, results map[string][]dao.RailWay, seatClasses []string) map[string][]dao.RailWay {
+	for key, railWays := range results {
+		merged := make([]dao.RailWay, 0, len(railWays))
+		changed := false
+		for _, railWay := range railWays {
+			last := len(merged) - 1
+			if last < 0 || merged[last].TrainNo != railWay.TrainNo {
+				merged = append(merged, railWay)
+				continue
+			}
+			leg, err := r.throughLeg(ctx, railWay.TrainNo, merged[last].DepartureStation, railWay.ArrivalStation)
+			if err != nil {
+				r.logger(ctx).Error("query failed", "method", "throughTickets", "train_no", railWay.TrainNo, "err", err)
+			}
+			if leg == nil {
+				merged = append(merged, railWay)
+				continue
+			}
+			merged[last] = *leg
+			changed = true
+		}
+		if changed {
+			results[key] = ApplySeatClasses(merged, seatClasses)
+		}
+	}
+	return results
+}
+
+// stopMinutes 经停站时刻换算为相对始发日零点的分钟数
+func stopMinutes(day uint, clock string) int64 {
+	minutes, _ := GetTime(clock)
+	return int64(day)*1440 + minutes
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"railway/dao"
+	"testing"
+)
+
+// fareRailWays 车次 T100：北京南 08:00 → 济南西 09:30/09:32 → 南京南 11:30/11:32 → 上海虹桥 12:30。
+// 始发站到各站有 O/D 记录，济南西→南京南与南京南→上海虹桥也有 O/D 记录，济南西→上海虹桥没有
+func fareRailWays() []dao.RailWay {
+	railWay := func(from, to, departure, arrival, running string, ze, zy float64) dao.RailWay {
+		return dao.RailWay{TrainNumber: "T1", TrainNo: "T100", DepartureStation: from, ArrivalStation: to,
+			DepartureTime: departure, ArrivalTime: arrival, RunningTime: running, Price: ze, ZEPrice: ze, ZYPrice: zy}
+	}
+	return []dao.RailWay{
+		railWay("北京南", "济南西", "08:00", "09:30", "01:30", 200, 0),
+		railWay("北京南", "南京南", "08:00", "11:30", "03:30", 450, 700),
+		railWay("北京南", "上海虹桥", "08:00", "12:30", "04:30", 550, 900),
+		railWay("济南西", "南京南", "09:32", "11:30", "01:58", 260, 400),
+		railWay("南京南", "上海虹桥", "11:32", "12:30", "00:58", 120, 190),
+	}
+}
+
+func TestFareTable(t *testing.T) {
+	table := NewFareTable(fareRailWays())
+	tests := []struct {
+		name     string
+		from, to string
+		ok       bool
+		ze, zy   float64
+	}{
+		{"od record", "济南西", "南京南", true, 260, 400},
+		{"from origin", "北京南", "南京南", true, 450, 700},
+		// 没有 O/D 记录时用始发站累计票价相减，始发站到济南西不售一等座，一等座视为不售
+		{"not from origin", "济南西", "上海虹桥", true, 350, 0},
+		{"reversed", "上海虹桥", "济南西", false, 0, 0},
+		{"missing arrival stop", "济南西", "杭州东", false, 0, 0},
+		{"missing departure stop", "杭州东", "上海虹桥", false, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fare, ok := table.Fare(test.from, test.to)
+			if ok != test.ok {
+				t.Fatalf("ok = %v, want %v", ok, test.ok)
+			}
+			if !ok {
+				return
+			}
+			if fare.ZEPrice != test.ze || fare.ZYPrice != test.zy || fare.Price != test.ze {
+				t.Fatalf("fare = ze %v zy %v price %v, want ze %v zy %v", fare.ZEPrice, fare.ZYPrice, fare.Price, test.ze, test.zy)
+			}
+			if fare.DepartureStation != test.from || fare.ArrivalStation != test.to {
+				t.Fatalf("fare is for %s → %s", fare.DepartureStation, fare.ArrivalStation)
+			}
+		})
+	}
+}
+
+func TestThroughTickets(t *testing.T) {
+	railWays := fareRailWays()
+	other := dao.RailWay{TrainNumber: "G5", TrainNo: "G500", DepartureStation: "南京南", ArrivalStation: "上海虹桥",
+		DepartureTime: "12:00", ArrivalTime: "13:00", RunningTime: "01:00", Price: 150, ZEPrice: 150}
+	impl := NewRailwayService(&memoryRailWayDAO{railWays: append(railWays, other)}, nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	total := func(legs []dao.RailWay) float64 {
+		sum := 0.0
+		for _, leg := range legs {
+			sum = sum + leg.Price
+		}
+		return sum
+	}
+	tests := []struct {
+		name        string
+		legs        []dao.RailWay
+		seatClasses []string
+		wantLegs    int
+		wantTotal   float64
+	}{
+		// 三段合并为北京南→上海虹桥一张票：550，而不是 200 + 260 + 120
+		{"merged od", []dao.RailWay{railWays[0], railWays[3], railWays[4]}, nil, 1, 550},
+		{"merged od seat class", []dao.RailWay{railWays[0], railWays[3], railWays[4]}, []string{SeatZY}, 1, 900},
+		// 济南西→上海虹桥没有 O/D 记录，按累计票价相减：550 - 200
+		{"merged not from origin", []dao.RailWay{railWays[3], railWays[4]}, nil, 1, 350},
+		// 换乘其他车次的段不合并，总价不变
+		{"unmerged", []dao.RailWay{railWays[1], other}, nil, 2, 450 + 150},
+		// 只合并连续乘坐 T100 的两段：450 + 150，而不是 200 + 260 + 150
+		{"partly merged", []dao.RailWay{railWays[0], railWays[3], other}, nil, 2, 450 + 150},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			legs := append([]dao.RailWay(nil), test.legs...)
+			results := impl.throughTickets(context.Background(), map[string][]dao.RailWay{"journey": legs}, test.seatClasses)
+			merged := results["journey"]
+			if len(merged) != test.wantLegs {
+				t.Fatalf("legs = %v, want %d legs", merged, test.wantLegs)
+			}
+			if got := total(merged); got != test.wantTotal {
+				t.Fatalf("total = %v, want %v", got, test.wantTotal)
+			}
+		})
+	}
+
+	results := impl.throughTickets(context.Background(), map[string][]dao.RailWay{"journey": {railWays[3], railWays[4]}}, nil)
+	leg := results["journey"][0]
+	if leg.DepartureStation != "济南西" || leg.ArrivalStation != "上海虹桥" || leg.DepartureTime != "09:32" ||
+		leg.ArrivalTime != "12:30" || leg.RunningTime != "02:58" || leg.TrainNumber != "T1" {
+		t.Fatalf("merged leg = %+v", leg)
+	}
+}
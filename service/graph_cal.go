@@ -2,36 +2,64 @@ package service
 
 import (
 	"container/heap"
-	"fmt"
-	"log"
+	"context"
+	"log/slog"
 	"math"
 	"railway/dao"
+	"railway/metrics"
 	"strconv"
 	"strings"
 )
 
-var findbugs AnalyseTrans
+// cancelCheckInterval 最短路每弹出多少个点检查一次 ctx 是否已取消
+const cancelCheckInterval = 1024
 
-func (r *RailWayServiceImpl) AddNewStation(stationName string, isDeparture bool, startTime int64) error {
+// searchGraph 一次 graphSearch 的临时点边、票价与最短路距离。Graph 与 TrainFares 在搜索中只读，
+// 每次搜索只写自己的 searchGraph，因此不同的搜索可以并发
+type searchGraph struct {
+	templateGraph      map[string][]dao.RailWay  //临时加入的出发站、到达站与途经站的点和边
+	templateTrainFares map[string]*FareTable     //临时加入图中的车次的票价
+	dist               []map[string]AnalyseTrans //按换乘次数分层的最短路距离，每次 Dijkstra 重新初始化
+}
+
+func newSearchGraph() *searchGraph {
+	return &searchGraph{
+		templateGraph:      make(map[string][]dao.RailWay),
+		templateTrainFares: make(map[string]*FareTable),
+	}
+}
+
+// keyStationGraph 一次建图的结果，构建完成后整体替换 Graph、TrainFares 与 KeyStationDeparture、KeyStationArrival
+type keyStationGraph struct {
+	graph      map[string][]dao.RailWay
+	fares      map[string]*FareTable
+	departures map[string][]dao.RailWay
+	arrivals   map[string][]dao.RailWay
+}
+
+func (r *RailWayServiceImpl) AddNewStation(ctx context.Context, search *searchGraph, stationName string, isDeparture bool, startTime int64) error {
 	if isDeparture {
-		departureTrains, err := r.RailWayDAO.GetRailWayByDepartureStation(stationName)
+		departureTrains, err := r.RailWayDAO.GetRailWayByDepartureStation(ctx, stationName)
 		if err != nil {
-			log.Fatal(err)
+			r.logger(ctx).Error("query failed", "method", "AddNewStation", "station", stationName, "err", err)
 			return err
 		}
+		for _, train := range departureTrains {
+			addTrainFare(search.templateTrainFares, train)
+		}
 		_, isKey := KeyStation[stationName]
-		departureTrains = getOneKeyTrains(departureTrains, false, 0, false, isKey)
+		departureTrains = getOneKeyTrains(departureTrains, nil, 0, false, isKey)
 		for _, train := range departureTrains {
 			dTime, _ := GetTime(train.DepartureTime)
 			if startTime <= dTime {
-				value, ok := TemplateGraph[StartIndex]
+				value, ok := search.templateGraph[StartIndex]
 				if ok {
 					value = append(value, train)
-					TemplateGraph[StartIndex] = value
+					search.templateGraph[StartIndex] = value
 				} else {
-					TemplateGraph[StartIndex] = []dao.RailWay{train}
+					search.templateGraph[StartIndex] = []dao.RailWay{train}
 				}
-				AddStationTrans(train, KeyStationDeparture[train.ArrivalStation], DefaultStopTime)
+				AddStationTrans(search.templateGraph, train, KeyStationDeparture[train.ArrivalStation], DefaultStopTime)
 			}
 		}
 	} else {
@@ -39,20 +67,23 @@ func (r *RailWayServiceImpl) AddNewStation(stationName string, isDeparture bool,
 		if ok {
 			return nil
 		}
-		arrivalTrains, err := r.RailWayDAO.GetRailWayByArrivalStation(stationName)
+		arrivalTrains, err := r.RailWayDAO.GetRailWayByArrivalStation(ctx, stationName)
 		if err != nil {
-			log.Fatal(err)
+			r.logger(ctx).Error("query failed", "method", "AddNewStation", "station", stationName, "err", err)
 			return err
 		}
+		for _, train := range arrivalTrains {
+			addTrainFare(search.templateTrainFares, train)
+		}
 		_, isKey := KeyStation[stationName]
-		arrivalTrains = getOneKeyTrains(arrivalTrains, false, 0, false, isKey)
-		getOneKeyTrains(arrivalTrains, true, 0, true, isKey)
-		getOneKeyTrains(arrivalTrains, true, 1, true, isKey)
-		getOneKeyTrains(arrivalTrains, true, 2, true, isKey)
+		arrivalTrains = getOneKeyTrains(arrivalTrains, nil, 0, false, isKey)
+		getOneKeyTrains(arrivalTrains, search.templateGraph, 0, true, isKey)
+		getOneKeyTrains(arrivalTrains, search.templateGraph, 1, true, isKey)
+		getOneKeyTrains(arrivalTrains, search.templateGraph, 2, true, isKey)
 		if !isKey {
 			for _, train := range arrivalTrains {
 				for _, arrivalTrain := range KeyStationArrival[train.DepartureStation] {
-					turnADToEdges(arrivalTrain, train, 2, DefaultStopTime, true)
+					turnADToEdges(search.templateGraph, arrivalTrain, train, 2, DefaultStopTime)
 				}
 			}
 		}
@@ -60,20 +91,20 @@ func (r *RailWayServiceImpl) AddNewStation(stationName string, isDeparture bool,
 	return nil
 }
 
-func AddStationTrans(arriveTrain dao.RailWay, departureKeyTrains []dao.RailWay, limitStopTime int64) {
+func AddStationTrans(graph map[string][]dao.RailWay, arriveTrain dao.RailWay, departureKeyTrains []dao.RailWay, limitStopTime int64) {
 	if len(departureKeyTrains) == 0 {
 		return
 	}
 	for _, train := range departureKeyTrains {
 		if train.TrainNo == arriveTrain.TrainNo {
-			turnADToEdges(arriveTrain, train, 2, 0, true)
+			turnADToEdges(graph, arriveTrain, train, 2, 0)
 		}
 	}
 	for _, train := range departureKeyTrains {
 		aTime, _ := GetTime(arriveTrain.ArrivalTime)
 		dTime, _ := GetTime(train.DepartureTime)
 		if aTime+limitStopTime < dTime && train.TrainNo != arriveTrain.TrainNo {
-			turnADToEdges(arriveTrain, train, 2, limitStopTime, true)
+			turnADToEdges(graph, arriveTrain, train, 2, limitStopTime)
 			return
 		}
 	}
@@ -82,70 +113,121 @@ func AddStationTrans(arriveTrain dao.RailWay, departureKeyTrains []dao.RailWay,
 		aTime, _ := GetTime(arriveTrain.ArrivalTime)
 		dTime, _ := GetTime(train.DepartureTime)
 		if aTime+limitStopTime < dTime+1440 && train.TrainNo != arriveTrain.TrainNo {
-			turnADToEdges(arriveTrain, train, 2, limitStopTime, true)
+			turnADToEdges(graph, arriveTrain, train, 2, limitStopTime)
 			return
 		}
 	}
 }
 
-func (r *RailWayServiceImpl) DeleteNewStation(stationName string, isDeparture bool) {
-	if isDeparture {
-		Graph[StartIndex] = []dao.RailWay{}
+// InitBuildGraph 按 KeyStation 构建换乘图。新图在锁外构建，完成后整体替换，重建期间的搜索继续使用原来的图
+func (r *RailWayServiceImpl) InitBuildGraph(ctx context.Context) error {
+	rebuildMu.Lock()
+	defer rebuildMu.Unlock()
+	return r.rebuildGraph(ctx, KeyStation)
+}
+
+// ReloadTimetable 从数据库重新读取关键站点并重建换乘图，时刻表版本随之加一。
+// 另一个进程导入时刻表或车站坐标后，运行中的服务通过 POST /admin/reload 调用它才能看到变化
+func (r *RailWayServiceImpl) ReloadTimetable(ctx context.Context) error {
+	rebuildMu.Lock()
+	defer rebuildMu.Unlock()
+	keyStations := make(map[string]dao.Station, len(KeyStation))
+	for name := range KeyStation {
+		station, err := r.StationDAO.GetStationByName(ctx, name)
+		if err != nil {
+			r.logger(ctx).Error("query failed", "method", "ReloadTimetable", "station", name, "err", err)
+			return err
+		}
+		keyStations[name] = *station
 	}
-	return
+	return r.rebuildGraph(ctx, keyStations)
+}
+
+// rebuildGraph 需持有 rebuildMu；构建完成后在 graphMu 写锁内替换关键站点与图
+func (r *RailWayServiceImpl) rebuildGraph(ctx context.Context, keyStations map[string]dao.Station) error {
+	built, err := r.buildGraph(ctx, keyStations)
+	if err != nil {
+		return err
+	}
+	graphMu.Lock()
+	KeyStation = keyStations
+	Graph = built.graph
+	TrainFares = built.fares
+	KeyStationDeparture = built.departures
+	KeyStationArrival = built.arrivals
+	graphMu.Unlock()
+	nodes, edges := graphSize(built.graph)
+	r.logger(ctx).Info("building graph successfully", "nodes", nodes, "edges", edges)
+	metrics.GraphNodes.Set(float64(nodes))
+	metrics.GraphEdges.Set(float64(edges))
+	graphBuilt.Store(true)
+	bumpTimetableVersion()
+	return nil
 }
 
-func (r *RailWayServiceImpl) InitBuildGraph() error {
+func (r *RailWayServiceImpl) buildGraph(ctx context.Context, keyStations map[string]dao.Station) (keyStationGraph, error) {
 	/**
 	可以将构造图的模式调整成完全图，不使用KeyStation来构造图，这样构造出来的整个图是所有列车运行时刻表形成的图
 	总计150w边+50w点，对于大型服务器来说，以这个数据量去跑最短路还是可行的，但是单台电脑不太行
 	**/
 
-	//allStation, err := r.StationDAO.GetAllStations()
+	//allStation, err := r.StationDAO.GetAllStations(ctx)
 	//if err != nil {
 	//	return err
 	//}
 	//for _, station := range allStation {
-	for key, _ := range KeyStation {
-		arrivalTrains, err := r.RailWayDAO.GetRailWayByArrivalStation(key)
+	built := keyStationGraph{
+		graph:      make(map[string][]dao.RailWay),
+		fares:      make(map[string]*FareTable),
+		departures: make(map[string][]dao.RailWay),
+		arrivals:   make(map[string][]dao.RailWay),
+	}
+	for key, _ := range keyStations {
+		arrivalTrains, err := r.RailWayDAO.GetRailWayByArrivalStation(ctx, key)
 		if err != nil {
-			log.Fatal(err)
-			return err
+			r.logger(ctx).Error("query failed", "method", "InitBuildGraph", "station", key, "err", err)
+			return built, err
 		}
-		departureTrains, err := r.RailWayDAO.GetRailWayByDepartureStation(key)
+		departureTrains, err := r.RailWayDAO.GetRailWayByDepartureStation(ctx, key)
 		if err != nil {
-			log.Fatal(err)
-			return err
+			r.logger(ctx).Error("query failed", "method", "InitBuildGraph", "station", key, "err", err)
+			return built, err
+		}
+		for _, train := range departureTrains {
+			if _, ok := keyStations[train.ArrivalStation]; ok {
+				addTrainFare(built.fares, train)
+			}
 		}
 		departureTrains = sortByEarlyArriveFirst(departureTrains)
 		arrivalTrains = sortByEarlyArriveFirst(arrivalTrains)
 
-		//arrivalTrains = getKeyTrains(arrivalTrains, true, 0)
-		departureTrains = getKeyTrains(departureTrains, true, 0)
-		//getKeyTrains(arrivalTrains, true, 1)
-		//getKeyTrains(arrivalTrains, true, 2)
-		getKeyTrains(departureTrains, true, 1)
-		getKeyTrains(departureTrains, true, 2)
-		//CalGraphSize()
+		//arrivalTrains = getKeyTrains(arrivalTrains, keyStations, built.graph, 0)
+		departureTrains = getKeyTrains(departureTrains, keyStations, built.graph, 0)
+		//getKeyTrains(arrivalTrains, keyStations, built.graph, 1)
+		//getKeyTrains(arrivalTrains, keyStations, built.graph, 2)
+		getKeyTrains(departureTrains, keyStations, built.graph, 1)
+		getKeyTrains(departureTrains, keyStations, built.graph, 2)
 		departureTrains = sortByEarlyFirst(departureTrains)
-		KeyStationDeparture[key] = departureTrains
-		KeyStationArrival[key] = arrivalTrains
+		built.departures[key] = departureTrains
+		built.arrivals[key] = arrivalTrains
 
 		length := len(departureTrains)
 		for index, train := range departureTrains {
-			buildDepartureWaitingEdges(departureTrains[(index+1)%length], train, 2)
+			buildDepartureWaitingEdges(built.graph, departureTrains[(index+1)%length], train, 2)
 		}
-		buildArrivalToDepartureWaitingEdges(arrivalTrains, departureTrains, DefaultStopTime)
+		buildArrivalToDepartureWaitingEdges(built.graph, arrivalTrains, departureTrains, DefaultStopTime)
 	}
-	log.Printf("[InitBuildGraph] Building graph successfully")
-	return nil
+	return built, nil
 }
 
-func getKeyTrains(input []dao.RailWay, isAddGraph bool, dayTime int) []dao.RailWay {
+// getKeyTrains 两端都是关键站点的车次，每个车次保留一条；graph 不为 nil 时把这些车次按第 dayTime 天加入 graph
+func getKeyTrains(input []dao.RailWay, keyStations map[string]dao.Station, graph map[string][]dao.RailWay, dayTime int) []dao.RailWay {
 	result := make([]dao.RailWay, 0)
 	rememberTrainNo := make(map[string]string)
 	for _, v := range input {
-		if !checkKeyStation(v.DepartureStation) || !checkKeyStation(v.ArrivalStation) {
+		_, departureKey := keyStations[v.DepartureStation]
+		_, arrivalKey := keyStations[v.ArrivalStation]
+		if !departureKey || !arrivalKey {
 			continue
 		}
 		_, ok := rememberTrainNo[v.TrainNo]
@@ -154,25 +236,19 @@ func getKeyTrains(input []dao.RailWay, isAddGraph bool, dayTime int) []dao.RailW
 		}
 		rememberTrainNo[v.TrainNo] = v.TrainNo
 		result = append(result, v)
-		if isAddGraph {
+		if graph != nil {
 			//加边
 			vv := v
 			vv.ArrivalDay = vv.ArrivalDay + uint(dayTime)
 			departIndex := "D/" + v.DepartureStation + "/" + v.TrainNo + "/" + strconv.Itoa(dayTime)
-			value, ok2 := Graph[departIndex]
-			if ok2 {
-				value = append(value, vv)
-				Graph[departIndex] = value
-			} else {
-				Graph[departIndex] = []dao.RailWay{vv}
-			}
+			graph[departIndex] = append(graph[departIndex], vv)
 		}
 	}
 	return result
 }
 
-// 只要满足一个是关键站点即可
-func getOneKeyTrains(input []dao.RailWay, isAddGraph bool, dayTime int, IsTemplate, isKey bool) []dao.RailWay {
+// 只要满足一个是关键站点即可；graph 不为 nil 时加边，IsTemplate 时同时登记到达点
+func getOneKeyTrains(input []dao.RailWay, graph map[string][]dao.RailWay, dayTime int, IsTemplate, isKey bool) []dao.RailWay {
 	result := make([]dao.RailWay, 0)
 	rememberTrainNo := make(map[string]string)
 	for _, v := range input {
@@ -193,32 +269,17 @@ func getOneKeyTrains(input []dao.RailWay, isAddGraph bool, dayTime int, IsTempla
 		if ok {
 			rememberTrainNo[v.TrainNo] = v.TrainNo
 			result = append(result, v)
-			if isAddGraph {
+			if graph != nil {
 				//加边
 				vv := v
 				vv.ArrivalDay = vv.ArrivalDay + uint(dayTime)
 				departIndex := "D/" + v.DepartureStation + "/" + v.TrainNo + "/" + strconv.Itoa(dayTime)
 				arriveIndex := "A/" + v.ArrivalStation + "/" + v.TrainNo + "/" + strconv.Itoa(dayTime)
-
+				graph[departIndex] = append(graph[departIndex], vv)
 				if IsTemplate {
-					value, ok := TemplateGraph[departIndex]
-					if ok {
-						value = append(value, vv)
-						TemplateGraph[departIndex] = value
-					} else {
-						TemplateGraph[departIndex] = []dao.RailWay{vv}
-					}
-					_, ok = TemplateGraph[arriveIndex]
+					_, ok = graph[arriveIndex]
 					if !ok {
-						TemplateGraph[arriveIndex] = []dao.RailWay{}
-					}
-				} else {
-					value, ok := Graph[departIndex]
-					if ok {
-						value = append(value, vv)
-						Graph[departIndex] = value
-					} else {
-						Graph[departIndex] = []dao.RailWay{vv}
+						graph[arriveIndex] = []dao.RailWay{}
 					}
 				}
 			}
@@ -227,7 +288,7 @@ func getOneKeyTrains(input []dao.RailWay, isAddGraph bool, dayTime int, IsTempla
 	return result
 }
 
-func buildDepartureWaitingEdges(arrival, departure dao.RailWay, maxArrivalDay int64) {
+func buildDepartureWaitingEdges(graph map[string][]dao.RailWay, arrival, departure dao.RailWay, maxArrivalDay int64) {
 	rememberTrainNo := make(map[string]string)
 	for arrivalDay := int64(0); arrivalDay <= maxArrivalDay; arrivalDay++ {
 		_, ok := rememberTrainNo[arrival.TrainNo+strconv.FormatInt(arrivalDay, 10)]
@@ -245,8 +306,7 @@ func buildDepartureWaitingEdges(arrival, departure dao.RailWay, maxArrivalDay in
 			ArrivalDay:       uint(arrivalDay),
 		}
 		if newEdge.ArrivalStation != newEdge.DepartureStation {
-			fmt.Println("[buildDepartureWaitingEdges] WRONG!!!")
-			fmt.Println(arrival, departure)
+			slog.Error("waiting edge between different stations", "method", "buildDepartureWaitingEdges", "arrival", arrival, "departure", departure)
 			return
 		}
 		runningTime := CalculateStopTime(newEdge.DepartureTime, newEdge.ArrivalTime)
@@ -258,13 +318,7 @@ func buildDepartureWaitingEdges(arrival, departure dao.RailWay, maxArrivalDay in
 			arrivalDay = arrivalDay + 1
 		}
 		departIndex := "D/" + newEdge.DepartureStation + "/" + departure.TrainNo + "/" + strconv.FormatInt(arrivalDay, 10)
-		value, ok := Graph[departIndex]
-		if ok {
-			value = append(value, newEdge)
-			Graph[departIndex] = value
-		} else {
-			Graph[departIndex] = []dao.RailWay{newEdge}
-		}
+		graph[departIndex] = append(graph[departIndex], newEdge)
 	}
 	return
 }
@@ -273,7 +327,7 @@ func checkKeyStation(stationName string) bool {
 	_, ok := KeyStation[stationName]
 	return ok
 }
-func buildArrivalToDepartureWaitingEdges(arrivalTrains, departureTrains []dao.RailWay, limitStopTime int64) {
+func buildArrivalToDepartureWaitingEdges(graph map[string][]dao.RailWay, arrivalTrains, departureTrains []dao.RailWay, limitStopTime int64) {
 	dIndex := 0
 	dLength := len(departureTrains)
 	if dLength == 0 {
@@ -288,7 +342,7 @@ func buildArrivalToDepartureWaitingEdges(arrivalTrains, departureTrains []dao.Ra
 		rememberTrainNo[arrival.TrainNo] = arrival.TrainNo
 		for _, departure := range departureTrains {
 			if arrival.TrainNo == departure.TrainNo {
-				turnADToEdges(arrival, departure, 2, 0, false)
+				turnADToEdges(graph, arrival, departure, 2, 0)
 				break
 			}
 		}
@@ -305,7 +359,7 @@ func buildArrivalToDepartureWaitingEdges(arrivalTrains, departureTrains []dao.Ra
 			aTime, _ := GetTime(arrival.ArrivalTime)
 			dTime, _ := GetTime(departureTrains[dIndex].DepartureTime)
 			if aTime+limitStopTime <= dTime && arrival.TrainNo != departureTrains[dIndex].TrainNo {
-				turnADToEdges(arrival, departureTrains[dIndex], 2, limitStopTime, false)
+				turnADToEdges(graph, arrival, departureTrains[dIndex], 2, limitStopTime)
 				isSuccess = true
 				dIndex = i
 				break
@@ -317,7 +371,7 @@ func buildArrivalToDepartureWaitingEdges(arrivalTrains, departureTrains []dao.Ra
 				aTime, _ := GetTime(arrival.ArrivalTime)
 				dTime, _ := GetTime(train.DepartureTime)
 				if aTime+limitStopTime <= dTime+1440 && arrival.TrainNo != train.TrainNo {
-					turnADToEdges(arrival, train, 2, limitStopTime, false)
+					turnADToEdges(graph, arrival, train, 2, limitStopTime)
 					break
 				}
 			}
@@ -325,7 +379,7 @@ func buildArrivalToDepartureWaitingEdges(arrivalTrains, departureTrains []dao.Ra
 	}
 }
 
-func turnADToEdges(arrival, departure dao.RailWay, maxArrivalDay, limitStopTime int64, isTemplate bool) {
+func turnADToEdges(graph map[string][]dao.RailWay, arrival, departure dao.RailWay, maxArrivalDay, limitStopTime int64) {
 	rememberTrainNo := make(map[string]string)
 	for arrivalDay := int64(0); arrivalDay <= maxArrivalDay; arrivalDay++ {
 		_, ok := rememberTrainNo[arrival.TrainNo+strconv.FormatInt(arrivalDay, 10)]
@@ -344,8 +398,7 @@ func turnADToEdges(arrival, departure dao.RailWay, maxArrivalDay, limitStopTime
 			ArrivalDay:       uint(arrivalDay),
 		}
 		if newEdge.ArrivalStation != newEdge.DepartureStation {
-			fmt.Println("[turnADToEdges] WRONG!!!")
-			fmt.Println(arrival, departure)
+			slog.Error("waiting edge between different stations", "method", "turnADToEdges", "arrival", arrival, "departure", departure)
 			return
 		}
 		runningTime := CalculateStopTime(newEdge.DepartureTime, newEdge.ArrivalTime)
@@ -358,27 +411,14 @@ func turnADToEdges(arrival, departure dao.RailWay, maxArrivalDay, limitStopTime
 			templateArrivalDay = arrivalDay + 1
 		}
 		arrivalIndex := "A/" + newEdge.DepartureStation + "/" + arrival.TrainNo + "/" + strconv.FormatInt(templateArrivalDay, 10)
-		if isTemplate {
-			value, ok := TemplateGraph[arrivalIndex]
-			if ok {
-				value = append(value, newEdge)
-				TemplateGraph[arrivalIndex] = value
-			} else {
-				TemplateGraph[arrivalIndex] = []dao.RailWay{newEdge}
-			}
-		} else {
-			value, ok := Graph[arrivalIndex]
-			if ok {
-				value = append(value, newEdge)
-				Graph[arrivalIndex] = value
-			} else {
-				Graph[arrivalIndex] = []dao.RailWay{newEdge}
-			}
-		}
+		graph[arrivalIndex] = append(graph[arrivalIndex], newEdge)
 	}
 }
 
-func Dijkstra(startStation, endStation, speedOption string, forbidTrain []string, maxTrans int64, sortOptions int) AnalyseTrans {
+// Dijkstra 按总耗时搜索，realtime 不为 nil 时各边按实时动态修正；constraints 不为 nil 时只走满足约束的边，
+// 按顺序经过所有途经站后到达终点才算找到
+func Dijkstra(ctx context.Context, search *searchGraph, startStation, endStation, speedOption string, forbidTrain []string, maxTrans int64, sortOptions int, realtime *realtimeView, constraints *searchConstraints) AnalyseTrans {
+	const algorithm = "time"
 	//for key, value := range Graph {
 	//	stringIndex := strings.Split(key, "/")
 	//	if len(stringIndex) > 2 && stringIndex[1] == "乌鲁木齐" {
@@ -387,21 +427,21 @@ func Dijkstra(startStation, endStation, speedOption string, forbidTrain []string
 	//}
 
 	// 初始化最短路径映射
-	dist = make([]map[string]AnalyseTrans, 0)
+	search.dist = make([]map[string]AnalyseTrans, 0)
 	for i := int64(0); i <= maxTrans; i++ {
-		dist = append(dist, make(map[string]AnalyseTrans))
+		search.dist = append(search.dist, make(map[string]AnalyseTrans))
 	}
 	// 初始化所有点的路径值为最大
 	for node := range Graph {
 		for i := int64(0); i <= maxTrans; i++ {
-			dist[i][node] = AnalyseTrans{
+			search.dist[i][node] = AnalyseTrans{
 				AllRunningTime: math.MaxInt64,
 				ToTalPrice:     math.MaxInt64,
 				TransFerTimes:  math.MaxInt64,
 			}
 		}
 	}
-	dist[0][StartIndex] = AnalyseTrans{
+	search.dist[0][StartIndex] = AnalyseTrans{
 		AllRunningTime:  0,
 		TransFerTimes:   0,
 		ToTalPrice:      0,
@@ -413,33 +453,41 @@ func Dijkstra(startStation, endStation, speedOption string, forbidTrain []string
 
 	// 初始化最小堆
 	pq := &PriorityQueue{}
-	findbugs = dist[4]["A/海口/65000Z80060S/0"]
 	heap.Init(pq)
 	heap.Push(pq, &Item{node: StartIndex, allTime: 0, transferTimes: 0})
 	//fmt.Println(Graph[StartIndex])
 	// 运行 Dijkstra
-	for pq.Len() > 0 {
+	expanded := 0
+	defer func() {
+		metrics.DijkstraNodesExpanded.WithLabelValues(algorithm).Add(float64(expanded))
+	}()
+	for ; pq.Len() > 0; expanded++ {
+		// 超时或请求取消后放弃本次搜索，按未找到处理
+		if expanded%cancelCheckInterval == 0 && ctx.Err() != nil {
+			break
+		}
 		curr := heap.Pop(pq).(*Item)
 		currNode, currTime, currTransfers, currPrice := curr.node, curr.allTime, curr.transferTimes, curr.price
+		currKey := curr.key(currNode)
 		// 如果当前路径已经不是最短路径，则跳过
-		if currTime > dist[currTransfers][currNode].AllRunningTime ||
-			(currTime == dist[currTransfers][currNode].AllRunningTime && currTransfers > dist[currTransfers][currNode].TransFerTimes) {
+		if currTime > search.dist[currTransfers][currKey].AllRunningTime ||
+			(currTime == search.dist[currTransfers][currKey].AllRunningTime && currTransfers > search.dist[currTransfers][currKey].TransFerTimes) {
 			continue
 		}
 		indexString := strings.Split(currNode, "/")
-		if len(indexString) > 1 && indexString[1] == endStation {
-			return dist[currTransfers][currNode]
+		if len(indexString) > 1 && indexString[1] == endStation && constraints.done(curr.viaState) {
+			return search.dist[currTransfers][currKey]
 		}
 		//fmt.Println(dist[currTransfers][currNode])
 		// 遍历邻接点
-		edges, ok := TemplateGraph[currNode]
+		edges, ok := search.templateGraph[currNode]
 		if ok {
 			for _, edge := range edges {
 				//判断specialTag
 				if curr.specialTag == true && edge.DepartureStation == edge.ArrivalStation {
 					continue
 				}
-				item := getAnalyseTransByTime(edge, forbidTrain, currNode, speedOption, currTransfers, currTime, maxTrans, currPrice)
+				item := getAnalyseTransByTime(search, edge, forbidTrain, currNode, speedOption, currTransfers, currTime, maxTrans, currPrice, realtime, curr.delay, constraints, curr.viaState)
 				if item != nil {
 					heap.Push(pq, item)
 				}
@@ -451,7 +499,7 @@ func Dijkstra(startStation, endStation, speedOption string, forbidTrain []string
 				if curr.specialTag == true && edge.DepartureStation == edge.ArrivalStation {
 					continue
 				}
-				item := getAnalyseTransByTime(edge, forbidTrain, currNode, speedOption, currTransfers, currTime, maxTrans, currPrice)
+				item := getAnalyseTransByTime(search, edge, forbidTrain, currNode, speedOption, currTransfers, currTime, maxTrans, currPrice, realtime, curr.delay, constraints, curr.viaState)
 				if item != nil {
 					heap.Push(pq, item)
 				}
@@ -478,10 +526,22 @@ func isInForbid(trainNo string, forbidTrains []string) bool {
 
 // 最短路的具体实现
 // 转乘的逻辑是如果当前边是出发边且不是站内Waiting边且和点本身的TrainNo不一致，那么将视为进行转乘，并且将列车信息写入Dist当中
-func getAnalyseTransByTime(edge dao.RailWay, forbidTrain []string, currNode, speedOption string, currTransfers, currTime, maxTrans int64, currPrice float64) *Item {
+// dist 中的点按 currState 区分经过途经站的进度
+func getAnalyseTransByTime(search *searchGraph, edge dao.RailWay, forbidTrain []string, currNode, speedOption string, currTransfers, currTime, maxTrans int64, currPrice float64, realtime *realtimeView, currDelay int64, constraints *searchConstraints, currState viaState) *Item {
 	if isInForbid(edge.TrainNo, forbidTrain) {
 		return nil
 	}
+	delay := int64(0)
+	if realtime != nil {
+		var ok bool
+		if edge, delay, ok = realtime.edge(search.templateGraph, edge, currNode, currDelay); !ok {
+			return nil
+		}
+	}
+	// 实时动态可能把等待边换成之后的车次，约束按修正后的边判断
+	if !constraints.allows(edge) {
+		return nil
+	}
 	if speedOption == OnlyHighSpeed && edge.IsHighSpeed == 0 {
 		return nil
 	}
@@ -492,6 +552,11 @@ func getAnalyseTransByTime(edge dao.RailWay, forbidTrain []string, currNode, spe
 	if edge.ArrivalDay > 2 {
 		return nil
 	}
+	nextState, ok := constraints.advance(edge, currState)
+	if !ok {
+		return nil
+	}
+	currKey := currState.key(currNode)
 	var (
 		nextNode   string
 		status     string
@@ -509,14 +574,14 @@ func getAnalyseTransByTime(edge dao.RailWay, forbidTrain []string, currNode, spe
 	}
 
 	travelTime, _ = GetTime(edge.RunningTime)
-	length := len(dist[currTransfers][currNode].TrainNo)
-	if dist[currTransfers][currNode].NowStatus == "D" && edge.TrainNumber != Waiting && (length == 0 || dist[currTransfers][currNode].TrainNo[length-1] != edge.TrainNo) {
+	length := len(search.dist[currTransfers][currKey].TrainNo)
+	if search.dist[currTransfers][currKey].NowStatus == "D" && edge.TrainNumber != Waiting && (length == 0 || search.dist[currTransfers][currKey].TrainNo[length-1] != edge.TrainNo) {
 		transfers = 1
 	} else {
 		transfers = 0
 	}
 	//增加标签判断
-	if dist[currTransfers][currNode].NowStatus == "A" && travelTime < DefaultStopTime {
+	if search.dist[currTransfers][currKey].NowStatus == "A" && travelTime < DefaultStopTime {
 		specialTag = true
 	} else {
 		specialTag = false
@@ -526,45 +591,63 @@ func getAnalyseTransByTime(edge dao.RailWay, forbidTrain []string, currNode, spe
 	if newTransfers > maxTrans {
 		return nil
 	}
+	penalty := search.dist[currTransfers][currKey].Penalty
+	if transfers == 1 && constraints.penalized(edge) {
+		newTime = newTime + PreferPenalty
+		penalty = penalty + PreferPenalty
+	}
 	// 如果找到更优路径，则更新
-	_, ok := dist[newTransfers][nextNode]
+	nextKey := nextState.key(nextNode)
+	_, ok = search.dist[newTransfers][nextKey]
 	if !ok {
-		dist[newTransfers][nextNode] = AnalyseTrans{
+		search.dist[newTransfers][nextKey] = AnalyseTrans{
 			AllRunningTime: math.MaxInt64,
 			TransFerTimes:  math.MaxInt64,
 			ToTalPrice:     math.MaxInt64,
 		}
 	}
-	if newTime < dist[newTransfers][nextNode].AllRunningTime ||
-		(newTime == dist[newTransfers][nextNode].AllRunningTime && newTransfers < dist[newTransfers][nextNode].TransFerTimes) {
+	if newTime < search.dist[newTransfers][nextKey].AllRunningTime ||
+		(newTime == search.dist[newTransfers][nextKey].AllRunningTime && newTransfers < search.dist[newTransfers][nextKey].TransFerTimes) {
 		newAnalyseTrans := AnalyseTrans{
 			NowTrainNumber:  edge.TrainNumber,
 			NowTrainNo:      edge.TrainNo,
 			NowStation:      edge.ArrivalStation,
 			NowStatus:       status,
-			TrainNumber:     append([]string(nil), dist[currTransfers][currNode].TrainNumber...),
-			TrainNo:         append([]string(nil), dist[currTransfers][currNode].TrainNo...),
-			StationSequence: append([]string(nil), dist[currTransfers][currNode].StationSequence...),
+			TrainNumber:     append([]string(nil), search.dist[currTransfers][currKey].TrainNumber...),
+			TrainNo:         append([]string(nil), search.dist[currTransfers][currKey].TrainNo...),
+			StationSequence: append([]string(nil), search.dist[currTransfers][currKey].StationSequence...),
 			AllRunningTime:  newTime,
 			TransFerTimes:   newTransfers,
 			ToTalPrice:      currPrice + edge.Price,
 			NowArrivalDay:   int64(edge.ArrivalDay),
+			Penalty:         penalty,
 		}
 		if transfers == 1 {
 			newAnalyseTrans.TrainNumber = append(newAnalyseTrans.TrainNumber, edge.TrainNumber)
 			newAnalyseTrans.TrainNo = append(newAnalyseTrans.TrainNo, edge.TrainNo)
 			newAnalyseTrans.StationSequence = append(newAnalyseTrans.StationSequence, edge.DepartureStation)
 		}
-		dist[newTransfers][nextNode] = newAnalyseTrans
-		return &Item{node: nextNode, allTime: newTime, transferTimes: newTransfers, specialTag: specialTag}
+		search.dist[newTransfers][nextKey] = newAnalyseTrans
+		return &Item{node: nextNode, allTime: newTime, transferTimes: newTransfers, specialTag: specialTag, delay: delay, viaState: nextState}
 	}
 	return nil
 }
 
-func getAnalyseTransByPrice(edge dao.RailWay, forbidTrain []string, currNode, speedOption string, currTransfers, currTime, maxTrans int64, currPrice float64) *Item2 {
+func getAnalyseTransByPrice(search *searchGraph, edge dao.RailWay, forbidTrain []string, currNode, speedOption string, currTransfers, currTime, maxTrans int64, currPrice float64, seatClasses []string, realtime *realtimeView, currDelay int64, constraints *searchConstraints, currState viaState) *Item2 {
 	if isInForbid(edge.TrainNo, forbidTrain) {
 		return nil
 	}
+	delay := int64(0)
+	if realtime != nil {
+		var ok bool
+		if edge, delay, ok = realtime.edge(search.templateGraph, edge, currNode, currDelay); !ok {
+			return nil
+		}
+	}
+	// 实时动态可能把等待边换成之后的车次，约束按修正后的边判断
+	if !constraints.allows(edge) {
+		return nil
+	}
 	if speedOption == OnlyHighSpeed && edge.IsHighSpeed == 0 {
 		return nil
 	}
@@ -575,6 +658,11 @@ func getAnalyseTransByPrice(edge dao.RailWay, forbidTrain []string, currNode, sp
 	if edge.ArrivalDay > 2 {
 		return nil
 	}
+	nextState, ok := constraints.advance(edge, currState)
+	if !ok {
+		return nil
+	}
+	currKey := currState.key(currNode)
 	var (
 		nextNode   string
 		status     string
@@ -591,8 +679,9 @@ func getAnalyseTransByPrice(edge dao.RailWay, forbidTrain []string, currNode, sp
 	}
 
 	travelTime, _ = GetTime(edge.RunningTime)
-	length := len(dist[currTransfers][currNode].TrainNo)
-	if dist[currTransfers][currNode].NowStatus == "D" && edge.TrainNumber != Waiting && (length == 0 || dist[currTransfers][currNode].TrainNo[length-1] != edge.TrainNo) {
+	current := search.dist[currTransfers][currKey]
+	length := len(current.TrainNo)
+	if current.NowStatus == "D" && edge.TrainNumber != Waiting && (length == 0 || current.TrainNo[length-1] != edge.TrainNo) {
 		transfers = 1
 	} else {
 		transfers = 0
@@ -600,32 +689,49 @@ func getAnalyseTransByPrice(edge dao.RailWay, forbidTrain []string, currNode, sp
 
 	newTime := currTime + travelTime
 	newTransfers := currTransfers + transfers
-	newPrice := currPrice + edge.Price
+	edgePrice, _ := SeatPrice(edge, seatClasses)
+	legPrice := edgePrice
+	switch {
+	case edge.TrainNumber == Waiting:
+		legPrice = current.LegPrice
+	case transfers == 0 && length > 0:
+		// 继续乘坐同一车次：从上车站到下一站按一张车票计价，票价不低于已经走过的部分
+		legPrice = math.Max(search.throughPrice(edge.TrainNo, current.StationSequence[length-1], edge.ArrivalStation, seatClasses, current.LegPrice+edgePrice), current.LegPrice)
+	}
+	newPrice := currPrice + legPrice
+	if transfers == 0 {
+		newPrice = newPrice - current.LegPrice
+	}
+	if transfers == 1 && constraints.penalized(edge) {
+		newPrice = newPrice + PreferPricePenalty
+	}
 	if newTransfers > maxTrans {
 		return nil
 	}
 	// 如果找到更优路径，则更新
-	_, ok := dist[newTransfers][nextNode]
+	nextKey := nextState.key(nextNode)
+	_, ok = search.dist[newTransfers][nextKey]
 	if !ok {
-		dist[newTransfers][nextNode] = AnalyseTrans{
+		search.dist[newTransfers][nextKey] = AnalyseTrans{
 			AllRunningTime: math.MaxInt64,
 			TransFerTimes:  math.MaxInt64,
 			ToTalPrice:     math.MaxFloat64,
 		}
 	}
-	if newPrice < dist[newTransfers][nextNode].ToTalPrice ||
-		(newPrice == dist[newTransfers][nextNode].ToTalPrice && newTransfers < dist[newTransfers][nextNode].TransFerTimes) {
+	if newPrice < search.dist[newTransfers][nextKey].ToTalPrice ||
+		(newPrice == search.dist[newTransfers][nextKey].ToTalPrice && newTransfers < search.dist[newTransfers][nextKey].TransFerTimes) {
 		newAnalyseTrans := AnalyseTrans{
 			NowTrainNumber:  edge.TrainNumber,
 			NowTrainNo:      edge.TrainNo,
 			NowStation:      edge.ArrivalStation,
 			NowStatus:       status,
-			TrainNumber:     append([]string(nil), dist[currTransfers][currNode].TrainNumber...),
-			TrainNo:         append([]string(nil), dist[currTransfers][currNode].TrainNo...),
-			StationSequence: append([]string(nil), dist[currTransfers][currNode].StationSequence...),
+			TrainNumber:     append([]string(nil), search.dist[currTransfers][currKey].TrainNumber...),
+			TrainNo:         append([]string(nil), search.dist[currTransfers][currKey].TrainNo...),
+			StationSequence: append([]string(nil), search.dist[currTransfers][currKey].StationSequence...),
 			AllRunningTime:  newTime,
 			TransFerTimes:   newTransfers,
 			ToTalPrice:      newPrice,
+			LegPrice:        legPrice,
 			NowArrivalDay:   int64(edge.ArrivalDay),
 		}
 		if transfers == 1 {
@@ -633,32 +739,30 @@ func getAnalyseTransByPrice(edge dao.RailWay, forbidTrain []string, currNode, sp
 			newAnalyseTrans.TrainNo = append(newAnalyseTrans.TrainNo, edge.TrainNo)
 			newAnalyseTrans.StationSequence = append(newAnalyseTrans.StationSequence, edge.DepartureStation)
 		}
-		dist[newTransfers][nextNode] = newAnalyseTrans
-		return &Item2{node: nextNode, allTime: newTime, transferTimes: newTransfers}
+		search.dist[newTransfers][nextKey] = newAnalyseTrans
+		return &Item2{node: nextNode, allTime: newTime, transferTimes: newTransfers, price: newPrice, delay: delay, viaState: nextState}
 	}
 	return nil
 }
 
-func CalGraphSize() {
-	sum := 0
-	st := 0
-	for _, value := range Graph {
-		sum = sum + len(value)
-		st = st + 1
-	}
-	fmt.Println(sum)
-	fmt.Println(st)
-	if sum > st*4 {
-		for key, value := range Graph {
-			if len(value) > 4 {
-				fmt.Println(key, value)
-				break
-			}
-		}
+// GraphSize 返回图中点和边的数量
+func GraphSize() (nodes, edges int) {
+	graphMu.RLock()
+	defer graphMu.RUnlock()
+	return graphSize(Graph)
+}
 
+func graphSize(graph map[string][]dao.RailWay) (nodes, edges int) {
+	for _, value := range graph {
+		edges = edges + len(value)
+		nodes = nodes + 1
 	}
+	return nodes, edges
 }
-func DijkstraByPrice(startStation, endStation, speedOption string, forbidTrain []string, maxTrans int64, sortOptions int) AnalyseTrans {
+
+// DijkstraByPrice 按票价最低搜索，每条边按 seatClasses 中第一个有售的席别计价，realtime 与 constraints 同 Dijkstra
+func DijkstraByPrice(ctx context.Context, search *searchGraph, startStation, endStation, speedOption string, forbidTrain []string, maxTrans int64, sortOptions int, seatClasses []string, realtime *realtimeView, constraints *searchConstraints) AnalyseTrans {
+	const algorithm = "price"
 	//for key, value := range Graph {
 	//	stringIndex := strings.Split(key, "/")
 	//	if len(stringIndex) > 2 && stringIndex[1] == "乌鲁木齐" {
@@ -667,21 +771,21 @@ func DijkstraByPrice(startStation, endStation, speedOption string, forbidTrain [
 	//}
 
 	// 初始化最短路径映射
-	dist = make([]map[string]AnalyseTrans, 0)
+	search.dist = make([]map[string]AnalyseTrans, 0)
 	for i := int64(0); i <= maxTrans; i++ {
-		dist = append(dist, make(map[string]AnalyseTrans))
+		search.dist = append(search.dist, make(map[string]AnalyseTrans))
 	}
 	// 初始化所有点的路径值为最大
 	for node := range Graph {
 		for i := int64(0); i <= maxTrans; i++ {
-			dist[i][node] = AnalyseTrans{
+			search.dist[i][node] = AnalyseTrans{
 				AllRunningTime: math.MaxInt64,
 				ToTalPrice:     math.MaxInt64,
 				TransFerTimes:  math.MaxInt64,
 			}
 		}
 	}
-	dist[0][StartIndex] = AnalyseTrans{
+	search.dist[0][StartIndex] = AnalyseTrans{
 		AllRunningTime:  0,
 		TransFerTimes:   0,
 		ToTalPrice:      0,
@@ -693,26 +797,34 @@ func DijkstraByPrice(startStation, endStation, speedOption string, forbidTrain [
 
 	// 初始化最小堆
 	pq := &PriorityQueue2{}
-	findbugs = dist[4]["A/海口/65000Z80060S/0"]
 	heap.Init(pq)
 	heap.Push(pq, &Item2{node: StartIndex, allTime: 0, transferTimes: 0})
 	//fmt.Println(Graph[StartIndex])
 	// 运行 Dijkstra
-	for pq.Len() > 0 {
+	expanded := 0
+	defer func() {
+		metrics.DijkstraNodesExpanded.WithLabelValues(algorithm).Add(float64(expanded))
+	}()
+	for ; pq.Len() > 0; expanded++ {
+		// 超时或请求取消后放弃本次搜索，按未找到处理
+		if expanded%cancelCheckInterval == 0 && ctx.Err() != nil {
+			break
+		}
 		curr := heap.Pop(pq).(*Item2)
 		currNode, currTime, currTransfers, currPrice := curr.node, curr.allTime, curr.transferTimes, curr.price
-		// 如果当前路径已经不是最短路径，则跳过
-		if currTime > dist[currTransfers][currNode].AllRunningTime ||
-			(currTime == dist[currTransfers][currNode].AllRunningTime && currTransfers > dist[currTransfers][currNode].TransFerTimes) {
+		currKey := curr.key(currNode)
+		// 如果当前路径已经不是最便宜的路径，则跳过
+		if currPrice > search.dist[currTransfers][currKey].ToTalPrice ||
+			(currPrice == search.dist[currTransfers][currKey].ToTalPrice && currTransfers > search.dist[currTransfers][currKey].TransFerTimes) {
 			continue
 		}
 		indexString := strings.Split(currNode, "/")
-		if len(indexString) > 1 && indexString[1] == endStation {
-			return dist[currTransfers][currNode]
+		if len(indexString) > 1 && indexString[1] == endStation && constraints.done(curr.viaState) {
+			return search.dist[currTransfers][currKey]
 		}
 		//fmt.Println(dist[currTransfers][currNode])
 		// 遍历邻接点
-		edges, ok := TemplateGraph[currNode]
+		edges, ok := search.templateGraph[currNode]
 		if ok {
 			for _, edge := range edges {
 				//判断specialTag
@@ -720,7 +832,7 @@ func DijkstraByPrice(startStation, endStation, speedOption string, forbidTrain [
 					continue
 				}
 				if sortOptions == LowPriceFirst {
-					item := getAnalyseTransByPrice(edge, forbidTrain, currNode, speedOption, currTransfers, currTime, maxTrans, currPrice)
+					item := getAnalyseTransByPrice(search, edge, forbidTrain, currNode, speedOption, currTransfers, currTime, maxTrans, currPrice, seatClasses, realtime, curr.delay, constraints, curr.viaState)
 					if item != nil {
 						heap.Push(pq, item)
 					}
@@ -733,7 +845,7 @@ func DijkstraByPrice(startStation, endStation, speedOption string, forbidTrain [
 				if curr.specialTag == true && edge.DepartureStation == edge.ArrivalStation {
 					continue
 				}
-				item := getAnalyseTransByPrice(edge, forbidTrain, currNode, speedOption, currTransfers, currTime, maxTrans, currPrice)
+				item := getAnalyseTransByPrice(search, edge, forbidTrain, currNode, speedOption, currTransfers, currTime, maxTrans, currPrice, seatClasses, realtime, curr.delay, constraints, curr.viaState)
 				if item != nil {
 					heap.Push(pq, item)
 				}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"railway/dao"
+	"railway/fare"
+	"railway/logging"
+	"time"
+)
+
+const (
+	// MaxOrderPassengers 一个订单最多的乘车人数
+	MaxOrderPassengers = 5
+	// MaxOrderLegs 一个订单最多的乘车段数
+	MaxOrderLegs = 4
+	// DefaultHoldTimeout 占座后等待确认的默认时长
+	DefaultHoldTimeout = 30 * time.Minute
+	// expiredOrderBatch 每次释放的超时订单数量上限
+	expiredOrderBatch = 100
+	// releaseTimeout 退还余票的超时时长，退还不随请求取消
+	releaseTimeout = 10 * time.Second
+)
+
+var (
+	ErrOrderNotFound    = errors.New("orderNotFind")
+	ErrInvalidOrder     = errors.New("invalidOrder")
+	ErrLegNotFound      = errors.New("legNotFind")
+	ErrSeatClassNotSold = errors.New("seatClassNotSold")
+	ErrOrderExpired     = errors.New("orderExpired")
+	ErrOrderDeparted    = errors.New("orderDeparted")
+
+	OrderDAO dao.OrderDAO
+	O        OrderServiceImpl
+)
+
+// RefundTier 距首段发车不少于 Before 时按票款的 Rate 收取退票费
+type RefundTier struct {
+	Before time.Duration
+	Rate   float64
+}
+
+// RefundFeeSchedule 退票费率，按 Before 从大到小排列：8 天以上免费，48 小时以上 5%，24 小时以上 10%，其余 20%
+var RefundFeeSchedule = []RefundTier{
+	{Before: 8 * 24 * time.Hour, Rate: 0},
+	{Before: 48 * time.Hour, Rate: 0.05},
+	{Before: 24 * time.Hour, Rate: 0.1},
+	{Before: 0, Rate: 0.2},
+}
+
+// timetableZone 时刻表中的日期与时刻均为北京时间
+var timetableZone = time.FixedZone("CST", 8*3600)
+
+// OrderLegRequest 下单时的一段，Date 为在 From 上车的日期
+type OrderLegRequest struct {
+	TrainNo   string
+	Date      string
+	From      string
+	To        string
+	SeatClass string
+}
+
+type OrderPassengerRequest struct {
+	Name     string
+	IDNumber string
+	Type     fare.PassengerType
+}
+
+// OrderRequest 每位乘车人在每一段各占一个 SeatClass 席位
+type OrderRequest struct {
+	Legs       []OrderLegRequest
+	Passengers []OrderPassengerRequest
+	APIKeyID   int
+}
+
+type OrderService interface {
+	CreateOrder(ctx context.Context, req OrderRequest) (*dao.Order, error)
+	GetOrder(ctx context.Context, id string, apiKeyID int) (*dao.Order, error)
+	ConfirmOrder(ctx context.Context, id string, apiKeyID int) (*dao.Order, error)
+	CancelOrder(ctx context.Context, id string, apiKeyID int) (*dao.Order, error)
+	ExpireOrders(ctx context.Context) (int, error)
+	RunExpiry(ctx context.Context, interval time.Duration)
+}
+
+type OrderServiceImpl struct {
+	OrderDAO    dao.OrderDAO
+	Railways    RailwayService
+	Logger      *slog.Logger
+	HoldTimeout time.Duration
+	// SeatsReleased 取消、退票或超时退还座位后调用，用于唤醒候补兑现
+	SeatsReleased func()
+
+	now func() time.Time
+}
+
+var _ OrderService = (*OrderServiceImpl)(nil)
+
+func NewOrderService(OrderDAO dao.OrderDAO, Railways RailwayService, holdTimeout time.Duration, logger *slog.Logger) OrderServiceImpl {
+	if holdTimeout <= 0 {
+		holdTimeout = DefaultHoldTimeout
+	}
+	return OrderServiceImpl{
+		OrderDAO:    OrderDAO,
+		Railways:    Railways,
+		Logger:      logger,
+		HoldTimeout: holdTimeout,
+		now:         time.Now,
+	}
+}
+
+func (s *OrderServiceImpl) logger(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, s.Logger)
+}
+
+// CreateOrder 校验各段并按席别与旅客类型计价，逐段扣减余票后保存为 held 订单；
+// 任一段余票不足时退还已扣减的各段并返回 dao.ErrSeatsSoldOut
+func (s *OrderServiceImpl) CreateOrder(ctx context.Context, req OrderRequest) (*dao.Order, error) {
+	if len(req.Legs) == 0 || len(req.Legs) > MaxOrderLegs {
+		return nil, fmt.Errorf("%w: legs: must have 1 to %d items", ErrInvalidOrder, MaxOrderLegs)
+	}
+	if len(req.Passengers) == 0 || len(req.Passengers) > MaxOrderPassengers {
+		return nil, fmt.Errorf("%w: passengers: must have 1 to %d items", ErrInvalidOrder, MaxOrderPassengers)
+	}
+	now := s.now()
+	order := &dao.Order{
+		Status:     dao.OrderHeld,
+		APIKeyID:   req.APIKeyID,
+		HoldUntil:  now.Add(s.HoldTimeout),
+		Legs:       make([]dao.OrderLeg, 0, len(req.Legs)),
+		Passengers: make([]dao.OrderPassenger, 0, len(req.Passengers)),
+	}
+	fareLegs := make([]fare.Leg, 0, len(req.Legs))
+	for index, legReq := range req.Legs {
+		leg, err := s.Railways.GetLeg(ctx, legReq.TrainNo, legReq.From, legReq.To)
+		if err != nil {
+			return nil, err
+		}
+		if leg == nil {
+			return nil, fmt.Errorf("%w: legs[%d]: %s does not run from %s to %s", ErrLegNotFound, index, legReq.TrainNo, legReq.From, legReq.To)
+		}
+		price := seatFare(*leg, legReq.SeatClass)
+		if price < 0.5 {
+			return nil, fmt.Errorf("%w: legs[%d]: %s", ErrSeatClassNotSold, index, legReq.SeatClass)
+		}
+		orderLeg := dao.OrderLeg{
+			Seq:           index,
+			TrainNo:       legReq.TrainNo,
+			TrainNumber:   leg.TrainNumber,
+			Date:          legReq.Date,
+			From:          legReq.From,
+			To:            legReq.To,
+			DepartureTime: leg.DepartureTime,
+			ArrivalTime:   leg.ArrivalTime,
+			SeatClass:     legReq.SeatClass,
+			Price:         price,
+		}
+		departure, err := legDeparture(orderLeg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: legs[%d].date: %v", ErrInvalidOrder, index, err)
+		}
+		if !departure.After(now) {
+			return nil, fmt.Errorf("%w: legs[%d]", ErrOrderDeparted, index)
+		}
+		order.Legs = append(order.Legs, orderLeg)
+		fareLegs = append(fareLegs, fare.Leg{
+			TrainNumber: leg.TrainNumber,
+			From:        legReq.From,
+			To:          legReq.To,
+			SeatClass:   legReq.SeatClass,
+			BaseFare:    price,
+		})
+	}
+	for _, passenger := range req.Passengers {
+		quote := FareEngine.Price(passenger.Type, fareLegs)
+		order.Passengers = append(order.Passengers, dao.OrderPassenger{
+			Name:     passenger.Name,
+			IDNumber: passenger.IDNumber,
+			Type:     string(quote.Passenger),
+			Amount:   quote.Total,
+		})
+		order.TotalAmount = order.TotalAmount + quote.Total
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	order.ID = id
+
+	for index, leg := range order.Legs {
+		err := s.Railways.ReserveSeats(ctx, leg.TrainNo, leg.Date, leg.From, leg.To, leg.SeatClass, len(order.Passengers))
+		if err != nil {
+			s.releaseSeats(ctx, order.ID, order.Legs[:index], len(order.Passengers))
+			return nil, err
+		}
+	}
+	if err := s.OrderDAO.CreateOrder(ctx, order); err != nil {
+		s.logger(ctx).Error("query failed", "method", "CreateOrder", "order_id", order.ID, "err", err)
+		s.releaseSeats(ctx, order.ID, order.Legs, len(order.Passengers))
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetOrder 查询 apiKeyID 创建的订单，占座已超时的 held 订单先释放再返回
+func (s *OrderServiceImpl) GetOrder(ctx context.Context, id string, apiKeyID int) (*dao.Order, error) {
+	order, err := s.getOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// 其他使用方的订单与不存在的订单一样处理
+	if order.APIKeyID != apiKeyID {
+		return nil, ErrOrderNotFound
+	}
+	if order.Status == dao.OrderHeld && s.now().After(order.HoldUntil) {
+		return s.expireOrder(ctx, order)
+	}
+	return order, nil
+}
+
+// ConfirmOrder held 订单在 HoldUntil 之前确认，超时后返回 ErrOrderExpired
+func (s *OrderServiceImpl) ConfirmOrder(ctx context.Context, id string, apiKeyID int) (*dao.Order, error) {
+	order, err := s.GetOrder(ctx, id, apiKeyID)
+	if err != nil {
+		return nil, err
+	}
+	switch order.Status {
+	case dao.OrderHeld:
+	case dao.OrderExpired:
+		return order, ErrOrderExpired
+	default:
+		return order, dao.ErrOrderStateChanged
+	}
+	now := s.now()
+	if err := s.OrderDAO.UpdateOrderStatus(ctx, order.ID, dao.OrderHeld, dao.OrderConfirmed, map[string]any{"confirmed_at": now}); err != nil {
+		return nil, s.transitionFailed(ctx, "ConfirmOrder", order.ID, err)
+	}
+	order.Status = dao.OrderConfirmed
+	order.ConfirmedAt = &now
+	return order, nil
+}
+
+// CancelOrder held 订单直接取消；confirmed 订单按 RefundFeeSchedule 扣除退票费后退票，首段发车后不能退票
+func (s *OrderServiceImpl) CancelOrder(ctx context.Context, id string, apiKeyID int) (*dao.Order, error) {
+	order, err := s.GetOrder(ctx, id, apiKeyID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	switch order.Status {
+	case dao.OrderHeld:
+		err = s.OrderDAO.UpdateOrderStatus(ctx, order.ID, dao.OrderHeld, dao.OrderCancelled, map[string]any{"closed_at": now})
+		if err != nil {
+			return nil, s.transitionFailed(ctx, "CancelOrder", order.ID, err)
+		}
+		order.Status = dao.OrderCancelled
+	case dao.OrderConfirmed:
+		departure, err := legDeparture(order.Legs[0])
+		if err != nil {
+			return nil, err
+		}
+		if !departure.After(now) {
+			return order, ErrOrderDeparted
+		}
+		fee := RefundFee(order.Passengers, departure.Sub(now))
+		updates := map[string]any{"closed_at": now, "refund_fee": fee, "refund_amount": order.TotalAmount - fee}
+		if err := s.OrderDAO.UpdateOrderStatus(ctx, order.ID, dao.OrderConfirmed, dao.OrderRefunded, updates); err != nil {
+			return nil, s.transitionFailed(ctx, "CancelOrder", order.ID, err)
+		}
+		order.Status = dao.OrderRefunded
+		order.RefundFee = fee
+		order.RefundAmount = order.TotalAmount - fee
+	default:
+		return order, dao.ErrOrderStateChanged
+	}
+	order.ClosedAt = &now
+	s.releaseSeats(ctx, order.ID, order.Legs, len(order.Passengers))
+	return order, nil
+}
+
+// ExpireOrders 释放占座已超时的订单，返回释放的订单数
+func (s *OrderServiceImpl) ExpireOrders(ctx context.Context) (int, error) {
+	orders, err := s.OrderDAO.GetExpiredOrders(ctx, s.now(), expiredOrderBatch)
+	if err != nil {
+		s.logger(ctx).Error("query failed", "method", "ExpireOrders", "err", err)
+		return 0, err
+	}
+	expired := 0
+	for i := range orders {
+		if _, err := s.expireOrder(ctx, &orders[i]); err == nil {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// RunExpiry 每隔 interval 释放一次超时订单，ctx 取消后返回；interval 不大于 0 时为一分钟
+func (s *OrderServiceImpl) RunExpiry(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if expired, err := s.ExpireOrders(ctx); err == nil && expired > 0 {
+				s.logger(ctx).Info("orders expired", "count", expired)
+			}
+		}
+	}
+}
+
+// RefundFee 按距发车时间 before 查找费率，每位乘车人的退票费按 0.5 元四舍五入
+func RefundFee(passengers []dao.OrderPassenger, before time.Duration) float64 {
+	rate := RefundFeeSchedule[len(RefundFeeSchedule)-1].Rate
+	for _, tier := range RefundFeeSchedule {
+		if before >= tier.Before {
+			rate = tier.Rate
+			break
+		}
+	}
+	fee := 0.0
+	for _, passenger := range passengers {
+		fee = fee + math.Round(passenger.Amount*rate*2)/2
+	}
+	return fee
+}
+
+func (s *OrderServiceImpl) getOrder(ctx context.Context, id string) (*dao.Order, error) {
+	order, err := s.OrderDAO.GetOrder(ctx, id)
+	if err != nil {
+		s.logger(ctx).Error("query failed", "method", "GetOrder", "order_id", id, "err", err)
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// expireOrder 并发的确认或取消先完成时返回其结果
+func (s *OrderServiceImpl) expireOrder(ctx context.Context, order *dao.Order) (*dao.Order, error) {
+	now := s.now()
+	err := s.OrderDAO.UpdateOrderStatus(ctx, order.ID, dao.OrderHeld, dao.OrderExpired, map[string]any{"closed_at": now})
+	if errors.Is(err, dao.ErrOrderStateChanged) {
+		return s.getOrder(ctx, order.ID)
+	}
+	if err != nil {
+		return nil, s.transitionFailed(ctx, "expireOrder", order.ID, err)
+	}
+	order.Status = dao.OrderExpired
+	order.ClosedAt = &now
+	s.releaseSeats(ctx, order.ID, order.Legs, len(order.Passengers))
+	return order, nil
+}
+
+// releaseSeats 订单状态已经转换，退还失败只记录日志；
+// 请求被取消或超时后仍要退还，否则已扣减的余票会一直占用
+func (s *OrderServiceImpl) releaseSeats(ctx context.Context, orderID string, legs []dao.OrderLeg, count int) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+	for _, leg := range legs {
+		if err := s.Railways.ReleaseSeats(ctx, leg.TrainNo, leg.Date, leg.From, leg.To, leg.SeatClass, count); err != nil {
+			s.logger(ctx).Error("release seats failed", "order_id", orderID, "train_no", leg.TrainNo, "date", leg.Date, "err", err)
+		}
+	}
+	if len(legs) > 0 && s.SeatsReleased != nil {
+		s.SeatsReleased()
+	}
+}
+
+func (s *OrderServiceImpl) transitionFailed(ctx context.Context, method, orderID string, err error) error {
+	if !errors.Is(err, dao.ErrOrderStateChanged) {
+		s.logger(ctx).Error("query failed", "method", method, "order_id", orderID, "err", err)
+	}
+	return err
+}
+
+// legDeparture 一段的发车时刻（北京时间）
+func legDeparture(leg dao.OrderLeg) (time.Time, error) {
+	return time.ParseInLocation(seatDateLayout+" 15:04", leg.Date+" "+leg.DepartureTime, timetableZone)
+}
+
+// newID 订单号与候补单号，32 位十六进制随机数
+func newID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
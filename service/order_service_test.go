@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"railway/dao"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryRailWayDAO 内存中的时刻表，只实现下单用到的方法
+type memoryRailWayDAO struct {
+	dao.RailWayDAO
+	railWays []dao.RailWay
+}
+
+func (m *memoryRailWayDAO) GetRailWayByTrainNo(_ context.Context, trainNo string) ([]dao.RailWay, error) {
+	result := make([]dao.RailWay, 0)
+	for _, railWay := range m.railWays {
+		if railWay.TrainNo == trainNo {
+			result = append(result, railWay)
+		}
+	}
+	return result, nil
+}
+
+func (m *memoryRailWayDAO) GetRailWayByDepartureStationAndArrivalStationAndTrainNo(_ context.Context, departureName, arrivalName, trainNo string) (*dao.RailWay, error) {
+	for _, railWay := range m.railWays {
+		if railWay.DepartureStation == departureName && railWay.ArrivalStation == arrivalName && railWay.TrainNo == trainNo {
+			return &railWay, nil
+		}
+	}
+	return nil, nil
+}
+
+// memorySeatDAO 与数据库实现一样整体扣减各区间，并记录出现过的最小余票；ctx 取消后拒绝写入
+type memorySeatDAO struct {
+	mu        sync.Mutex
+	remaining map[string]int
+	capacity  map[string]int
+	lowest    int
+}
+
+func newMemorySeatDAO() *memorySeatDAO {
+	return &memorySeatDAO{remaining: make(map[string]int), capacity: make(map[string]int)}
+}
+
+func seatKey(trainNo, date, seatClass string, segment int) string {
+	return fmt.Sprintf("%s/%s/%s/%d", trainNo, date, seatClass, segment)
+}
+
+func (m *memorySeatDAO) CreateSeatInventories(_ context.Context, inventories []dao.SeatInventory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, inventory := range inventories {
+		key := seatKey(inventory.TrainNo, inventory.Date, inventory.SeatClass, inventory.Segment)
+		if _, ok := m.remaining[key]; !ok {
+			m.remaining[key] = inventory.Remaining
+			m.capacity[key] = inventory.Capacity
+		}
+	}
+	return nil
+}
+
+func (m *memorySeatDAO) GetSeatInventories(_ context.Context, trainNo, date string) ([]dao.SeatInventory, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inventories := make([]dao.SeatInventory, 0)
+	for key, remaining := range m.remaining {
+		if strings.HasPrefix(key, trainNo+"/"+date+"/") {
+			inventories = append(inventories, dao.SeatInventory{TrainNo: trainNo, Date: date, Remaining: remaining})
+		}
+	}
+	return inventories, nil
+}
+
+func (m *memorySeatDAO) GetSeatInventoriesByTrains(_ context.Context, trains []dao.TrainDate) ([]dao.SeatInventory, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inventories := make([]dao.SeatInventory, 0)
+	for _, train := range trains {
+		for key, remaining := range m.remaining {
+			prefix := train.TrainNo + "/" + train.Date + "/"
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			seatClass, segmentText, _ := strings.Cut(strings.TrimPrefix(key, prefix), "/")
+			segment, _ := strconv.Atoi(segmentText)
+			inventories = append(inventories, dao.SeatInventory{TrainNo: train.TrainNo, Date: train.Date, SeatClass: seatClass, Segment: segment, Remaining: remaining})
+		}
+	}
+	return inventories, nil
+}
+
+func (m *memorySeatDAO) DecrementSeats(ctx context.Context, trainNo, date, seatClass string, fromSegment, toSegment, count int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for segment := fromSegment; segment < toSegment; segment++ {
+		if m.remaining[seatKey(trainNo, date, seatClass, segment)] < count {
+			return dao.ErrSeatsSoldOut
+		}
+	}
+	for segment := fromSegment; segment < toSegment; segment++ {
+		key := seatKey(trainNo, date, seatClass, segment)
+		m.remaining[key] = m.remaining[key] - count
+		m.lowest = min(m.lowest, m.remaining[key])
+	}
+	return nil
+}
+
+func (m *memorySeatDAO) IncrementSeats(ctx context.Context, trainNo, date, seatClass string, fromSegment, toSegment, count int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for segment := fromSegment; segment < toSegment; segment++ {
+		key := seatKey(trainNo, date, seatClass, segment)
+		m.remaining[key] = min(m.remaining[key]+count, m.capacity[key])
+	}
+	return nil
+}
+
+func (m *memorySeatDAO) seats(trainNo, date, seatClass string, segment int) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.remaining[seatKey(trainNo, date, seatClass, segment)]
+}
+
+// memoryOrderDAO 忽略 ctx，模拟状态转换已提交而请求随后被取消
+type memoryOrderDAO struct {
+	mu     sync.Mutex
+	orders map[string]dao.Order
+}
+
+func (m *memoryOrderDAO) CreateOrder(_ context.Context, order *dao.Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.orders[order.ID] = *order
+	return nil
+}
+
+func (m *memoryOrderDAO) GetOrder(_ context.Context, id string) (*dao.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order, ok := m.orders[id]
+	if !ok {
+		return nil, nil
+	}
+	return &order, nil
+}
+
+func (m *memoryOrderDAO) UpdateOrderStatus(_ context.Context, id, from, to string, _ map[string]any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order, ok := m.orders[id]
+	if !ok || order.Status != from {
+		return dao.ErrOrderStateChanged
+	}
+	order.Status = to
+	m.orders[id] = order
+	return nil
+}
+
+func (m *memoryOrderDAO) GetExpiredOrders(context.Context, time.Time, int) ([]dao.Order, error) {
+	return nil, nil
+}
+
+const testTrainNo = "240000G1010A"
+
+func newTestOrderService() (*OrderServiceImpl, *memorySeatDAO) {
+	railWay := func(from, to, departure, arrival string) dao.RailWay {
+		return dao.RailWay{TrainNumber: "G101", TrainNo: testTrainNo, DepartureStation: from, ArrivalStation: to,
+			DepartureTime: departure, ArrivalTime: arrival, RunningTime: "01:00", Price: 100, ZEPrice: 100, SWZPrice: 300}
+	}
+	railWays := &memoryRailWayDAO{railWays: []dao.RailWay{
+		railWay("北京南", "济南西", "08:00", "09:30"),
+		railWay("北京南", "南京南", "08:00", "11:30"),
+		railWay("济南西", "南京南", "09:35", "11:30"),
+	}}
+	seats := newMemorySeatDAO()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	railways := NewRailwayService(railWays, nil, nil, seats, logger)
+	orders := NewOrderService(&memoryOrderDAO{orders: make(map[string]dao.Order)}, &railways, 0, logger)
+	return &orders, seats
+}
+
+func testOrderRequest(legs ...OrderLegRequest) OrderRequest {
+	return OrderRequest{
+		Legs:       legs,
+		Passengers: []OrderPassengerRequest{{Name: "张三", IDNumber: "110101199001011234"}},
+	}
+}
+
+// TestCreateOrderConcurrent 并发下单不超卖，售出的正好是座位数
+func TestCreateOrderConcurrent(t *testing.T) {
+	orders, seats := newTestOrderService()
+	date := time.Now().AddDate(0, 0, 1).Format(seatDateLayout)
+	capacity := DefaultSeatCapacity[SeatSWZ]
+	buyers := capacity * 5
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := orders.CreateOrder(context.Background(), testOrderRequest(
+				OrderLegRequest{TrainNo: testTrainNo, Date: date, From: "北京南", To: "南京南", SeatClass: SeatSWZ}))
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != capacity {
+		t.Fatalf("succeeded = %d, want %d", succeeded, capacity)
+	}
+	if seats.lowest < 0 {
+		t.Fatalf("remaining seats went down to %d", seats.lowest)
+	}
+	for segment := 0; segment < 2; segment++ {
+		if remaining := seats.seats(testTrainNo, date, SeatSWZ, segment); remaining != 0 {
+			t.Fatalf("segment %d remaining = %d, want 0", segment, remaining)
+		}
+	}
+}
+
+// TestReleaseSeatsAfterCancel 请求已取消时回滚和过期仍退还余票
+func TestReleaseSeatsAfterCancel(t *testing.T) {
+	orders, seats := newTestOrderService()
+	date := time.Now().AddDate(0, 0, 1).Format(seatDateLayout)
+	capacity := DefaultSeatCapacity[SeatSWZ]
+
+	order, err := orders.CreateOrder(context.Background(), testOrderRequest(
+		OrderLegRequest{TrainNo: testTrainNo, Date: date, From: "北京南", To: "济南西", SeatClass: SeatSWZ}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining := seats.seats(testTrainNo, date, SeatSWZ, 0); remaining != capacity-1 {
+		t.Fatalf("remaining = %d, want %d", remaining, capacity-1)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := orders.expireOrder(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := seats.seats(testTrainNo, date, SeatSWZ, 0); remaining != capacity {
+		t.Fatalf("remaining after expiry = %d, want %d", remaining, capacity)
+	}
+}
+
+// TestRemainingSeatsReadOnly 查询余票不放票，未放票的车按 DefaultSeatCapacity 计；下单后批量查询反映各区间的余票
+func TestRemainingSeatsReadOnly(t *testing.T) {
+	orders, seats := newTestOrderService()
+	date := time.Now().AddDate(0, 0, 1).Format(seatDateLayout)
+	capacity := DefaultSeatCapacity[SeatSWZ]
+
+	remaining, err := orders.Railways.RemainingSeats(context.Background(), testTrainNo, date, "北京南", "南京南")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining[SeatSWZ] != capacity {
+		t.Fatalf("remaining = %d, want %d", remaining[SeatSWZ], capacity)
+	}
+	if len(seats.remaining) != 0 {
+		t.Fatalf("query wrote %d inventory rows", len(seats.remaining))
+	}
+
+	if _, err := orders.CreateOrder(context.Background(), testOrderRequest(
+		OrderLegRequest{TrainNo: testTrainNo, Date: date, From: "北京南", To: "济南西", SeatClass: SeatSWZ})); err != nil {
+		t.Fatal(err)
+	}
+	results, err := orders.Railways.BatchRemainingSeats(context.Background(), []SeatQuery{
+		{TrainNo: testTrainNo, Date: date, From: "北京南", To: "济南西"},
+		{TrainNo: testTrainNo, Date: date, From: "济南西", To: "南京南"},
+		{TrainNo: testTrainNo, Date: date, From: "南京南", To: "北京南"},
+		{TrainNo: testTrainNo, Date: date, From: "北京南", To: "南京南"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{capacity - 1, capacity, -1, capacity - 1}
+	for index, result := range results {
+		if want[index] < 0 {
+			if result != nil {
+				t.Fatalf("query %d = %v, want nil", index, result)
+			}
+			continue
+		}
+		if result[SeatSWZ] != want[index] {
+			t.Fatalf("query %d remaining = %d, want %d", index, result[SeatSWZ], want[index])
+		}
+	}
+}
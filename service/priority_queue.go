@@ -6,7 +6,9 @@ type Item struct {
 	transferTimes int64
 	index         int
 	price         float64
-	specialTag    bool //如果上一条边由A到D且中转时间小于15分钟，则这个Tag为true
+	specialTag    bool  //如果上一条边由A到D且中转时间小于15分钟，则这个Tag为true
+	delay         int64 //按实时动态到达该点时的晚点分钟数
+	viaState            //经过途经站的进度
 }
 
 // PriorityQueue：最小堆的实现
@@ -46,7 +48,9 @@ type Item2 struct {
 	transferTimes int64
 	index         int
 	price         float64
-	specialTag    bool //如果上一条边由A到D且中转时间小于15分钟，则这个Tag为true
+	specialTag    bool  //如果上一条边由A到D且中转时间小于15分钟，则这个Tag为true
+	delay         int64 //按实时动态到达该点时的晚点分钟数
+	viaState            //经过途经站的进度
 }
 
 // PriorityQueue：最小堆的实现
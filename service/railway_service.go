@@ -1,14 +1,18 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/xuri/excelize/v2"
-	"log"
+	"log/slog"
 	"railway/dao"
+	"railway/logging"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -68,6 +72,7 @@ type TemplateTrainSchedule struct {
 	SWZPrice         []float64
 	TZPrice          []float64
 	GRPrice          []float64
+	SeatClass        []string
 	AllRunningTime   uint
 }
 
@@ -81,21 +86,39 @@ type AnalyseTrans struct {
 	StationSequence []string
 	AllRunningTime  int64
 	ToTalPrice      float64
-	TransFerTimes   int64 //中转次数
-	NowArrivalDay   int64 //目前所在第几天
+	LegPrice        float64 //当前所乘车次从上车站到所在点的票价，同一车次按一张 O/D 车票计价
+	TransFerTimes   int64   //中转次数
+	NowArrivalDay   int64   //目前所在第几天
+	Penalty         int64   //乘坐优先车次之外的车次累计的惩罚分钟数，已计入 AllRunningTime
 }
 
 type RailwayService interface {
-	SearchDirectly(departureStation, arrivalStation, speedOption string, sortOption int) (map[string][]dao.RailWay, error)
-	SearchDirectlyOnline(departureStation, arrivalStation string) (map[string][]dao.RailWay, error)
-	SearchWithOneTrans(departureStation, arrivalStation, speedOption string, sortOption int, limitStopTime, getAllResult int64) (map[string][]dao.RailWay, error)
-	SearchWithOneSpecificTrans(departureStation, midStation, arrivalStation, speedOption string, sortOption int, limitStopTime int64) (map[string][]dao.RailWay, error)
-	SearchWithTwoTrans(departureStation, arrivalStation, speedOption string, maxTrans, recordNumber int64, sortOption int) (map[string][]dao.RailWay, error)
+	SearchDirectly(ctx context.Context, departureStation, arrivalStation, speedOption string, sortOption int, seatClasses []string, date string) (map[string][]dao.RailWay, error)
+	SearchDirectlyOnline(ctx context.Context, departureStation, arrivalStation string) (map[string][]dao.RailWay, error)
+	SearchWithOneTrans(ctx context.Context, departureStation, arrivalStation, speedOption string, sortOption int, limitStopTime, getAllResult int64, seatClasses []string, date string) (map[string][]dao.RailWay, error)
+	SearchWithOneSpecificTrans(ctx context.Context, departureStation, midStation, arrivalStation, speedOption string, sortOption int, limitStopTime int64, seatClasses []string, date string) (map[string][]dao.RailWay, error)
+	SearchWithTwoTrans(ctx context.Context, departureStation, arrivalStation, speedOption string, maxTrans, recordNumber int64, sortOption int, seatClasses []string, date string) (map[string][]dao.RailWay, error)
+	SearchWithConstraints(ctx context.Context, departureStation, arrivalStation, speedOption string, maxTrans, recordNumber int64, sortOption int, seatClasses []string, date string, constraints SearchConstraints) (map[string][]dao.RailWay, error)
+	LookupStations(ctx context.Context, keyword string) ([]dao.City, []dao.Station, error)
+	Autocomplete(ctx context.Context, keyword string, limit int) ([]Suggestion, error)
+	ListCities(ctx context.Context, keyword string) ([]dao.City, error)
+	GetCity(ctx context.Context, cityCode string) (*dao.City, []dao.Station, error)
+	GetTrain(ctx context.Context, trainNo string) (*TrainDetail, error)
+	GetTrainByNumber(ctx context.Context, trainNumber string) (*TrainDetail, error)
+	GetLeg(ctx context.Context, trainNo, from, to string) (*dao.RailWay, error)
+	RemainingSeats(ctx context.Context, trainNo, date, from, to string) (map[string]int, error)
+	BatchRemainingSeats(ctx context.Context, queries []SeatQuery) ([]map[string]int, error)
+	ReserveSeats(ctx context.Context, trainNo, date, from, to, seatClass string, count int) error
+	ReleaseSeats(ctx context.Context, trainNo, date, from, to, seatClass string, count int) error
+	Ready(ctx context.Context) error
 }
 
 type RailWayServiceImpl struct {
 	RailWayDAO dao.RailWayDAO
 	StationDAO dao.StationDAO
+	CityDAO    dao.CityDAO
+	SeatDAO    dao.SeatDAO
+	Logger     *slog.Logger
 }
 
 var (
@@ -105,38 +128,75 @@ var (
 
 	//图，前面的string是图中的点，以D或者A开头（表示出发还是到达）加上站点名加上车次NO加上第几天的车；后面的[]是从这个点出发的边 在站内转乘时TrainNumber记为Waiting，TrainNo为arrival的TrainNo，这样能够找到下一班车所在点
 	Graph               = make(map[string][]dao.RailWay)
-	TemplateGraph       = make(map[string][]dao.RailWay) //记录临时添加的点和边，后续需要删除
 	KeyStationDeparture = make(map[string][]dao.RailWay) //记录关键站点的所有离开的车
 	KeyStationArrival   = make(map[string][]dao.RailWay) //记录关键站点的所有到达的车
-	dist                = make([]map[string]AnalyseTrans, 0)
+	//搜索持有读锁；建图在锁外进行，完成后持有写锁替换 Graph、TrainFares 与关键站点。rebuildMu 保证同一时间只建一次图
+	graphMu          sync.RWMutex
+	rebuildMu        sync.Mutex
+	timetableVersion atomic.Uint64 //时刻表版本，重新加载时刻表或重新建图后加一
+	graphBuilt       atomic.Bool   //InitBuildGraph 是否已完成
 )
 
-func NewRailwayService(RailWayDAO dao.RailWayDAO, StationDAO dao.StationDAO) RailWayServiceImpl {
+// TimetableVersion 时刻表版本号，查询缓存据此判断结果是否已经失效
+func TimetableVersion() uint64 {
+	return timetableVersion.Load()
+}
+
+func bumpTimetableVersion() {
+	timetableVersion.Add(1)
+}
+
+// Ready 数据库可以连接且换乘图已经构建完成时返回 nil
+func (r *RailWayServiceImpl) Ready(ctx context.Context) error {
+	if err := r.StationDAO.Ping(ctx); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	if !graphBuilt.Load() {
+		return errors.New("graph: not built")
+	}
+	return nil
+}
+
+func NewRailwayService(RailWayDAO dao.RailWayDAO, StationDAO dao.StationDAO, CityDAO dao.CityDAO, SeatDAO dao.SeatDAO, logger *slog.Logger) RailWayServiceImpl {
 	return RailWayServiceImpl{
 		RailWayDAO: RailWayDAO,
 		StationDAO: StationDAO,
+		CityDAO:    CityDAO,
+		SeatDAO:    SeatDAO,
+		Logger:     logger,
 	}
 }
 
-func (r *RailWayServiceImpl) SearchDirectly(departureStation, arrivalStation, speedOption string, sortOption int) (returnResult map[string][]dao.RailWay, err error) {
-	if !r.checkStation(departureStation) || !r.checkStation(arrivalStation) {
-		log.Printf("[SearchDirectly] stationNotFind")
-		return nil, errors.New("stationNotFind")
+// logger 返回带有请求 ID 的 logger
+func (r *RailWayServiceImpl) logger(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, r.Logger)
+}
+
+// SearchDirectly seatClasses 为按优先顺序排列的席别，每段按第一个有售的席别计价，为空时取最低价；
+// date 不为空时按当天的实时动态修正时刻并去掉停运的车次
+func (r *RailWayServiceImpl) SearchDirectly(ctx context.Context, departureStation, arrivalStation, speedOption string, sortOption int, seatClasses []string, date string) (returnResult map[string][]dao.RailWay, err error) {
+	departureStation, err = r.ResolveStation(ctx, departureStation)
+	if err != nil {
+		return nil, err
+	}
+	arrivalStation, err = r.ResolveStation(ctx, arrivalStation)
+	if err != nil {
+		return nil, err
 	}
 	result := make([]dao.RailWay, 0)
 	switch speedOption {
 	case OnlyHighSpeed:
-		result, err = r.RailWayDAO.GetRailWayByDepartureStationAndArrivalStationOnlyHighSpeed(departureStation, arrivalStation)
+		result, err = r.RailWayDAO.GetRailWayByDepartureStationAndArrivalStationOnlyHighSpeed(ctx, departureStation, arrivalStation)
 	case OnlyLowSpeed:
-		result, err = r.RailWayDAO.GetRailWayByDepartureStationAndArrivalStationOnlyLowSpeed(departureStation, arrivalStation)
+		result, err = r.RailWayDAO.GetRailWayByDepartureStationAndArrivalStationOnlyLowSpeed(ctx, departureStation, arrivalStation)
 	default:
-		result, err = r.RailWayDAO.GetRailWayByDepartureStationAndArrivalStation(departureStation, arrivalStation)
+		result, err = r.RailWayDAO.GetRailWayByDepartureStationAndArrivalStation(ctx, departureStation, arrivalStation)
 	}
 	if err != nil {
-		log.Printf("[SearchDirectly] err:%s", err.Error())
+		r.logger(ctx).Error("query failed", "method", "SearchDirectly", "err", err)
 		return nil, err
 	}
-	result = resultDedUp(result)
+	result = currentRealtime(date).legs(ApplySeatClasses(resultDedUp(result), seatClasses))
 	switch sortOption {
 	case LowRunningTimeFirst:
 		result = sortByLowRunningTime(result)
@@ -153,85 +213,138 @@ func (r *RailWayServiceImpl) SearchDirectly(departureStation, arrivalStation, sp
 	}
 	return turnSliceToMap(result), nil
 }
-func (r *RailWayServiceImpl) SearchDirectlyOnline(departureStation, arrivalStation string) (map[string][]dao.RailWay, error) {
+func (r *RailWayServiceImpl) SearchDirectlyOnline(ctx context.Context, departureStation, arrivalStation string) (map[string][]dao.RailWay, error) {
 	return nil, errors.New("not implement")
 }
 
-func (r *RailWayServiceImpl) SearchWithOneSpecificTrans(departureStation, midStation, arrivalStation, speedOption string, sortOption int, limitStopTime int64) (map[string][]dao.RailWay, error) {
-	if !r.checkStation(departureStation) || !r.checkStation(arrivalStation) || !r.checkStation(midStation) {
-		log.Printf("[SearchWithOneSpecificTrans] stationNotFind")
-		return nil, errors.New("stationNotFind")
+func (r *RailWayServiceImpl) SearchWithOneSpecificTrans(ctx context.Context, departureStation, midStation, arrivalStation, speedOption string, sortOption int, limitStopTime int64, seatClasses []string, date string) (map[string][]dao.RailWay, error) {
+	var err error
+	departureStation, err = r.ResolveStation(ctx, departureStation)
+	if err != nil {
+		return nil, err
+	}
+	arrivalStation, err = r.ResolveStation(ctx, arrivalStation)
+	if err != nil {
+		return nil, err
 	}
-	departTrain, err := r.RailWayDAO.GetRailWayByDepartureStationAndArrivalStation(departureStation, midStation)
+	midStation, err = r.ResolveStation(ctx, midStation)
 	if err != nil {
-		log.Printf("[SearchWithOneSpecificTrans] err:%s", err.Error())
 		return nil, err
 	}
-	arrivalTrain, err := r.RailWayDAO.GetRailWayByDepartureStationAndArrivalStation(midStation, arrivalStation)
+	departTrain, err := r.RailWayDAO.GetRailWayByDepartureStationAndArrivalStation(ctx, departureStation, midStation)
 	if err != nil {
-		log.Printf("[SearchWithOneSpecificTrans] err:%s", err.Error())
+		r.logger(ctx).Error("query failed", "method", "SearchWithOneSpecificTrans", "err", err)
 		return nil, err
 	}
-	result := CombineTrainSchedule(departTrain, arrivalTrain, speedOption)
+	arrivalTrain, err := r.RailWayDAO.GetRailWayByDepartureStationAndArrivalStation(ctx, midStation, arrivalStation)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "SearchWithOneSpecificTrans", "err", err)
+		return nil, err
+	}
+	result := CombineTrainSchedule(ApplySeatClasses(departTrain, seatClasses), ApplySeatClasses(arrivalTrain, seatClasses), speedOption)
+	result = currentRealtime(date).journeys(r.throughTickets(ctx, result, seatClasses))
 	return SortTransResult(result, sortOption, limitStopTime, 0), nil
 }
 
-func (r *RailWayServiceImpl) SearchWithOneTrans(departureStation, arrivalStation, speedOption string, sortOption int, limitStopTime, getAllResult int64) (map[string][]dao.RailWay, error) {
-	if !r.checkStation(departureStation) || !r.checkStation(arrivalStation) {
-		log.Printf("[SearchWithOneTrans] stationNotFind")
-		return nil, errors.New("stationNotFind")
+// SearchWithOneTrans date 不为空时按当天的实时动态修正时刻，并重新判断换乘时间是否足够
+func (r *RailWayServiceImpl) SearchWithOneTrans(ctx context.Context, departureStation, arrivalStation, speedOption string, sortOption int, limitStopTime, getAllResult int64, seatClasses []string, date string) (map[string][]dao.RailWay, error) {
+	var err error
+	departureStation, err = r.ResolveStation(ctx, departureStation)
+	if err != nil {
+		return nil, err
+	}
+	arrivalStation, err = r.ResolveStation(ctx, arrivalStation)
+	if err != nil {
+		return nil, err
 	}
-	departTrain, err := r.RailWayDAO.GetRailWayByDepartureStationWithoutArrivalStation(departureStation, arrivalStation)
+	departTrain, err := r.RailWayDAO.GetRailWayByDepartureStationWithoutArrivalStation(ctx, departureStation, arrivalStation)
 	if err != nil {
-		log.Printf("[SearchWithOneTrans ] err:%s", err.Error())
+		r.logger(ctx).Error("query failed", "method", "SearchWithOneTrans", "err", err)
 		return nil, err
 	}
-	arrivalTrain, err := r.RailWayDAO.GetRailWayByArrivalStationWithoutDepartureStation(departureStation, arrivalStation)
+	arrivalTrain, err := r.RailWayDAO.GetRailWayByArrivalStationWithoutDepartureStation(ctx, departureStation, arrivalStation)
 	if err != nil {
-		log.Printf("[SearchWithOneTrans ] err:%s", err.Error())
+		r.logger(ctx).Error("query failed", "method", "SearchWithOneTrans", "err", err)
 		return nil, err
 	}
-	result := CombineTrainSchedule(departTrain, arrivalTrain, speedOption)
+	result := CombineTrainSchedule(ApplySeatClasses(departTrain, seatClasses), ApplySeatClasses(arrivalTrain, seatClasses), speedOption)
+	for key, railWays := range r.throughTickets(ctx, result, seatClasses) {
+		// 前后两段是同一车次时合并后就是直达，已经由 SearchDirectly 返回
+		if len(railWays) < 2 {
+			delete(result, key)
+		}
+	}
+	result = currentRealtime(date).journeys(result)
 	return SortTransResult(result, sortOption, limitStopTime, getAllResult), nil
 }
 
-func (r *RailWayServiceImpl) SearchWithTwoTrans(departureStation, arrivalStation, speedOption string, maxTrans, recordNumber int64, sortOption int) (map[string][]dao.RailWay, error) {
-	if !r.checkStation(departureStation) || !r.checkStation(arrivalStation) {
-		log.Printf("[SearchWithTwoTrans] stationNotFind")
-		return nil, errors.New("stationNotFind")
+// SearchWithTwoTrans date 不为空时最短路按当天的实时动态计算各边的时间，停运的车次与赶不上的换乘不参与搜索
+func (r *RailWayServiceImpl) SearchWithTwoTrans(ctx context.Context, departureStation, arrivalStation, speedOption string, maxTrans, recordNumber int64, sortOption int, seatClasses []string, date string) (map[string][]dao.RailWay, error) {
+	var err error
+	departureStation, err = r.ResolveStation(ctx, departureStation)
+	if err != nil {
+		return nil, err
+	}
+	arrivalStation, err = r.ResolveStation(ctx, arrivalStation)
+	if err != nil {
+		return nil, err
 	}
-	TemplateGraph = make(map[string][]dao.RailWay)
+	return r.graphSearch(ctx, departureStation, arrivalStation, speedOption, maxTrans, recordNumber, sortOption, seatClasses, date, 0, nil)
+}
+
+// graphSearch 在换乘图上搜索最多 recordNumber 个方案，每找到一个方案就排除它的最后一段车次再搜索下一个；
+// 起点只考虑不早于 startTime（分钟数）发车的车次，constraints 为 nil 时不限制途经站与车次
+func (r *RailWayServiceImpl) graphSearch(ctx context.Context, departureStation, arrivalStation, speedOption string, maxTrans, recordNumber int64, sortOption int, seatClasses []string, date string, startTime int64, constraints *searchConstraints) (map[string][]dao.RailWay, error) {
+	// Graph 在搜索中只读，临时点边与 dist 都在本次搜索的 searchGraph 中，多个搜索可以并发
+	graphMu.RLock()
+	defer graphMu.RUnlock()
+	search := newSearchGraph()
 	forbidTrain := make([]string, 0)
 	answer := make(map[string][]dao.RailWay)
-	err := r.AddNewStation(departureStation, true, 0)
+	err := r.AddNewStation(ctx, search, departureStation, true, startTime)
 	if err != nil {
-		log.Printf("[SearchWithTwoTrans ] err:%s", err.Error())
+		r.logger(ctx).Error("query failed", "method", "graphSearch", "err", err)
 		return nil, err
 	}
-	err = r.AddNewStation(arrivalStation, false, 0)
+	err = r.AddNewStation(ctx, search, arrivalStation, false, 0)
 	if err != nil {
-		log.Printf("[SearchWithTwoTrans ] err:%s", err.Error())
+		r.logger(ctx).Error("query failed", "method", "graphSearch", "err", err)
 		return nil, err
 	}
+	if constraints != nil {
+		targets := constraints.viaTargets(arrivalStation)
+		for _, via := range constraints.via {
+			if err = r.AddViaStation(ctx, search, via.Station, departureStation, startTime, targets); err != nil {
+				return nil, err
+			}
+		}
+	}
+	realtime := currentRealtime(date)
 	for i := int64(0); i < recordNumber; i++ {
 		result := AnalyseTrans{}
 		if sortOption == LowPriceFirst {
-			result = DijkstraByPrice(departureStation, arrivalStation, speedOption, forbidTrain, maxTrans, sortOption)
+			result = DijkstraByPrice(ctx, search, departureStation, arrivalStation, speedOption, forbidTrain, maxTrans, sortOption, seatClasses, realtime, constraints)
 		} else {
-			result = Dijkstra(departureStation, arrivalStation, speedOption, forbidTrain, maxTrans, sortOption)
+			result = Dijkstra(ctx, search, departureStation, arrivalStation, speedOption, forbidTrain, maxTrans, sortOption, realtime, constraints)
 		}
 
+		if ctx.Err() != nil {
+			// 超时时返回已经找到的方案
+			return answer, ctx.Err()
+		}
 		if result.AllRunningTime > 1440*30 {
 			break
 		}
-		title, railways := r.convertAnalyseToRailways(result)
-		answer[title] = railways
+		title, railways := r.convertAnalyseToRailways(ctx, result)
 		forbidTrain = append(forbidTrain, result.NowTrainNo)
+		if railways, ok := realtime.journey(railways); ok {
+			answer[title] = ApplySeatClasses(railways, seatClasses)
+		}
 	}
 	return answer, nil
 }
 
-func (r *RailWayServiceImpl) convertAnalyseToRailways(trans AnalyseTrans) (string, []dao.RailWay) {
+func (r *RailWayServiceImpl) convertAnalyseToRailways(ctx context.Context, trans AnalyseTrans) (string, []dao.RailWay) {
 	title := ""
 	result := make([]dao.RailWay, 0)
 	for index, trainNumber := range trans.TrainNumber {
@@ -243,22 +356,21 @@ func (r *RailWayServiceImpl) convertAnalyseToRailways(trans AnalyseTrans) (strin
 		} else {
 			arrivalStation = trans.NowStation
 		}
-		train, err := r.RailWayDAO.GetRailWayByDepartureStationAndArrivalStationAndTrainNo(departureStation, arrivalStation, trans.TrainNo[index])
+		train, err := r.throughLeg(ctx, trans.TrainNo[index], departureStation, arrivalStation)
 		if err != nil {
-			fmt.Println("[convertAnalyseToRailways] GetRailWayByDepartureStationAndArrivalStationAndTrainNo Error")
+			r.logger(ctx).Error("query failed", "method", "convertAnalyseToRailways", "train_no", trans.TrainNo[index], "err", err)
 			return "", []dao.RailWay{}
 		}
 		if train == nil {
-			fmt.Println("[convertAnalyseToRailways] train nil!")
+			r.logger(ctx).Error("train not found", "method", "convertAnalyseToRailways", "train_no", trans.TrainNo[index])
 			return "", []dao.RailWay{}
 		}
 		if train.DepartureStation != departureStation {
-			fmt.Println("[convertAnalyseToRailways] train different departure station!")
-			fmt.Println(*train)
+			r.logger(ctx).Warn("train has different departure station", "method", "convertAnalyseToRailways", "expected", departureStation, "train", *train)
 		}
 		result = append(result, *train)
 	}
-	title = title + strconv.FormatInt(trans.AllRunningTime, 10)
+	title = title + strconv.FormatInt(trans.AllRunningTime-trans.Penalty, 10)
 	return title, result
 }
 
@@ -281,6 +393,8 @@ func SortTransResult(result map[string][]dao.RailWay, sortOption int, limitStopT
 		templateStruct = sortTemplateStructByEarlyFirst(templateStruct)
 	case LateFirst:
 		templateStruct = sortTemplateStructByLateFirst(templateStruct)
+	case LowPriceFirst:
+		templateStruct = sortTemplateStructByLowPrice(templateStruct)
 	default:
 		templateStruct = sortTemplateStructByLowRunningTime(templateStruct)
 	}
@@ -335,6 +449,7 @@ func ChangeToRailWays(tt TemplateTrainSchedule) ([]dao.RailWay, string) {
 			RWPrice:          tt.RWPrice[index],
 			ZEPrice:          tt.ZEPrice[index],
 			SWZPrice:         tt.SWZPrice[index],
+			SeatClass:        tt.SeatClass[index],
 			IsHighSpeed:      tt.IsHighSpeed[index],
 		})
 	}
@@ -363,6 +478,7 @@ func ChangeToTemplate(railWays []dao.RailWay, limitStopTime int64) TemplateTrain
 		SWZPrice:         make([]float64, 0),
 		TZPrice:          make([]float64, 0),
 		GRPrice:          make([]float64, 0),
+		SeatClass:        make([]string, 0),
 		AllRunningTime:   0,
 	}
 	for _, train := range railWays {
@@ -381,9 +497,10 @@ func ChangeToTemplate(railWays []dao.RailWay, limitStopTime int64) TemplateTrain
 		schedule.RWPrice = append(schedule.RWPrice, train.RWPrice)
 		schedule.ZEPrice = append(schedule.ZEPrice, train.ZEPrice)
 		schedule.ZYPrice = append(schedule.ZYPrice, train.ZYPrice)
-		schedule.SWZPrice = append(schedule.ZYPrice, train.SWZPrice)
+		schedule.SWZPrice = append(schedule.SWZPrice, train.SWZPrice)
 		schedule.TZPrice = append(schedule.TZPrice, train.TZPrice)
 		schedule.GRPrice = append(schedule.GRPrice, train.GRPrice)
+		schedule.SeatClass = append(schedule.SeatClass, train.SeatClass)
 		schedule.IsHighSpeed = append(schedule.IsHighSpeed, train.IsHighSpeed)
 	}
 	schedule.AllRunningTime = uint(GetAllRunningTime(schedule, limitStopTime))
@@ -460,17 +577,23 @@ func sortTemplateStructByLateFirst(result []TemplateTrainSchedule) []TemplateTra
 	return result
 }
 
-func (r *RailWayServiceImpl) checkStation(stationName string) bool {
-	station, err := r.StationDAO.GetStationByName(stationName)
-	if err != nil {
-		fmt.Println(err)
-		log.Printf("[checkStation] error err:%s\n", err.Error())
-		return false
-	}
-	if station == nil || station.StationName != stationName {
-		return false
+// sortTemplateStructByLowPrice 按各段 Price 之和排序，Price 已按席别偏好计价
+func sortTemplateStructByLowPrice(result []TemplateTrainSchedule) []TemplateTrainSchedule {
+	totalPrice := func(schedule TemplateTrainSchedule) float64 {
+		price := float64(0)
+		for _, p := range schedule.Price {
+			price = price + p
+		}
+		return price
 	}
-	return true
+	sort.Slice(result, func(i, j int) bool {
+		iPrice, jPrice := totalPrice(result[i]), totalPrice(result[j])
+		if iPrice == jPrice {
+			return result[i].AllRunningTime < result[j].AllRunningTime
+		}
+		return iPrice < jPrice
+	})
+	return result
 }
 
 func turnSliceToMap(Railways []dao.RailWay) map[string][]dao.RailWay {
@@ -615,12 +738,11 @@ func resultDedUp(result []dao.RailWay) []dao.RailWay {
 func DownLoadRailWay() error {
 	file, err := excelize.OpenFile("train_ticket_prices_2.xlsx")
 	if err != nil {
-		log.Fatalf("无法打开文件: %v", err)
+		slog.Error("无法打开文件", "err", err)
 		return err
 	}
 	sheetNames := file.GetSheetList()
 	if len(sheetNames) == 0 {
-		log.Fatal("Excel 文件中没有工作表")
 		return errors.New("Excel 文件中没有工作表")
 	}
 
@@ -629,7 +751,7 @@ func DownLoadRailWay() error {
 	// 读取 Excel 工作表的数据
 	rows, err := file.GetRows(sheetName)
 	if err != nil {
-		log.Fatalf("无法读取工作表数据: %v", err)
+		slog.Error("无法读取工作表数据", "err", err)
 		return err
 	}
 	rememberTrainNo := make(map[string]string)
@@ -672,22 +794,23 @@ func DownLoadRailWay() error {
 		originalRailway.Price = GetLowPrice(originalRailway)
 		railWays = append(railWays, originalRailway)
 		if len(railWays) > 50 {
-			err = RailWayDAO.BatchCreateRailWays(railWays)
+			err = RailWayDAO.BatchCreateRailWays(context.Background(), railWays)
 			if err != nil {
 				return err
 			}
 			sum = sum + 1
 			railWays = make([]dao.RailWay, 0)
 			if sum%200 == 0 {
-				fmt.Println(sum)
+				slog.Info("railway batches created", "batches", sum)
 			}
 		}
 	}
-	err = RailWayDAO.BatchCreateRailWays(railWays)
+	err = RailWayDAO.BatchCreateRailWays(context.Background(), railWays)
 	if err != nil {
 		return err
 	}
-	fmt.Println("railway create success")
+	slog.Info("railway create success")
+	bumpTimetableVersion()
 	return nil
 }
 func GetPrice(price string) float64 {
@@ -711,6 +834,17 @@ func GetLowPrice(railway dao.RailWay) float64 {
 	return price
 }
 
+// Fares 返回各席别的票价，键为 yw/yz/rw/ze/zy/swz/tz/gr，不售的席别不返回
+func Fares(railway dao.RailWay) map[string]float64 {
+	fares := make(map[string]float64)
+	for _, seatClass := range SeatClasses {
+		if price := seatFare(railway, seatClass); price > 0 {
+			fares[seatClass] = price
+		}
+	}
+	return fares
+}
+
 func comparePrice(priceA, priceB float64) float64 {
 	if priceA < 0.5 {
 		return priceB
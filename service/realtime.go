@@ -0,0 +1,547 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"railway/dao"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// MaxRealtimeDelay 一条实时动态允许的最大晚点分钟数
+	MaxRealtimeDelay = 24 * 60
+	// realtimeRetentionDays 始发日期早于今天这么多天的动态在下次导入时丢弃
+	realtimeRetentionDays = 3
+	// maxRealtimeChain 赶不上下一班车时沿同站发车顺延的最多班次
+	maxRealtimeChain = 64
+)
+
+var ErrInvalidRealtime = errors.New("invalidRealtime")
+
+// RealtimeUpdate 某天始发的一个车次的实时动态。Delay 为从始发站起的晚点分钟数，
+// Stations 中列出的车站从该站起改为该站的晚点并向后传递，同一车次同一天的新动态覆盖旧动态
+type RealtimeUpdate struct {
+	TrainNo   string         `json:"train_no"`
+	Date      string         `json:"date"` // 始发日期
+	Cancelled bool           `json:"cancelled"`
+	Delay     int64          `json:"delay_minutes"`
+	Stations  []StationDelay `json:"stations,omitempty"`
+}
+
+type StationDelay struct {
+	Station string `json:"station"`
+	Delay   int64  `json:"delay_minutes"`
+}
+
+// RealtimeFeed 一次推送或一个投递文件的内容
+type RealtimeFeed struct {
+	Updates []RealtimeUpdate `json:"updates"`
+}
+
+// realtimeState 一趟车的实时状态，Delays 与经停站一一对应，到站与发车晚点相同
+type realtimeState struct {
+	Cancelled bool
+	Delays    []int64
+	UpdatedAt time.Time
+}
+
+// realtimeTrain 同一车次各始发日期的实时状态，Detail 用于由车站确定站序与始发日期
+type realtimeTrain struct {
+	Detail *TrainDetail
+	Dates  map[string]*realtimeState
+}
+
+// realtimeOverlay 导入后不再修改，查询时无锁读取，导入时复制后整体替换
+type realtimeOverlay map[string]*realtimeTrain
+
+var (
+	realtimeMu      sync.Mutex
+	realtimeTrains  atomic.Pointer[realtimeOverlay]
+	realtimeVersion atomic.Uint64 //每导入一批实时动态加一
+)
+
+// RealtimeVersion 实时动态版本号，带日期的查询结果据此判断是否已经失效
+func RealtimeVersion() uint64 {
+	return realtimeVersion.Load()
+}
+
+// ApplyRealtime 校验并导入一批实时动态，任一条不合法时整批不导入并返回 ErrInvalidRealtime
+func (r *RailWayServiceImpl) ApplyRealtime(ctx context.Context, updates []RealtimeUpdate) (int, error) {
+	type resolved struct {
+		update RealtimeUpdate
+		detail *TrainDetail
+		delays []int64
+	}
+	batch := make([]resolved, 0, len(updates))
+	for index, update := range updates {
+		if _, err := time.Parse(seatDateLayout, update.Date); err != nil || update.TrainNo == "" {
+			return 0, fmt.Errorf("%w: updates[%d]: train_no and date (YYYY-MM-DD) are required", ErrInvalidRealtime, index)
+		}
+		detail, err := r.cachedTrain(ctx, update.TrainNo)
+		if err != nil {
+			if err.Error() == "trainNotFind" {
+				return 0, fmt.Errorf("%w: updates[%d]: train %s not found", ErrInvalidRealtime, index, update.TrainNo)
+			}
+			r.logger(ctx).Error("query failed", "method", "ApplyRealtime", "train_no", update.TrainNo, "err", err)
+			return 0, err
+		}
+		delays, err := realtimeDelays(detail, update)
+		if err != nil {
+			return 0, fmt.Errorf("%w: updates[%d]: %v", ErrInvalidRealtime, index, err)
+		}
+		batch = append(batch, resolved{update: update, detail: detail, delays: delays})
+	}
+
+	realtimeMu.Lock()
+	defer realtimeMu.Unlock()
+	now := time.Now()
+	cutoff := now.In(timetableZone).AddDate(0, 0, -realtimeRetentionDays).Format(seatDateLayout)
+	overlay := make(realtimeOverlay)
+	if current := realtimeTrains.Load(); current != nil {
+		for trainNo, train := range *current {
+			dates := make(map[string]*realtimeState, len(train.Dates))
+			for date, state := range train.Dates {
+				if date >= cutoff {
+					dates[date] = state
+				}
+			}
+			if len(dates) > 0 {
+				overlay[trainNo] = &realtimeTrain{Detail: train.Detail, Dates: dates}
+			}
+		}
+	}
+	for _, item := range batch {
+		train, ok := overlay[item.update.TrainNo]
+		if !ok {
+			train = &realtimeTrain{Dates: make(map[string]*realtimeState)}
+			overlay[item.update.TrainNo] = train
+		}
+		train.Detail = item.detail
+		train.Dates[item.update.Date] = &realtimeState{Cancelled: item.update.Cancelled, Delays: item.delays, UpdatedAt: now}
+	}
+	realtimeTrains.Store(&overlay)
+	realtimeVersion.Add(1)
+	return len(batch), nil
+}
+
+// realtimeDelays 把一条动态展开为各经停站的晚点分钟数
+func realtimeDelays(detail *TrainDetail, update RealtimeUpdate) ([]int64, error) {
+	if update.Delay < 0 || update.Delay > MaxRealtimeDelay {
+		return nil, fmt.Errorf("delay_minutes must be between 0 and %d", MaxRealtimeDelay)
+	}
+	stations := make(map[string]int64, len(update.Stations))
+	for _, station := range update.Stations {
+		if stopIndex(detail, station.Station, 0) < 0 {
+			return nil, fmt.Errorf("station %s is not on train %s", station.Station, update.TrainNo)
+		}
+		if station.Delay < 0 || station.Delay > MaxRealtimeDelay {
+			return nil, fmt.Errorf("stations.delay_minutes must be between 0 and %d", MaxRealtimeDelay)
+		}
+		stations[station.Station] = station.Delay
+	}
+	delays := make([]int64, len(detail.Stops))
+	delay := update.Delay
+	for index, stop := range detail.Stops {
+		if stationDelay, ok := stations[stop.Station]; ok {
+			delay = stationDelay
+		}
+		delays[index] = delay
+	}
+	return delays, nil
+}
+
+// LoadRealtimeFile 导入一个 RealtimeFeed 格式的 JSON 文件
+func (r *RailWayServiceImpl) LoadRealtimeFile(ctx context.Context, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var feed RealtimeFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidRealtime, err)
+	}
+	return r.ApplyRealtime(ctx, feed.Updates)
+}
+
+// RunRealtimeFeed 每隔 interval 按文件名顺序导入 dir 下投递的 *.json，直到 ctx 取消。
+// 导入成功的文件改名为 .done，内容不合法的改名为 .failed，其余错误保留文件下次重试
+func (r *RailWayServiceImpl) RunRealtimeFeed(ctx context.Context, dir string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.importRealtimeDir(ctx, dir)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *RailWayServiceImpl) importRealtimeDir(ctx context.Context, dir string) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		r.logger(ctx).Error("list realtime feed failed", "dir", dir, "err", err)
+		return
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		applied, err := r.LoadRealtimeFile(ctx, file)
+		suffix := ".done"
+		switch {
+		case errors.Is(err, ErrInvalidRealtime):
+			r.logger(ctx).Error("invalid realtime feed", "file", file, "err", err)
+			suffix = ".failed"
+		case err != nil:
+			r.logger(ctx).Error("import realtime feed failed", "file", file, "err", err)
+			return
+		default:
+			r.logger(ctx).Info("realtime feed imported", "file", file, "updates", applied)
+		}
+		if err := os.Rename(file, file+suffix); err != nil {
+			r.logger(ctx).Error("rename realtime feed failed", "file", file, "err", err)
+		}
+	}
+}
+
+// MockRealtimeFeed 为 date（为空时为北京时间今天）从 station 发车的车次随机生成实时动态，供本地测试投递目录与推送接口：
+// 约二十分之一停运，约三成从 station 起晚点 5 到 90 分钟
+func (r *RailWayServiceImpl) MockRealtimeFeed(ctx context.Context, station, date string, random *rand.Rand) (RealtimeFeed, error) {
+	feed := RealtimeFeed{Updates: make([]RealtimeUpdate, 0)}
+	if date == "" {
+		date = time.Now().In(timetableZone).Format(seatDateLayout)
+	}
+	station, err := r.ResolveStation(ctx, station)
+	if err != nil {
+		return feed, err
+	}
+	railWays, err := r.RailWayDAO.GetRailWayByDepartureStation(ctx, station)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "MockRealtimeFeed", "station", station, "err", err)
+		return feed, err
+	}
+	rememberTrainNo := make(map[string]bool)
+	for _, railWay := range railWays {
+		if rememberTrainNo[railWay.TrainNo] {
+			continue
+		}
+		rememberTrainNo[railWay.TrainNo] = true
+		span, err := r.seatSpan(ctx, railWay.TrainNo, date, railWay.DepartureStation, railWay.ArrivalStation)
+		if err != nil {
+			return feed, err
+		}
+		update := RealtimeUpdate{TrainNo: railWay.TrainNo, Date: span.OriginDate}
+		switch chance := random.Float64(); {
+		case chance < 0.05:
+			update.Cancelled = true
+		case chance < 0.35:
+			update.Stations = []StationDelay{{Station: station, Delay: 5 + random.Int63n(86)}}
+		default:
+			continue
+		}
+		feed.Updates = append(feed.Updates, update)
+	}
+	return feed, nil
+}
+
+// BoardEntry 车站大屏上的一趟车，Station 在出发大屏上为终到站、在到达大屏上为始发站，
+// Time 为计划时刻，ExpectedTime 为按实时动态推算的时刻
+type BoardEntry struct {
+	TrainNumber  string
+	TrainNo      string
+	Station      string
+	Time         string
+	ExpectedTime string
+	Delay        int64
+}
+
+// StationBoard date 为在该站发车（arrivals 为 true 时为到站）的日期，为空时为北京时间今天；
+// 按计划时刻排序，停运的车次不列出
+func (r *RailWayServiceImpl) StationBoard(ctx context.Context, station, date string, arrivals bool) ([]BoardEntry, error) {
+	station, err := r.ResolveStation(ctx, station)
+	if err != nil {
+		return nil, err
+	}
+	if date == "" {
+		date = time.Now().In(timetableZone).Format(seatDateLayout)
+	}
+	if _, err := time.Parse(seatDateLayout, date); err != nil {
+		return nil, errors.New("invalidDate")
+	}
+	var railWays []dao.RailWay
+	if arrivals {
+		railWays, err = r.RailWayDAO.GetRailWayByArrivalStation(ctx, station)
+	} else {
+		railWays, err = r.RailWayDAO.GetRailWayByDepartureStation(ctx, station)
+	}
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "StationBoard", "station", station, "err", err)
+		return nil, err
+	}
+	// 同一车次只保留运行时间最长的一条，即始发站到本站或本站到终到站
+	longest := make(map[string]dao.RailWay)
+	for _, railWay := range railWays {
+		current, ok := longest[railWay.TrainNo]
+		if !ok || CompareTime(railWay.RunningTime, current.RunningTime) {
+			longest[railWay.TrainNo] = railWay
+		}
+	}
+	view := currentRealtime(date)
+	entries := make([]BoardEntry, 0, len(longest))
+	for _, railWay := range longest {
+		entry := BoardEntry{TrainNumber: railWay.TrainNumber, TrainNo: railWay.TrainNo, Station: railWay.ArrivalStation, Time: railWay.DepartureTime}
+		if arrivals {
+			entry.Station, entry.Time = railWay.DepartureStation, railWay.ArrivalTime
+		}
+		delay, cancelled := view.delay(railWay.TrainNo, station, 0, arrivals)
+		if cancelled {
+			continue
+		}
+		minutes, _ := GetTime(entry.Time)
+		entry.Delay = delay
+		entry.ExpectedTime = clockTime(minutes + delay)
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		iTime, _ := GetTime(entries[i].Time)
+		jTime, _ := GetTime(entries[j].Time)
+		if iTime == jTime {
+			return entries[i].TrainNumber < entries[j].TrainNumber
+		}
+		return iTime < jTime
+	})
+	return entries, nil
+}
+
+// realtimeView 一次查询使用的实时动态快照，start 为查询日期，各处的 day 为相对查询日期的天数。
+// 没有日期或没有任何动态时为 nil，各方法按计划时刻处理
+type realtimeView struct {
+	trains realtimeOverlay
+	start  time.Time
+}
+
+func currentRealtime(date string) *realtimeView {
+	overlay := realtimeTrains.Load()
+	if date == "" || overlay == nil || len(*overlay) == 0 {
+		return nil
+	}
+	start, err := time.Parse(seatDateLayout, date)
+	if err != nil {
+		return nil
+	}
+	return &realtimeView{trains: *overlay, start: start}
+}
+
+// stop 车次在第 day 天从 station 发车（arrival 为 true 时为到站）的那一趟的实时状态与站序，没有动态时 state 为 nil
+func (v *realtimeView) stop(trainNo, station string, day int64, arrival bool) (*realtimeState, *TrainDetail, int) {
+	train, ok := v.trains[trainNo]
+	if !ok {
+		return nil, nil, -1
+	}
+	index := stopIndex(train.Detail, station, 0)
+	if index < 0 {
+		return nil, nil, -1
+	}
+	days := departureDay(train.Detail.Stops[index])
+	if arrival {
+		days = int(train.Detail.Stops[index].Day)
+	}
+	state, ok := train.Dates[v.start.AddDate(0, 0, int(day)-days).Format(seatDateLayout)]
+	if !ok || len(state.Delays) != len(train.Detail.Stops) {
+		return nil, nil, -1
+	}
+	return state, train.Detail, index
+}
+
+// delay 车次在第 day 天经过 station 的晚点分钟数以及是否停运
+func (v *realtimeView) delay(trainNo, station string, day int64, arrival bool) (int64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	state, _, index := v.stop(trainNo, station, day, arrival)
+	if state == nil {
+		return 0, false
+	}
+	return state.Delays[index], state.Cancelled
+}
+
+// leg 按实时动态修正第 day 天发车的一段，停运时返回 false
+func (v *realtimeView) leg(leg dao.RailWay, day int64) (dao.RailWay, bool) {
+	if v == nil {
+		return leg, true
+	}
+	state, detail, from := v.stop(leg.TrainNo, leg.DepartureStation, day, false)
+	if state == nil {
+		return leg, true
+	}
+	if state.Cancelled {
+		return leg, false
+	}
+	to := stopIndex(detail, leg.ArrivalStation, from+1)
+	if to < 0 {
+		return leg, true
+	}
+	departureDelay, arrivalDelay := state.Delays[from], state.Delays[to]
+	departure, _ := GetTime(leg.DepartureTime)
+	running, _ := GetTime(leg.RunningTime)
+	departure = departure + departureDelay
+	running = max(running+arrivalDelay-departureDelay, 0)
+	leg.DepartureTime = clockTime(departure)
+	leg.ArrivalTime = clockTime(departure + running)
+	leg.RunningTime = fmt.Sprintf("%02d:%02d", running/60, running%60)
+	leg.ArrivalDay = uint((departure%1440 + running) / 1440)
+	leg.DepartureDelay, leg.ArrivalDelay = departureDelay, arrivalDelay
+	return leg, true
+}
+
+// legs 修正直达结果，去掉停运的车次；返回新的切片，不修改 railWays
+func (v *realtimeView) legs(railWays []dao.RailWay) []dao.RailWay {
+	if v == nil {
+		return railWays
+	}
+	result := make([]dao.RailWay, 0, len(railWays))
+	for _, railWay := range railWays {
+		if predicted, ok := v.leg(railWay, 0); ok {
+			result = append(result, predicted)
+		}
+	}
+	return result
+}
+
+// journey 修正一个行程的各段，各段的日期按计划时刻推算（与 web 层计算各段日期的方式一致）；
+// 任一段停运，或换乘时间按预计时刻不足 DefaultStopTime 时返回 false
+func (v *realtimeView) journey(railWays []dao.RailWay) ([]dao.RailWay, bool) {
+	if v == nil {
+		return railWays, true
+	}
+	result := make([]dao.RailWay, 0, len(railWays))
+	day := int64(0)
+	for index, railWay := range railWays {
+		wait := int64(0)
+		if index > 0 {
+			wait = GetTransTime(railWays[index-1].ArrivalTime, railWay.DepartureTime, DefaultStopTime)
+			if wait >= 1440 {
+				day++
+			}
+		}
+		predicted, ok := v.leg(railWay, day)
+		if !ok {
+			return nil, false
+		}
+		if index > 0 && railWay.TrainNo != railWays[index-1].TrainNo &&
+			wait+predicted.DepartureDelay-result[index-1].ArrivalDelay < DefaultStopTime {
+			return nil, false
+		}
+		day = day + int64(railWay.ArrivalDay)
+		result = append(result, predicted)
+	}
+	return result, true
+}
+
+// journeys 修正中转结果，去掉停运或赶不上换乘的行程
+func (v *realtimeView) journeys(results map[string][]dao.RailWay) map[string][]dao.RailWay {
+	if v == nil {
+		return results
+	}
+	for key, railWays := range results {
+		predicted, ok := v.journey(railWays)
+		if !ok {
+			delete(results, key)
+			continue
+		}
+		results[key] = predicted
+	}
+	return results
+}
+
+// edge 按实时动态修正最短路中的一条边，delay 为到达 currNode 时的晚点分钟数，返回修正后的边与到达下一个点时的晚点。
+// 列车边的运行时间加上到站与发车晚点之差，停运时返回 false；站内等待边赶不上下一班车（换乘时间不足或该车停运）时，
+// 沿同一车站之后的发车顺延到第一班赶得上的车。点仍按计划时刻所在的天数编号
+func (v *realtimeView) edge(templateGraph map[string][]dao.RailWay, edge dao.RailWay, currNode string, delay int64) (dao.RailWay, int64, bool) {
+	day, currTrainNo := nodeDayAndTrain(currNode)
+	if edge.TrainNumber != Waiting {
+		state, detail, from := v.stop(edge.TrainNo, edge.DepartureStation, day, false)
+		if state == nil {
+			return edge, 0, true
+		}
+		if state.Cancelled {
+			return edge, 0, false
+		}
+		to := stopIndex(detail, edge.ArrivalStation, from+1)
+		if to < 0 {
+			return edge, 0, true
+		}
+		running, _ := GetTime(edge.RunningTime)
+		edge.RunningTime = TurnToTime(max(running+state.Delays[to]-state.Delays[from], 0))
+		return edge, state.Delays[to], true
+	}
+	transfer := strings.HasPrefix(currNode, "A/") && currTrainNo != edge.TrainNo
+	minWait := int64(0)
+	if transfer {
+		minWait = DefaultStopTime
+	}
+	wait, _ := GetTime(edge.RunningTime)
+	for step := 0; step < maxRealtimeChain; step++ {
+		departureDelay, cancelled := v.delay(edge.TrainNo, edge.ArrivalStation, int64(edge.ArrivalDay), false)
+		if !cancelled && wait+departureDelay-delay >= minWait && !(transfer && edge.TrainNo == currTrainNo) {
+			edge.RunningTime = TurnToTime(wait + departureDelay - delay)
+			return edge, departureDelay, true
+		}
+		next, ok := nextDeparture(templateGraph, "D/"+edge.ArrivalStation+"/"+edge.TrainNo+"/"+strconv.Itoa(int(edge.ArrivalDay)))
+		if !ok || next.ArrivalDay > 2 {
+			return edge, 0, false
+		}
+		nextWait, _ := GetTime(next.RunningTime)
+		wait = wait + nextWait
+		edge.TrainNo, edge.ArrivalTime, edge.ArrivalDay = next.TrainNo, next.ArrivalTime, next.ArrivalDay
+	}
+	return edge, 0, false
+}
+
+// nextDeparture 同一车站按发车时刻的下一班车，即该出发点上的站内等待边，templateGraph 为本次搜索的临时图
+func nextDeparture(templateGraph map[string][]dao.RailWay, node string) (dao.RailWay, bool) {
+	for _, graph := range []map[string][]dao.RailWay{templateGraph, Graph} {
+		for _, edge := range graph[node] {
+			if edge.TrainNumber == Waiting {
+				return edge, true
+			}
+		}
+	}
+	return dao.RailWay{}, false
+}
+
+// nodeDayAndTrain 由图中点的名称取出天数与车次，起点为第 0 天
+func nodeDayAndTrain(node string) (int64, string) {
+	parts := strings.Split(node, "/")
+	if len(parts) != 4 {
+		return 0, ""
+	}
+	day, _ := strconv.ParseInt(parts[3], 10, 64)
+	return day, parts[2]
+}
+
+// stopIndex 车站在经停站中从 from 起第一次出现的位置，不经过时返回 -1
+func stopIndex(detail *TrainDetail, station string, from int) int {
+	for index := from; index < len(detail.Stops); index++ {
+		if detail.Stops[index].Station == station {
+			return index
+		}
+	}
+	return -1
+}
+
+// clockTime 把分钟数格式化为当天的时刻，超过 24 小时的部分舍去
+func clockTime(minutes int64) string {
+	minutes = minutes % 1440
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
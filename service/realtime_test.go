@@ -0,0 +1,36 @@
+package service
+
+import (
+	"railway/dao"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestRealtimeLegsKeepsInput 去掉停运车次时不改写调用方的切片
+func TestRealtimeLegsKeepsInput(t *testing.T) {
+	detail := func(trainNo string) *TrainDetail {
+		return &TrainDetail{TrainNo: trainNo, Stops: []TrainStop{
+			{Station: "北京南", DepartureTime: "08:00"},
+			{Station: "济南西", ArrivalTime: "09:30", DepartureTime: "09:32"},
+		}}
+	}
+	date := "2024-10-01"
+	start, _ := time.Parse(seatDateLayout, date)
+	view := &realtimeView{start: start, trains: realtimeOverlay{
+		"G100": {Detail: detail("G100"), Dates: map[string]*realtimeState{date: {Cancelled: true, Delays: []int64{0, 0}}}},
+	}}
+	railWays := []dao.RailWay{
+		{TrainNo: "G100", DepartureStation: "北京南", ArrivalStation: "济南西", DepartureTime: "08:00", ArrivalTime: "09:30", RunningTime: "01:30"},
+		{TrainNo: "G200", DepartureStation: "北京南", ArrivalStation: "济南西", DepartureTime: "09:00", ArrivalTime: "10:30", RunningTime: "01:30"},
+	}
+	original := append([]dao.RailWay(nil), railWays...)
+
+	result := view.legs(railWays)
+	if len(result) != 1 || result[0].TrainNo != "G200" {
+		t.Fatalf("result = %v, want only G200", result)
+	}
+	if !reflect.DeepEqual(railWays, original) {
+		t.Fatalf("input changed to %v", railWays)
+	}
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"railway/dao"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxReplanLegs 重新规划时原行程最多的段数
+	MaxReplanLegs = 8
+
+	// 原行程中一次换乘赶不上的原因
+	ReplanMissed    = "missed"    // 按预计时刻换乘时间不足
+	ReplanCancelled = "cancelled" // 下一段停运
+)
+
+var ErrInvalidReplan = errors.New("invalidReplan")
+
+// ReplanRequest 原行程与当前位置。DelayedLeg 不小于 0 时表示正在乘坐该段且晚点 Delay 分钟，在它的下车站重新规划；
+// 否则表示已经在 Station，从 Time（分钟数）起可以乘车。Date 为当前位置的日期（DelayedLeg 为计划到站的日期），
+// 为空时不按实时动态修正，也不计算各方案的日期
+type ReplanRequest struct {
+	Legs        []dao.RailWay // 只用 TrainNo、DepartureStation、ArrivalStation，时刻以时刻表为准
+	DelayedLeg  int
+	Delay       int64
+	Station     string
+	Time        int64
+	Date        string
+	SpeedOption string
+	MaxTrans    int64
+	SeatClasses []string
+}
+
+// ReplanConnection 原行程剩余部分的一次换乘，第一次为从当前位置登上剩余的第一段；
+// FromTrainNo 为空表示当前不在车上，等待时间均为分钟数
+type ReplanConnection struct {
+	Station      string
+	FromTrainNo  string
+	ToTrainNo    string
+	PlannedWait  int64
+	ExpectedWait int64
+	Feasible     bool
+	Reason       string
+}
+
+// ReplanOption 一个后续方案。Arrival 为从 ReplanResult.ReadyTime 起到达终点的分钟数，
+// KeptLegs 为沿用原行程车次的段数，Original 表示原行程剩余部分仍然可行
+type ReplanOption struct {
+	Key      string
+	Legs     []dao.RailWay
+	Date     string // 第一段的发车日期
+	Arrival  int64
+	KeptLegs int
+	Original bool
+}
+
+// ReplanResult Options 按到达时间从早到晚排列，到达时间相同时沿用原行程车次多的在前
+type ReplanResult struct {
+	Station     string
+	ReadyDate   string
+	ReadyTime   string
+	Connections []ReplanConnection
+	Options     []ReplanOption
+}
+
+// ReplanJourney 按当前位置检查原行程剩余部分的各次换乘，并在换乘图上从当前位置重新搜索到终点的方案。
+// 搜索超时时返回已经找到的方案与 ctx 的错误
+func (r *RailWayServiceImpl) ReplanJourney(ctx context.Context, req ReplanRequest) (*ReplanResult, error) {
+	if len(req.Legs) == 0 || len(req.Legs) > MaxReplanLegs {
+		return nil, fmt.Errorf("%w: 1 to %d legs are required", ErrInvalidReplan, MaxReplanLegs)
+	}
+	var base time.Time
+	if req.Date != "" {
+		var err error
+		if base, err = time.Parse(seatDateLayout, req.Date); err != nil {
+			return nil, errors.New("invalidDate")
+		}
+	}
+	legs := make([]dao.RailWay, 0, len(req.Legs))
+	for index, leg := range req.Legs {
+		scheduled, err := r.throughLeg(ctx, leg.TrainNo, leg.DepartureStation, leg.ArrivalStation)
+		if err != nil {
+			r.logger(ctx).Error("query failed", "method", "ReplanJourney", "train_no", leg.TrainNo, "err", err)
+			return nil, err
+		}
+		if scheduled == nil {
+			return nil, fmt.Errorf("%w: legs[%d]: train %s does not run from %s to %s", ErrInvalidReplan, index, leg.TrainNo, leg.DepartureStation, leg.ArrivalStation)
+		}
+		legs = append(legs, *scheduled)
+	}
+	destination := legs[len(legs)-1].ArrivalStation
+
+	// 计划与预计到达当前位置的时刻，均为从 Date 当天 0 点起的分钟数
+	var (
+		station, fromTrainNo string
+		planned, expected    int64
+		remaining            []dao.RailWay
+	)
+	if req.DelayedLeg >= 0 {
+		if req.DelayedLeg >= len(legs) || req.Delay < 0 || req.Delay > MaxRealtimeDelay {
+			return nil, fmt.Errorf("%w: delayed leg must be one of the legs and delay between 0 and %d minutes", ErrInvalidReplan, MaxRealtimeDelay)
+		}
+		leg := legs[req.DelayedLeg]
+		station, fromTrainNo = leg.ArrivalStation, leg.TrainNo
+		planned, _ = GetTime(leg.ArrivalTime)
+		expected = planned + req.Delay
+		remaining = legs[req.DelayedLeg+1:]
+	} else {
+		var err error
+		if station, err = r.ResolveStation(ctx, req.Station); err != nil {
+			return nil, err
+		}
+		if req.Time < 0 || req.Time >= 1440 {
+			return nil, fmt.Errorf("%w: time must be between 00:00 and 23:59", ErrInvalidReplan)
+		}
+		planned, expected = req.Time, req.Time
+		remaining = remainingLegs(legs, station)
+	}
+	if station == destination {
+		return nil, fmt.Errorf("%w: already at the destination %s", ErrInvalidReplan, destination)
+	}
+
+	// 下车换乘至少留出 DefaultStopTime
+	ready := expected
+	if fromTrainNo != "" {
+		ready = ready + DefaultStopTime
+	}
+	readyDate := ""
+	if req.Date != "" {
+		readyDate = base.AddDate(0, 0, int(ready/1440)).Format(seatDateLayout)
+	}
+	result := &ReplanResult{
+		Station:   station,
+		ReadyDate: readyDate,
+		ReadyTime: clockTime(ready),
+		Options:   make([]ReplanOption, 0),
+	}
+	connections, predicted := currentRealtime(req.Date).connections(remaining, fromTrainNo, planned, expected)
+	result.Connections = connections
+	kept := make(map[string]bool, len(remaining))
+	for _, railWay := range remaining {
+		kept[railWay.TrainNo] = true
+	}
+	seen := make(map[string]bool)
+	if len(remaining) > 0 && allFeasible(connections) {
+		predicted = ApplySeatClasses(predicted, req.SeatClasses)
+		option := replanOption(predicted, expected, ready, base, req.Date != "", kept)
+		option.Original = true
+		result.Options = append(result.Options, option)
+		seen[trainSequence(predicted)] = true
+	}
+
+	// 耗时相近时优先沿用原行程剩余的车次
+	var constraints *searchConstraints
+	if len(kept) > 0 {
+		constraints = &searchConstraints{prefer: kept}
+	}
+	found, err := r.graphSearch(ctx, station, destination, req.SpeedOption, req.MaxTrans+1, DefaultResultNumber, LowRunningTimeFirst, req.SeatClasses, readyDate, ready%1440, constraints)
+	for key, railWays := range found {
+		if len(railWays) == 0 || seen[trainSequence(railWays)] {
+			continue
+		}
+		seen[trainSequence(railWays)] = true
+		option := replanOption(railWays, ready, ready, base, req.Date != "", kept)
+		option.Key = key
+		result.Options = append(result.Options, option)
+	}
+	sort.Slice(result.Options, func(i, j int) bool {
+		a, b := result.Options[i], result.Options[j]
+		if a.Arrival != b.Arrival {
+			return a.Arrival < b.Arrival
+		}
+		if a.KeptLegs != b.KeptLegs {
+			return a.KeptLegs > b.KeptLegs
+		}
+		if len(a.Legs) != len(b.Legs) {
+			return len(a.Legs) < len(b.Legs)
+		}
+		return a.Key < b.Key
+	})
+	if err != nil && ctx.Err() == nil {
+		return nil, err
+	}
+	return result, err
+}
+
+// connections 按计划时刻与实时动态逐个判断原行程剩余各段的换乘是否还赶得上，planned 与 expected 为计划与预计到达当前位置的时刻。
+// 前一次换乘赶不上时，之后的换乘按乘坐原计划车次判断；返回的各段已按实时动态修正
+func (v *realtimeView) connections(remaining []dao.RailWay, fromTrainNo string, planned, expected int64) ([]ReplanConnection, []dao.RailWay) {
+	connections := make([]ReplanConnection, 0, len(remaining))
+	predicted := make([]dao.RailWay, 0, len(remaining))
+	for _, railWay := range remaining {
+		minWait := int64(0)
+		if fromTrainNo != "" && fromTrainNo != railWay.TrainNo {
+			minWait = DefaultStopTime
+		}
+		plannedWait := CalculateStopTime(clockTime(planned), railWay.DepartureTime)
+		if plannedWait < minWait {
+			plannedWait = plannedWait + 1440
+		}
+		departure := planned + plannedWait
+		leg, ok := v.leg(railWay, departure/1440)
+		connection := ReplanConnection{
+			Station:      railWay.DepartureStation,
+			FromTrainNo:  fromTrainNo,
+			ToTrainNo:    railWay.TrainNo,
+			PlannedWait:  plannedWait,
+			ExpectedWait: departure + leg.DepartureDelay - expected,
+			Feasible:     true,
+		}
+		switch {
+		case !ok:
+			connection.Feasible, connection.Reason = false, ReplanCancelled
+		case fromTrainNo != railWay.TrainNo && connection.ExpectedWait < minWait:
+			connection.Feasible, connection.Reason = false, ReplanMissed
+		}
+		running, _ := GetTime(railWay.RunningTime)
+		planned = departure + running
+		expected = planned + leg.ArrivalDelay
+		fromTrainNo = railWay.TrainNo
+		connections = append(connections, connection)
+		predicted = append(predicted, leg)
+	}
+	return connections, predicted
+}
+
+// remainingLegs 原行程中还没有乘坐的各段：station 是某一段的下车站时为其后各段，是某一段的上车站时为从该段起的各段，都不是时为空
+func remainingLegs(legs []dao.RailWay, station string) []dao.RailWay {
+	for index := len(legs) - 1; index >= 0; index-- {
+		if legs[index].ArrivalStation == station {
+			return legs[index+1:]
+		}
+	}
+	for index, leg := range legs {
+		if leg.DepartureStation == station {
+			return legs[index:]
+		}
+	}
+	return nil
+}
+
+// replanOption 从 start（分钟数）起依次乘坐 railWays，到达时间按从 ready 起的分钟数计
+func replanOption(railWays []dao.RailWay, start, ready int64, base time.Time, hasDate bool, kept map[string]bool) ReplanOption {
+	current, departure := start, start
+	for index, railWay := range railWays {
+		wait := CalculateStopTime(clockTime(current), railWay.DepartureTime)
+		if index > 0 && railWay.TrainNo != railWays[index-1].TrainNo && wait < DefaultStopTime {
+			wait = wait + 1440
+		}
+		current = current + wait
+		if index == 0 {
+			departure = current
+		}
+		running, _ := GetTime(railWay.RunningTime)
+		current = current + running
+	}
+	option := ReplanOption{Legs: railWays, Arrival: current - ready}
+	numbers := make([]string, 0, len(railWays))
+	for _, railWay := range railWays {
+		numbers = append(numbers, railWay.TrainNumber)
+		if kept[railWay.TrainNo] {
+			option.KeptLegs++
+		}
+	}
+	option.Key = strings.Join(numbers, "/") + "/" + strconv.FormatInt(current-departure, 10)
+	if hasDate {
+		option.Date = base.AddDate(0, 0, int(departure/1440)).Format(seatDateLayout)
+	}
+	return option
+}
+
+func allFeasible(connections []ReplanConnection) bool {
+	for _, connection := range connections {
+		if !connection.Feasible {
+			return false
+		}
+	}
+	return true
+}
+
+// trainSequence 依次乘坐的车次，用于去掉与原行程相同的搜索结果
+func trainSequence(railWays []dao.RailWay) string {
+	trainNos := make([]string, 0, len(railWays))
+	for _, railWay := range railWays {
+		trainNos = append(trainNos, railWay.TrainNo)
+	}
+	return strings.Join(trainNos, "/")
+}
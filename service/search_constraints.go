@@ -0,0 +1,347 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"railway/dao"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxViaStations 图搜索最多指定的途经站数量
+	MaxViaStations = 4
+	// MaxViaDwell 在途经站最长的停留分钟数
+	MaxViaDwell = 720
+	// MaxAvoidStations 展开城市后最多避开的车站数量
+	MaxAvoidStations = 50
+	// MaxConstraintTrains 排除或优先的车次各自最多的数量
+	MaxConstraintTrains = 20
+
+	// PreferPenalty 指定了优先车次时，每乘坐一个其他车次按多等 PreferPenalty 分钟计入耗时
+	PreferPenalty = 60
+	// PreferPricePenalty 按票价搜索时，每乘坐一个其他车次多计的票价
+	PreferPricePenalty = 50
+)
+
+var ErrInvalidConstraints = errors.New("invalidConstraints")
+
+// ViaStation 必须经过的车站，MinDwell 为在该站至少停留的分钟数，为 0 时可以不下车
+type ViaStation struct {
+	Station  string
+	MinDwell int64
+}
+
+// SearchConstraints 图搜索的约束条件，在最短路中逐边判断而不是对结果过滤。
+// 车次可以是车次号或 TrainNo，车站会先解析为标准站名
+type SearchConstraints struct {
+	Via           []ViaStation // 按顺序经过的车站
+	AvoidStations []string     // 不能上下车，也不能乘坐途中停靠这些车站的区间
+	AvoidCities   []string     // 城市代码，城市下属的车站都按 AvoidStations 处理
+	ExcludeTrains []string
+	PreferTrains  []string // 不为空时尽量只乘坐这些车次
+}
+
+// Empty 没有任何约束时按普通图搜索处理
+func (c SearchConstraints) Empty() bool {
+	return len(c.Via) == 0 && len(c.AvoidStations) == 0 && len(c.AvoidCities) == 0 && len(c.ExcludeTrains) == 0 && len(c.PreferTrains) == 0
+}
+
+// searchConstraints 解析后的约束，nil 表示没有约束
+type searchConstraints struct {
+	via     []ViaStation
+	avoid   map[string]bool
+	exclude map[string]bool
+	prefer  map[string]bool
+	// passing 在避开的车站停车的车次及其经停站，用于判断一条边途中是否经过避开的车站
+	passing map[string]*TrainDetail
+}
+
+// viaState 已经按顺序经过的途经站数量，以及在刚到达的途经站还需要停留的分钟数
+type viaState struct {
+	via   int
+	dwell int64
+}
+
+// key 最短路中点的状态，没有经过途经站时与点本身相同
+func (state viaState) key(node string) string {
+	if state.via == 0 && state.dwell == 0 {
+		return node
+	}
+	return node + "#" + strconv.Itoa(state.via) + "#" + strconv.FormatInt(state.dwell, 10)
+}
+
+// SearchWithConstraints 在换乘图上搜索满足 constraints 的方案，其余参数与 SearchWithTwoTrans 相同
+func (r *RailWayServiceImpl) SearchWithConstraints(ctx context.Context, departureStation, arrivalStation, speedOption string, maxTrans, recordNumber int64, sortOption int, seatClasses []string, date string, constraints SearchConstraints) (map[string][]dao.RailWay, error) {
+	var err error
+	departureStation, err = r.ResolveStation(ctx, departureStation)
+	if err != nil {
+		return nil, err
+	}
+	arrivalStation, err = r.ResolveStation(ctx, arrivalStation)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := r.compileConstraints(ctx, departureStation, arrivalStation, constraints)
+	if err != nil {
+		return nil, err
+	}
+	return r.graphSearch(ctx, departureStation, arrivalStation, speedOption, maxTrans, recordNumber, sortOption, seatClasses, date, 0, compiled)
+}
+
+// compileConstraints 解析车站与城市，并查出在避开的车站停车的车次
+func (r *RailWayServiceImpl) compileConstraints(ctx context.Context, departureStation, arrivalStation string, constraints SearchConstraints) (*searchConstraints, error) {
+	if constraints.Empty() {
+		return nil, nil
+	}
+	if len(constraints.Via) > MaxViaStations {
+		return nil, fmt.Errorf("%w: at most %d via stations", ErrInvalidConstraints, MaxViaStations)
+	}
+	if len(constraints.ExcludeTrains) > MaxConstraintTrains || len(constraints.PreferTrains) > MaxConstraintTrains {
+		return nil, fmt.Errorf("%w: at most %d excluded or preferred trains", ErrInvalidConstraints, MaxConstraintTrains)
+	}
+	compiled := &searchConstraints{
+		via:     make([]ViaStation, 0, len(constraints.Via)),
+		avoid:   make(map[string]bool),
+		exclude: trainSet(constraints.ExcludeTrains),
+		prefer:  trainSet(constraints.PreferTrains),
+		passing: make(map[string]*TrainDetail),
+	}
+	previous := departureStation
+	for _, via := range constraints.Via {
+		station, err := r.ResolveStation(ctx, via.Station)
+		if err != nil {
+			return nil, err
+		}
+		if via.MinDwell < 0 || via.MinDwell > MaxViaDwell {
+			return nil, fmt.Errorf("%w: dwell at %s must be between 0 and %d minutes", ErrInvalidConstraints, station, MaxViaDwell)
+		}
+		if station == previous || station == arrivalStation {
+			return nil, fmt.Errorf("%w: via station %s repeats the previous station or the destination", ErrInvalidConstraints, station)
+		}
+		compiled.via = append(compiled.via, ViaStation{Station: station, MinDwell: via.MinDwell})
+		previous = station
+	}
+	for _, input := range constraints.AvoidStations {
+		station, err := r.ResolveStation(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		compiled.avoid[station] = true
+	}
+	for _, cityCode := range constraints.AvoidCities {
+		stations, err := r.CityStationNames(ctx, cityCode)
+		if err != nil {
+			return nil, err
+		}
+		for _, station := range stations {
+			compiled.avoid[station] = true
+		}
+	}
+	if len(compiled.avoid) > MaxAvoidStations {
+		return nil, fmt.Errorf("%w: at most %d avoided stations", ErrInvalidConstraints, MaxAvoidStations)
+	}
+	if compiled.avoid[departureStation] || compiled.avoid[arrivalStation] {
+		return nil, fmt.Errorf("%w: the departure and arrival stations cannot be avoided", ErrInvalidConstraints)
+	}
+	for _, via := range compiled.via {
+		if compiled.avoid[via.Station] {
+			return nil, fmt.Errorf("%w: via station %s is also avoided", ErrInvalidConstraints, via.Station)
+		}
+	}
+	for trainNo := range compiled.exclude {
+		if compiled.prefer[trainNo] {
+			return nil, fmt.Errorf("%w: train %s is both excluded and preferred", ErrInvalidConstraints, trainNo)
+		}
+	}
+	for station := range compiled.avoid {
+		departures, err := r.RailWayDAO.GetRailWayByDepartureStation(ctx, station)
+		if err != nil {
+			r.logger(ctx).Error("query failed", "method", "compileConstraints", "station", station, "err", err)
+			return nil, err
+		}
+		arrivals, err := r.RailWayDAO.GetRailWayByArrivalStation(ctx, station)
+		if err != nil {
+			r.logger(ctx).Error("query failed", "method", "compileConstraints", "station", station, "err", err)
+			return nil, err
+		}
+		for _, railWay := range append(departures, arrivals...) {
+			if _, ok := compiled.passing[railWay.TrainNo]; ok {
+				continue
+			}
+			detail, err := r.cachedTrain(ctx, railWay.TrainNo)
+			if err != nil {
+				r.logger(ctx).Error("query failed", "method", "compileConstraints", "train_no", railWay.TrainNo, "err", err)
+				return nil, err
+			}
+			compiled.passing[railWay.TrainNo] = detail
+		}
+	}
+	return compiled, nil
+}
+
+func trainSet(trains []string) map[string]bool {
+	set := make(map[string]bool, len(trains))
+	for _, train := range trains {
+		if train = strings.TrimSpace(train); train != "" {
+			set[train] = true
+		}
+	}
+	return set
+}
+
+// allows 排除的车次，以及上下车站或途中停靠站是避开的车站的列车边都不能走；等待边只判断要换乘的车次
+func (c *searchConstraints) allows(edge dao.RailWay) bool {
+	if c == nil {
+		return true
+	}
+	if c.exclude[edge.TrainNo] || c.exclude[edge.TrainNumber] {
+		return false
+	}
+	if edge.TrainNumber == Waiting {
+		return true
+	}
+	if c.avoid[edge.DepartureStation] || c.avoid[edge.ArrivalStation] {
+		return false
+	}
+	detail, ok := c.passing[edge.TrainNo]
+	if !ok {
+		return true
+	}
+	from := stopIndex(detail, edge.DepartureStation, 0)
+	if from < 0 {
+		return true
+	}
+	to := stopIndex(detail, edge.ArrivalStation, from+1)
+	for index := from + 1; index < to; index++ {
+		if c.avoid[detail.Stops[index].Station] {
+			return false
+		}
+	}
+	return true
+}
+
+// advance 走过 edge 之后的状态：在途经站的停留时间未满时不能上车，按顺序到达下一个途经站后开始计停留时间
+func (c *searchConstraints) advance(edge dao.RailWay, state viaState) (viaState, bool) {
+	if c == nil {
+		return state, true
+	}
+	if edge.TrainNumber == Waiting {
+		wait, _ := GetTime(edge.RunningTime)
+		state.dwell = max(state.dwell-wait, 0)
+		return state, true
+	}
+	if state.dwell > 0 {
+		return state, false
+	}
+	if state.via < len(c.via) && edge.ArrivalStation == c.via[state.via].Station {
+		state.dwell = c.via[state.via].MinDwell
+		state.via++
+	}
+	return state, true
+}
+
+// penalized 指定了优先车次时，乘坐其他车次要计入惩罚
+func (c *searchConstraints) penalized(edge dao.RailWay) bool {
+	return c != nil && len(c.prefer) > 0 && !c.prefer[edge.TrainNo] && !c.prefer[edge.TrainNumber]
+}
+
+// done 已经按顺序经过了所有途经站
+func (c *searchConstraints) done(state viaState) bool {
+	return c == nil || state.via == len(c.via)
+}
+
+// viaTargets 途经站之间以及到终点的车次也要加入临时图
+func (c *searchConstraints) viaTargets(arrivalStation string) map[string]bool {
+	targets := map[string]bool{arrivalStation: true}
+	if c == nil {
+		return targets
+	}
+	for _, via := range c.via {
+		targets[via.Station] = true
+	}
+	return targets
+}
+
+// AddViaStation 非关键站作为途经站时加入临时图：从关键站、起点或其他途经站到达该站的边，
+// 从该站出发到关键站、终点或其他途经站的边，以及在该站的换乘与等待边
+func (r *RailWayServiceImpl) AddViaStation(ctx context.Context, search *searchGraph, stationName, departureStation string, startTime int64, targets map[string]bool) error {
+	if checkKeyStation(stationName) {
+		return nil
+	}
+	arrivalTrains, err := r.RailWayDAO.GetRailWayByArrivalStation(ctx, stationName)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "AddViaStation", "station", stationName, "err", err)
+		return err
+	}
+	departureTrains, err := r.RailWayDAO.GetRailWayByDepartureStation(ctx, stationName)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "AddViaStation", "station", stationName, "err", err)
+		return err
+	}
+	arrivals := make([]dao.RailWay, 0)
+	for _, train := range arrivalTrains {
+		switch {
+		case train.DepartureStation == departureStation:
+			dTime, _ := GetTime(train.DepartureTime)
+			if dTime < startTime {
+				continue
+			}
+			search.templateGraph[StartIndex] = append(search.templateGraph[StartIndex], train)
+		case checkKeyStation(train.DepartureStation):
+			addTemplateDepartureEdges(search.templateGraph, train)
+			// 该车次在关键站不往其他关键站去时，图中没有换乘到它的边
+			if _, ok := Graph["D/"+train.DepartureStation+"/"+train.TrainNo+"/0"]; !ok {
+				for _, arrivalTrain := range KeyStationArrival[train.DepartureStation] {
+					turnADToEdges(search.templateGraph, arrivalTrain, train, 2, DefaultStopTime)
+				}
+			}
+		case targets[train.DepartureStation]:
+			// 从其他途经站出发的边由该途经站加入
+		default:
+			continue
+		}
+		addTrainFare(search.templateTrainFares, train)
+		arrivals = append(arrivals, train)
+	}
+	departures := make([]dao.RailWay, 0)
+	for _, train := range departureTrains {
+		if !checkKeyStation(train.ArrivalStation) && !targets[train.ArrivalStation] {
+			continue
+		}
+		addTrainFare(search.templateTrainFares, train)
+		addTemplateDepartureEdges(search.templateGraph, train)
+		departures = append(departures, train)
+	}
+	departures = sortByEarlyFirst(uniqueTrains(sortByEarlyArriveFirst(departures)))
+	length := len(departures)
+	for index, train := range departures {
+		buildDepartureWaitingEdges(search.templateGraph, departures[(index+1)%length], train, 2)
+	}
+	buildArrivalToDepartureWaitingEdges(search.templateGraph, sortByEarlyArriveFirst(arrivals), departures, DefaultStopTime)
+	return nil
+}
+
+// addTemplateDepartureEdges 把一条列车边按发车后第 0 到 2 天加入临时图 graph
+func addTemplateDepartureEdges(graph map[string][]dao.RailWay, train dao.RailWay) {
+	for day := 0; day <= 2; day++ {
+		edge := train
+		edge.ArrivalDay = edge.ArrivalDay + uint(day)
+		departIndex := "D/" + train.DepartureStation + "/" + train.TrainNo + "/" + strconv.Itoa(day)
+		graph[departIndex] = append(graph[departIndex], edge)
+	}
+}
+
+// uniqueTrains 每个车次只保留第一条记录
+func uniqueTrains(trains []dao.RailWay) []dao.RailWay {
+	result := make([]dao.RailWay, 0, len(trains))
+	seen := make(map[string]bool)
+	for _, train := range trains {
+		if !seen[train.TrainNo] {
+			seen[train.TrainNo] = true
+			result = append(result, train)
+		}
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"railway/dao"
+	"strings"
+)
+
+// 席别代码，与 Fares 返回的键一致
+const (
+	SeatYW  = "yw"  // 硬卧
+	SeatYZ  = "yz"  // 硬座
+	SeatRW  = "rw"  // 软卧
+	SeatZE  = "ze"  // 二等座
+	SeatZY  = "zy"  // 一等座
+	SeatSWZ = "swz" // 商务座
+	SeatTZ  = "tz"  // 特等座
+	SeatGR  = "gr"  // 高级软卧
+)
+
+// SeatClasses 全部席别代码
+var SeatClasses = []string{SeatYW, SeatYZ, SeatRW, SeatZE, SeatZY, SeatSWZ, SeatTZ, SeatGR}
+
+// seatFare 某一席别的票价，不售时为 0
+func seatFare(railway dao.RailWay, seatClass string) float64 {
+	switch seatClass {
+	case SeatYW:
+		return railway.YWPrice
+	case SeatYZ:
+		return railway.YZPrice
+	case SeatRW:
+		return railway.RWPrice
+	case SeatZE:
+		return railway.ZEPrice
+	case SeatZY:
+		return railway.ZYPrice
+	case SeatSWZ:
+		return railway.SWZPrice
+	case SeatTZ:
+		return railway.TZPrice
+	case SeatGR:
+		return railway.GRPrice
+	}
+	return 0
+}
+
+func setSeatFare(railway *dao.RailWay, seatClass string, price float64) {
+	switch seatClass {
+	case SeatYW:
+		railway.YWPrice = price
+	case SeatYZ:
+		railway.YZPrice = price
+	case SeatRW:
+		railway.RWPrice = price
+	case SeatZE:
+		railway.ZEPrice = price
+	case SeatZY:
+		railway.ZYPrice = price
+	case SeatSWZ:
+		railway.SWZPrice = price
+	case SeatTZ:
+		railway.TZPrice = price
+	case SeatGR:
+		railway.GRPrice = price
+	}
+}
+
+// ParseSeatClasses 解析逗号分隔的席别偏好，如 "ze,yz" 表示优先二等座，没有二等座时硬座
+func ParseSeatClasses(input string) ([]string, error) {
+	seatClasses := make([]string, 0)
+	for _, seatClass := range strings.Split(input, ",") {
+		seatClass = strings.ToLower(strings.TrimSpace(seatClass))
+		if seatClass == "" {
+			continue
+		}
+		if !isSeatClass(seatClass) {
+			return nil, errors.New("unknown seat class: " + seatClass)
+		}
+		seatClasses = append(seatClasses, seatClass)
+	}
+	return seatClasses, nil
+}
+
+func isSeatClass(seatClass string) bool {
+	for _, known := range SeatClasses {
+		if seatClass == known {
+			return true
+		}
+	}
+	return false
+}
+
+// SeatPrice 按偏好顺序取第一个有售的席别；没有偏好或偏好的席别都不售时取最低价的席别，
+// 与 GetLowPrice 一致。站内换乘边等没有任何票价的边返回原 Price 与空席别
+func SeatPrice(railway dao.RailWay, seatClasses []string) (float64, string) {
+	for _, seatClass := range seatClasses {
+		if price := seatFare(railway, seatClass); price >= 0.5 {
+			return price, seatClass
+		}
+	}
+	price, cheapest := railway.Price, ""
+	for _, seatClass := range SeatClasses {
+		fare := seatFare(railway, seatClass)
+		if fare >= 0.5 && (cheapest == "" || fare < price) {
+			price, cheapest = fare, seatClass
+		}
+	}
+	return price, cheapest
+}
+
+// ApplySeatClasses 将每段的 Price 改为所选席别的票价并记录 SeatClass，之后的排序与合计都基于该票价
+func ApplySeatClasses(railways []dao.RailWay, seatClasses []string) []dao.RailWay {
+	for i := range railways {
+		railways[i].Price, railways[i].SeatClass = SeatPrice(railways[i], seatClasses)
+	}
+	return railways
+}
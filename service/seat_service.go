@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"railway/dao"
+	"sync"
+	"time"
+)
+
+const seatDateLayout = "2006-01-02"
+
+var SeatDAO dao.SeatDAO
+
+// DefaultSeatCapacity 放票时各席别每个区间的座位数
+var DefaultSeatCapacity = map[string]int{
+	SeatSWZ: 10,
+	SeatTZ:  10,
+	SeatZY:  60,
+	SeatZE:  500,
+	SeatGR:  8,
+	SeatRW:  60,
+	SeatYW:  300,
+	SeatYZ:  600,
+}
+
+// seatSpan 在某天从 from 上车到 to 下车占用的区间 [From, To)，OriginDate 为列车的始发日期
+type seatSpan struct {
+	Detail     *TrainDetail
+	OriginDate string
+	From       int
+	To         int
+}
+
+type trainDetailSnapshot struct {
+	version uint64
+	details map[string]*TrainDetail
+}
+
+var (
+	trainDetailMu  sync.Mutex
+	trainDetailSet = trainDetailSnapshot{details: make(map[string]*TrainDetail)}
+)
+
+// cachedTrain 按时刻表版本缓存车次详情，查询余票时每段行程都要用到经停顺序
+func (r *RailWayServiceImpl) cachedTrain(ctx context.Context, trainNo string) (*TrainDetail, error) {
+	version := TimetableVersion()
+	trainDetailMu.Lock()
+	if trainDetailSet.version != version {
+		trainDetailSet = trainDetailSnapshot{version: version, details: make(map[string]*TrainDetail)}
+	}
+	detail, ok := trainDetailSet.details[trainNo]
+	trainDetailMu.Unlock()
+	if ok {
+		return detail, nil
+	}
+	detail, err := r.GetTrain(ctx, trainNo)
+	if err != nil {
+		return nil, err
+	}
+	trainDetailMu.Lock()
+	if trainDetailSet.version == version {
+		trainDetailSet.details[trainNo] = detail
+	}
+	trainDetailMu.Unlock()
+	return detail, nil
+}
+
+// seatSpan 由经停顺序确定区间；date 为在 from 上车的日期，列车在 from 发车时已运行 n 天则始发日期提前 n 天
+func (r *RailWayServiceImpl) seatSpan(ctx context.Context, trainNo, date, from, to string) (seatSpan, error) {
+	detail, err := r.cachedTrain(ctx, trainNo)
+	if err != nil {
+		return seatSpan{}, err
+	}
+	return newSeatSpan(detail, date, from, to)
+}
+
+// newSeatSpan 在 detail 的经停站中确定 from 到 to 的区间与始发日期
+func newSeatSpan(detail *TrainDetail, date, from, to string) (seatSpan, error) {
+	boardDate, err := time.Parse(seatDateLayout, date)
+	if err != nil {
+		return seatSpan{}, errors.New("invalidDate")
+	}
+	span := seatSpan{Detail: detail, From: -1, To: -1}
+	for index, stop := range detail.Stops {
+		if stop.Station == from && span.From < 0 {
+			span.From = index
+		}
+		if stop.Station == to && span.From >= 0 {
+			span.To = index
+			break
+		}
+	}
+	if span.From < 0 || span.To < 0 {
+		return seatSpan{}, errors.New("stationNotOnTrain")
+	}
+	span.OriginDate = boardDate.AddDate(0, 0, -departureDay(detail.Stops[span.From])).Format(seatDateLayout)
+	return span, nil
+}
+
+// departureDay 列车从该站发车时已运行的天数，到站后跨过零点才发车时比 Day 多一天
+func departureDay(stop TrainStop) int {
+	day := int(stop.Day)
+	if stop.ArrivalTime != "" && !CompareTime(stop.DepartureTime, stop.ArrivalTime) {
+		day++
+	}
+	return day
+}
+
+// issueSeats 某车次某天第一次被预订时按 DefaultSeatCapacity 放票，并发放票时重复的区间被忽略；
+// 查询余票不放票，尚未放票的车按 DefaultSeatCapacity 计算余票
+func (r *RailWayServiceImpl) issueSeats(ctx context.Context, span seatSpan) error {
+	inventories, err := r.SeatDAO.GetSeatInventories(ctx, span.Detail.TrainNo, span.OriginDate)
+	if err != nil || len(inventories) > 0 {
+		return err
+	}
+	inventories = make([]dao.SeatInventory, 0, len(span.Detail.SeatClasses)*(len(span.Detail.Stops)-1))
+	for _, seatClass := range span.Detail.SeatClasses {
+		for segment := 0; segment+1 < len(span.Detail.Stops); segment++ {
+			inventories = append(inventories, dao.SeatInventory{
+				TrainNo:     span.Detail.TrainNo,
+				Date:        span.OriginDate,
+				SeatClass:   seatClass,
+				Segment:     segment,
+				FromStation: span.Detail.Stops[segment].Station,
+				ToStation:   span.Detail.Stops[segment+1].Station,
+				Capacity:    DefaultSeatCapacity[seatClass],
+				Remaining:   DefaultSeatCapacity[seatClass],
+			})
+		}
+	}
+	return r.SeatDAO.CreateSeatInventories(ctx, inventories)
+}
+
+// SeatQuery 查询余票的一段行程，Date 为在 From 上车的日期
+type SeatQuery struct {
+	TrainNo string
+	Date    string
+	From    string
+	To      string
+}
+
+// RemainingSeats 在 date 从 from 上车到 to 的各席别余票，为途经各区间余票的最小值
+func (r *RailWayServiceImpl) RemainingSeats(ctx context.Context, trainNo, date, from, to string) (map[string]int, error) {
+	span, err := r.seatSpan(ctx, trainNo, date, from, to)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := r.remainingSeats(ctx, []seatSpan{span})
+	if err != nil {
+		return nil, err
+	}
+	return remaining[0], nil
+}
+
+// BatchRemainingSeats 用一次查询取得多段行程的余票，结果与 queries 一一对应；
+// 车次不经过所查车站等无法确定区间的段结果为 nil，只有查询余票失败时返回 error。只读，不会放票
+func (r *RailWayServiceImpl) BatchRemainingSeats(ctx context.Context, queries []SeatQuery) ([]map[string]int, error) {
+	results := make([]map[string]int, len(queries))
+	spans := make([]seatSpan, 0, len(queries))
+	indexes := make([]int, 0, len(queries))
+	for index, query := range queries {
+		span, err := r.seatSpan(ctx, query.TrainNo, query.Date, query.From, query.To)
+		if err != nil {
+			r.logger(ctx).Warn("seat span failed", "method", "BatchRemainingSeats", "train_no", query.TrainNo, "date", query.Date, "err", err)
+			continue
+		}
+		spans = append(spans, span)
+		indexes = append(indexes, index)
+	}
+	remaining, err := r.remainingSeats(ctx, spans)
+	if err != nil {
+		return nil, err
+	}
+	for i, index := range indexes {
+		results[index] = remaining[i]
+	}
+	return results, nil
+}
+
+// remainingSeats 一次读取 spans 涉及的各趟车的余票；尚未放票的车各席别按 DefaultSeatCapacity 计
+func (r *RailWayServiceImpl) remainingSeats(ctx context.Context, spans []seatSpan) ([]map[string]int, error) {
+	trains := make([]dao.TrainDate, 0, len(spans))
+	seen := make(map[dao.TrainDate]bool)
+	for _, span := range spans {
+		train := dao.TrainDate{TrainNo: span.Detail.TrainNo, Date: span.OriginDate}
+		if !seen[train] {
+			seen[train] = true
+			trains = append(trains, train)
+		}
+	}
+	inventories, err := r.SeatDAO.GetSeatInventoriesByTrains(ctx, trains)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "RemainingSeats", "trains", len(trains), "err", err)
+		return nil, err
+	}
+	byTrain := make(map[dao.TrainDate][]dao.SeatInventory)
+	for _, inventory := range inventories {
+		train := dao.TrainDate{TrainNo: inventory.TrainNo, Date: inventory.Date}
+		byTrain[train] = append(byTrain[train], inventory)
+	}
+	results := make([]map[string]int, len(spans))
+	for index, span := range spans {
+		rows, issued := byTrain[dao.TrainDate{TrainNo: span.Detail.TrainNo, Date: span.OriginDate}]
+		remaining := make(map[string]int)
+		if !issued {
+			for _, seatClass := range span.Detail.SeatClasses {
+				remaining[seatClass] = DefaultSeatCapacity[seatClass]
+			}
+		}
+		for _, row := range rows {
+			if row.Segment < span.From || row.Segment >= span.To {
+				continue
+			}
+			if current, ok := remaining[row.SeatClass]; !ok || row.Remaining < current {
+				remaining[row.SeatClass] = row.Remaining
+			}
+		}
+		results[index] = remaining
+	}
+	return results, nil
+}
+
+// ReserveSeats 原子扣减 from 到 to 途经各区间的余票，任一区间不足时不扣减并返回 dao.ErrSeatsSoldOut
+func (r *RailWayServiceImpl) ReserveSeats(ctx context.Context, trainNo, date, from, to, seatClass string, count int) error {
+	span, err := r.seatSpan(ctx, trainNo, date, from, to)
+	if err != nil {
+		return err
+	}
+	if err := r.issueSeats(ctx, span); err != nil {
+		r.logger(ctx).Error("query failed", "method", "ReserveSeats", "train_no", trainNo, "err", err)
+		return err
+	}
+	err = r.SeatDAO.DecrementSeats(ctx, trainNo, span.OriginDate, seatClass, span.From, span.To, count)
+	if err != nil && !errors.Is(err, dao.ErrSeatsSoldOut) {
+		r.logger(ctx).Error("query failed", "method", "ReserveSeats", "train_no", trainNo, "err", err)
+	}
+	return err
+}
+
+// ReleaseSeats 取消或退票时退还 ReserveSeats 扣减的余票
+func (r *RailWayServiceImpl) ReleaseSeats(ctx context.Context, trainNo, date, from, to, seatClass string, count int) error {
+	span, err := r.seatSpan(ctx, trainNo, date, from, to)
+	if err != nil {
+		return err
+	}
+	err = r.SeatDAO.IncrementSeats(ctx, trainNo, span.OriginDate, seatClass, span.From, span.To, count)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "ReleaseSeats", "train_no", trainNo, "err", err)
+	}
+	return err
+}
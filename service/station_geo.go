@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"railway/dao"
+	"railway/geo"
+	"strconv"
+	"strings"
+)
+
+// 附近车站查询的默认与最大半径（千米）
+const (
+	DefaultNearbyRadiusKm = 10.0
+	MaxNearbyRadiusKm     = 200.0
+)
+
+// NearbyStation 附近车站及其与查询点的距离
+type NearbyStation struct {
+	Station    dao.Station
+	DistanceKm float64
+}
+
+// DownLoadStationCoordinates 导入车站坐标 CSV，表头需包含 latitude、longitude，
+// 以及 station_code 或 station_name 之一，province 可选
+func DownLoadStationCoordinates(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		slog.Error("无法打开文件", "path", path, "err", err)
+		return err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%s: read header: %w", path, err)
+	}
+	columns := make(map[string]int)
+	for index, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = index
+	}
+	_, hasCode := columns["station_code"]
+	_, hasName := columns["station_name"]
+	_, hasLat := columns["latitude"]
+	_, hasLon := columns["longitude"]
+	if !hasLat || !hasLon || (!hasCode && !hasName) {
+		return errors.New("coordinates csv needs latitude, longitude and station_code or station_name columns")
+	}
+	column := func(row []string, name string) string {
+		index, ok := columns[name]
+		if !ok || index >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[index])
+	}
+
+	ctx := context.Background()
+	updated, skipped := 0, 0
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		latitude, latErr := strconv.ParseFloat(column(row, "latitude"), 64)
+		longitude, lonErr := strconv.ParseFloat(column(row, "longitude"), 64)
+		if latErr != nil || lonErr != nil || !(geo.Point{Lat: latitude, Lon: longitude}).Valid() {
+			slog.Warn("invalid coordinates", "line", line, "row", row)
+			skipped++
+			continue
+		}
+		code := column(row, "station_code")
+		if code == "" {
+			station, err := StationService.GetStationByName(ctx, column(row, "station_name"))
+			if err != nil || station == nil {
+				slog.Warn("station not found", "line", line, "station", column(row, "station_name"))
+				skipped++
+				continue
+			}
+			code = station.StationCode
+		}
+		rows, err := StationService.UpdateStationLocation(ctx, code, latitude, longitude, column(row, "province"))
+		if err != nil {
+			slog.Error("update station location failed", "line", line, "station_code", code, "err", err)
+			return err
+		}
+		if rows == 0 {
+			slog.Warn("station not found", "line", line, "station_code", code)
+			skipped++
+			continue
+		}
+		updated++
+	}
+	slog.Info("DownLoadStationCoordinates success", "updated", updated, "skipped", skipped)
+	bumpTimetableVersion()
+	return nil
+}
+
+// NearbyStations 返回距 center 不超过 radiusKm 的车站，按距离排序，limit 不大于 0 时不限制条数
+func (r *RailWayServiceImpl) NearbyStations(ctx context.Context, center geo.Point, radiusKm float64, limit int) ([]NearbyStation, error) {
+	index, err := r.stationIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]NearbyStation, 0)
+	for _, result := range index.spatial.Within(center, radiusKm) {
+		if limit > 0 && len(results) == limit {
+			break
+		}
+		results = append(results, NearbyStation{Station: *index.targets[result.ID].Station, DistanceKm: result.DistanceKm})
+	}
+	return results, nil
+}
+
+// StationLocation 返回车站坐标，未导入坐标时第二个返回值为 false
+func (r *RailWayServiceImpl) StationLocation(ctx context.Context, stationName string) (geo.Point, bool) {
+	index, err := r.stationIndex(ctx)
+	if err != nil {
+		return geo.Point{}, false
+	}
+	return index.location(stationName)
+}
+
+func (index *StationIndex) location(stationName string) (geo.Point, bool) {
+	target, ok := index.stationByName[stationName]
+	if !ok {
+		return geo.Point{}, false
+	}
+	station := index.targets[target].Station
+	point := geo.Point{Lat: station.Latitude, Lon: station.Longitude}
+	return point, point.Valid()
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"railway/dao"
+	"railway/geo"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultSuggestionLimit 联想默认返回的条数
+const DefaultSuggestionLimit = 20
+
+// MatchKind 联想命中的方式，数值越小匹配质量越高
+type MatchKind int
+
+const (
+	MatchExactName      MatchKind = iota // 车站名或城市名完全一致
+	MatchNamePrefix                      // 车站名前缀
+	MatchPinyin                          // 全拼完全一致，如 beijingnan
+	MatchCode                            // 电报码完全一致，如 VNP
+	MatchInitials                        // 首字母或简称完全一致，如 bjn
+	MatchPinyinPrefix                    // 全拼前缀
+	MatchInitialsPrefix                  // 首字母或简称前缀
+	MatchCodePrefix                      // 电报码前缀
+)
+
+var matchKindNames = map[MatchKind]string{
+	MatchExactName:      "name",
+	MatchNamePrefix:     "name_prefix",
+	MatchPinyin:         "pinyin",
+	MatchCode:           "code",
+	MatchInitials:       "initials",
+	MatchPinyinPrefix:   "pinyin_prefix",
+	MatchInitialsPrefix: "initials_prefix",
+	MatchCodePrefix:     "code_prefix",
+}
+
+func (k MatchKind) String() string {
+	return matchKindNames[k]
+}
+
+// Suggestion 一条联想结果，城市的 Station 为 nil，Trains 为城市内各站车次数之和
+type Suggestion struct {
+	Name         string
+	IsCity       bool
+	CityCode     string
+	Station      *dao.Station
+	Match        MatchKind
+	Trains       int64
+	IsKeyStation bool
+}
+
+type termField int
+
+const (
+	fieldName termField = iota
+	fieldPinyin
+	fieldInitials
+	fieldCode
+)
+
+// exactKind 与 prefixKind 为各字段完全一致与前缀命中时的 MatchKind
+var (
+	exactKind  = map[termField]MatchKind{fieldName: MatchExactName, fieldPinyin: MatchPinyin, fieldInitials: MatchInitials, fieldCode: MatchCode}
+	prefixKind = map[termField]MatchKind{fieldName: MatchNamePrefix, fieldPinyin: MatchPinyinPrefix, fieldInitials: MatchInitialsPrefix, fieldCode: MatchCodePrefix}
+)
+
+type indexTerm struct {
+	term   string
+	field  termField
+	target int
+}
+
+// StationIndex 车站与城市的内存前缀索引：所有词条按字典序排列，前缀查询为一次二分查找加顺序扫描
+type StationIndex struct {
+	terms         []indexTerm
+	targets       []Suggestion
+	stationByName map[string]int
+	spatial       *geo.KDTree
+	version       uint64
+}
+
+var (
+	stationIndexMu      sync.Mutex
+	currentStationIndex atomic.Pointer[StationIndex]
+)
+
+// NewStationIndex 由车站列表与各站车次数构建索引，keyStations 中的车站视为换乘枢纽
+func NewStationIndex(stations []dao.Station, trains map[string]int64, keyStations map[string]dao.Station) *StationIndex {
+	index := &StationIndex{stationByName: make(map[string]int, len(stations))}
+	cityTargets := make(map[string]int)
+	pinyinByName := make(map[string]dao.Station)
+	for i := range stations {
+		station := stations[i]
+		pinyinByName[station.StationName] = station
+		_, isKey := keyStations[station.StationName]
+		target := len(index.targets)
+		index.stationByName[station.StationName] = target
+		index.targets = append(index.targets, Suggestion{
+			Name:         station.StationName,
+			CityCode:     station.CityCode,
+			Station:      &station,
+			Trains:       trains[station.StationName],
+			IsKeyStation: isKey || station.IsKeyStation == 1,
+		})
+		index.add(station.StationName, fieldName, target)
+		index.add(station.StationPinyin, fieldPinyin, target)
+		index.add(station.StationFirstLetter, fieldInitials, target)
+		index.add(station.StationAbbr, fieldInitials, target)
+		index.add(station.StationCode, fieldCode, target)
+
+		if station.CityCode == "" || station.CityName == "" {
+			continue
+		}
+		cityTarget, ok := cityTargets[station.CityCode]
+		if !ok {
+			cityTarget = len(index.targets)
+			cityTargets[station.CityCode] = cityTarget
+			index.targets = append(index.targets, Suggestion{Name: station.CityName, IsCity: true, CityCode: station.CityCode})
+			index.add(station.CityName, fieldName, cityTarget)
+		}
+		index.targets[cityTarget].Trains = index.targets[cityTarget].Trains + trains[station.StationName]
+		index.targets[cityTarget].IsKeyStation = index.targets[cityTarget].IsKeyStation || index.targets[target].IsKeyStation
+	}
+	// 城市没有拼音字段，借用与城市同名车站的拼音，如“北京”借用北京站的 beijing / bj
+	for _, cityTarget := range cityTargets {
+		if station, ok := pinyinByName[index.targets[cityTarget].Name]; ok {
+			index.add(station.StationPinyin, fieldPinyin, cityTarget)
+			index.add(station.StationFirstLetter, fieldInitials, cityTarget)
+		}
+	}
+	sort.Slice(index.terms, func(i, j int) bool {
+		return index.terms[i].term < index.terms[j].term
+	})
+	points := make([]geo.Item, 0, len(index.stationByName))
+	for _, target := range index.stationByName {
+		station := index.targets[target].Station
+		points = append(points, geo.Item{Point: geo.Point{Lat: station.Latitude, Lon: station.Longitude}, ID: target})
+	}
+	index.spatial = geo.NewKDTree(points)
+	return index
+}
+
+func (index *StationIndex) add(term string, field termField, target int) {
+	term = strings.ToLower(strings.TrimSpace(term))
+	if term == "" {
+		return
+	}
+	index.terms = append(index.terms, indexTerm{term: term, field: field, target: target})
+}
+
+// Lookup 返回前缀匹配 keyword 的车站与城市，按匹配质量、是否枢纽、车次数排序
+func (index *StationIndex) Lookup(keyword string, limit int) []Suggestion {
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	if keyword == "" {
+		return nil
+	}
+	best := make(map[int]MatchKind)
+	start := sort.Search(len(index.terms), func(i int) bool {
+		return index.terms[i].term >= keyword
+	})
+	for i := start; i < len(index.terms) && strings.HasPrefix(index.terms[i].term, keyword); i++ {
+		term := index.terms[i]
+		kind := prefixKind[term.field]
+		if term.term == keyword {
+			kind = exactKind[term.field]
+		}
+		if current, ok := best[term.target]; !ok || kind < current {
+			best[term.target] = kind
+		}
+	}
+	results := make([]Suggestion, 0, len(best))
+	for target, kind := range best {
+		suggestion := index.targets[target]
+		suggestion.Match = kind
+		results = append(results, suggestion)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Match != b.Match {
+			return a.Match < b.Match
+		}
+		if a.IsCity != b.IsCity {
+			return a.IsCity
+		}
+		if a.IsKeyStation != b.IsKeyStation {
+			return a.IsKeyStation
+		}
+		if a.Trains != b.Trains {
+			return a.Trains > b.Trains
+		}
+		return a.Name < b.Name
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// stationIndex 返回当前时刻表版本对应的索引，版本变化后（重新导入车站或车次）重建
+func (r *RailWayServiceImpl) stationIndex(ctx context.Context) (*StationIndex, error) {
+	version := TimetableVersion()
+	if index := currentStationIndex.Load(); index != nil && index.version == version {
+		return index, nil
+	}
+	stationIndexMu.Lock()
+	defer stationIndexMu.Unlock()
+	if index := currentStationIndex.Load(); index != nil && index.version == version {
+		return index, nil
+	}
+	stations, err := r.StationDAO.GetAllStations(ctx)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "stationIndex", "err", err)
+		return nil, err
+	}
+	trains, err := r.RailWayDAO.CountTrainsByStation(ctx)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "stationIndex", "err", err)
+		return nil, err
+	}
+	index := NewStationIndex(stations, trains, KeyStation)
+	index.version = version
+	currentStationIndex.Store(index)
+	r.logger(ctx).Info("station index built", "stations", len(stations), "terms", len(index.terms), "located", index.spatial.Len())
+	return index, nil
+}
+
+// Autocomplete 按车站名、全拼、首字母、简称或电报码联想
+func (r *RailWayServiceImpl) Autocomplete(ctx context.Context, keyword string, limit int) ([]Suggestion, error) {
+	index, err := r.stationIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return index.Lookup(keyword, limit), nil
+}
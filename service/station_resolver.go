@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxResolveSuggestions 无法确定车站时最多返回的候选数
+const MaxResolveSuggestions = 5
+
+// UnresolvedStationError 输入无法唯一确定一个车站，Suggestions 为按可能性排序的候选
+type UnresolvedStationError struct {
+	Input       string
+	Suggestions []Suggestion
+}
+
+func (e *UnresolvedStationError) Error() string {
+	return "stationNotFind: " + e.Input
+}
+
+// IsStationNotFound 判断 err 是否为车站无法确定
+func IsStationNotFound(err error) bool {
+	var unresolved *UnresolvedStationError
+	return errors.As(err, &unresolved)
+}
+
+// NormalizeStationName 去掉空白、将全角字符转为半角、去掉结尾的“站”，拉丁字母转为小写
+func NormalizeStationName(input string) string {
+	var builder strings.Builder
+	for _, char := range input {
+		switch {
+		case char == '　':
+			continue
+		case char >= '！' && char <= '～':
+			char = char - 0xFEE0
+		}
+		if unicode.IsSpace(char) {
+			continue
+		}
+		builder.WriteRune(unicode.ToLower(char))
+	}
+	name := builder.String()
+	if utf8.RuneCountInString(name) > 2 {
+		name = strings.TrimSuffix(name, "站")
+	}
+	return name
+}
+
+// ResolveStation 将用户输入解析为车站名：先按车站名精确匹配，再按全拼、电报码、首字母匹配，
+// 最后按编辑距离匹配；只有唯一候选时才自动解析，否则返回 *UnresolvedStationError
+func (r *RailWayServiceImpl) ResolveStation(ctx context.Context, input string) (string, error) {
+	index, err := r.stationIndex(ctx)
+	if err != nil {
+		return "", err
+	}
+	if name, ok := index.resolve(input); ok {
+		return name, nil
+	}
+	suggestions := index.suggest(input)
+	r.logger(ctx).Info("station unresolved", "input", input, "suggestions", len(suggestions))
+	return "", &UnresolvedStationError{Input: input, Suggestions: suggestions}
+}
+
+func (index *StationIndex) resolve(input string) (string, bool) {
+	if target, ok := index.stationByName[strings.TrimSpace(input)]; ok {
+		return index.targets[target].Name, true
+	}
+	name := NormalizeStationName(input)
+	if name == "" {
+		return "", false
+	}
+	if target, ok := index.stationByName[name]; ok {
+		return index.targets[target].Name, true
+	}
+	exact := make([]Suggestion, 0)
+	prefix := make([]Suggestion, 0)
+	for _, suggestion := range index.Lookup(name, 0) {
+		if suggestion.IsCity {
+			continue
+		}
+		switch suggestion.Match {
+		case MatchExactName, MatchPinyin, MatchCode, MatchInitials:
+			exact = append(exact, suggestion)
+		case MatchNamePrefix, MatchPinyinPrefix:
+			prefix = append(prefix, suggestion)
+		}
+	}
+	if len(exact) == 1 {
+		return exact[0].Name, true
+	}
+	if len(exact) == 0 && len(prefix) == 1 {
+		return prefix[0].Name, true
+	}
+	if len(exact) > 0 || len(prefix) > 0 {
+		return "", false
+	}
+	// 编辑距离需小于输入长度的一半才自动解析，避免“火星”这类两个字的输入被改成别的车站
+	candidates := index.fuzzy(name)
+	if len(candidates) == 0 || candidates[0].distance*2 >= utf8.RuneCountInString(name) {
+		return "", false
+	}
+	if len(candidates) == 1 || candidates[0].distance < candidates[1].distance {
+		return candidates[0].suggestion.Name, true
+	}
+	return "", false
+}
+
+// suggest 前缀匹配的车站在前，其次为编辑距离最近的车站
+func (index *StationIndex) suggest(input string) []Suggestion {
+	name := NormalizeStationName(input)
+	results := make([]Suggestion, 0, MaxResolveSuggestions)
+	seen := make(map[string]bool)
+	for _, suggestion := range index.Lookup(name, 0) {
+		if len(results) == MaxResolveSuggestions {
+			return results
+		}
+		if !suggestion.IsCity && !seen[suggestion.Name] {
+			seen[suggestion.Name] = true
+			results = append(results, suggestion)
+		}
+	}
+	for _, candidate := range index.fuzzy(name) {
+		if len(results) == MaxResolveSuggestions {
+			break
+		}
+		if !seen[candidate.suggestion.Name] {
+			seen[candidate.suggestion.Name] = true
+			results = append(results, candidate.suggestion)
+		}
+	}
+	return results
+}
+
+type fuzzyCandidate struct {
+	suggestion Suggestion
+	distance   int
+}
+
+// fuzzy 汉字输入与车站名比较，拉丁字母输入与全拼比较；允许的编辑距离随长度增加
+func (index *StationIndex) fuzzy(name string) []fuzzyCandidate {
+	latin := isLatin(name)
+	length := utf8.RuneCountInString(name)
+	maxDistance := 1
+	if (latin && length >= 6) || (!latin && length >= 4) {
+		maxDistance = 2
+	}
+	candidates := make([]fuzzyCandidate, 0)
+	for _, target := range index.targets {
+		if target.IsCity {
+			continue
+		}
+		term := target.Name
+		if latin {
+			term = strings.ToLower(target.Station.StationPinyin)
+		}
+		if term == "" {
+			continue
+		}
+		if distance := editDistance(name, term, maxDistance); distance <= maxDistance {
+			target.Match = MatchNamePrefix
+			if latin {
+				target.Match = MatchPinyinPrefix
+			}
+			candidates = append(candidates, fuzzyCandidate{suggestion: target, distance: distance})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if a.suggestion.IsKeyStation != b.suggestion.IsKeyStation {
+			return a.suggestion.IsKeyStation
+		}
+		if a.suggestion.Trains != b.suggestion.Trains {
+			return a.suggestion.Trains > b.suggestion.Trains
+		}
+		return a.suggestion.Name < b.suggestion.Name
+	})
+	return candidates
+}
+
+func isLatin(name string) bool {
+	for _, char := range name {
+		if char > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// editDistance 按字符计算 Levenshtein 距离，超过 limit 时提前返回 limit+1
+func editDistance(a, b string, limit int) int {
+	source, target := []rune(a), []rune(b)
+	if abs(len(source)-len(target)) > limit {
+		return limit + 1
+	}
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		previous, current = current, previous
+	}
+	return previous[len(target)]
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"railway/dao"
+	"testing"
+)
+
+var resolverStations = []dao.Station{
+	{StationName: "北京南", StationPinyin: "beijingnan", StationFirstLetter: "bjn", StationCode: "VNP", CityCode: "BJP", CityName: "北京"},
+	{StationName: "北京西", StationPinyin: "beijingxi", StationFirstLetter: "bjx", StationCode: "BXP", CityCode: "BJP", CityName: "北京"},
+	{StationName: "北京", StationPinyin: "beijing", StationFirstLetter: "bj", StationCode: "BJP", CityCode: "BJP", CityName: "北京"},
+	{StationName: "南京南", StationPinyin: "nanjingnan", StationFirstLetter: "njn", StationCode: "NKH", CityCode: "NJH", CityName: "南京"},
+	{StationName: "上海虹桥", StationPinyin: "shanghaihongqiao", StationFirstLetter: "shhq", StationCode: "AOH", CityCode: "SHH", CityName: "上海"},
+}
+
+var resolverTrains = map[string]int64{"北京南": 100, "北京西": 80, "北京": 50, "南京南": 90, "上海虹桥": 120}
+
+// resolverRailWayDAO 只提供构建车站索引用到的各站车次数
+type resolverRailWayDAO struct {
+	dao.RailWayDAO
+}
+
+func (resolverRailWayDAO) CountTrainsByStation(context.Context) (map[string]int64, error) {
+	return resolverTrains, nil
+}
+
+type memoryStationDAO struct {
+	dao.StationDAO
+	stations []dao.Station
+}
+
+func (m *memoryStationDAO) GetAllStations(context.Context) ([]dao.Station, error) {
+	return m.stations, nil
+}
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"beijingnan", "beijingnan", 2, 0},
+		{"kitten", "sitting", 5, 3},
+		{"北京南", "北京西", 2, 1},
+		{"上海红桥", "上海虹桥", 2, 1},
+		{"北京", "北京南", 1, 1},
+		// 长度相差超过 limit 或整行都超过 limit 时提前返回 limit+1
+		{"beijingnan", "bj", 2, 3},
+		{"abcd", "dcba", 1, 2},
+	}
+	for _, c := range cases {
+		if got := editDistance(c.a, c.b, c.limit); got != c.want {
+			t.Fatalf("editDistance(%q, %q, %d) = %d, want %d", c.a, c.b, c.limit, got, c.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	index := NewStationIndex(resolverStations, resolverTrains, nil)
+	cases := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"北京南", "北京南", true},
+		{" 北京南站 ", "北京南", true},
+		{"北京", "北京", true},
+		{"ＶＮＰ", "北京南", true},
+		{"BeijingNan", "北京南", true},
+		{"shhq", "上海虹桥", true},
+		// 同名城市不参与解析，beijing 只完全匹配北京站
+		{"beijing", "北京", true},
+		{"上海虹", "上海虹桥", true},
+		{"shanghaih", "上海虹桥", true},
+		{"北", "", false},
+		{"beijingx", "北京西", true},
+		// 编辑距离
+		{"上海红桥", "上海虹桥", true},
+		{"shanghaihonqiao", "上海虹桥", true},
+		{"南京北", "南京南", true},
+		{"北京东", "", false},
+		{"火星", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		got, ok := index.resolve(c.input)
+		if got != c.want || ok != c.ok {
+			t.Fatalf("resolve(%q) = %q, %v, want %q, %v", c.input, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+// TestResolveStationSuggestions 无法确定车站时返回 *UnresolvedStationError，候选按编辑距离与车次数排序
+func TestResolveStationSuggestions(t *testing.T) {
+	bumpTimetableVersion()
+	railways := NewRailwayService(resolverRailWayDAO{}, &memoryStationDAO{stations: resolverStations}, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	name, err := railways.ResolveStation(context.Background(), "上海红桥站")
+	if err != nil || name != "上海虹桥" {
+		t.Fatalf("ResolveStation = %q, %v, want 上海虹桥", name, err)
+	}
+
+	cases := []struct {
+		input string
+		want  []string
+	}{
+		{"北京东", []string{"北京南", "北京西", "北京"}},
+		{"北", []string{"北京南", "北京西", "北京"}},
+		{"火星", []string{}},
+	}
+	for _, c := range cases {
+		_, err := railways.ResolveStation(context.Background(), c.input)
+		var unresolved *UnresolvedStationError
+		if !errors.As(err, &unresolved) || !IsStationNotFound(err) {
+			t.Fatalf("ResolveStation(%q) error = %v, want *UnresolvedStationError", c.input, err)
+		}
+		if unresolved.Input != c.input {
+			t.Fatalf("Input = %q, want %q", unresolved.Input, c.input)
+		}
+		names := make([]string, 0, len(unresolved.Suggestions))
+		for _, suggestion := range unresolved.Suggestions {
+			names = append(names, suggestion.Name)
+		}
+		if len(names) != len(c.want) {
+			t.Fatalf("ResolveStation(%q) suggestions = %v, want %v", c.input, names, c.want)
+		}
+		for i := range names {
+			if names[i] != c.want[i] {
+				t.Fatalf("ResolveStation(%q) suggestions = %v, want %v", c.input, names, c.want)
+			}
+		}
+	}
+}
@@ -1,10 +1,10 @@
 package service
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"github.com/xuri/excelize/v2"
-	"log"
+	"log/slog"
 	"os"
 	"railway/dao"
 	"strings"
@@ -17,14 +17,13 @@ var KeyStation map[string]dao.Station
 func DownLoadStation() error {
 	file, err := excelize.OpenFile("车站信息.xlsx")
 	if err != nil {
-		log.Fatalf("无法打开文件: %v", err)
+		slog.Error("无法打开文件", "err", err)
 		return err
 	}
 
 	// 获取第一个工作表名称
 	sheetNames := file.GetSheetList()
 	if len(sheetNames) == 0 {
-		log.Fatal("Excel 文件中没有工作表")
 		return errors.New("Excel 文件中没有工作表")
 	}
 
@@ -33,7 +32,7 @@ func DownLoadStation() error {
 	// 读取 Excel 工作表的数据
 	rows, err := file.GetRows(sheetName)
 	if err != nil {
-		log.Fatalf("无法读取工作表数据: %v", err)
+		slog.Error("无法读取工作表数据", "err", err)
 		return err
 	}
 
@@ -71,9 +70,9 @@ func DownLoadStation() error {
 
 	// 打印所有读取到的车站数据
 	for _, station := range stations {
-		err := StationService.CreateStation(&station)
+		err := StationService.CreateStation(context.Background(), &station)
 		if err != nil {
-			fmt.Printf("station:%v, err:%s\n", station, err)
+			slog.Error("create station failed", "station", station, "err", err)
 			return err
 		}
 		//else {
@@ -82,7 +81,7 @@ func DownLoadStation() error {
 	}
 	data, err := os.ReadFile("站点选择.txt") // 确保文件路径正确
 	if err != nil {
-		fmt.Println("读取文件失败:", err)
+		slog.Error("读取文件失败", "err", err)
 		return err
 	}
 
@@ -92,24 +91,25 @@ func DownLoadStation() error {
 	// 去掉可能的空行
 	for _, stationName := range stationNames {
 		stationName = strings.TrimSpace(stationName)
-		station, err := StationService.GetStationByName(stationName)
+		station, err := StationService.GetStationByName(context.Background(), stationName)
 		if err != nil {
-			fmt.Printf("city:%v, err:%s\n", station, err)
+			slog.Error("get station failed", "station", stationName, "err", err)
 			return err
 		}
 		station.IsKeyStation = 1
 		KeyStation[station.StationName] = *station
-		err = StationService.UpdateStation(station)
+		err = StationService.UpdateStation(context.Background(), station)
 	}
 
-	log.Print("DownLoadStation success\n")
+	slog.Info("DownLoadStation success")
+	bumpTimetableVersion()
 	return nil
 }
 
 func DownLoadKeyStation() error {
 	data, err := os.ReadFile("站点选择.txt") // 确保文件路径正确
 	if err != nil {
-		fmt.Println("读取文件失败:", err)
+		slog.Error("读取文件失败", "err", err)
 		return err
 	}
 
@@ -119,14 +119,33 @@ func DownLoadKeyStation() error {
 	// 去掉可能的空行
 	for _, city := range cities {
 		city = strings.TrimSpace(city)
-		Stations, err := StationService.GetStationByName(city)
+		Stations, err := StationService.GetStationByName(context.Background(), city)
 		if err != nil {
-			fmt.Printf("city:%v, err:%s\n", city, err)
+			slog.Error("get station failed", "station", city, "err", err)
 			return err
 		}
 		KeyStation[Stations.StationName] = *Stations
 	}
 
-	log.Print("DownLoadKeyStation success\n")
+	slog.Info("DownLoadKeyStation success")
+	bumpTimetableVersion()
 	return nil
 }
+
+// LookupStations 按前缀联想，返回排序后的城市和车站，匹配车站名、拼音、首字母、简称与电报码
+func (r *RailWayServiceImpl) LookupStations(ctx context.Context, keyword string) ([]dao.City, []dao.Station, error) {
+	suggestions, err := r.Autocomplete(ctx, keyword, DefaultSuggestionLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	cities := make([]dao.City, 0)
+	stations := make([]dao.Station, 0)
+	for _, suggestion := range suggestions {
+		if suggestion.IsCity {
+			cities = append(cities, dao.City{CityCode: suggestion.CityCode, CityName: suggestion.Name})
+		} else {
+			stations = append(stations, *suggestion.Station)
+		}
+	}
+	return cities, stations, nil
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"railway/dao"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// TrainStop 车次的一个经停站，Day 为相对始发日的天数
+type TrainStop struct {
+	Station         string
+	ArrivalTime     string
+	DepartureTime   string
+	Day             uint
+	PriceFromOrigin float64
+}
+
+// TrainDetail 车次详情，Stops 按经停顺序排列，SeatClasses 为任一区间有售的席别
+type TrainDetail struct {
+	TrainNumber string
+	TrainNo     string
+	IsHighSpeed uint
+	Stops       []TrainStop
+	SeatClasses []string
+}
+
+// GetTrainByNumber 按车次号查询，同一车次号有多个 TrainNo 时取第一个
+func (r *RailWayServiceImpl) GetTrainByNumber(ctx context.Context, trainNumber string) (*TrainDetail, error) {
+	railWays, err := r.RailWayDAO.GetRailWayByTrainNumber(ctx, trainNumber)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "GetTrainByNumber", "train_number", trainNumber, "err", err)
+		return nil, err
+	}
+	if len(railWays) == 0 {
+		return nil, errors.New("trainNotFind")
+	}
+	return r.GetTrain(ctx, railWays[0].TrainNo)
+}
+
+// GetTrain 由 O/D 记录还原车次的经停站：始发站是唯一一个没有作为到达站出现过的车站，
+// 从始发站出发的各条记录按运行时间排序即为经停顺序
+func (r *RailWayServiceImpl) GetTrain(ctx context.Context, trainNo string) (*TrainDetail, error) {
+	railWays, err := r.RailWayDAO.GetRailWayByTrainNo(ctx, trainNo)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "GetTrain", "train_no", trainNo, "err", err)
+		return nil, err
+	}
+	return buildTrainDetail(trainNo, railWays)
+}
+
+// buildTrainDetail 由同一 TrainNo 的全部 O/D 记录还原经停站
+func buildTrainDetail(trainNo string, railWays []dao.RailWay) (*TrainDetail, error) {
+	if len(railWays) == 0 {
+		return nil, errors.New("trainNotFind")
+	}
+	arrivalStations := make(map[string]bool)
+	departureTimes := make(map[string]string)
+	for _, railWay := range railWays {
+		arrivalStations[railWay.ArrivalStation] = true
+		departureTimes[railWay.DepartureStation] = railWay.DepartureTime
+	}
+	origin := ""
+	for _, railWay := range railWays {
+		if !arrivalStations[railWay.DepartureStation] {
+			origin = railWay.DepartureStation
+			break
+		}
+	}
+	if origin == "" {
+		return nil, errors.New("trainOriginNotFind")
+	}
+	fromOrigin := make([]dao.RailWay, 0)
+	rememberStation := make(map[string]bool)
+	for _, railWay := range railWays {
+		if railWay.DepartureStation != origin || rememberStation[railWay.ArrivalStation] {
+			continue
+		}
+		rememberStation[railWay.ArrivalStation] = true
+		fromOrigin = append(fromOrigin, railWay)
+	}
+	sort.Slice(fromOrigin, func(i, j int) bool {
+		iRunningTime, _ := GetTime(fromOrigin[i].RunningTime)
+		jRunningTime, _ := GetTime(fromOrigin[j].RunningTime)
+		return iRunningTime < jRunningTime
+	})
+
+	detail := &TrainDetail{
+		TrainNumber: railWays[0].TrainNumber,
+		TrainNo:     trainNo,
+		IsHighSpeed: railWays[0].IsHighSpeed,
+		Stops: []TrainStop{{
+			Station:       origin,
+			DepartureTime: departureTimes[origin],
+		}},
+	}
+	sold := make(map[string]bool)
+	for _, railWay := range railWays {
+		for seatClass := range Fares(railWay) {
+			sold[seatClass] = true
+		}
+	}
+	for _, seatClass := range SeatClasses {
+		if sold[seatClass] {
+			detail.SeatClasses = append(detail.SeatClasses, seatClass)
+		}
+	}
+	for _, railWay := range fromOrigin {
+		detail.Stops = append(detail.Stops, TrainStop{
+			Station:         railWay.ArrivalStation,
+			ArrivalTime:     railWay.ArrivalTime,
+			DepartureTime:   departureTimes[railWay.ArrivalStation],
+			Day:             railWay.ArrivalDay,
+			PriceFromOrigin: railWay.Price,
+		})
+	}
+	return detail, nil
+}
+
+// Between 返回 from 到 to 之间（含两端）的经停站，任一车站不在本车次中时返回 nil
+func (d *TrainDetail) Between(from, to string) []TrainStop {
+	start, end := -1, -1
+	for index, stop := range d.Stops {
+		if stop.Station == from && start < 0 {
+			start = index
+		}
+		if stop.Station == to && start >= 0 {
+			end = index
+			break
+		}
+	}
+	if start < 0 || end < 0 {
+		return nil
+	}
+	return d.Stops[start : end+1]
+}
+
+// NetworkLink 相邻两站之间的线路，Trains 为经过该区间的车次数
+type NetworkLink struct {
+	From   string
+	To     string
+	Trains int
+}
+
+type networkSnapshot struct {
+	version uint64
+	links   []NetworkLink
+}
+
+var (
+	networkMu      sync.Mutex
+	currentNetwork atomic.Pointer[networkSnapshot]
+)
+
+// NetworkLinks 按车次还原经停顺序，统计每对相邻车站之间的车次数；结果按时刻表版本缓存
+func (r *RailWayServiceImpl) NetworkLinks(ctx context.Context) ([]NetworkLink, error) {
+	version := TimetableVersion()
+	if snapshot := currentNetwork.Load(); snapshot != nil && snapshot.version == version {
+		return snapshot.links, nil
+	}
+	networkMu.Lock()
+	defer networkMu.Unlock()
+	if snapshot := currentNetwork.Load(); snapshot != nil && snapshot.version == version {
+		return snapshot.links, nil
+	}
+	railWays, err := r.RailWayDAO.GetAllRailWays(ctx)
+	if err != nil {
+		r.logger(ctx).Error("query failed", "method", "NetworkLinks", "err", err)
+		return nil, err
+	}
+	byTrain := make(map[string][]dao.RailWay)
+	for _, railWay := range railWays {
+		byTrain[railWay.TrainNo] = append(byTrain[railWay.TrainNo], railWay)
+	}
+	counts := make(map[[2]string]int)
+	for trainNo, trainRailWays := range byTrain {
+		detail, err := buildTrainDetail(trainNo, trainRailWays)
+		if err != nil {
+			continue
+		}
+		for index := 1; index < len(detail.Stops); index++ {
+			counts[[2]string{detail.Stops[index-1].Station, detail.Stops[index].Station}]++
+		}
+	}
+	links := make([]NetworkLink, 0, len(counts))
+	for pair, trains := range counts {
+		links = append(links, NetworkLink{From: pair[0], To: pair[1], Trains: trains})
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Trains != links[j].Trains {
+			return links[i].Trains > links[j].Trains
+		}
+		return links[i].From+links[i].To < links[j].From+links[j].To
+	})
+	currentNetwork.Store(&networkSnapshot{version: version, links: links})
+	return links, nil
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"railway/dao"
+	"railway/fare"
+	"railway/logging"
+	"time"
+)
+
+// 候补优先级，数值大的先兑现
+const (
+	WaitlistTierStandard = 0
+	WaitlistTierPriority = 1
+)
+
+const (
+	// WaitlistCutoff 发车前该时长停止兑现候补，截止时间不能晚于此
+	WaitlistCutoff = 2 * time.Hour
+	// waitingEntryBatch 每次兑现时读取的排队候补数量上限
+	waitingEntryBatch = 1000
+)
+
+var (
+	ErrWaitlistNotFound = errors.New("waitlistNotFind")
+	ErrSeatsAvailable   = errors.New("seatsAvailable")
+
+	WaitlistDAO dao.WaitlistDAO
+	W           WaitlistServiceImpl
+)
+
+// WaitlistRequest 候补一段，Deadline 为零值时截止到 WaitlistCutoff
+type WaitlistRequest struct {
+	Leg        OrderLegRequest
+	Passengers []OrderPassengerRequest
+	Priority   int
+	Deadline   time.Time
+	APIKeyID   int
+}
+
+type WaitlistService interface {
+	JoinWaitlist(ctx context.Context, req WaitlistRequest) (*dao.WaitlistEntry, error)
+	GetWaitlistEntry(ctx context.Context, id string, apiKeyID int) (*dao.WaitlistEntry, int64, error)
+	CancelWaitlistEntry(ctx context.Context, id string, apiKeyID int) (*dao.WaitlistEntry, error)
+	MatchWaitlist(ctx context.Context) (int, error)
+	RunWaitlist(ctx context.Context, interval time.Duration)
+	Notify()
+}
+
+type WaitlistServiceImpl struct {
+	WaitlistDAO dao.WaitlistDAO
+	Railways    RailwayService
+	Orders      OrderService
+	Logger      *slog.Logger
+
+	wake chan struct{}
+	now  func() time.Time
+}
+
+var _ WaitlistService = (*WaitlistServiceImpl)(nil)
+
+func NewWaitlistService(WaitlistDAO dao.WaitlistDAO, Railways RailwayService, Orders OrderService, logger *slog.Logger) WaitlistServiceImpl {
+	return WaitlistServiceImpl{
+		WaitlistDAO: WaitlistDAO,
+		Railways:    Railways,
+		Orders:      Orders,
+		Logger:      logger,
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+	}
+}
+
+func (s *WaitlistServiceImpl) logger(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, s.Logger)
+}
+
+// JoinWaitlist 所选席别余票不足时加入候补，仍有余票时返回 ErrSeatsAvailable
+func (s *WaitlistServiceImpl) JoinWaitlist(ctx context.Context, req WaitlistRequest) (*dao.WaitlistEntry, error) {
+	if len(req.Passengers) == 0 || len(req.Passengers) > MaxOrderPassengers {
+		return nil, fmt.Errorf("%w: passengers: must have 1 to %d items", ErrInvalidOrder, MaxOrderPassengers)
+	}
+	leg, err := s.Railways.GetLeg(ctx, req.Leg.TrainNo, req.Leg.From, req.Leg.To)
+	if err != nil {
+		return nil, err
+	}
+	if leg == nil {
+		return nil, fmt.Errorf("%w: %s does not run from %s to %s", ErrLegNotFound, req.Leg.TrainNo, req.Leg.From, req.Leg.To)
+	}
+	if seatFare(*leg, req.Leg.SeatClass) < 0.5 {
+		return nil, fmt.Errorf("%w: %s", ErrSeatClassNotSold, req.Leg.SeatClass)
+	}
+	departure, err := legDeparture(dao.OrderLeg{Date: req.Leg.Date, DepartureTime: leg.DepartureTime})
+	if err != nil {
+		return nil, fmt.Errorf("%w: date: %v", ErrInvalidOrder, err)
+	}
+	now := s.now()
+	cutoff := departure.Add(-WaitlistCutoff)
+	if !cutoff.After(now) {
+		return nil, ErrOrderDeparted
+	}
+	deadline := req.Deadline
+	if deadline.IsZero() || deadline.After(cutoff) {
+		deadline = cutoff
+	}
+	if !deadline.After(now) {
+		return nil, fmt.Errorf("%w: deadline: must be in the future", ErrInvalidOrder)
+	}
+	remaining, err := s.Railways.RemainingSeats(ctx, req.Leg.TrainNo, req.Leg.Date, req.Leg.From, req.Leg.To)
+	if err != nil {
+		return nil, err
+	}
+	if remaining[req.Leg.SeatClass] >= len(req.Passengers) {
+		return nil, ErrSeatsAvailable
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	entry := &dao.WaitlistEntry{
+		ID:         id,
+		Status:     dao.WaitlistWaiting,
+		APIKeyID:   req.APIKeyID,
+		TrainNo:    req.Leg.TrainNo,
+		Date:       req.Leg.Date,
+		SeatClass:  req.Leg.SeatClass,
+		From:       req.Leg.From,
+		To:         req.Leg.To,
+		Priority:   req.Priority,
+		Deadline:   deadline,
+		Passengers: make([]dao.WaitlistPassenger, 0, len(req.Passengers)),
+	}
+	for _, passenger := range req.Passengers {
+		entry.Passengers = append(entry.Passengers, dao.WaitlistPassenger{
+			Name:     passenger.Name,
+			IDNumber: passenger.IDNumber,
+			Type:     string(passenger.Type),
+		})
+	}
+	if err := s.WaitlistDAO.CreateWaitlistEntry(ctx, entry); err != nil {
+		s.logger(ctx).Error("query failed", "method", "JoinWaitlist", "err", err)
+		return nil, err
+	}
+	// 加入时恰好有座位被退还的情况交给下一次兑现处理
+	s.Notify()
+	return entry, nil
+}
+
+// GetWaitlistEntry 查询候补及其在队列中的位置（从 1 开始），不在排队中时位置为 0
+func (s *WaitlistServiceImpl) GetWaitlistEntry(ctx context.Context, id string, apiKeyID int) (*dao.WaitlistEntry, int64, error) {
+	entry, err := s.getEntry(ctx, id, apiKeyID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if entry.Status != dao.WaitlistWaiting {
+		return entry, 0, nil
+	}
+	ahead, err := s.WaitlistDAO.CountWaitlistAhead(ctx, entry)
+	if err != nil {
+		s.logger(ctx).Error("query failed", "method", "CountWaitlistAhead", "waitlist_id", id, "err", err)
+		return nil, 0, err
+	}
+	return entry, ahead + 1, nil
+}
+
+// CancelWaitlistEntry 取消排队中的候补，已兑现的候补需要取消对应的订单
+func (s *WaitlistServiceImpl) CancelWaitlistEntry(ctx context.Context, id string, apiKeyID int) (*dao.WaitlistEntry, error) {
+	entry, err := s.getEntry(ctx, id, apiKeyID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	err = s.WaitlistDAO.UpdateWaitlistStatus(ctx, entry.ID, dao.WaitlistWaiting, dao.WaitlistCancelled, map[string]any{"closed_at": now})
+	if err != nil {
+		if !errors.Is(err, dao.ErrWaitlistStateChanged) {
+			s.logger(ctx).Error("query failed", "method", "CancelWaitlistEntry", "waitlist_id", id, "err", err)
+		}
+		return entry, err
+	}
+	entry.Status = dao.WaitlistCancelled
+	entry.ClosedAt = &now
+	return entry, nil
+}
+
+// waitlistPool 同一车次、始发日期与席别的候补共用各区间的余票
+type waitlistPool struct {
+	trainNo, originDate, seatClass string
+}
+
+// MatchWaitlist 按优先级从高到低、同一优先级按加入先后兑现候补：为候补生成并确认订单。
+// 同一车次、始发日期与席别的候补共用各区间的余票，某个候补余票不足时，之后与其区间重叠的候补本轮不再兑现，
+// 保证退还的座位先给排在前面的候补；区间不重叠的候补仍可兑现。返回兑现的候补数
+func (s *WaitlistServiceImpl) MatchWaitlist(ctx context.Context) (int, error) {
+	entries, err := s.WaitlistDAO.GetWaitingEntries(ctx, waitingEntryBatch)
+	if err != nil {
+		s.logger(ctx).Error("query failed", "method", "MatchWaitlist", "err", err)
+		return 0, err
+	}
+	trains := make(map[string]*TrainDetail)
+	blocked := make(map[waitlistPool][]seatSpan)
+	fulfilled := 0
+	for i := range entries {
+		entry := &entries[i]
+		if s.now().After(entry.Deadline) {
+			s.closeEntry(ctx, entry, dao.WaitlistExpired)
+			continue
+		}
+		span, err := s.entrySpan(ctx, trains, entry)
+		if err != nil {
+			s.logger(ctx).Error("fulfil waitlist failed", "waitlist_id", entry.ID, "err", err)
+			continue
+		}
+		pool := waitlistPool{entry.TrainNo, span.OriginDate, entry.SeatClass}
+		if overlapsAny(blocked[pool], span) {
+			continue
+		}
+		err = s.fulfil(ctx, entry)
+		switch {
+		case err == nil:
+			fulfilled++
+		case errors.Is(err, ErrOrderDeparted):
+			s.closeEntry(ctx, entry, dao.WaitlistExpired)
+		case errors.Is(err, dao.ErrWaitlistStateChanged):
+		default:
+			if !errors.Is(err, dao.ErrSeatsSoldOut) {
+				s.logger(ctx).Error("fulfil waitlist failed", "waitlist_id", entry.ID, "err", err)
+			}
+			blocked[pool] = append(blocked[pool], span)
+		}
+	}
+	return fulfilled, nil
+}
+
+// entrySpan 候补占用的区间，trains 缓存本轮已查询的车次
+func (s *WaitlistServiceImpl) entrySpan(ctx context.Context, trains map[string]*TrainDetail, entry *dao.WaitlistEntry) (seatSpan, error) {
+	detail, ok := trains[entry.TrainNo]
+	if !ok {
+		var err error
+		detail, err = s.Railways.GetTrain(ctx, entry.TrainNo)
+		if err != nil {
+			return seatSpan{}, err
+		}
+		trains[entry.TrainNo] = detail
+	}
+	return newSeatSpan(detail, entry.Date, entry.From, entry.To)
+}
+
+// overlapsAny span 是否与 spans 中的某个区间有共同的区段
+func overlapsAny(spans []seatSpan, span seatSpan) bool {
+	for _, other := range spans {
+		if span.From < other.To && other.From < span.To {
+			return true
+		}
+	}
+	return false
+}
+
+// RunWaitlist 每隔 interval 或有座位被退还时兑现一次候补，ctx 取消后返回；interval 不大于 0 时为一分钟
+func (s *WaitlistServiceImpl) RunWaitlist(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		if fulfilled, err := s.MatchWaitlist(ctx); err == nil && fulfilled > 0 {
+			s.logger(ctx).Info("waitlist fulfilled", "count", fulfilled)
+		}
+	}
+}
+
+// Notify 唤醒 RunWaitlist，已有待处理的唤醒时直接返回
+func (s *WaitlistServiceImpl) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// fulfil 先生成 held 订单占座，候补转为 fulfilled 后再确认订单；
+// 候补已被取消时取消刚生成的订单退还座位
+func (s *WaitlistServiceImpl) fulfil(ctx context.Context, entry *dao.WaitlistEntry) error {
+	req := OrderRequest{
+		Legs:       []OrderLegRequest{{TrainNo: entry.TrainNo, Date: entry.Date, From: entry.From, To: entry.To, SeatClass: entry.SeatClass}},
+		Passengers: make([]OrderPassengerRequest, 0, len(entry.Passengers)),
+		APIKeyID:   entry.APIKeyID,
+	}
+	for _, passenger := range entry.Passengers {
+		req.Passengers = append(req.Passengers, OrderPassengerRequest{
+			Name:     passenger.Name,
+			IDNumber: passenger.IDNumber,
+			Type:     fare.PassengerType(passenger.Type),
+		})
+	}
+	order, err := s.Orders.CreateOrder(ctx, req)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	err = s.WaitlistDAO.UpdateWaitlistStatus(ctx, entry.ID, dao.WaitlistWaiting, dao.WaitlistFulfilled, map[string]any{"order_id": order.ID, "fulfilled_at": now})
+	if err != nil {
+		if _, cancelErr := s.Orders.CancelOrder(ctx, order.ID, entry.APIKeyID); cancelErr != nil {
+			s.logger(ctx).Error("cancel waitlist order failed", "waitlist_id", entry.ID, "order_id", order.ID, "err", cancelErr)
+		}
+		return err
+	}
+	if _, err := s.Orders.ConfirmOrder(ctx, order.ID, entry.APIKeyID); err != nil {
+		s.logger(ctx).Error("confirm waitlist order failed", "waitlist_id", entry.ID, "order_id", order.ID, "err", err)
+	}
+	return nil
+}
+
+func (s *WaitlistServiceImpl) closeEntry(ctx context.Context, entry *dao.WaitlistEntry, status string) {
+	err := s.WaitlistDAO.UpdateWaitlistStatus(ctx, entry.ID, dao.WaitlistWaiting, status, map[string]any{"closed_at": s.now()})
+	if err != nil && !errors.Is(err, dao.ErrWaitlistStateChanged) {
+		s.logger(ctx).Error("query failed", "method", "closeEntry", "waitlist_id", entry.ID, "err", err)
+	}
+}
+
+// getEntry 其他使用方的候补与不存在的候补一样处理
+func (s *WaitlistServiceImpl) getEntry(ctx context.Context, id string, apiKeyID int) (*dao.WaitlistEntry, error) {
+	entry, err := s.WaitlistDAO.GetWaitlistEntry(ctx, id)
+	if err != nil {
+		s.logger(ctx).Error("query failed", "method", "GetWaitlistEntry", "waitlist_id", id, "err", err)
+		return nil, err
+	}
+	if entry == nil || entry.APIKeyID != apiKeyID {
+		return nil, ErrWaitlistNotFound
+	}
+	return entry, nil
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"railway/dao"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryWaitlistDAO 内存中的候补，与数据库实现一样按 Priority 从大到小、同一 Priority 按先后返回
+type memoryWaitlistDAO struct {
+	dao.WaitlistDAO
+	mu      sync.Mutex
+	entries map[string]dao.WaitlistEntry
+}
+
+func (m *memoryWaitlistDAO) CreateWaitlistEntry(_ context.Context, entry *dao.WaitlistEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[entry.ID] = *entry
+	return nil
+}
+
+func (m *memoryWaitlistDAO) GetWaitlistEntry(_ context.Context, id string) (*dao.WaitlistEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[id]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (m *memoryWaitlistDAO) GetWaitingEntries(_ context.Context, limit int) ([]dao.WaitlistEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make([]dao.WaitlistEntry, 0)
+	for _, entry := range m.entries {
+		if entry.Status == dao.WaitlistWaiting {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Priority != entries[j].Priority {
+			return entries[i].Priority > entries[j].Priority
+		}
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries[:min(limit, len(entries))], nil
+}
+
+func (m *memoryWaitlistDAO) UpdateWaitlistStatus(_ context.Context, id, from, to string, updates map[string]any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[id]
+	if !ok || entry.Status != from {
+		return dao.ErrWaitlistStateChanged
+	}
+	entry.Status = to
+	if orderID, ok := updates["order_id"].(string); ok {
+		entry.OrderID = orderID
+	}
+	m.entries[id] = entry
+	return nil
+}
+
+func newTestWaitlistService() (*WaitlistServiceImpl, *OrderServiceImpl, *memoryWaitlistDAO) {
+	orders, _ := newTestOrderService()
+	waitlistDAO := &memoryWaitlistDAO{entries: make(map[string]dao.WaitlistEntry)}
+	waitlist := NewWaitlistService(waitlistDAO, orders.Railways, orders, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return &waitlist, orders, waitlistDAO
+}
+
+// queueEntry 直接写入一条排队中的候补，created 为加入的先后
+func queueEntry(t *testing.T, waitlistDAO *memoryWaitlistDAO, id, date, from, to string, priority int, created time.Duration) {
+	t.Helper()
+	err := waitlistDAO.CreateWaitlistEntry(context.Background(), &dao.WaitlistEntry{
+		ID:         id,
+		Status:     dao.WaitlistWaiting,
+		TrainNo:    testTrainNo,
+		Date:       date,
+		SeatClass:  SeatSWZ,
+		From:       from,
+		To:         to,
+		Priority:   priority,
+		Deadline:   time.Now().Add(time.Hour),
+		CreatedAt:  time.Now().Add(created),
+		Passengers: []dao.WaitlistPassenger{{Name: "李四", IDNumber: "110101199001011234", Type: "adult"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sellOut 买光 from 到 to 的商务座，返回各订单
+func sellOut(t *testing.T, orders *OrderServiceImpl, date, from, to string) []*dao.Order {
+	t.Helper()
+	sold := make([]*dao.Order, 0, DefaultSeatCapacity[SeatSWZ])
+	for i := 0; i < DefaultSeatCapacity[SeatSWZ]; i++ {
+		order, err := orders.CreateOrder(context.Background(), testOrderRequest(
+			OrderLegRequest{TrainNo: testTrainNo, Date: date, From: from, To: to, SeatClass: SeatSWZ}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sold = append(sold, order)
+	}
+	return sold
+}
+
+func waitlistStatus(t *testing.T, waitlistDAO *memoryWaitlistDAO, id string) string {
+	t.Helper()
+	entry, err := waitlistDAO.GetWaitlistEntry(context.Background(), id)
+	if err != nil || entry == nil {
+		t.Fatalf("GetWaitlistEntry(%s) = %v, %v", id, entry, err)
+	}
+	return entry.Status
+}
+
+// TestMatchWaitlistFIFO 退还的座位先给排在前面的候补，之后区间重叠的候补不能插队，区间不重叠的仍可兑现
+func TestMatchWaitlistFIFO(t *testing.T) {
+	waitlist, orders, waitlistDAO := newTestWaitlistService()
+	ctx := context.Background()
+	date := time.Now().AddDate(0, 0, 1).Format(seatDateLayout)
+	first := sellOut(t, orders, date, "北京南", "济南西")
+	second := sellOut(t, orders, date, "济南西", "南京南")
+
+	queueEntry(t, waitlistDAO, "through", date, "北京南", "南京南", WaitlistTierStandard, 0)
+	queueEntry(t, waitlistDAO, "short", date, "北京南", "济南西", WaitlistTierStandard, time.Second)
+
+	// 只退还第一段时全程的候补仍不能兑现，第一段的候补排在其后，不能先拿走座位
+	if _, err := orders.CancelOrder(ctx, first[0].ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	fulfilled, err := waitlist.MatchWaitlist(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fulfilled != 0 {
+		t.Fatalf("fulfilled = %d, want 0", fulfilled)
+	}
+
+	if _, err := orders.CancelOrder(ctx, second[0].ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	fulfilled, err = waitlist.MatchWaitlist(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fulfilled != 1 {
+		t.Fatalf("fulfilled = %d, want 1", fulfilled)
+	}
+	if status := waitlistStatus(t, waitlistDAO, "through"); status != dao.WaitlistFulfilled {
+		t.Fatalf("through status = %s, want %s", status, dao.WaitlistFulfilled)
+	}
+	if status := waitlistStatus(t, waitlistDAO, "short"); status != dao.WaitlistWaiting {
+		t.Fatalf("short status = %s, want %s", status, dao.WaitlistWaiting)
+	}
+
+	// 第二段的候补与排在前面的第一段候补不重叠，可以使用第二段退还的座位
+	queueEntry(t, waitlistDAO, "second", date, "济南西", "南京南", WaitlistTierStandard, 2*time.Second)
+	if _, err := orders.CancelOrder(ctx, second[1].ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	fulfilled, err = waitlist.MatchWaitlist(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fulfilled != 1 {
+		t.Fatalf("fulfilled = %d, want 1", fulfilled)
+	}
+	if status := waitlistStatus(t, waitlistDAO, "second"); status != dao.WaitlistFulfilled {
+		t.Fatalf("second status = %s, want %s", status, dao.WaitlistFulfilled)
+	}
+	if status := waitlistStatus(t, waitlistDAO, "short"); status != dao.WaitlistWaiting {
+		t.Fatalf("short status = %s, want %s", status, dao.WaitlistWaiting)
+	}
+}
+
+// TestMatchWaitlistPriority 优先级高的候补即使加入得晚也先兑现
+func TestMatchWaitlistPriority(t *testing.T) {
+	waitlist, orders, waitlistDAO := newTestWaitlistService()
+	ctx := context.Background()
+	date := time.Now().AddDate(0, 0, 1).Format(seatDateLayout)
+	sold := sellOut(t, orders, date, "北京南", "济南西")
+
+	queueEntry(t, waitlistDAO, "standard", date, "北京南", "济南西", WaitlistTierStandard, 0)
+	queueEntry(t, waitlistDAO, "priority", date, "北京南", "南京南", WaitlistTierPriority, time.Second)
+
+	if _, err := orders.CancelOrder(ctx, sold[0].ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	fulfilled, err := waitlist.MatchWaitlist(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fulfilled != 1 {
+		t.Fatalf("fulfilled = %d, want 1", fulfilled)
+	}
+	if status := waitlistStatus(t, waitlistDAO, "priority"); status != dao.WaitlistFulfilled {
+		t.Fatalf("priority status = %s, want %s", status, dao.WaitlistFulfilled)
+	}
+	if status := waitlistStatus(t, waitlistDAO, "standard"); status != dao.WaitlistWaiting {
+		t.Fatalf("standard status = %s, want %s", status, dao.WaitlistWaiting)
+	}
+}
+
+// TestMatchWaitlistConfirmsOrder 兑现的候补记录订单号，订单随即确认；过了截止时间的候补转为 expired
+func TestMatchWaitlistConfirmsOrder(t *testing.T) {
+	waitlist, orders, waitlistDAO := newTestWaitlistService()
+	ctx := context.Background()
+	date := time.Now().AddDate(0, 0, 1).Format(seatDateLayout)
+	capacity := DefaultSeatCapacity[SeatSWZ]
+
+	queueEntry(t, waitlistDAO, "waiting", date, "北京南", "济南西", WaitlistTierStandard, 0)
+	queueEntry(t, waitlistDAO, "late", date, "北京南", "济南西", WaitlistTierStandard, time.Second)
+	late := waitlistDAO.entries["late"]
+	late.Deadline = time.Now().Add(-time.Minute)
+	waitlistDAO.entries["late"] = late
+
+	fulfilled, err := waitlist.MatchWaitlist(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fulfilled != 1 {
+		t.Fatalf("fulfilled = %d, want 1", fulfilled)
+	}
+	entry, err := waitlistDAO.GetWaitlistEntry(ctx, "waiting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Status != dao.WaitlistFulfilled || entry.OrderID == "" {
+		t.Fatalf("entry = %s with order %q, want %s with an order", entry.Status, entry.OrderID, dao.WaitlistFulfilled)
+	}
+	order, err := orders.OrderDAO.GetOrder(ctx, entry.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order == nil || order.Status != dao.OrderConfirmed {
+		t.Fatalf("order = %v, want %s", order, dao.OrderConfirmed)
+	}
+	remaining, err := orders.Railways.RemainingSeats(ctx, testTrainNo, date, "北京南", "济南西")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining[SeatSWZ] != capacity-1 {
+		t.Fatalf("remaining = %d, want %d", remaining[SeatSWZ], capacity-1)
+	}
+	if status := waitlistStatus(t, waitlistDAO, "late"); status != dao.WaitlistExpired {
+		t.Fatalf("late status = %s, want %s", status, dao.WaitlistExpired)
+	}
+}
@@ -0,0 +1,288 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"railway/fare"
+	"railway/geo"
+	"railway/service"
+	"strconv"
+	"strings"
+)
+
+// sortNames /api/v1 中 sort 参数到 service 排序常量的映射
+var sortNames = map[string]int{
+	"price":          service.LowPriceFirst,
+	"price_desc":     service.HighPriceFirst,
+	"duration":       service.LowRunningTimeFirst,
+	"duration_desc":  service.HighRunningTimeFirst,
+	"departure":      service.EarlyFirst,
+	"departure_desc": service.LateFirst,
+}
+
+func (h *HandlerImpl) stationsV1Handler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultSuggestionLimit)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query.limit: must be an integer"})
+		return
+	}
+	suggestions, err := h.RailWayServiceImpl.Autocomplete(c.Request.Context(), c.Query("q"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching results"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"stations": toSuggestionDTOs(suggestions)})
+}
+
+func (h *HandlerImpl) journeysV1Handler(c *gin.Context) {
+	query, err := parseJourneyQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	results, partial, err := h.cachedSearchJourneys(c.Request.Context(), query)
+	if err != nil {
+		writeSearchError(c, err)
+		return
+	}
+	journeys := h.attachSeats(c, toJourneyDTOs(results, query.Date, query.Time), query.OnlyAvailable)
+	c.JSON(http.StatusOK, gin.H{"journeys": journeys, "partial": partial})
+}
+
+// nearbyStationsV1Handler 按坐标查询附近车站，radius 单位为千米
+func (h *HandlerImpl) nearbyStationsV1Handler(c *gin.Context) {
+	lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(c.Query("lon"), 64)
+	center := geo.Point{Lat: lat, Lon: lon}
+	if latErr != nil || lonErr != nil || !center.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query.lat, query.lon: must be valid coordinates"})
+		return
+	}
+	radius, err := strconv.ParseFloat(c.DefaultQuery("radius", strconv.FormatFloat(service.DefaultNearbyRadiusKm, 'f', -1, 64)), 64)
+	if err != nil || radius <= 0 || radius > service.MaxNearbyRadiusKm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query.radius: must be between 0 and " + strconv.FormatFloat(service.MaxNearbyRadiusKm, 'f', -1, 64)})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultSuggestionLimit)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query.limit: must be an integer"})
+		return
+	}
+	stations, err := h.RailWayServiceImpl.NearbyStations(c.Request.Context(), center, radius, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching results"})
+		return
+	}
+	results := make([]NearbyStationDTO, 0, len(stations))
+	for _, station := range stations {
+		results = append(results, toNearbyStationDTO(station))
+	}
+	c.JSON(http.StatusOK, gin.H{"stations": results})
+}
+
+// citiesV1Handler q 为空时返回全部城市
+func (h *HandlerImpl) citiesV1Handler(c *gin.Context) {
+	cities, err := h.RailWayServiceImpl.ListCities(c.Request.Context(), c.Query("q"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching results"})
+		return
+	}
+	results := make([]CityDTO, 0, len(cities))
+	for _, city := range cities {
+		results = append(results, CityDTO{Code: city.CityCode, Name: city.CityName})
+	}
+	c.JSON(http.StatusOK, gin.H{"cities": results})
+}
+
+func (h *HandlerImpl) cityV1Handler(c *gin.Context) {
+	city, stations, err := h.RailWayServiceImpl.GetCity(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		if isCityNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "city not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching results"})
+		return
+	}
+	result := CityDTO{Code: city.CityCode, Name: city.CityName, Stations: make([]StationDTO, 0, len(stations))}
+	for _, station := range stations {
+		result.Stations = append(result.Stations, StationDTO{
+			Name:   station.StationName,
+			Type:   "station",
+			Code:   station.StationCode,
+			Pinyin: station.StationPinyin,
+			City:   city.CityName,
+		})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// parseJourneyQuery 解析 /api/v1/journeys 与 /search/stream 共用的查询参数
+func parseJourneyQuery(c *gin.Context) (journeyQuery, error) {
+	query := journeyQuery{
+		From:      c.Query("from"),
+		To:        c.Query("to"),
+		Mid:       c.Query("via"),
+		FromCity:  c.Query("from_city"),
+		ToCity:    c.Query("to_city"),
+		MidCity:   c.Query("via_city"),
+		Date:      c.Query("date"),
+		Time:      c.Query("time"),
+		TrainType: c.DefaultQuery("train_type", service.Default),
+		SortBy:    sortNames[c.DefaultQuery("sort", "duration")],
+	}
+	if (query.From == "") == (query.FromCity == "") {
+		return query, errors.New("query: exactly one of from and from_city is required")
+	}
+	if (query.To == "") == (query.ToCity == "") {
+		return query, errors.New("query: exactly one of to and to_city is required")
+	}
+	if query.Mid != "" && query.MidCity != "" {
+		return query, errors.New("query: via and via_city are mutually exclusive")
+	}
+	transfers, err := strconv.ParseInt(c.DefaultQuery("transfers", "1"), 10, 64)
+	if err != nil {
+		return query, errors.New("query.transfers: must be an integer")
+	}
+	query.MaxTransfer = transfers
+	query.SeatClasses, err = service.ParseSeatClasses(c.Query("seat_class"))
+	if err != nil {
+		return query, errors.New("query.seat_class: " + err.Error())
+	}
+	query.Passenger, err = fare.ParsePassengerType(c.Query("passenger"))
+	if err != nil {
+		return query, errors.New("query.passenger: " + err.Error())
+	}
+	if available := c.Query("available"); available != "" {
+		query.OnlyAvailable, err = strconv.ParseBool(available)
+		if err != nil {
+			return query, errors.New("query.available: must be a boolean")
+		}
+	}
+	if query.OnlyAvailable && query.Date == "" {
+		return query, errors.New("query.available: requires date")
+	}
+	query.Constraints, err = parseConstraints(c)
+	if err != nil {
+		return query, err
+	}
+	if !query.Constraints.Empty() {
+		if query.MidCity != "" {
+			return query, errors.New("query.via_city: cannot be combined with via lists, avoid or train filters")
+		}
+		query.Mid = ""
+	}
+	return query, nil
+}
+
+// parseConstraints 解析途经站列表与避开的车站、车次。只有一个途经站且没有其他约束时仍按 via 指定中转站查询，返回空约束
+func parseConstraints(c *gin.Context) (service.SearchConstraints, error) {
+	constraints := service.SearchConstraints{
+		AvoidStations: splitList(c.Query("avoid")),
+		AvoidCities:   splitList(c.Query("avoid_city")),
+		ExcludeTrains: splitList(c.Query("exclude_trains")),
+		PreferTrains:  splitList(c.Query("prefer_trains")),
+	}
+	stations := splitList(c.Query("via"))
+	dwells := splitList(c.Query("via_dwell"))
+	if len(stations) <= 1 && len(dwells) == 0 && constraints.Empty() {
+		return constraints, nil
+	}
+	if len(dwells) > 0 && len(stations) == 0 {
+		return constraints, errors.New("query.via_dwell: requires via")
+	}
+	if len(dwells) > 1 && len(dwells) != len(stations) {
+		return constraints, errors.New("query.via_dwell: must be one value or one value per via station")
+	}
+	for index, station := range stations {
+		via := service.ViaStation{Station: station}
+		if len(dwells) > 0 {
+			dwell := dwells[0]
+			if len(dwells) > 1 {
+				dwell = dwells[index]
+			}
+			minutes, err := strconv.ParseInt(dwell, 10, 64)
+			if err != nil || minutes < 0 || minutes > service.MaxViaDwell {
+				return constraints, errors.New("query.via_dwell: must be minutes between 0 and " + strconv.Itoa(service.MaxViaDwell))
+			}
+			via.MinDwell = minutes
+		}
+		constraints.Via = append(constraints.Via, via)
+	}
+	return constraints, nil
+}
+
+// splitList 逗号分隔的列表，去掉空白与空项
+func splitList(input string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(input, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// toJourneyDTOs departAfter 不为空时只保留不早于该时刻出发的行程
+func toJourneyDTOs(results []ResponseSearch, date, departAfter string) []JourneyDTO {
+	earliest := int64(0)
+	if departAfter != "" {
+		earliest, _ = service.GetTime(departAfter)
+	}
+	journeys := make([]JourneyDTO, 0, len(results))
+	for _, result := range results {
+		departureTime, _ := service.GetTime(result.DepartureTime)
+		if departureTime < earliest {
+			continue
+		}
+		journeys = append(journeys, toJourneyDTO(result, date))
+	}
+	return journeys
+}
+
+// attachSeats 为每段填入当天的余票，onlyAvailable 时去掉任一段所选席别已售罄的行程。
+// 全部段的余票一次查出；行程没有日期时原样返回，查询余票失败的段不标记售罄
+func (h *HandlerImpl) attachSeats(c *gin.Context, journeys []JourneyDTO, onlyAvailable bool) []JourneyDTO {
+	queries := make([]service.SeatQuery, 0)
+	for _, journey := range journeys {
+		for _, leg := range journey.Legs {
+			if leg.DepartureDate != "" {
+				queries = append(queries, service.SeatQuery{TrainNo: leg.TrainNo, Date: leg.DepartureDate, From: leg.From, To: leg.To})
+			}
+		}
+	}
+	if len(queries) == 0 {
+		return journeys
+	}
+	seats, err := h.RailWayServiceImpl.BatchRemainingSeats(c.Request.Context(), queries)
+	if err != nil {
+		h.logger(c).Warn("remaining seats failed", "legs", len(queries), "err", err)
+		return journeys
+	}
+	results := journeys[:0]
+	next := 0
+	for _, journey := range journeys {
+		available := true
+		for index := range journey.Legs {
+			leg := &journey.Legs[index]
+			if leg.DepartureDate == "" {
+				continue
+			}
+			legSeats := seats[next]
+			next++
+			if legSeats == nil {
+				continue
+			}
+			leg.Seats = legSeats
+			leg.SoldOut = leg.SeatClass != "" && legSeats[leg.SeatClass] <= 0
+			if leg.SoldOut {
+				available = false
+			}
+		}
+		if available || !onlyAvailable {
+			results = append(results, journey)
+		}
+	}
+	return results
+}
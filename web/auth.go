@@ -0,0 +1,169 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"math"
+	"net/http"
+	"railway/config"
+	"railway/dao"
+	"railway/ratelimit"
+	"railway/service"
+	"strconv"
+	"strings"
+)
+
+// 接口类别，对应 config.AuthConfig.Costs 中的键
+const (
+	CostLookup      = "lookup"
+	CostSearch      = "search"
+	CostGraphSearch = "graph_search"
+	CostOrder       = "order"
+)
+
+const (
+	CostAdmin = "admin"
+
+	APIKeyHeader = "X-API-Key"
+	apiKeyCtxKey = "apiKey"
+)
+
+// costFunc 根据请求判断接口类别，多次中转查询需要在换乘图上跑 Dijkstra，消耗更多令牌
+type costFunc func(c *gin.Context) string
+
+func lookupCost(*gin.Context) string {
+	return CostLookup
+}
+
+func orderCost(*gin.Context) string {
+	return CostOrder
+}
+
+func adminCost(*gin.Context) string {
+	return CostAdmin
+}
+
+// journeyCost transfers 不少于 2，或带有途经站列表、避开的车站与车次约束时走图搜索
+func journeyCost(c *gin.Context) string {
+	transfers, _ := strconv.ParseInt(c.DefaultQuery("transfers", "1"), 10, 64)
+	if transfers >= 2 {
+		return CostGraphSearch
+	}
+	for _, name := range []string{"via_dwell", "avoid", "avoid_city", "exclude_trains", "prefer_trains"} {
+		if c.Query(name) != "" {
+			return CostGraphSearch
+		}
+	}
+	if strings.Contains(c.Query("via"), ",") {
+		return CostGraphSearch
+	}
+	return CostSearch
+}
+
+// replanCost 重新规划总是走图搜索
+func replanCost(*gin.Context) string {
+	return CostGraphSearch
+}
+
+// tripCost 多段行程要逐段查询，按图搜索计
+func tripCost(*gin.Context) string {
+	return CostGraphSearch
+}
+
+// searchBodyCost 读取 POST /search 请求体中的 max_transfer，之后还原请求体
+func searchBodyCost(c *gin.Context) string {
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return CostGraphSearch
+	}
+	var req RequestSearch
+	if err := json.Unmarshal(body, &req); err != nil {
+		return CostSearch
+	}
+	transfers, _ := strconv.ParseInt(req.MaxTransfer, 10, 64)
+	if transfers >= 2 {
+		return CostGraphSearch
+	}
+	return CostSearch
+}
+
+// apiKey 优先读取 X-API-Key，其次为 Authorization: Bearer
+func apiKey(c *gin.Context) string {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return key
+	}
+	authorization := c.GetHeader("Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+	return ""
+}
+
+// authorize 返回按接口类别鉴权并限流的中间件，auth.Enabled 为 false 时直接放行
+func (h *HandlerImpl) authorize(auth config.AuthConfig) func(cost costFunc) gin.HandlerFunc {
+	return func(cost costFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if !auth.Enabled {
+				c.Next()
+				return
+			}
+			key, err := h.APIKeyService.Authenticate(c.Request.Context(), apiKey(c))
+			if err != nil {
+				if errors.Is(err, service.ErrInvalidAPIKey) || errors.Is(err, service.ErrDisabledAPIKey) {
+					c.Header("WWW-Authenticate", `Bearer realm="railway"`)
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing API key"})
+					return
+				}
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error checking API key"})
+				return
+			}
+			c.Set(apiKeyCtxKey, key)
+			category := cost(c)
+			tokens, ok := auth.Costs[category]
+			if !ok {
+				tokens = 1
+			}
+			result := h.Limiter.Allow(key.KeyHash, rateLimitOf(*key), tokens)
+			c.Header("X-RateLimit-Remaining", strconv.FormatInt(int64(math.Floor(result.Remaining)), 10))
+			if !result.Allowed {
+				retryAfter := int64(math.Ceil(result.RetryAfter.Seconds()))
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+				message := "rate limit exceeded"
+				if result.QuotaExceeded {
+					message = "daily quota exceeded"
+				}
+				h.logger(c).Info("request throttled", "api_key", key.Prefix, "category", category, "quota_exceeded", result.QuotaExceeded)
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": message})
+				return
+			}
+			c.Next()
+		}
+	}
+}
+
+func rateLimitOf(key dao.APIKey) ratelimit.Limit {
+	return ratelimit.Limit{Rate: key.RatePerSecond, Burst: key.Burst, DailyQuota: key.DailyQuota}
+}
+
+// requireAdmin 放在 authorize 之后，只允许 Admin 为 true 的 key；鉴权关闭时放行
+func requireAdmin(auth config.AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.Enabled {
+			c.Next()
+			return
+		}
+		value, _ := c.Get(apiKeyCtxKey)
+		if key, ok := value.(*dao.APIKey); !ok || !key.Admin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin API key required"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,273 @@
+package web
+
+import (
+	"math"
+	"railway/dao"
+	"railway/service"
+	"strings"
+	"time"
+)
+
+// StationDTO /api/v1/stations 返回的联想结果
+type StationDTO struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`           // station 或 city
+	Code   string `json:"code,omitempty"` // 车站为电报码，城市为城市代码
+	Pinyin string `json:"pinyin,omitempty"`
+	City   string `json:"city,omitempty"`
+	Match  string `json:"match,omitempty"` // 命中方式，如 name_prefix、pinyin、initials、code
+}
+
+// NearbyStationDTO /api/v1/stations/nearby 返回的车站
+type NearbyStationDTO struct {
+	Name       string  `json:"name"`
+	Code       string  `json:"code"`
+	City       string  `json:"city"`
+	Province   string  `json:"province,omitempty"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// CityDTO /api/v1/cities 返回的城市，Stations 只在查询单个城市时返回
+type CityDTO struct {
+	Code     string       `json:"code"`
+	Name     string       `json:"name"`
+	Stations []StationDTO `json:"stations,omitempty"`
+}
+
+// LegDTO 行程中乘坐同一车次的一段
+type LegDTO struct {
+	TrainNumber    string             `json:"train_number"`
+	TrainNo        string             `json:"train_no"`
+	From           string             `json:"from"`
+	To             string             `json:"to"`
+	DepartureDate  string             `json:"departure_date,omitempty"`
+	DepartureTime  string             `json:"departure_time"`
+	ArrivalDate    string             `json:"arrival_date,omitempty"`
+	ArrivalTime    string             `json:"arrival_time"`
+	DepartureDelay int64              `json:"departure_delay,omitempty"` // 预计晚点分钟数，时刻已按晚点修正，只在指定 date 时返回
+	ArrivalDelay   int64              `json:"arrival_delay,omitempty"`
+	RunningMinutes int64              `json:"running_minutes"`
+	HighSpeed      bool               `json:"high_speed"`
+	Price          float64            `json:"price"`
+	SeatClass      string             `json:"seat_class,omitempty"` // price 对应的席别
+	Payable        float64            `json:"payable"`              // 按旅客类型与优惠规则计算的应付金额
+	FareRules      []string           `json:"fare_rules,omitempty"`
+	Fares          map[string]float64 `json:"fares"`
+	Seats          map[string]int     `json:"seats,omitempty"`    // 各席别余票，只在指定 date 时返回
+	SoldOut        bool               `json:"sold_out,omitempty"` // seat_class 对应的席别已售罄
+}
+
+// JourneyDTO 一个完整行程
+type JourneyDTO struct {
+	ID              string   `json:"id"`
+	DurationMinutes int64    `json:"duration_minutes"`
+	TotalPrice      float64  `json:"total_price"`
+	TotalPayable    float64  `json:"total_payable"`
+	Transfers       int      `json:"transfers"`
+	DepartureTime   string   `json:"departure_time"`
+	Legs            []LegDTO `json:"legs"`
+}
+
+// OrderDTO /orders 返回的订单，证件号只保留首尾各 4 位
+type OrderDTO struct {
+	ID           string              `json:"id"`
+	Status       string              `json:"status"`
+	TotalAmount  float64             `json:"total_amount"`
+	RefundFee    float64             `json:"refund_fee,omitempty"`
+	RefundAmount float64             `json:"refund_amount,omitempty"`
+	HoldUntil    time.Time           `json:"hold_until"`
+	ConfirmedAt  *time.Time          `json:"confirmed_at,omitempty"`
+	ClosedAt     *time.Time          `json:"closed_at,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+	Legs         []dao.OrderLeg      `json:"legs"`
+	Passengers   []OrderPassengerDTO `json:"passengers"`
+}
+
+type OrderPassengerDTO struct {
+	Name     string  `json:"name"`
+	IDNumber string  `json:"id_number"`
+	Type     string  `json:"type"`
+	Amount   float64 `json:"amount"`
+}
+
+// WaitlistDTO /waitlist 返回的候补，Position 为排队位置（从 1 开始），不在排队中时省略
+type WaitlistDTO struct {
+	ID          string              `json:"id"`
+	Status      string              `json:"status"`
+	Position    int64               `json:"position,omitempty"`
+	TrainNo     string              `json:"train_no"`
+	Date        string              `json:"date"`
+	From        string              `json:"from"`
+	To          string              `json:"to"`
+	SeatClass   string              `json:"seat_class"`
+	Priority    int                 `json:"priority"`
+	Deadline    time.Time           `json:"deadline"`
+	OrderID     string              `json:"order_id,omitempty"`
+	FulfilledAt *time.Time          `json:"fulfilled_at,omitempty"`
+	ClosedAt    *time.Time          `json:"closed_at,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	Passengers  []OrderPassengerDTO `json:"passengers"`
+}
+
+// BoardDTO 车站大屏上的一趟车，station 在出发大屏上为终到站、在到达大屏上为始发站
+type BoardDTO struct {
+	TrainNumber  string `json:"train_number"`
+	TrainNo      string `json:"train_no"`
+	Station      string `json:"station"`
+	Time         string `json:"time"`
+	ExpectedTime string `json:"expected_time"`
+	DelayMinutes int64  `json:"delay_minutes"`
+}
+
+const dateLayout = "2006-01-02"
+
+func toSuggestionDTOs(suggestions []service.Suggestion) []StationDTO {
+	results := make([]StationDTO, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		result := StationDTO{Name: suggestion.Name, Type: "city", Code: suggestion.CityCode, Match: suggestion.Match.String()}
+		if !suggestion.IsCity {
+			result.Type = "station"
+			result.Code = suggestion.Station.StationCode
+			result.Pinyin = suggestion.Station.StationPinyin
+			result.City = suggestion.Station.CityName
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func toNearbyStationDTO(station service.NearbyStation) NearbyStationDTO {
+	return NearbyStationDTO{
+		Name:       station.Station.StationName,
+		Code:       station.Station.StationCode,
+		City:       station.Station.CityName,
+		Province:   station.Station.Province,
+		Latitude:   station.Station.Latitude,
+		Longitude:  station.Station.Longitude,
+		DistanceKm: math.Round(station.DistanceKm*100) / 100,
+	}
+}
+
+// toJourneyDTO date 为空时不计算各段的日期
+func toJourneyDTO(result ResponseSearch, date string) JourneyDTO {
+	journey := JourneyDTO{
+		ID:              result.Index,
+		DurationMinutes: result.TotalTime,
+		TotalPrice:      result.TotalPrice,
+		TotalPayable:    result.TotalPayable,
+		Transfers:       len(result.Railway) - 1,
+		DepartureTime:   result.DepartureTime,
+		Legs:            make([]LegDTO, 0, len(result.Railway)),
+	}
+	startDate, err := time.Parse(dateLayout, date)
+	hasDate := err == nil
+	dayOffset := 0
+	for index, railway := range result.Railway {
+		if index > 0 && service.GetTransTime(result.Railway[index-1].ArrivalTime, railway.DepartureTime, service.DefaultStopTime) >= 1440 {
+			dayOffset++
+		}
+		leg := toLegDTO(railway)
+		if hasDate {
+			leg.DepartureDate = startDate.AddDate(0, 0, dayOffset).Format(dateLayout)
+			leg.ArrivalDate = startDate.AddDate(0, 0, dayOffset+int(railway.ArrivalDay)).Format(dateLayout)
+		}
+		dayOffset = dayOffset + int(railway.ArrivalDay)
+		journey.Legs = append(journey.Legs, leg)
+	}
+	return journey
+}
+
+func toLegDTO(railway dao.RailWay) LegDTO {
+	runningMinutes, _ := service.GetTime(railway.RunningTime)
+	return LegDTO{
+		TrainNumber:    railway.TrainNumber,
+		TrainNo:        railway.TrainNo,
+		From:           railway.DepartureStation,
+		To:             railway.ArrivalStation,
+		DepartureTime:  railway.DepartureTime,
+		ArrivalTime:    railway.ArrivalTime,
+		DepartureDelay: railway.DepartureDelay,
+		ArrivalDelay:   railway.ArrivalDelay,
+		RunningMinutes: runningMinutes,
+		HighSpeed:      railway.IsHighSpeed == 1,
+		Price:          railway.Price,
+		SeatClass:      railway.SeatClass,
+		Payable:        railway.Payable,
+		FareRules:      railway.FareRules,
+		Fares:          service.Fares(railway),
+	}
+}
+
+func toOrderDTO(order dao.Order) OrderDTO {
+	result := OrderDTO{
+		ID:           order.ID,
+		Status:       order.Status,
+		TotalAmount:  order.TotalAmount,
+		RefundFee:    order.RefundFee,
+		RefundAmount: order.RefundAmount,
+		HoldUntil:    order.HoldUntil,
+		ConfirmedAt:  order.ConfirmedAt,
+		ClosedAt:     order.ClosedAt,
+		CreatedAt:    order.CreatedAt,
+		Legs:         order.Legs,
+		Passengers:   make([]OrderPassengerDTO, 0, len(order.Passengers)),
+	}
+	for _, passenger := range order.Passengers {
+		result.Passengers = append(result.Passengers, OrderPassengerDTO{
+			Name:     passenger.Name,
+			IDNumber: maskIDNumber(passenger.IDNumber),
+			Type:     passenger.Type,
+			Amount:   passenger.Amount,
+		})
+	}
+	return result
+}
+
+func maskIDNumber(idNumber string) string {
+	runes := []rune(idNumber)
+	if len(runes) <= 8 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:4]) + strings.Repeat("*", len(runes)-8) + string(runes[len(runes)-4:])
+}
+
+func toWaitlistDTO(entry dao.WaitlistEntry, position int64) WaitlistDTO {
+	result := WaitlistDTO{
+		ID:          entry.ID,
+		Status:      entry.Status,
+		Position:    position,
+		TrainNo:     entry.TrainNo,
+		Date:        entry.Date,
+		From:        entry.From,
+		To:          entry.To,
+		SeatClass:   entry.SeatClass,
+		Priority:    entry.Priority,
+		Deadline:    entry.Deadline,
+		OrderID:     entry.OrderID,
+		FulfilledAt: entry.FulfilledAt,
+		ClosedAt:    entry.ClosedAt,
+		CreatedAt:   entry.CreatedAt,
+		Passengers:  make([]OrderPassengerDTO, 0, len(entry.Passengers)),
+	}
+	for _, passenger := range entry.Passengers {
+		result.Passengers = append(result.Passengers, OrderPassengerDTO{
+			Name:     passenger.Name,
+			IDNumber: maskIDNumber(passenger.IDNumber),
+			Type:     passenger.Type,
+		})
+	}
+	return result
+}
+
+func toBoardDTO(entry service.BoardEntry) BoardDTO {
+	return BoardDTO{
+		TrainNumber:  entry.TrainNumber,
+		TrainNo:      entry.TrainNo,
+		Station:      entry.Station,
+		Time:         entry.Time,
+		ExpectedTime: entry.ExpectedTime,
+		DelayMinutes: entry.Delay,
+	}
+}
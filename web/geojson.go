@@ -0,0 +1,155 @@
+package web
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"railway/geo"
+	"railway/service"
+)
+
+const geoJSONContentType = "application/geo+json"
+
+// journeysGeoJSONHandler 参数与 /api/v1/journeys 相同，每段行程输出为一条经过各经停站的 LineString
+func (h *HandlerImpl) journeysGeoJSONHandler(c *gin.Context) {
+	query, err := parseJourneyQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	results, partial, err := h.cachedSearchJourneys(c.Request.Context(), query)
+	if err != nil {
+		writeSearchError(c, err)
+		return
+	}
+	if partial {
+		c.Header("X-Partial-Results", "true")
+	}
+	collection := geo.NewFeatureCollection()
+	trains := make(map[string]*service.TrainDetail)
+	for _, journey := range toJourneyDTOs(results, query.Date, query.Time) {
+		for index, leg := range journey.Legs {
+			properties := map[string]any{
+				"journey_id":     journey.ID,
+				"leg":            index,
+				"train_number":   leg.TrainNumber,
+				"train_no":       leg.TrainNo,
+				"from":           leg.From,
+				"to":             leg.To,
+				"departure_time": leg.DepartureTime,
+				"arrival_time":   leg.ArrivalTime,
+				"price":          leg.Price,
+				"seat_class":     leg.SeatClass,
+				"payable":        leg.Payable,
+				"fares":          leg.Fares,
+			}
+			if leg.DepartureDate != "" {
+				properties["departure_date"] = leg.DepartureDate
+				properties["arrival_date"] = leg.ArrivalDate
+			}
+			collection.Add(geo.NewLineString(h.legPoints(c.Request.Context(), trains, leg.TrainNo, leg.From, leg.To)), properties)
+		}
+	}
+	writeGeoJSON(c, collection)
+}
+
+// trainGeoJSONHandler 车次全程：经停站为 Point，全程为一条 LineString
+func (h *HandlerImpl) trainGeoJSONHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	detail, err := h.RailWayServiceImpl.GetTrain(ctx, c.Param("train_no"))
+	if err != nil {
+		if err.Error() == "trainNotFind" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "train not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching results"})
+		return
+	}
+	collection := geo.NewFeatureCollection()
+	points := make([]geo.Point, 0, len(detail.Stops))
+	for index, stop := range detail.Stops {
+		point, ok := h.RailWayServiceImpl.StationLocation(ctx, stop.Station)
+		var geometry *geo.Geometry
+		if ok {
+			points = append(points, point)
+			geometry = geo.NewPoint(point)
+		}
+		collection.Add(geometry, map[string]any{
+			"stop":              index,
+			"station":           stop.Station,
+			"arrival_time":      stop.ArrivalTime,
+			"departure_time":    stop.DepartureTime,
+			"day":               stop.Day,
+			"price_from_origin": stop.PriceFromOrigin,
+		})
+	}
+	collection.Add(geo.NewLineString(points), map[string]any{
+		"train_number": detail.TrainNumber,
+		"train_no":     detail.TrainNo,
+		"high_speed":   detail.IsHighSpeed == 1,
+	})
+	writeGeoJSON(c, collection)
+}
+
+// networkGeoJSONHandler 全路网：有坐标的车站为 Point，相邻两站之间的线路为 LineString，trains 为经过的车次数
+func (h *HandlerImpl) networkGeoJSONHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	links, err := h.RailWayServiceImpl.NetworkLinks(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching results"})
+		return
+	}
+	collection := geo.NewFeatureCollection()
+	stationTrains := make(map[string]int)
+	for _, link := range links {
+		stationTrains[link.From] = stationTrains[link.From] + link.Trains
+		stationTrains[link.To] = stationTrains[link.To] + link.Trains
+	}
+	for station, trains := range stationTrains {
+		if point, ok := h.RailWayServiceImpl.StationLocation(ctx, station); ok {
+			collection.Add(geo.NewPoint(point), map[string]any{"kind": "station", "station": station, "trains": trains})
+		}
+	}
+	for _, link := range links {
+		from, fromOK := h.RailWayServiceImpl.StationLocation(ctx, link.From)
+		to, toOK := h.RailWayServiceImpl.StationLocation(ctx, link.To)
+		if !fromOK || !toOK {
+			continue
+		}
+		collection.Add(geo.NewLineString([]geo.Point{from, to}), map[string]any{
+			"kind":   "link",
+			"from":   link.From,
+			"to":     link.To,
+			"trains": link.Trains,
+		})
+	}
+	writeGeoJSON(c, collection)
+}
+
+// legPoints 一段行程经过的各站坐标；取不到车次经停信息时只用起终点，没有坐标的车站跳过
+func (h *HandlerImpl) legPoints(ctx context.Context, trains map[string]*service.TrainDetail, trainNo, from, to string) []geo.Point {
+	detail, ok := trains[trainNo]
+	if !ok {
+		detail, _ = h.RailWayServiceImpl.GetTrain(ctx, trainNo)
+		trains[trainNo] = detail
+	}
+	stops := []service.TrainStop{{Station: from}, {Station: to}}
+	if detail != nil {
+		if between := detail.Between(from, to); len(between) >= 2 {
+			stops = between
+		}
+	}
+	points := make([]geo.Point, 0, len(stops))
+	for _, stop := range stops {
+		if point, ok := h.RailWayServiceImpl.StationLocation(ctx, stop.Station); ok {
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// writeGeoJSON 预先设置的 Content-Type 不会被 c.JSON 覆盖
+func writeGeoJSON(c *gin.Context, collection *geo.FeatureCollection) {
+	c.Header("Content-Type", geoJSONContentType)
+	c.JSON(http.StatusOK, collection)
+}
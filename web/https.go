@@ -1,18 +1,32 @@
 package web
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"log/slog"
 	"net/http"
+	"railway/cache"
+	"railway/config"
 	"railway/dao"
+	"railway/fare"
+	"railway/ratelimit"
 	"railway/service"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type HandlerImpl struct {
 	RailWayServiceImpl service.RailWayServiceImpl
+	Cache              cache.Cache
+	APIKeyService      service.APIKeyServiceImpl
+	OrderService       service.OrderServiceImpl
+	WaitlistService    service.WaitlistServiceImpl
+	Limiter            *ratelimit.Limiter
+	Logger             *slog.Logger
 }
 
 var (
@@ -20,9 +34,15 @@ var (
 	H HandlerImpl
 )
 
-func NewHandler(RailWayServiceImpl service.RailWayServiceImpl) HandlerImpl {
+func NewHandler(RailWayServiceImpl service.RailWayServiceImpl, APIKeyService service.APIKeyServiceImpl, OrderService service.OrderServiceImpl, WaitlistService service.WaitlistServiceImpl, logger *slog.Logger) HandlerImpl {
 	return HandlerImpl{
 		RailWayServiceImpl: RailWayServiceImpl,
+		Cache:              NewResultCache(config.DefaultCacheBytes),
+		APIKeyService:      APIKeyService,
+		OrderService:       OrderService,
+		WaitlistService:    WaitlistService,
+		Limiter:            ratelimit.New(),
+		Logger:             logger,
 	}
 }
 
@@ -30,46 +50,113 @@ type RequestStation struct {
 	Keyword string `json:"keyword"` // JSON 标签，表示 JSON 中的字段名为 "keyword"
 }
 type ResponseStation struct {
-	Results []string `json:"results"` // 返回结果数组，类型为字符串数组
+	Cities  []CityDTO `json:"cities"`  // 匹配的城市，查询时以 code 作为 from_city / to_city
+	Results []string  `json:"results"` // 匹配的车站名
 }
 
 type RequestSearch struct {
-	From        string   `json:"from"`
-	To          string   `json:"to"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	FromCity string `json:"from_city"`
+	ToCity   string `json:"to_city"`
+	// SortBy 取值为 legacySortBy 的下标
 	SortBy      int64    `json:"sort_by"`
 	MaxTransfer string   `json:"max_transfer"`
 	MidStations []string `json:"midStations"`
 	TrainType   string   `json:"train_type"`
+	SeatClasses []string `json:"seat_classes"`
+	Passenger   string   `json:"passenger"`
+	// LegacyCitySuffix 为 true 时 from / to / midStations 中以“（市）”结尾的名称按城市处理，已废弃
+	LegacyCitySuffix bool `json:"legacy_city_suffix"`
 }
 
 type ResponseSearch struct {
 	Index         string        `json:"index"`
 	TotalTime     int64         `json:"total_time"`
-	TotalPrice    float64       `json:"total_price"`
+	TotalPrice    float64       `json:"total_price"`   // 各段所选席别的公布票价之和
+	TotalPayable  float64       `json:"total_payable"` // 按旅客类型与优惠规则计算的应付金额
 	DepartureTime string        `json:"start_time"`
 	Railway       []dao.RailWay `json:"railway"`
 }
 
-func StartNgork() {
-	r := gin.Default()
+// NewRouter 注册全部路由，查询接口需要 API key 并按接口类别限流
+func NewRouter(auth config.AuthConfig) *gin.Engine {
+	limit := H.authorize(auth)
+	r := gin.New()
+	r.Use(gin.Recovery(), requestIDMiddleware, H.accessLogMiddleware, metricsMiddleware)
+	H.registerObservability(r)
 
 	// 定义一个 GET 请求接口
 	r.GET("/test", func(c *gin.Context) {
 		// 返回一个字符串表示成功连接
 		c.String(http.StatusOK, "连接成功")
 	})
-	r.POST("/station", H.stationHandler)
-	r.POST("/search", H.searchHandler)
-	// 启动 HTTPS 服务
-	err := r.RunTLS(":443", "cert.pem", "server.key")
-	if err != nil {
-		return
+	r.GET("/openapi.json", openAPIHandler)
+	// 旧接口保留为 /api/v1 的别名，已标记为 deprecated
+	r.POST("/station", limit(lookupCost), validateBody("RequestStation"), H.stationHandler)
+	r.POST("/search", limit(searchBodyCost), validateBody("RequestSearch"), H.searchHandler)
+	v1 := r.Group("/api/v1")
+	{
+		v1.GET("/stations", limit(lookupCost), validateQuery("/api/v1/stations"), H.stationsV1Handler)
+		v1.GET("/stations/nearby", limit(lookupCost), validateQuery("/api/v1/stations/nearby"), H.nearbyStationsV1Handler)
+		v1.GET("/journeys", limit(journeyCost), validateQuery("/api/v1/journeys"), H.journeysV1Handler)
+		v1.GET("/journeys/geojson", limit(journeyCost), validateQuery("/api/v1/journeys"), H.journeysGeoJSONHandler)
+		v1.GET("/trains/:train_no/geojson", limit(lookupCost), H.trainGeoJSONHandler)
+		v1.GET("/cities", limit(lookupCost), validateQuery("/api/v1/cities"), H.citiesV1Handler)
+		v1.GET("/cities/:code", limit(lookupCost), H.cityV1Handler)
+		v1.GET("/boards/:station", limit(lookupCost), validateQuery("/api/v1/boards/{station}"), H.stationBoardV1Handler)
+		v1.POST("/replan", limit(replanCost), validateBody("RequestReplan"), H.replanHandler)
+		v1.POST("/trips", limit(tripCost), validateBody("RequestTrip"), H.tripsHandler)
+	}
+	r.GET("/search/stream", limit(journeyCost), validateQuery("/search/stream"), H.searchStreamHandler)
+	orders := r.Group("/orders", limit(orderCost))
+	{
+		orders.POST("", validateBody("RequestOrder"), H.createOrderHandler)
+		orders.GET("/:id", H.getOrderHandler)
+		orders.POST("/:id/confirm", H.confirmOrderHandler)
+		orders.POST("/:id/cancel", H.cancelOrderHandler)
+	}
+	waitlist := r.Group("/waitlist", limit(orderCost))
+	{
+		waitlist.POST("", validateBody("RequestWaitlist"), H.joinWaitlistHandler)
+		waitlist.GET("/:id", H.getWaitlistHandler)
+		waitlist.POST("/:id/cancel", H.cancelWaitlistHandler)
 	}
+	admin := r.Group("/admin", limit(adminCost), requireAdmin(auth))
+	{
+		admin.GET("/network/geojson", H.networkGeoJSONHandler)
+		admin.POST("/realtime", validateBody("RequestRealtime"), H.pushRealtimeHandler)
+		admin.POST("/reload", H.reloadHandler)
+	}
+	return r
 }
 
 type Handler interface {
 	stationHandler(c *gin.Context)
 	searchHandler(c *gin.Context)
+	stationsV1Handler(c *gin.Context)
+	nearbyStationsV1Handler(c *gin.Context)
+	journeysV1Handler(c *gin.Context)
+	citiesV1Handler(c *gin.Context)
+	cityV1Handler(c *gin.Context)
+	journeysGeoJSONHandler(c *gin.Context)
+	trainGeoJSONHandler(c *gin.Context)
+	networkGeoJSONHandler(c *gin.Context)
+	reloadHandler(c *gin.Context)
+	searchStreamHandler(c *gin.Context)
+	createOrderHandler(c *gin.Context)
+	getOrderHandler(c *gin.Context)
+	confirmOrderHandler(c *gin.Context)
+	cancelOrderHandler(c *gin.Context)
+	joinWaitlistHandler(c *gin.Context)
+	getWaitlistHandler(c *gin.Context)
+	cancelWaitlistHandler(c *gin.Context)
+	stationBoardV1Handler(c *gin.Context)
+	pushRealtimeHandler(c *gin.Context)
+	replanHandler(c *gin.Context)
+	tripsHandler(c *gin.Context)
+	healthzHandler(c *gin.Context)
+	readyzHandler(c *gin.Context)
 }
 
 func (h *HandlerImpl) stationHandler(c *gin.Context) {
@@ -78,96 +165,188 @@ func (h *HandlerImpl) stationHandler(c *gin.Context) {
 	if err := c.ShouldBindJSON(&req); err != nil {
 		// 如果解析失败，返回 400 错误
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
-		fmt.Println(err)
+		h.logger(c).Warn("invalid request payload", "err", err)
+		return
 	}
+	markDeprecated(c, "/api/v1/stations")
 
-	// 调用服务层（例如 RailWayDAO）来获取查询结果
-	resultCities, err := h.RailWayServiceImpl.StationDAO.GetCityByPrefixName(req.Keyword)
+	result, err := h.lookupStations(c.Request.Context(), req.Keyword)
 	if err != nil {
 		// 如果查询出错，返回 500 错误
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching results"})
+		return
 	}
-	resultStations, err := h.RailWayServiceImpl.StationDAO.GetStationByPrefixName(req.Keyword)
+
+	// 返回查询结果
+	c.JSON(http.StatusOK, result)
+}
+
+// lookupStations 根据前缀联想城市和车站，城市带城市代码单独返回
+func (h *HandlerImpl) lookupStations(ctx context.Context, keyword string) (ResponseStation, error) {
+	// 调用服务层来获取查询结果
+	cities, stations, err := h.RailWayServiceImpl.LookupStations(ctx, keyword)
 	if err != nil {
-		// 如果查询出错，返回 500 错误
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching results"})
+		return ResponseStation{}, err
 	}
-	results := make([]string, 0)
-	resultStation := make([]string, 0)
-	resultCity := make([]string, 0)
-	city := make(map[string]string)
-	for _, result := range resultStations {
-		resultStation = append(resultStation, result.StationName)
-	}
-	for _, result := range resultCities {
-		_, ok := city[result.CityName]
-		if !ok {
-			city[result.CityName] = result.CityName
-			resultCity = append(resultCity, result.CityName+"（市）")
-		}
+	result := ResponseStation{
+		Cities:  make([]CityDTO, 0, len(cities)),
+		Results: make([]string, 0, len(stations)),
 	}
-	results = append(results, resultCity...)
-	results = append(results, resultStation...)
-
-	// 返回查询结果
-	c.JSON(http.StatusOK, ResponseStation{Results: results})
+	for _, city := range cities {
+		result.Cities = append(result.Cities, CityDTO{Code: city.CityCode, Name: city.CityName})
+	}
+	for _, station := range stations {
+		result.Results = append(result.Results, station.StationName)
+	}
+	return result, nil
 }
 
 func (h *HandlerImpl) searchHandler(c *gin.Context) {
 	var req RequestSearch
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
-		fmt.Println(c)
+		h.logger(c).Warn("invalid request payload", "err", err)
+		return
 	}
+	markDeprecated(c, "/api/v1/journeys")
 	maxTransfer, err := strconv.ParseInt(req.MaxTransfer, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
-		fmt.Println(c)
+		h.logger(c).Warn("invalid max_transfer", "max_transfer", req.MaxTransfer)
+		return
 	}
-	req.SortBy = req.SortBy + 1
-	if req.SortBy > 6 {
+	if req.SortBy < 0 || req.SortBy >= int64(len(legacySortBy)) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
 	}
-	results := make(map[string][]dao.RailWay)
-	departStations, err := h.getStations(req.From)
+	query := journeyQuery{
+		From:        req.From,
+		To:          req.To,
+		TrainType:   req.TrainType,
+		SortBy:      sortNames[legacySortBy[req.SortBy]],
+		MaxTransfer: maxTransfer,
+	}
+	query.SeatClasses, err = service.ParseSeatClasses(strings.Join(req.SeatClasses, ","))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getStations fetching results"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
 	}
-	arrivalStations, err := h.getStations(req.To)
+	query.Passenger, err = fare.ParsePassengerType(req.Passenger)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getStations fetching results"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
 	}
 	if len(req.MidStations) > 0 {
-		midStations, err := h.getStations(req.MidStations[0])
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getStations fetching results"})
+		query.Mid = req.MidStations[0]
+	}
+	if err := h.legacyCityQuery(c.Request.Context(), &query, req); err != nil {
+		writeSearchError(c, err)
+		return
+	}
+	if (query.From == "" && query.FromCity == "") || (query.To == "" && query.ToCity == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	returnResult, partial, err := h.cachedSearchJourneys(c.Request.Context(), query)
+	if err != nil {
+		writeSearchError(c, err)
+		return
+	}
+	if partial {
+		c.Header("X-Partial-Results", "true")
+	}
+	c.JSON(http.StatusOK, returnResult)
+}
+
+// journeyQuery 新旧查询接口共用的查询条件，SortBy 为 service 中的排序常量
+// journeyQuery From/To/Mid 为车站名，对应的 *City 为城市代码，两者只填其一
+type journeyQuery struct {
+	From        string
+	To          string
+	Mid         string
+	FromCity    string
+	ToCity      string
+	MidCity     string
+	Date        string
+	Time        string
+	TrainType   string
+	SortBy      int
+	MaxTransfer int64
+	SeatClasses []string // 按优先顺序排列的席别，为空时按最低价计价
+	Passenger   fare.PassengerType
+	// OnlyAvailable 只返回每段都有余票的行程，余票不参与缓存
+	OnlyAvailable bool
+	// Constraints 不为空时只做图搜索，via 已并入其中，Mid 为空
+	Constraints service.SearchConstraints
+}
+
+const (
+	// SearchTimeout 单次查询的最长耗时，超时后返回已完成部分的结果
+	SearchTimeout = 10 * time.Second
+	// SearchWorkers 城市到城市查询时同时查询的车站对数量
+	SearchWorkers = 4
+)
+
+// searchJourneys 展开城市到车站后并发查询各车站对，合并结果并按 SortBy 排序。
+// 超过 SearchTimeout 时返回已完成部分的结果，partial 为 true
+func (h *HandlerImpl) searchJourneys(ctx context.Context, query journeyQuery) (returnResult []ResponseSearch, partial bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, SearchTimeout)
+	defer cancel()
+	pairs, err := h.stationPairs(ctx, query)
+	if err != nil {
+		if service.IsStationNotFound(err) || isCityNotFound(err) {
+			return nil, false, err
 		}
-		if len(midStations) > 0 {
-			for _, midStation := range midStations {
-				for _, departStation := range departStations {
-					for _, arrivalStation := range arrivalStations {
-						templateResults, err := h.searchWithStations(departStation, midStation, arrivalStation, req.TrainType, int(req.SortBy), maxTransfer)
-						if err != nil {
-							c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searchWithStations fetching results"})
-						}
-						results = combineMap(results, templateResults)
-					}
-				}
+		return nil, false, errors.New("Error getStations fetching results")
+	}
+
+	type pairResult struct {
+		results map[string][]dao.RailWay
+		err     error
+	}
+	jobs := make(chan stationPair)
+	out := make(chan pairResult, len(pairs))
+	var wg sync.WaitGroup
+	for i := 0; i < SearchWorkers && i < len(pairs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pair := range jobs {
+				templateResults, err := h.searchWithStations(ctx, pair.departure, pair.mid, pair.arrival, query.TrainType, query.SortBy, query.MaxTransfer, query.SeatClasses, query.Date, query.Constraints)
+				out <- pairResult{results: templateResults, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, pair := range pairs {
+			select {
+			case jobs <- pair:
+			case <-ctx.Done():
+				return
 			}
 		}
-	} else {
-		for _, departStation := range departStations {
-			for _, arrivalStation := range arrivalStations {
-				templateResults, err := h.searchWithStations(departStation, "", arrivalStation, req.TrainType, int(req.SortBy), maxTransfer)
-				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searchWithStations fetching results"})
-				}
-				results = combineMap(results, templateResults)
+	}()
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	results := make(map[string][]dao.RailWay)
+	for result := range out {
+		if result.err != nil && ctx.Err() == nil {
+			if errors.Is(result.err, service.ErrInvalidConstraints) || service.IsStationNotFound(result.err) || isCityNotFound(result.err) {
+				return nil, false, result.err
 			}
+			return nil, false, errors.New("Error searchWithStations fetching results")
 		}
+		results = combineMap(results, result.results)
 	}
-	returnResult := turnMapToResponseSlice(results)
-	switch req.SortBy {
+	return sortResponse(turnMapToResponseSlice(results, query.Passenger), query.SortBy), ctx.Err() != nil, nil
+}
+
+func sortResponse(returnResult []ResponseSearch, sortBy int) []ResponseSearch {
+	switch sortBy {
 	case service.LowRunningTimeFirst:
 		returnResult = sortTemplateStructByLowRunningTime(returnResult)
 	case service.HighRunningTimeFirst:
@@ -183,57 +362,145 @@ func (h *HandlerImpl) searchHandler(c *gin.Context) {
 	default:
 		returnResult = sortTemplateStructByLowRunningTime(returnResult)
 	}
-	c.JSON(http.StatusOK, returnResult)
+	return returnResult
 }
 
-func (h *HandlerImpl) searchWithStations(departureStation, midStation, arrivalStation, speedOption string, sortOption int, maxTrans int64) (map[string][]dao.RailWay, error) {
+// markDeprecated 旧的 POST 接口仍然可用，但在响应头中提示调用方迁移到 /api/v1
+func markDeprecated(c *gin.Context, successor string) {
+	c.Header("Deprecation", "true")
+	c.Header("Link", "<"+successor+">; rel=\"successor-version\"")
+}
+
+// searchWithStations 依次执行一对车站的各个子查询，出错时一并返回已经得到的结果
+func (h *HandlerImpl) searchWithStations(ctx context.Context, departureStation, midStation, arrivalStation, speedOption string, sortOption int, maxTrans int64, seatClasses []string, date string, constraints service.SearchConstraints) (map[string][]dao.RailWay, error) {
 	results := make(map[string][]dao.RailWay)
-	if len(midStation) > 0 {
-		templateResult, err := h.RailWayServiceImpl.SearchWithOneSpecificTrans(departureStation, midStation, arrivalStation, speedOption, sortOption, service.DefaultStopTime)
-		if err != nil {
-			return nil, err
-		}
-		results = templateResult
-	} else {
-		templateResult, err := h.RailWayServiceImpl.SearchDirectly(departureStation, arrivalStation, speedOption, sortOption)
+	for _, stage := range h.searchStages(departureStation, midStation, arrivalStation, speedOption, sortOption, maxTrans, seatClasses, date, constraints) {
+		templateResult, err := stage.run(ctx)
+		results = combineMap(results, templateResult)
 		if err != nil {
-			return nil, err
+			return results, err
 		}
-		results = combineMap(results, templateResult)
+	}
+	return results, nil
+}
 
-		if maxTrans >= 1 {
-			templateResult, err = h.RailWayServiceImpl.SearchWithOneTrans(departureStation, arrivalStation, speedOption, sortOption, service.DefaultStopTime, 0)
-			if err != nil {
-				return nil, err
-			}
-			results = combineMap(results, templateResult)
-		}
-		if maxTrans >= 2 && (sortOption == service.LowRunningTimeFirst || sortOption == service.LowPriceFirst) {
-			templateResult, err = h.RailWayServiceImpl.SearchWithTwoTrans(departureStation, arrivalStation, speedOption, maxTrans+1, service.DefaultResultNumber, sortOption)
-			if err != nil {
-				return nil, err
-			}
-			results = combineMap(results, templateResult)
+const (
+	StageDirect        = "direct"
+	StageVia           = "via"
+	StageOneTransfer   = "one_transfer"
+	StageMultiTransfer = "multi_transfer"
+	StageConstrained   = "constrained"
+)
+
+// searchStage 一次子查询，一对车站的查询由若干个 stage 依次组成
+type searchStage struct {
+	name string
+	run  func(ctx context.Context) (map[string][]dao.RailWay, error)
+}
+
+// searchStages date 不为空时各子查询按当天的实时动态修正时刻
+func (h *HandlerImpl) searchStages(departureStation, midStation, arrivalStation, speedOption string, sortOption int, maxTrans int64, seatClasses []string, date string, constraints service.SearchConstraints) []searchStage {
+	if !constraints.Empty() {
+		// 约束在最短路中处理，只有图搜索一个子查询；每个途经站至少允许换乘一次
+		graphSort := service.LowRunningTimeFirst
+		if sortOption == service.LowPriceFirst {
+			graphSort = service.LowPriceFirst
 		}
+		maxTrans = max(maxTrans, int64(len(constraints.Via)))
+		return []searchStage{{name: StageConstrained, run: observeStage(StageConstrained, func(ctx context.Context) (map[string][]dao.RailWay, error) {
+			return h.RailWayServiceImpl.SearchWithConstraints(ctx, departureStation, arrivalStation, speedOption, maxTrans+1, service.DefaultResultNumber, graphSort, seatClasses, date, constraints)
+		})}}
 	}
-	return results, nil
+	if len(midStation) > 0 {
+		return []searchStage{{name: StageVia, run: observeStage(StageVia, func(ctx context.Context) (map[string][]dao.RailWay, error) {
+			return h.RailWayServiceImpl.SearchWithOneSpecificTrans(ctx, departureStation, midStation, arrivalStation, speedOption, sortOption, service.DefaultStopTime, seatClasses, date)
+		})}}
+	}
+	stages := []searchStage{{name: StageDirect, run: func(ctx context.Context) (map[string][]dao.RailWay, error) {
+		return h.RailWayServiceImpl.SearchDirectly(ctx, departureStation, arrivalStation, speedOption, sortOption, seatClasses, date)
+	}}}
+	if maxTrans >= 1 {
+		stages = append(stages, searchStage{name: StageOneTransfer, run: func(ctx context.Context) (map[string][]dao.RailWay, error) {
+			return h.RailWayServiceImpl.SearchWithOneTrans(ctx, departureStation, arrivalStation, speedOption, sortOption, service.DefaultStopTime, 0, seatClasses, date)
+		}})
+	}
+	if maxTrans >= 2 && (sortOption == service.LowRunningTimeFirst || sortOption == service.LowPriceFirst) {
+		stages = append(stages, searchStage{name: StageMultiTransfer, run: func(ctx context.Context) (map[string][]dao.RailWay, error) {
+			return h.RailWayServiceImpl.SearchWithTwoTrans(ctx, departureStation, arrivalStation, speedOption, maxTrans+1, service.DefaultResultNumber, sortOption, seatClasses, date)
+		}})
+	}
+	for i := range stages {
+		stages[i].run = observeStage(stages[i].name, stages[i].run)
+	}
+	return stages
 }
 
-func (h *HandlerImpl) getStations(inputStation string) ([]string, error) {
-	if strings.Contains(inputStation, "（市）") {
-		inputCity := strings.TrimSuffix(inputStation, "（市）")
-		startStations, err := h.RailWayServiceImpl.StationDAO.GetStationByPrefixName(inputCity)
-		if err != nil {
-			return nil, err
+// getStations cityCode 不为空时展开为城市下属的所有车站，否则解析车站名
+func (h *HandlerImpl) getStations(ctx context.Context, inputStation, cityCode string) ([]string, error) {
+	if cityCode != "" {
+		return h.RailWayServiceImpl.CityStationNames(ctx, cityCode)
+	}
+	station, err := h.RailWayServiceImpl.ResolveStation(ctx, inputStation)
+	if err != nil {
+		return nil, err
+	}
+	return []string{station}, nil
+}
+
+// legacyCityQuery from_city / to_city 优先；请求带 legacy_city_suffix 时才把以“（市）”结尾的名称转换为城市代码
+func (h *HandlerImpl) legacyCityQuery(ctx context.Context, query *journeyQuery, req RequestSearch) error {
+	fields := []struct {
+		name     *string
+		city     *string
+		explicit string
+	}{
+		{&query.From, &query.FromCity, req.FromCity},
+		{&query.To, &query.ToCity, req.ToCity},
+		{&query.Mid, &query.MidCity, ""},
+	}
+	for _, field := range fields {
+		if field.explicit != "" {
+			*field.city = field.explicit
+			*field.name = ""
+			continue
+		}
+		if !req.LegacyCitySuffix || !strings.HasSuffix(*field.name, "（市）") {
+			continue
 		}
-		results := make([]string, 0)
-		for _, station := range startStations {
-			results = append(results, station.StationName)
+		city, err := h.RailWayServiceImpl.GetCityByName(ctx, strings.TrimSuffix(*field.name, "（市）"))
+		if err != nil {
+			return err
 		}
-		return results, nil
-	} else {
-		return []string{inputStation}, nil
+		*field.city = city.CityCode
+		*field.name = ""
+	}
+	return nil
+}
+
+// writeSearchError 车站无法确定时返回 422 与候选车站，其余错误返回 500
+func writeSearchError(c *gin.Context, err error) {
+	var unresolved *service.UnresolvedStationError
+	if errors.As(err, &unresolved) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":       "station not found: " + unresolved.Input,
+			"input":       unresolved.Input,
+			"suggestions": toSuggestionDTOs(unresolved.Suggestions),
+		})
+		return
+	}
+	if isCityNotFound(err) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "city not found"})
+		return
 	}
+	if errors.Is(err, service.ErrInvalidConstraints) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func isCityNotFound(err error) bool {
+	return err != nil && err.Error() == "cityNotFind"
 }
 
 func combineMap(mapA, mapB map[string][]dao.RailWay) map[string][]dao.RailWay {
@@ -243,27 +510,33 @@ func combineMap(mapA, mapB map[string][]dao.RailWay) map[string][]dao.RailWay {
 	return mapA
 }
 
-func turnMapToResponseSlice(results map[string][]dao.RailWay) []ResponseSearch {
+// turnMapToResponseSlice 合计各段票价，并按 passenger 计算应付金额
+func turnMapToResponseSlice(results map[string][]dao.RailWay, passenger fare.PassengerType) []ResponseSearch {
 	returnResults := make([]ResponseSearch, 0)
 	for key, value := range results {
-		Price := float64(0)
-		keyStrings := strings.Split(key, "/")
-		TotalTime, _ := strconv.ParseInt(keyStrings[len(keyStrings)-1], 10, 64)
-		for _, element := range value {
-			Price = Price + element.Price
-		}
-		newReturnResult := ResponseSearch{
-			Index:         key,
-			TotalTime:     TotalTime,
-			TotalPrice:    Price,
-			DepartureTime: value[0].DepartureTime,
-			Railway:       value,
-		}
-		returnResults = append(returnResults, newReturnResult)
+		returnResults = append(returnResults, toResponseSearch(key, value, passenger))
 	}
 	return returnResults
 }
 
+// toResponseSearch key 的最后一部分为总耗时（分钟）
+func toResponseSearch(key string, value []dao.RailWay, passenger fare.PassengerType) ResponseSearch {
+	Price := float64(0)
+	keyStrings := strings.Split(key, "/")
+	TotalTime, _ := strconv.ParseInt(keyStrings[len(keyStrings)-1], 10, 64)
+	for _, element := range value {
+		Price = Price + element.Price
+	}
+	return ResponseSearch{
+		Index:         key,
+		TotalTime:     TotalTime,
+		TotalPrice:    Price,
+		TotalPayable:  service.ApplyFares(value, passenger),
+		DepartureTime: value[0].DepartureTime,
+		Railway:       value,
+	}
+}
+
 func sortTemplateStructByLowRunningTime(result []ResponseSearch) []ResponseSearch {
 	sort.Slice(result, func(i, j int) bool {
 		if result[i].TotalTime == result[j].TotalTime {
@@ -313,8 +586,8 @@ func sortTemplateStructByLateFirst(result []ResponseSearch) []ResponseSearch {
 }
 func sortTemplateStructByLowPrice(result []ResponseSearch) []ResponseSearch {
 	sort.Slice(result, func(i, j int) bool {
-		if result[i].TotalPrice != result[j].TotalPrice {
-			return result[i].TotalPrice < result[j].TotalPrice
+		if result[i].TotalPayable != result[j].TotalPayable {
+			return result[i].TotalPayable < result[j].TotalPayable
 		}
 		iDepartTime, _ := service.GetTime(result[i].Railway[0].DepartureTime)
 		jDepartTime, _ := service.GetTime(result[j].Railway[0].DepartureTime)
@@ -327,8 +600,8 @@ func sortTemplateStructByLowPrice(result []ResponseSearch) []ResponseSearch {
 }
 func sortTemplateStructByHighPrice(result []ResponseSearch) []ResponseSearch {
 	sort.Slice(result, func(i, j int) bool {
-		if result[i].TotalPrice != result[j].TotalPrice {
-			return result[i].TotalPrice > result[j].TotalPrice
+		if result[i].TotalPayable != result[j].TotalPayable {
+			return result[i].TotalPayable > result[j].TotalPayable
 		}
 		iDepartTime, _ := service.GetTime(result[i].Railway[0].DepartureTime)
 		jDepartTime, _ := service.GetTime(result[j].Railway[0].DepartureTime)
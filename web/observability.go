@@ -0,0 +1,88 @@
+package web
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"railway/cache"
+	"railway/dao"
+	"railway/metrics"
+	"railway/service"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ReadyTimeout /readyz 检查数据库连接的超时时间
+const ReadyTimeout = 2 * time.Second
+
+var registerCacheOnce sync.Once
+
+// metricsMiddleware 按路由记录请求耗时，未匹配到路由的请求记为 unmatched
+func metricsMiddleware(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	endpoint := c.FullPath()
+	if endpoint == "" {
+		endpoint = "unmatched"
+	}
+	metrics.HTTPRequestDuration.WithLabelValues(endpoint, c.Request.Method, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+}
+
+// registerObservability 注册 /metrics、/healthz 与 /readyz
+func (h *HandlerImpl) registerObservability(r *gin.Engine) {
+	registerCacheOnce.Do(func() {
+		metrics.RegisterCacheStats(func() cache.Stats {
+			if h.Cache == nil {
+				return cache.Stats{}
+			}
+			return h.Cache.Stats()
+		})
+	})
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.GET("/healthz", h.healthzHandler)
+	r.GET("/readyz", h.readyzHandler)
+}
+
+func (h *HandlerImpl) healthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h *HandlerImpl) readyzHandler(c *gin.Context) {
+	if draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), ReadyTimeout)
+	defer cancel()
+	if err := h.RailWayServiceImpl.Ready(ctx); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// reloadHandler 在其他进程导入时刻表或车站坐标后重建换乘图；请求断开后仍会完成重建，重建期间的搜索继续使用原来的图
+func (h *HandlerImpl) reloadHandler(c *gin.Context) {
+	ctx := context.WithoutCancel(c.Request.Context())
+	if err := h.RailWayServiceImpl.ReloadTimetable(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reloading timetable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"timetable_version": service.TimetableVersion()})
+}
+
+// observeStage 记录子查询耗时，超时或出错时 status 为 error
+func observeStage(name string, run func(ctx context.Context) (map[string][]dao.RailWay, error)) func(ctx context.Context) (map[string][]dao.RailWay, error) {
+	return func(ctx context.Context) (map[string][]dao.RailWay, error) {
+		start := time.Now()
+		results, err := run(ctx)
+		status := "ok"
+		if err != nil {
+			status = "error"
+		}
+		metrics.SearchDuration.WithLabelValues(name, status).Observe(time.Since(start).Seconds())
+		return results, err
+	}
+}
@@ -0,0 +1,1059 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"railway/dao"
+	"railway/fare"
+	"railway/service"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema OpenAPI 3 schema 的一个子集，既用于生成 /openapi.json，也用于校验请求
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	MinLength   int                `json:"minLength,omitempty"`
+	MaxItems    int                `json:"maxItems,omitempty"`
+	Deprecated  bool               `json:"deprecated,omitempty"`
+	EnumNames   []string           `json:"x-enum-varnames,omitempty"`
+
+	// pattern 由 compilePatterns 在启动时编译的 Pattern
+	pattern *regexp.Regexp
+}
+
+// legacySortBy 旧接口 sort_by 的取值，下标即取值，名称与 /api/v1 的 sort 参数一致，见 sortNames
+var legacySortBy = []string{"price", "price_desc", "duration", "duration_desc", "departure", "departure_desc"}
+
+var trainTypeValues = []any{service.Default, service.OnlyHighSpeed, service.OnlyLowSpeed}
+
+var maxTransferValues = []any{"0", "1", "2"}
+
+var components = map[string]*Schema{
+	"RequestStation": {
+		Type:     "object",
+		Required: []string{"keyword"},
+		Properties: map[string]*Schema{
+			"keyword": {Type: "string", MinLength: 1, Description: "车站或城市名前缀"},
+		},
+	},
+	"ResponseStation": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"cities":  {Type: "array", Items: ref("City"), Description: "匹配的城市，code 可作为 from_city / to_city"},
+			"results": {Type: "array", Items: &Schema{Type: "string"}, Description: "匹配的车站名"},
+		},
+	},
+	"RequestSearch": {
+		Type:        "object",
+		Description: "from 与 from_city、to 与 to_city 各需提供一个",
+		Properties: map[string]*Schema{
+			"from":      {Type: "string", Description: "出发站"},
+			"to":        {Type: "string", Description: "到达站"},
+			"from_city": {Type: "string", Description: "出发城市代码，见 /api/v1/cities"},
+			"to_city":   {Type: "string", Description: "到达城市代码，见 /api/v1/cities"},
+			"sort_by": {Type: "integer", Enum: sortByValues(), EnumNames: legacySortBy,
+				Description: "0 低价优先，1 高价优先，2 耗时短优先，3 耗时长优先，4 出发早优先，5 出发晚优先"},
+			"max_transfer": {Type: "string", Enum: maxTransferValues, Description: "最多换乘次数"},
+			"midStations":  {Type: "array", Items: &Schema{Type: "string"}, Description: "指定中转站，只使用第一个，其余忽略；往返与多城市行程请使用 /api/v1/trips"},
+			"train_type":   {Type: "string", Enum: trainTypeValues, Description: "all 全部，highspeed 只看高铁动车，normal 只看普速"},
+			"seat_classes": {Type: "array", Items: &Schema{Type: "string", Enum: seatClassValues()},
+				Description: "按优先顺序排列的席别，每段按第一个有售的席别计价，如 [\"ze\", \"yz\"]；为空时按最低价"},
+			"passenger": {Type: "string", Enum: passengerValues(), Description: "旅客类型，决定适用的优惠规则，默认 adult"},
+			"legacy_city_suffix": {Type: "boolean", Deprecated: true,
+				Description: "为 true 时 from、to、midStations 中以“（市）”结尾的名称按城市处理；该写法已废弃，请使用 from_city / to_city"},
+		},
+	},
+	"RailWay": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":                {Type: "integer"},
+			"train_number":      {Type: "string"},
+			"train_no":          {Type: "string"},
+			"departure_station": {Type: "string"},
+			"departure_time":    {Type: "string"},
+			"arrival_station":   {Type: "string"},
+			"arrival_time":      {Type: "string"},
+			"running_time":      {Type: "string"},
+			"price":             {Type: "number"},
+			"yw_price":          {Type: "number"},
+			"yz_price":          {Type: "number"},
+			"rw_price":          {Type: "number"},
+			"ze_price":          {Type: "number"},
+			"zy_price":          {Type: "number"},
+			"swz_price":         {Type: "number"},
+			"tz_price":          {Type: "number"},
+			"gr_price":          {Type: "number"},
+			"arrival_day":       {Type: "integer"},
+			"is_high_speed":     {Type: "integer"},
+			"seat_class":        {Type: "string", Description: "price 对应的席别"},
+			"payable":           {Type: "number", Description: "按旅客类型与优惠规则计算的应付金额"},
+			"fare_rules":        {Type: "array", Items: &Schema{Type: "string"}, Description: "生效的优惠规则"},
+			"departure_delay":   {Type: "integer", Description: "预计发车晚点分钟数，时刻已按晚点修正"},
+			"arrival_delay":     {Type: "integer", Description: "预计到站晚点分钟数"},
+		},
+	},
+	"ResponseSearch": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"index":         {Type: "string"},
+			"total_time":    {Type: "integer", Description: "总耗时（分钟）"},
+			"total_price":   {Type: "number", Description: "各段公布票价之和"},
+			"total_payable": {Type: "number", Description: "应付金额，价格排序按此字段"},
+			"start_time":    {Type: "string"},
+			"railway":       {Type: "array", Items: &Schema{Ref: "#/components/schemas/RailWay"}},
+		},
+	},
+	"Station": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name":   {Type: "string"},
+			"type":   {Type: "string", Enum: []any{"station", "city"}},
+			"code":   {Type: "string", Description: "电报码"},
+			"pinyin": {Type: "string"},
+			"city":   {Type: "string", Description: "车站所属城市"},
+			"match":  {Type: "string", Description: "命中方式", Enum: []any{"name", "name_prefix", "pinyin", "code", "initials", "pinyin_prefix", "initials_prefix", "code_prefix"}},
+		},
+	},
+	"NearbyStation": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name":        {Type: "string"},
+			"code":        {Type: "string", Description: "电报码"},
+			"city":        {Type: "string"},
+			"province":    {Type: "string"},
+			"latitude":    {Type: "number"},
+			"longitude":   {Type: "number"},
+			"distance_km": {Type: "number", Description: "与查询点的大圆距离（千米）"},
+		},
+	},
+	"City": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":     {Type: "string", Description: "城市代码"},
+			"name":     {Type: "string"},
+			"stations": {Type: "array", Items: ref("Station")},
+		},
+	},
+	"Leg": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"train_number":    {Type: "string"},
+			"train_no":        {Type: "string"},
+			"from":            {Type: "string"},
+			"to":              {Type: "string"},
+			"departure_date":  {Type: "string", Format: "date"},
+			"departure_time":  {Type: "string"},
+			"arrival_date":    {Type: "string", Format: "date"},
+			"arrival_time":    {Type: "string"},
+			"departure_delay": {Type: "integer", Description: "预计发车晚点分钟数，时刻已按晚点修正，只在指定 date 时返回"},
+			"arrival_delay":   {Type: "integer", Description: "预计到站晚点分钟数"},
+			"running_minutes": {Type: "integer"},
+			"high_speed":      {Type: "boolean"},
+			"price":           {Type: "number"},
+			"seat_class":      {Type: "string", Enum: seatClassValues(), Description: "price 对应的席别"},
+			"payable":         {Type: "number", Description: "按旅客类型与优惠规则计算的应付金额"},
+			"fare_rules":      {Type: "array", Items: &Schema{Type: "string"}, Description: "生效的优惠规则"},
+			"fares":           {Type: "object", Description: "各席别票价，键为 yw/yz/rw/ze/zy/swz/tz/gr"},
+			"seats":           {Type: "object", Description: "各席别余票，键同 fares，只在指定 date 时返回"},
+			"sold_out":        {Type: "boolean", Description: "seat_class 对应的席别已售罄"},
+		},
+	},
+	"Journey": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":               {Type: "string"},
+			"duration_minutes": {Type: "integer"},
+			"total_price":      {Type: "number", Description: "各段公布票价之和"},
+			"total_payable":    {Type: "number", Description: "应付金额，价格排序按此字段"},
+			"transfers":        {Type: "integer"},
+			"departure_time":   {Type: "string"},
+			"legs":             {Type: "array", Items: ref("Leg")},
+		},
+	},
+	"StageEvent": {
+		Type:        "object",
+		Description: "/search/stream 中 journeys 事件的数据",
+		Properties: map[string]*Schema{
+			"stage":    {Type: "string", Enum: []any{StageDirect, StageVia, StageOneTransfer, StageMultiTransfer, StageConstrained}},
+			"from":     {Type: "string"},
+			"to":       {Type: "string"},
+			"journeys": {Type: "array", Items: ref("Journey")},
+		},
+	},
+	"StreamSummary": {
+		Type:        "object",
+		Description: "/search/stream 中 summary 事件的数据",
+		Properties: map[string]*Schema{
+			"journeys":   {Type: "integer"},
+			"stages":     {Type: "object"},
+			"errors":     {Type: "integer"},
+			"partial":    {Type: "boolean"},
+			"elapsed_ms": {Type: "integer"},
+		},
+	},
+	"RequestOrder": {
+		Type:     "object",
+		Required: []string{"legs", "passengers"},
+		Properties: map[string]*Schema{
+			"legs": {Type: "array", MaxItems: service.MaxOrderLegs, Items: &Schema{
+				Type:     "object",
+				Required: []string{"train_no", "date", "from", "to", "seat_class"},
+				Properties: map[string]*Schema{
+					"train_no":   {Type: "string", MinLength: 1, Description: "车次内部编号 TrainNo"},
+					"date":       {Type: "string", Format: "date", Pattern: `^\d{4}-\d{2}-\d{2}$`, Description: "在 from 上车的日期"},
+					"from":       {Type: "string", MinLength: 1},
+					"to":         {Type: "string", MinLength: 1},
+					"seat_class": {Type: "string", Enum: seatClassValues()},
+				},
+			}},
+			"passengers": {Type: "array", MaxItems: service.MaxOrderPassengers, Items: &Schema{
+				Type:     "object",
+				Required: []string{"name", "id_number"},
+				Properties: map[string]*Schema{
+					"name":      {Type: "string", MinLength: 1},
+					"id_number": {Type: "string", MinLength: 1, Description: "证件号码"},
+					"type":      {Type: "string", Enum: passengerValues(), Description: "旅客类型，默认 adult"},
+				},
+			}},
+		},
+	},
+	"Order": {
+		Type:        "object",
+		Description: "status 为 held 时需在 hold_until 之前确认，否则座位被释放",
+		Properties: map[string]*Schema{
+			"id":            {Type: "string"},
+			"status":        {Type: "string", Enum: []any{dao.OrderHeld, dao.OrderConfirmed, dao.OrderCancelled, dao.OrderExpired, dao.OrderRefunded}},
+			"total_amount":  {Type: "number", Description: "全部乘车人的应付金额"},
+			"refund_fee":    {Type: "number", Description: "退票手续费"},
+			"refund_amount": {Type: "number", Description: "实际退款金额"},
+			"hold_until":    {Type: "string", Format: "date-time"},
+			"confirmed_at":  {Type: "string", Format: "date-time"},
+			"closed_at":     {Type: "string", Format: "date-time", Description: "取消、超时或退票的时间"},
+			"created_at":    {Type: "string", Format: "date-time"},
+			"legs": {Type: "array", Items: &Schema{Type: "object", Properties: map[string]*Schema{
+				"seq":            {Type: "integer"},
+				"train_no":       {Type: "string"},
+				"train_number":   {Type: "string"},
+				"date":           {Type: "string", Format: "date"},
+				"from":           {Type: "string"},
+				"to":             {Type: "string"},
+				"departure_time": {Type: "string"},
+				"arrival_time":   {Type: "string"},
+				"seat_class":     {Type: "string", Enum: seatClassValues()},
+				"price":          {Type: "number", Description: "该席别的公布票价"},
+			}}},
+			"passengers": {Type: "array", Items: &Schema{Type: "object", Properties: map[string]*Schema{
+				"name":      {Type: "string"},
+				"id_number": {Type: "string", Description: "只保留首尾各 4 位"},
+				"type":      {Type: "string", Enum: passengerValues()},
+				"amount":    {Type: "number", Description: "按旅客类型计算的全程应付金额"},
+			}}},
+		},
+	},
+	"RequestWaitlist": {
+		Type:        "object",
+		Description: "所选席别余票不足时候补一段，有座位被退还时按队列顺序自动生成并确认订单",
+		Required:    []string{"train_no", "date", "from", "to", "seat_class", "passengers"},
+		Properties: map[string]*Schema{
+			"train_no":   {Type: "string", MinLength: 1, Description: "车次内部编号 TrainNo"},
+			"date":       {Type: "string", Format: "date", Pattern: `^\d{4}-\d{2}-\d{2}$`, Description: "在 from 上车的日期"},
+			"from":       {Type: "string", MinLength: 1},
+			"to":         {Type: "string", MinLength: 1},
+			"seat_class": {Type: "string", Enum: seatClassValues()},
+			"passengers": {Type: "array", MaxItems: service.MaxOrderPassengers, Items: &Schema{
+				Type:     "object",
+				Required: []string{"name", "id_number"},
+				Properties: map[string]*Schema{
+					"name":      {Type: "string", MinLength: 1},
+					"id_number": {Type: "string", MinLength: 1, Description: "证件号码"},
+					"type":      {Type: "string", Enum: passengerValues(), Description: "旅客类型，默认 adult"},
+				},
+			}},
+			"tier":     {Type: "string", Enum: []any{"standard", "priority"}, Description: "priority 排在 standard 之前，开启鉴权时需要管理员 key；默认 standard"},
+			"deadline": {Type: "string", Format: "date-time", Description: "截止时间，为空或晚于发车前两小时时截止到发车前两小时"},
+		},
+	},
+	"Waitlist": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":           {Type: "string"},
+			"status":       {Type: "string", Enum: []any{dao.WaitlistWaiting, dao.WaitlistFulfilled, dao.WaitlistExpired, dao.WaitlistCancelled}},
+			"position":     {Type: "integer", Description: "排队位置，从 1 开始，只在 waiting 时返回"},
+			"train_no":     {Type: "string"},
+			"date":         {Type: "string", Format: "date"},
+			"from":         {Type: "string"},
+			"to":           {Type: "string"},
+			"seat_class":   {Type: "string", Enum: seatClassValues()},
+			"priority":     {Type: "integer", Description: "0 standard，1 priority"},
+			"deadline":     {Type: "string", Format: "date-time"},
+			"order_id":     {Type: "string", Description: "兑现后生成的订单，见 /orders/{id}"},
+			"fulfilled_at": {Type: "string", Format: "date-time"},
+			"closed_at":    {Type: "string", Format: "date-time"},
+			"created_at":   {Type: "string", Format: "date-time"},
+			"passengers": {Type: "array", Items: &Schema{Type: "object", Properties: map[string]*Schema{
+				"name":      {Type: "string"},
+				"id_number": {Type: "string", Description: "只保留首尾各 4 位"},
+				"type":      {Type: "string", Enum: passengerValues()},
+			}}},
+		},
+	},
+	"RequestRealtime": {
+		Type:        "object",
+		Description: "晚点与停运动态，与投递目录中文件的格式相同；同一车次同一始发日期的新动态覆盖旧动态",
+		Required:    []string{"updates"},
+		Properties: map[string]*Schema{
+			"updates": {Type: "array", Items: &Schema{
+				Type:     "object",
+				Required: []string{"train_no", "date"},
+				Properties: map[string]*Schema{
+					"train_no":      {Type: "string", MinLength: 1, Description: "车次内部编号 TrainNo"},
+					"date":          {Type: "string", Format: "date", Pattern: `^\d{4}-\d{2}-\d{2}$`, Description: "始发日期"},
+					"cancelled":     {Type: "boolean", Description: "停运"},
+					"delay_minutes": {Type: "integer", Description: "从始发站起的晚点分钟数"},
+					"stations": {Type: "array", Description: "从所列车站起改为该站的晚点，并向后传递", Items: &Schema{
+						Type:     "object",
+						Required: []string{"station", "delay_minutes"},
+						Properties: map[string]*Schema{
+							"station":       {Type: "string", MinLength: 1},
+							"delay_minutes": {Type: "integer"},
+						},
+					}},
+				},
+			}},
+		},
+	},
+	"Board": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"train_number":  {Type: "string"},
+			"train_no":      {Type: "string"},
+			"station":       {Type: "string", Description: "出发大屏为终到站，到达大屏为始发站"},
+			"time":          {Type: "string", Description: "计划时刻"},
+			"expected_time": {Type: "string", Description: "按实时动态推算的时刻"},
+			"delay_minutes": {Type: "integer"},
+		},
+	},
+	"RequestReplan": {
+		Type:        "object",
+		Description: "正在乘坐的一段晚点时填 delayed_leg 与 delay_minutes，已经在某站时填 station 与 time，两种方式只填其一",
+		Required:    []string{"legs"},
+		Properties: map[string]*Schema{
+			"legs": {Type: "array", MaxItems: service.MaxReplanLegs, Description: "原行程各段，可以直接使用查询结果中的 railway", Items: &Schema{
+				Type:     "object",
+				Required: []string{"train_no", "departure_station", "arrival_station"},
+				Properties: map[string]*Schema{
+					"train_no":          {Type: "string", MinLength: 1, Description: "车次内部编号 TrainNo"},
+					"departure_station": {Type: "string", MinLength: 1},
+					"arrival_station":   {Type: "string", MinLength: 1},
+				},
+			}},
+			"delayed_leg":   {Type: "integer", Description: "正在乘坐的一段（从 0 开始），在它的下车站重新规划"},
+			"delay_minutes": {Type: "integer", Description: "正在乘坐的一段预计到站晚点的分钟数"},
+			"station":       {Type: "string", Description: "当前所在车站"},
+			"time":          {Type: "string", Pattern: `^\d{1,2}:\d{2}$`, Description: "从该时刻起可以乘车"},
+			"date":          {Type: "string", Format: "date", Pattern: `^\d{4}-\d{2}-\d{2}$`, Description: "当前位置的日期（填 delayed_leg 时为计划到站日期），用于计算各段日期并按实时动态修正"},
+			"max_transfer":  {Type: "string", Enum: maxTransferValues, Description: "重新规划时最多换乘次数，默认 2"},
+			"train_type":    {Type: "string", Enum: trainTypeValues},
+			"seat_classes":  {Type: "array", Items: &Schema{Type: "string", Enum: seatClassValues()}},
+			"passenger":     {Type: "string", Enum: passengerValues()},
+		},
+	},
+	"Connection": {
+		Type:        "object",
+		Description: "原行程剩余部分的一次换乘，第一次为从当前位置登上剩余的第一段",
+		Properties: map[string]*Schema{
+			"station":               {Type: "string"},
+			"from_train_no":         {Type: "string", Description: "为空表示当前不在车上"},
+			"to_train_no":           {Type: "string"},
+			"planned_wait_minutes":  {Type: "integer"},
+			"expected_wait_minutes": {Type: "integer"},
+			"feasible":              {Type: "boolean"},
+			"reason":                {Type: "string", Enum: []any{service.ReplanMissed, service.ReplanCancelled}, Description: "missed 换乘时间不足，cancelled 下一段停运"},
+		},
+	},
+	"RequestTrip": {
+		Type:        "object",
+		Description: "往返为两段，A→B→C→A 为三段；各段按自己的日期与出发时段查询，再组合出总价最低或在途时间最短的方案",
+		Required:    []string{"segments"},
+		Properties: map[string]*Schema{
+			"segments": {Type: "array", MaxItems: MaxTripSegments, Items: &Schema{
+				Type:        "object",
+				Description: "from 与 from_city、to 与 to_city 各需提供一个",
+				Required:    []string{"date"},
+				Properties: map[string]*Schema{
+					"from":             {Type: "string"},
+					"to":               {Type: "string"},
+					"from_city":        {Type: "string", Description: "城市代码，见 /api/v1/cities"},
+					"to_city":          {Type: "string", Description: "城市代码，见 /api/v1/cities"},
+					"date":             {Type: "string", Format: "date", Pattern: `^\d{4}-\d{2}-\d{2}$`, Description: "出发日期"},
+					"time":             {Type: "string", Pattern: `^\d{1,2}:\d{2}$`, Description: "最早出发时刻"},
+					"latest_time":      {Type: "string", Pattern: `^\d{1,2}:\d{2}$`, Description: "最晚出发时刻"},
+					"min_stay_minutes": {Type: "integer", Description: "到达上一段终点后至少停留的分钟数，代替顶层的 min_stay_minutes"},
+				},
+			}},
+			"optimize":         {Type: "string", Enum: []any{OptimizePrice, OptimizeDuration}, Description: "price（默认）总应付金额最低，duration 各段在途时间之和最短"},
+			"min_stay_minutes": {Type: "integer", Description: "每一站至少停留的分钟数，默认 0"},
+			"max_transfer":     {Type: "string", Enum: maxTransferValues, Description: "每段最多换乘次数，默认 1"},
+			"train_type":       {Type: "string", Enum: trainTypeValues},
+			"seat_classes":     {Type: "array", Items: &Schema{Type: "string", Enum: seatClassValues()}},
+			"passenger":        {Type: "string", Enum: passengerValues()},
+			"limit":            {Type: "integer", Description: "返回的组合数，默认 5，最多 10"},
+		},
+	},
+	"Trip": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"total_price":   {Type: "number", Description: "各段公布票价之和"},
+			"total_payable": {Type: "number", Description: "各段应付金额之和"},
+			"total_minutes": {Type: "integer", Description: "各段在途时间之和，不含停留"},
+			"segments":      {Type: "array", Items: ref("Journey")},
+		},
+	},
+	"Error": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"error": {Type: "string"},
+		},
+	},
+	"UnresolvedStation": {
+		Type:        "object",
+		Description: "输入无法唯一确定车站，suggestions 按可能性排序",
+		Properties: map[string]*Schema{
+			"error":       {Type: "string"},
+			"input":       {Type: "string"},
+			"suggestions": {Type: "array", Items: ref("Station")},
+		},
+	},
+}
+
+// Operation 描述 paths 下的一个接口
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+func jsonBody(name string) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: ref(name)}}}
+}
+
+func jsonResponse(description string, schema *Schema) Response {
+	return Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var errorResponses = map[string]Response{
+	"400": jsonResponse("请求参数不符合规范", ref("Error")),
+	"401": jsonResponse("缺少或无效的 API key", ref("Error")),
+	"429": jsonResponse("超出限流或每日配额，Retry-After 为建议等待的秒数", ref("Error")),
+	"500": jsonResponse("服务内部错误", ref("Error")),
+}
+
+func withErrors(responses map[string]Response) map[string]Response {
+	for code, response := range errorResponses {
+		responses[code] = response
+	}
+	return responses
+}
+
+// paths 新接口在这里登记，/openapi.json 会随之更新
+var paths = map[string]map[string]Operation{
+	"/station": {
+		"post": {
+			Summary:     "车站与城市联想（已废弃，请使用 GET /api/v1/stations）",
+			Deprecated:  true,
+			RequestBody: jsonBody("RequestStation"),
+			Responses:   withErrors(map[string]Response{"200": jsonResponse("联想结果", ref("ResponseStation"))}),
+		},
+	},
+	"/search": {
+		"post": {
+			Summary:     "车次查询（已废弃，请使用 GET /api/v1/journeys）",
+			Deprecated:  true,
+			RequestBody: jsonBody("RequestSearch"),
+			Responses: withErrors(map[string]Response{
+				"200": jsonResponse("查询结果", &Schema{Type: "array", Items: ref("ResponseSearch")}),
+			}),
+		},
+	},
+	"/api/v1/stations": {
+		"get": {
+			Summary: "车站与城市联想",
+			Parameters: []Parameter{
+				{Name: "q", In: "query", Required: true, Description: "车站名、城市名、全拼、首字母、简称或电报码的前缀", Schema: &Schema{Type: "string", MinLength: 1}},
+				{Name: "limit", In: "query", Description: "最多返回的条数，默认 20", Schema: &Schema{Type: "string", Pattern: `^[1-9]\d{0,2}$`}},
+			},
+			Responses: withErrors(map[string]Response{
+				"200": jsonResponse("联想结果", &Schema{Type: "object", Properties: map[string]*Schema{
+					"stations": {Type: "array", Items: ref("Station")},
+				}}),
+			}),
+		},
+	},
+	"/api/v1/stations/nearby": {
+		"get": {
+			Summary: "查询坐标附近的车站，按距离排序",
+			Parameters: []Parameter{
+				{Name: "lat", In: "query", Required: true, Description: "纬度", Schema: &Schema{Type: "string", Pattern: `^-?\d{1,2}(\.\d+)?$`}},
+				{Name: "lon", In: "query", Required: true, Description: "经度", Schema: &Schema{Type: "string", Pattern: `^-?\d{1,3}(\.\d+)?$`}},
+				{Name: "radius", In: "query", Description: "半径（千米），默认 10，最大 200", Schema: &Schema{Type: "string", Pattern: `^\d+(\.\d+)?$`}},
+				{Name: "limit", In: "query", Description: "最多返回的条数，默认 20", Schema: &Schema{Type: "string", Pattern: `^[1-9]\d{0,2}$`}},
+			},
+			Responses: withErrors(map[string]Response{
+				"200": jsonResponse("附近车站", &Schema{Type: "object", Properties: map[string]*Schema{
+					"stations": {Type: "array", Items: ref("NearbyStation")},
+				}}),
+			}),
+		},
+	},
+	"/api/v1/cities": {
+		"get": {
+			Summary: "城市列表，q 不为空时按城市名、全拼或首字母联想",
+			Parameters: []Parameter{
+				{Name: "q", In: "query", Description: "城市名、全拼或首字母前缀", Schema: &Schema{Type: "string"}},
+			},
+			Responses: withErrors(map[string]Response{
+				"200": jsonResponse("城市列表", &Schema{Type: "object", Properties: map[string]*Schema{
+					"cities": {Type: "array", Items: ref("City")},
+				}}),
+			}),
+		},
+	},
+	"/api/v1/cities/{code}": {
+		"get": {
+			Summary: "城市详情与下属车站",
+			Parameters: []Parameter{
+				{Name: "code", In: "path", Required: true, Description: "城市代码", Schema: &Schema{Type: "string"}},
+			},
+			Responses: withErrors(map[string]Response{
+				"200": jsonResponse("城市详情", ref("City")),
+				"404": jsonResponse("城市不存在", ref("Error")),
+			}),
+		},
+	},
+	"/api/v1/journeys": {
+		"get": {
+			Summary: "行程查询（直达、一次中转、多次中转）",
+			Parameters: []Parameter{
+				{Name: "from", In: "query", Description: "出发站，与 from_city 二选一", Schema: &Schema{Type: "string", MinLength: 1}},
+				{Name: "to", In: "query", Description: "到达站，与 to_city 二选一", Schema: &Schema{Type: "string", MinLength: 1}},
+				{Name: "via", In: "query", Description: "指定中转站；逗号分隔多个车站时按顺序经过，只走图搜索", Schema: &Schema{Type: "string"}},
+				{Name: "via_dwell", In: "query", Description: "在途经站至少停留的分钟数，一个值用于所有途经站，或逗号分隔、与 via 一一对应",
+					Schema: &Schema{Type: "string", Pattern: `^\d+(,\d+)*$`}},
+				{Name: "avoid", In: "query", Description: "逗号分隔的车站，不在这些车站上下车，也不乘坐途中停靠这些车站的区间", Schema: &Schema{Type: "string"}},
+				{Name: "avoid_city", In: "query", Description: "逗号分隔的城市代码，城市下属的车站都按 avoid 处理", Schema: &Schema{Type: "string"}},
+				{Name: "exclude_trains", In: "query", Description: "逗号分隔的车次，不乘坐这些车次", Schema: &Schema{Type: "string"}},
+				{Name: "prefer_trains", In: "query", Description: fmt.Sprintf("逗号分隔的车次，尽量只乘坐这些车次，每乘坐一个其他车次按多用 %d 分钟计", service.PreferPenalty), Schema: &Schema{Type: "string"}},
+				{Name: "from_city", In: "query", Description: "出发城市代码，查询城市内所有车站", Schema: &Schema{Type: "string", MinLength: 1}},
+				{Name: "to_city", In: "query", Description: "到达城市代码", Schema: &Schema{Type: "string", MinLength: 1}},
+				{Name: "via_city", In: "query", Description: "指定中转城市代码", Schema: &Schema{Type: "string", MinLength: 1}},
+				{Name: "date", In: "query", Description: "出发日期，用于计算各段日期，并按当天的实时动态修正时刻、去掉停运车次与赶不上的换乘", Schema: &Schema{Type: "string", Format: "date", Pattern: `^\d{4}-\d{2}-\d{2}$`}},
+				{Name: "time", In: "query", Description: "最早出发时刻", Schema: &Schema{Type: "string", Pattern: `^\d{1,2}:\d{2}$`}},
+				{Name: "sort", In: "query", Schema: &Schema{Type: "string", Enum: sortNameValues()}},
+				{Name: "transfers", In: "query", Description: "最多换乘次数", Schema: &Schema{Type: "string", Enum: maxTransferValues}},
+				{Name: "train_type", In: "query", Schema: &Schema{Type: "string", Enum: trainTypeValues}},
+				{Name: "seat_class", In: "query", Description: "逗号分隔、按优先顺序排列的席别，如 ze,yz 表示二等座，无二等座时硬座；为空时按最低价计价",
+					Schema: &Schema{Type: "string", Pattern: `^[a-z]+(,[a-z]+)*$`}},
+				{Name: "passenger", In: "query", Description: "旅客类型，决定适用的优惠规则，默认 adult", Schema: &Schema{Type: "string", Enum: passengerValues()}},
+				{Name: "available", In: "query", Description: "为 true 时只返回每段都有余票的行程，需要同时指定 date", Schema: &Schema{Type: "boolean"}},
+			},
+			Responses: withErrors(map[string]Response{
+				"200": jsonResponse("查询结果", &Schema{Type: "object", Properties: map[string]*Schema{
+					"journeys": {Type: "array", Items: ref("Journey")},
+					"partial":  {Type: "boolean", Description: "查询超时，只返回了部分结果"},
+				}}),
+			}),
+		},
+	},
+}
+
+func init() {
+	status := &Schema{Type: "object", Properties: map[string]*Schema{
+		"status": {Type: "string"},
+		"error":  {Type: "string"},
+	}}
+	paths["/healthz"] = map[string]Operation{
+		"get": {Summary: "存活检查", Responses: map[string]Response{"200": jsonResponse("服务进程存活", status)}},
+	}
+	paths["/readyz"] = map[string]Operation{
+		"get": {Summary: "就绪检查：数据库可连接且换乘图已构建", Responses: map[string]Response{
+			"200": jsonResponse("可以接收请求", status),
+			"503": jsonResponse("数据库不可用或图尚未构建", status),
+		}},
+	}
+	paths["/metrics"] = map[string]Operation{
+		"get": {Summary: "Prometheus 指标", Responses: map[string]Response{
+			"200": {Description: "Prometheus 文本格式", Content: map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}},
+		}},
+	}
+	// /search/stream 与 /api/v1/journeys 参数一致，返回 text/event-stream
+	paths["/search/stream"] = map[string]Operation{
+		"get": {
+			Summary:    "以 SSE 推送查询结果，事件依次为若干 journeys / error，最后为 summary",
+			Parameters: paths["/api/v1/journeys"]["get"].Parameters,
+			Responses: withErrors(map[string]Response{
+				"200": {Description: "事件流", Content: map[string]MediaType{"text/event-stream": {Schema: &Schema{Type: "string"}}}},
+			}),
+		},
+	}
+	geoJSON := func(description string) Response {
+		return Response{Description: description, Content: map[string]MediaType{"application/geo+json": {Schema: &Schema{Type: "object", Description: "GeoJSON FeatureCollection，坐标为 [经度, 纬度]"}}}}
+	}
+	paths["/api/v1/journeys/geojson"] = map[string]Operation{
+		"get": {
+			Summary:    "以 GeoJSON 导出行程，每段为一条经过各经停站的 LineString，属性含车次、时刻与票价",
+			Parameters: paths["/api/v1/journeys"]["get"].Parameters,
+			Responses:  withErrors(map[string]Response{"200": geoJSON("行程")}),
+		},
+	}
+	paths["/api/v1/trains/{train_no}/geojson"] = map[string]Operation{
+		"get": {
+			Summary: "以 GeoJSON 导出车次全程，经停站为 Point，全程为 LineString",
+			Parameters: []Parameter{
+				{Name: "train_no", In: "path", Required: true, Description: "车次内部编号 TrainNo", Schema: &Schema{Type: "string"}},
+			},
+			Responses: withErrors(map[string]Response{
+				"200": geoJSON("车次路线"),
+				"404": jsonResponse("车次不存在", ref("Error")),
+			}),
+		},
+	}
+	paths["/admin/network/geojson"] = map[string]Operation{
+		"get": {
+			Summary: "以 GeoJSON 导出全路网：车站为 Point，相邻站间线路为 LineString，trains 为经过的车次数；需要管理员 key",
+			Responses: withErrors(map[string]Response{
+				"200": geoJSON("全路网"),
+				"403": jsonResponse("不是管理员 key", ref("Error")),
+			}),
+		},
+	}
+	paths["/api/v1/boards/{station}"] = map[string]Operation{
+		"get": {
+			Summary: "车站出发或到达大屏，按实时动态给出预计时刻，停运的车次不列出",
+			Parameters: []Parameter{
+				{Name: "station", In: "path", Required: true, Description: "车站名", Schema: &Schema{Type: "string"}},
+				{Name: "date", In: "query", Description: "日期，默认今天", Schema: &Schema{Type: "string", Format: "date", Pattern: `^\d{4}-\d{2}-\d{2}$`}},
+				{Name: "type", In: "query", Description: "departures（默认）或 arrivals", Schema: &Schema{Type: "string", Enum: []any{"departures", "arrivals"}}},
+			},
+			Responses: withErrors(map[string]Response{
+				"200": jsonResponse("车站大屏", &Schema{Type: "object", Properties: map[string]*Schema{
+					"trains": {Type: "array", Items: ref("Board")},
+				}}),
+				"422": jsonResponse("车站无法确定，返回候选车站", ref("UnresolvedStation")),
+			}),
+		},
+	}
+	paths["/admin/realtime"] = map[string]Operation{
+		"post": {
+			Summary:     "推送晚点与停运动态，任一条不合法时整批不导入；需要管理员 key",
+			RequestBody: jsonBody("RequestRealtime"),
+			Responses: withErrors(map[string]Response{
+				"200": jsonResponse("导入的条数", &Schema{Type: "object", Properties: map[string]*Schema{
+					"applied": {Type: "integer"},
+				}}),
+				"403": jsonResponse("不是管理员 key", ref("Error")),
+				"422": jsonResponse("车次不存在、车站不在车次上或晚点分钟数超出范围", ref("Error")),
+			}),
+		},
+	}
+	paths["/admin/reload"] = map[string]Operation{
+		"post": {
+			Summary: "从数据库重新读取关键站点并重建换乘图，用于 -import-coordinates 等导入之后；需要管理员 key",
+			Responses: withErrors(map[string]Response{
+				"200": jsonResponse("重建后的时刻表版本", &Schema{Type: "object", Properties: map[string]*Schema{
+					"timetable_version": {Type: "integer"},
+				}}),
+				"403": jsonResponse("不是管理员 key", ref("Error")),
+			}),
+		},
+	}
+	replanJourney := &Schema{Type: "object", Properties: map[string]*Schema{
+		"arrival_minutes": {Type: "integer", Description: "从 ready_time 起到达终点的分钟数"},
+		"kept_legs":       {Type: "integer", Description: "沿用原行程车次的段数"},
+		"original":        {Type: "boolean", Description: "原行程剩余部分仍然可行"},
+	}}
+	for name, property := range components["Journey"].Properties {
+		replanJourney.Properties[name] = property
+	}
+	components["ReplanJourney"] = replanJourney
+	paths["/api/v1/replan"] = map[string]Operation{
+		"post": {
+			Summary:     "误车后重新规划：检查原行程剩余部分的各次换乘，并从当前位置重新搜索到终点的方案，按到达时间排列",
+			RequestBody: jsonBody("RequestReplan"),
+			Responses: withErrors(map[string]Response{
+				"200": jsonResponse("各次换乘是否还赶得上，以及后续方案", &Schema{Type: "object", Properties: map[string]*Schema{
+					"station":     {Type: "string", Description: "重新规划的车站"},
+					"ready_date":  {Type: "string", Description: "只在指定 date 时返回"},
+					"ready_time":  {Type: "string", Description: "从该时刻起可以乘车，下车换乘时已留出最短换乘时间"},
+					"connections": {Type: "array", Items: ref("Connection")},
+					"journeys":    {Type: "array", Items: ref("ReplanJourney")},
+					"partial":     {Type: "boolean", Description: "搜索超时，只返回了部分方案"},
+				}}),
+				"422": jsonResponse("原行程的车次或区间不存在、已经在终点，或车站无法确定", ref("Error")),
+			}),
+		},
+	}
+	paths["/api/v1/trips"] = map[string]Operation{
+		"post": {
+			Summary:     "往返与多城市行程：逐段查询后组合，相邻两段之间至少停留 min_stay_minutes",
+			RequestBody: jsonBody("RequestTrip"),
+			Responses: withErrors(map[string]Response{
+				"200": jsonResponse("按优化目标排列的组合，没有满足停留时间的组合时为空", &Schema{Type: "object", Properties: map[string]*Schema{
+					"trips":   {Type: "array", Items: ref("Trip")},
+					"partial": {Type: "boolean", Description: "某一段查询超时，只用了部分结果"},
+				}}),
+				"422": jsonResponse("车站无法确定，返回候选车站", ref("UnresolvedStation")),
+			}),
+		},
+	}
+	orderID := Parameter{Name: "id", In: "path", Required: true, Description: "订单号", Schema: &Schema{Type: "string"}}
+	orderResponses := func(description string, extra map[string]Response) map[string]Response {
+		responses := withErrors(map[string]Response{
+			"200": jsonResponse(description, ref("Order")),
+			"404": jsonResponse("订单不存在", ref("Error")),
+		})
+		for code, response := range extra {
+			responses[code] = response
+		}
+		return responses
+	}
+	paths["/orders"] = map[string]Operation{
+		"post": {
+			Summary:     "下单并占座，每位乘车人在每一段各占一个席位；需在 hold_until 之前确认",
+			RequestBody: jsonBody("RequestOrder"),
+			Responses: withErrors(map[string]Response{
+				"201": jsonResponse("占座成功", ref("Order")),
+				"409": jsonResponse("余票不足或已发车", ref("Error")),
+				"422": jsonResponse("车次不经过所选车站或所选席别不售", ref("Error")),
+			}),
+		},
+	}
+	paths["/orders/{id}"] = map[string]Operation{
+		"get": {Summary: "查询订单", Parameters: []Parameter{orderID}, Responses: orderResponses("订单", nil)},
+	}
+	paths["/orders/{id}/confirm"] = map[string]Operation{
+		"post": {Summary: "确认占座中的订单", Parameters: []Parameter{orderID}, Responses: orderResponses("已确认的订单", map[string]Response{
+			"409": jsonResponse("占座已超时或订单已取消", ref("Error")),
+		})},
+	}
+	paths["/orders/{id}/cancel"] = map[string]Operation{
+		"post": {Summary: "取消占座中的订单，或对已确认的订单退票：距发车 8 天以上免费，48 小时以上 5%，24 小时以上 10%，其余 20%",
+			Parameters: []Parameter{orderID}, Responses: orderResponses("取消或退票后的订单", map[string]Response{
+				"409": jsonResponse("已发车或订单已关闭", ref("Error")),
+			})},
+	}
+	waitlistID := Parameter{Name: "id", In: "path", Required: true, Description: "候补单号", Schema: &Schema{Type: "string"}}
+	paths["/waitlist"] = map[string]Operation{
+		"post": {
+			Summary:     "余票不足时加入候补，同一车次、日期、席别与区间为一个队列",
+			RequestBody: jsonBody("RequestWaitlist"),
+			Responses: withErrors(map[string]Response{
+				"201": jsonResponse("已加入候补", ref("Waitlist")),
+				"403": jsonResponse("priority 需要管理员 key", ref("Error")),
+				"409": jsonResponse("仍有余票或已过候补截止时间", ref("Error")),
+				"422": jsonResponse("车次不经过所选车站或所选席别不售", ref("Error")),
+			}),
+		},
+	}
+	paths["/waitlist/{id}"] = map[string]Operation{
+		"get": {Summary: "查询候补状态与排队位置", Parameters: []Parameter{waitlistID}, Responses: withErrors(map[string]Response{
+			"200": jsonResponse("候补", ref("Waitlist")),
+			"404": jsonResponse("候补不存在", ref("Error")),
+		})},
+	}
+	paths["/waitlist/{id}/cancel"] = map[string]Operation{
+		"post": {Summary: "取消排队中的候补", Parameters: []Parameter{waitlistID}, Responses: withErrors(map[string]Response{
+			"200": jsonResponse("已取消的候补", ref("Waitlist")),
+			"404": jsonResponse("候补不存在", ref("Error")),
+			"409": jsonResponse("候补已兑现、超时或已取消", ref("Error")),
+		})},
+	}
+	// 查询接口的车站无法确定时返回 422
+	for _, path := range []string{"/search", "/api/v1/journeys", "/search/stream", "/api/v1/journeys/geojson"} {
+		for _, operation := range paths[path] {
+			operation.Responses["422"] = jsonResponse("车站无法确定时返回候选车站；途经站、避开的车站等约束相互冲突时只有 error", ref("UnresolvedStation"))
+		}
+	}
+	// 会返回 401 的接口都需要 API key
+	for _, operations := range paths {
+		for method, operation := range operations {
+			if _, ok := operation.Responses["401"]; ok {
+				operation.Security = []map[string][]string{{"apiKey": {}}, {"bearer": {}}}
+				operations[method] = operation
+			}
+		}
+	}
+	for _, schema := range components {
+		schema.compilePatterns()
+	}
+	for _, operations := range paths {
+		for _, operation := range operations {
+			for _, parameter := range operation.Parameters {
+				parameter.Schema.compilePatterns()
+			}
+		}
+	}
+}
+
+func sortByValues() []any {
+	values := make([]any, 0, len(legacySortBy))
+	for value := range legacySortBy {
+		values = append(values, value)
+	}
+	return values
+}
+
+func sortNameValues() []any {
+	names := make([]string, 0, len(sortNames))
+	for name := range sortNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]any, 0, len(names))
+	for _, name := range names {
+		values = append(values, name)
+	}
+	return values
+}
+
+func passengerValues() []any {
+	values := make([]any, 0, len(fare.PassengerTypes))
+	for _, passenger := range fare.PassengerTypes {
+		values = append(values, string(passenger))
+	}
+	return values
+}
+
+func seatClassValues() []any {
+	values := make([]any, 0, len(service.SeatClasses))
+	for _, seatClass := range service.SeatClasses {
+		values = append(values, seatClass)
+	}
+	return values
+}
+
+func openAPISpec() gin.H {
+	return gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":   "Railway API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": gin.H{
+			"schemas": components,
+			"securitySchemes": gin.H{
+				"apiKey": gin.H{"type": "apiKey", "in": "header", "name": APIKeyHeader},
+				"bearer": gin.H{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+func openAPIHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, openAPISpec())
+}
+
+// validateBody 按 components 中的 schema 校验 JSON 请求体，校验通过后将请求体还原，供后续 ShouldBindJSON 使用
+func validateBody(schemaName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		var value any
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if err := ref(schemaName).Validate("body", value); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+// validateQuery 按 paths 中登记的 GET 参数校验查询字符串
+func validateQuery(path string) gin.HandlerFunc {
+	parameters := paths[path]["get"].Parameters
+	return func(c *gin.Context) {
+		for _, parameter := range parameters {
+			raw, ok := c.GetQuery(parameter.Name)
+			if !ok {
+				if parameter.Required {
+					c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "query." + parameter.Name + ": is required"})
+					return
+				}
+				continue
+			}
+			var value any = raw
+			// 查询字符串中的布尔值按 strconv.ParseBool 解析，与各接口读取时一致
+			if parameter.Schema.Type == "boolean" {
+				if parsed, err := strconv.ParseBool(raw); err == nil {
+					value = parsed
+				}
+			}
+			if err := parameter.Schema.Validate("query."+parameter.Name, value); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// Validate 校验 value 是否满足 schema，path 用于在错误信息中指出出错的字段
+func (s *Schema) Validate(path string, value any) error {
+	if s.Ref != "" {
+		target, ok := components[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %s", path, s.Ref)
+		}
+		return target.Validate(path, value)
+	}
+	if value == nil {
+		return fmt.Errorf("%s: must not be null", path)
+	}
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s.%s: is required", path, name)
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				continue
+			}
+			if err := property.Validate(path+"."+name, object[name]); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an array", path)
+		}
+		if s.MaxItems > 0 && len(array) > s.MaxItems {
+			return fmt.Errorf("%s: must have at most %d items", path, s.MaxItems)
+		}
+		for index, item := range array {
+			if s.Items == nil {
+				break
+			}
+			if err := s.Items.Validate(fmt.Sprintf("%s[%d]", path, index), item); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string", path)
+		}
+		if len([]rune(str)) < s.MinLength {
+			return fmt.Errorf("%s: must not be empty", path)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(str) {
+			return fmt.Errorf("%s: must match %s", path, s.Pattern)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: must be a boolean", path)
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: must be a %s", path, s.Type)
+		}
+		if s.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				return fmt.Errorf("%s: must be an integer", path)
+			}
+		}
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		return fmt.Errorf("%s: must be one of %v", path, s.Enum)
+	}
+	return nil
+}
+
+// compilePatterns 编译 schema 及其属性、数组元素中的 Pattern，校验时不再逐次编译
+func (s *Schema) compilePatterns() {
+	if s == nil {
+		return
+	}
+	if s.Pattern != "" && s.pattern == nil {
+		s.pattern = regexp.MustCompile(s.Pattern)
+	}
+	for _, property := range s.Properties {
+		property.compilePatterns()
+	}
+	s.Items.compilePatterns()
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
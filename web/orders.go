@@ -0,0 +1,122 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"railway/dao"
+	"railway/fare"
+	"railway/service"
+)
+
+// RequestOrder POST /orders 的请求体，每位乘车人在每一段各占一个 seat_class 席位
+type RequestOrder struct {
+	Legs       []RequestOrderLeg       `json:"legs"`
+	Passengers []RequestOrderPassenger `json:"passengers"`
+}
+
+type RequestOrderLeg struct {
+	TrainNo   string `json:"train_no"`
+	Date      string `json:"date"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	SeatClass string `json:"seat_class"`
+}
+
+type RequestOrderPassenger struct {
+	Name     string `json:"name"`
+	IDNumber string `json:"id_number"`
+	Type     string `json:"type"`
+}
+
+func (h *HandlerImpl) createOrderHandler(c *gin.Context) {
+	var req RequestOrder
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	orderReq := service.OrderRequest{
+		Legs:       make([]service.OrderLegRequest, 0, len(req.Legs)),
+		Passengers: make([]service.OrderPassengerRequest, 0, len(req.Passengers)),
+		APIKeyID:   apiKeyID(c),
+	}
+	for _, leg := range req.Legs {
+		orderReq.Legs = append(orderReq.Legs, service.OrderLegRequest(leg))
+	}
+	for _, passenger := range req.Passengers {
+		passengerType, err := fare.ParsePassengerType(passenger.Type)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "body.passengers.type: " + err.Error()})
+			return
+		}
+		orderReq.Passengers = append(orderReq.Passengers, service.OrderPassengerRequest{
+			Name:     passenger.Name,
+			IDNumber: passenger.IDNumber,
+			Type:     passengerType,
+		})
+	}
+	order, err := h.OrderService.CreateOrder(c.Request.Context(), orderReq)
+	if err != nil {
+		writeOrderError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, toOrderDTO(*order))
+}
+
+func (h *HandlerImpl) getOrderHandler(c *gin.Context) {
+	order, err := h.OrderService.GetOrder(c.Request.Context(), c.Param("id"), apiKeyID(c))
+	if err != nil {
+		writeOrderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toOrderDTO(*order))
+}
+
+func (h *HandlerImpl) confirmOrderHandler(c *gin.Context) {
+	order, err := h.OrderService.ConfirmOrder(c.Request.Context(), c.Param("id"), apiKeyID(c))
+	if err != nil {
+		writeOrderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toOrderDTO(*order))
+}
+
+// cancelOrderHandler 未确认的订单取消，已确认的订单按退票费率退票
+func (h *HandlerImpl) cancelOrderHandler(c *gin.Context) {
+	order, err := h.OrderService.CancelOrder(c.Request.Context(), c.Param("id"), apiKeyID(c))
+	if err != nil {
+		writeOrderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toOrderDTO(*order))
+}
+
+// apiKeyID 订单只对创建它的 API key 可见，鉴权关闭时为 0
+func apiKeyID(c *gin.Context) int {
+	value, _ := c.Get(apiKeyCtxKey)
+	if key, ok := value.(*dao.APIKey); ok {
+		return key.ID
+	}
+	return 0
+}
+
+func writeOrderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	case errors.Is(err, service.ErrInvalidOrder), errors.Is(err, service.ErrLegNotFound), errors.Is(err, service.ErrSeatClassNotSold):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, dao.ErrSeatsSoldOut):
+		c.JSON(http.StatusConflict, gin.H{"error": "seats sold out"})
+	case errors.Is(err, service.ErrOrderExpired):
+		c.JSON(http.StatusConflict, gin.H{"error": "order hold expired"})
+	case errors.Is(err, service.ErrOrderDeparted):
+		c.JSON(http.StatusConflict, gin.H{"error": "train already departed"})
+	case errors.Is(err, dao.ErrOrderStateChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "order status does not allow this operation"})
+	case err.Error() == "stationNotOnTrain" || err.Error() == "invalidDate":
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error processing order"})
+	}
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"railway/service"
+)
+
+// RequestRealtime POST /admin/realtime 的请求体，与投递目录中的文件格式相同
+type RequestRealtime struct {
+	Updates []service.RealtimeUpdate `json:"updates"`
+}
+
+// stationBoardV1Handler type 为 departures（默认）或 arrivals，停运的车次不列出
+func (h *HandlerImpl) stationBoardV1Handler(c *gin.Context) {
+	arrivals := c.DefaultQuery("type", "departures") == "arrivals"
+	entries, err := h.RailWayServiceImpl.StationBoard(c.Request.Context(), c.Param("station"), c.Query("date"), arrivals)
+	if err != nil {
+		if err.Error() == "invalidDate" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "query.date: must be YYYY-MM-DD"})
+			return
+		}
+		writeSearchError(c, err)
+		return
+	}
+	trains := make([]BoardDTO, 0, len(entries))
+	for _, entry := range entries {
+		trains = append(trains, toBoardDTO(entry))
+	}
+	c.JSON(http.StatusOK, gin.H{"trains": trains})
+}
+
+// pushRealtimeHandler 导入一批晚点与停运动态，任一条不合法时整批不导入
+func (h *HandlerImpl) pushRealtimeHandler(c *gin.Context) {
+	var req RequestRealtime
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	applied, err := h.RailWayServiceImpl.ApplyRealtime(c.Request.Context(), req.Updates)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRealtime) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error applying realtime updates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"applied": applied})
+}
@@ -0,0 +1,150 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"railway/dao"
+	"railway/fare"
+	"railway/service"
+	"strconv"
+	"strings"
+)
+
+// RequestReplan POST /api/v1/replan 的请求体，legs 可以直接使用查询结果中的 railway。
+// 正在乘坐的一段晚点时填 delayed_leg（从 0 开始）与 delay_minutes，已经在某站时填 station 与 time，两种方式只填其一
+type RequestReplan struct {
+	Legs        []RequestReplanLeg `json:"legs"`
+	DelayedLeg  *int               `json:"delayed_leg"`
+	Delay       int64              `json:"delay_minutes"`
+	Station     string             `json:"station"`
+	Time        string             `json:"time"`
+	Date        string             `json:"date"`
+	MaxTransfer string             `json:"max_transfer"`
+	TrainType   string             `json:"train_type"`
+	SeatClasses []string           `json:"seat_classes"`
+	Passenger   string             `json:"passenger"`
+}
+
+type RequestReplanLeg struct {
+	TrainNo          string `json:"train_no"`
+	DepartureStation string `json:"departure_station"`
+	ArrivalStation   string `json:"arrival_station"`
+}
+
+// ConnectionDTO 原行程剩余部分的一次换乘，from_train_no 为空表示当前不在车上
+type ConnectionDTO struct {
+	Station      string `json:"station"`
+	FromTrainNo  string `json:"from_train_no,omitempty"`
+	ToTrainNo    string `json:"to_train_no"`
+	PlannedWait  int64  `json:"planned_wait_minutes"`
+	ExpectedWait int64  `json:"expected_wait_minutes"`
+	Feasible     bool   `json:"feasible"`
+	Reason       string `json:"reason,omitempty"` // missed 或 cancelled
+}
+
+// ReplanJourneyDTO 一个后续方案，arrival_minutes 为从 ready_time 起到达终点的分钟数
+type ReplanJourneyDTO struct {
+	JourneyDTO
+	ArrivalMinutes int64 `json:"arrival_minutes"`
+	KeptLegs       int   `json:"kept_legs"`
+	Original       bool  `json:"original"`
+}
+
+// replanHandler 检查原行程剩余部分的各次换乘，并从当前位置重新搜索到终点的方案
+func (h *HandlerImpl) replanHandler(c *gin.Context) {
+	var req RequestReplan
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	replanReq, passenger, err := toReplanRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), SearchTimeout)
+	defer cancel()
+	result, err := h.RailWayServiceImpl.ReplanJourney(ctx, replanReq)
+	partial := err != nil && ctx.Err() != nil
+	if err != nil && !partial {
+		switch {
+		case errors.Is(err, service.ErrInvalidReplan):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case err.Error() == "invalidDate":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "body.date: must be YYYY-MM-DD"})
+		default:
+			writeSearchError(c, err)
+		}
+		return
+	}
+	connections := make([]ConnectionDTO, 0, len(result.Connections))
+	for _, connection := range result.Connections {
+		connections = append(connections, ConnectionDTO(connection))
+	}
+	journeys := make([]ReplanJourneyDTO, 0, len(result.Options))
+	for _, option := range result.Options {
+		journeys = append(journeys, ReplanJourneyDTO{
+			JourneyDTO:     toJourneyDTO(toResponseSearch(option.Key, option.Legs, passenger), option.Date),
+			ArrivalMinutes: option.Arrival,
+			KeptLegs:       option.KeptLegs,
+			Original:       option.Original,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"station":     result.Station,
+		"ready_date":  result.ReadyDate,
+		"ready_time":  result.ReadyTime,
+		"connections": connections,
+		"journeys":    journeys,
+		"partial":     partial,
+	})
+}
+
+func toReplanRequest(req RequestReplan) (service.ReplanRequest, fare.PassengerType, error) {
+	replanReq := service.ReplanRequest{
+		Legs:        make([]dao.RailWay, 0, len(req.Legs)),
+		DelayedLeg:  -1,
+		Delay:       req.Delay,
+		Station:     strings.TrimSpace(req.Station),
+		Date:        req.Date,
+		SpeedOption: req.TrainType,
+		MaxTrans:    2,
+	}
+	for _, leg := range req.Legs {
+		replanReq.Legs = append(replanReq.Legs, dao.RailWay{TrainNo: leg.TrainNo, DepartureStation: leg.DepartureStation, ArrivalStation: leg.ArrivalStation})
+	}
+	if replanReq.SpeedOption == "" {
+		replanReq.SpeedOption = service.Default
+	}
+	if (req.DelayedLeg == nil) == (replanReq.Station == "") {
+		return replanReq, "", errors.New("body: exactly one of delayed_leg and station is required")
+	}
+	if req.DelayedLeg != nil {
+		replanReq.DelayedLeg = *req.DelayedLeg
+	} else {
+		minutes, err := service.GetTime(req.Time)
+		if err != nil {
+			return replanReq, "", errors.New("body.time: must be HH:MM when station is set")
+		}
+		replanReq.Time = minutes
+	}
+	if req.MaxTransfer != "" {
+		maxTransfer, err := strconv.ParseInt(req.MaxTransfer, 10, 64)
+		if err != nil {
+			return replanReq, "", errors.New("body.max_transfer: must be an integer")
+		}
+		replanReq.MaxTrans = maxTransfer
+	}
+	var err error
+	replanReq.SeatClasses, err = service.ParseSeatClasses(strings.Join(req.SeatClasses, ","))
+	if err != nil {
+		return replanReq, "", errors.New("body.seat_classes: " + err.Error())
+	}
+	passenger, err := fare.ParsePassengerType(req.Passenger)
+	if err != nil {
+		return replanReq, "", errors.New("body.passenger: " + err.Error())
+	}
+	return replanReq, passenger, nil
+}
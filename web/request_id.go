@@ -0,0 +1,51 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"railway/logging"
+	"time"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDMiddleware 沿用客户端传入的 X-Request-ID，没有时生成一个，并写入响应头与请求的 context
+func requestIDMiddleware(c *gin.Context) {
+	id := c.GetHeader(RequestIDHeader)
+	if id == "" || len(id) > 64 {
+		id = newRequestID()
+	}
+	c.Header(RequestIDHeader, id)
+	c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+	c.Next()
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// accessLogMiddleware 每个请求结束后输出一条访问日志，替代 gin 默认的 Logger
+func (h *HandlerImpl) accessLogMiddleware(c *gin.Context) {
+	startTime := time.Now()
+	c.Next()
+	level := slog.LevelInfo
+	if c.Writer.Status() >= 500 {
+		level = slog.LevelError
+	}
+	h.logger(c).Log(c.Request.Context(), level, "request",
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+		"status", c.Writer.Status(),
+		"latency_ms", time.Since(startTime).Milliseconds(),
+		"client_ip", c.ClientIP(),
+	)
+}
+
+// logger 返回带有当前请求 ID 的 logger
+func (h *HandlerImpl) logger(c *gin.Context) *slog.Logger {
+	return logging.FromContext(c.Request.Context(), h.Logger)
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"railway/cache"
+	"railway/service"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultCacheSize 查询结果缓存的条目数上限，内存主要由 config.CacheConfig.MaxBytes 限制
+	DefaultCacheSize = 1024
+	DefaultCacheTTL  = 10 * time.Minute
+)
+
+// NewResultCache 查询结果缓存，键与值的总字节数不超过 maxBytes
+func NewResultCache(maxBytes int64) cache.Cache {
+	return cache.NewLRU(DefaultCacheSize, maxBytes, DefaultCacheTTL)
+}
+
+// cachedVersion 缓存中结果对应的时刻表版本，版本变化后清空缓存
+var cachedVersion atomic.Uint64
+
+// cacheKey 规范化后的查询条件，时刻表版本也是键的一部分；指定日期时结果还取决于实时动态的版本。
+// 车站名按 service.NormalizeStationName 规范化；出发时刻只在读取缓存之后过滤，不是键的一部分
+func (query journeyQuery) cacheKey(version uint64) string {
+	realtime := ""
+	if query.Date != "" {
+		realtime = "r" + strconv.FormatUint(service.RealtimeVersion(), 10)
+	}
+	trainType := query.TrainType
+	if trainType == "" {
+		trainType = service.Default
+	}
+	return strings.Join([]string{
+		"v" + strconv.FormatUint(version, 10),
+		realtime,
+		service.NormalizeStationName(query.From),
+		service.NormalizeStationName(query.To),
+		service.NormalizeStationName(query.Mid),
+		query.FromCity,
+		query.ToCity,
+		query.MidCity,
+		query.Date,
+		trainType,
+		strconv.Itoa(query.SortBy),
+		strconv.FormatInt(query.MaxTransfer, 10),
+		strings.Join(query.SeatClasses, ","),
+		string(query.Passenger),
+		constraintsKey(query.Constraints),
+	}, "|")
+}
+
+// constraintsKey 途经站的顺序有意义，避开的车站与车次排序后拼接
+func constraintsKey(constraints service.SearchConstraints) string {
+	if constraints.Empty() {
+		return ""
+	}
+	via := make([]string, 0, len(constraints.Via))
+	for _, station := range constraints.Via {
+		via = append(via, service.NormalizeStationName(station.Station)+"+"+strconv.FormatInt(station.MinDwell, 10))
+	}
+	sorted := func(values []string) string {
+		values = append([]string(nil), values...)
+		sort.Strings(values)
+		return strings.Join(values, ",")
+	}
+	return strings.Join([]string{
+		strings.Join(via, ","),
+		sorted(constraints.AvoidStations),
+		sorted(constraints.AvoidCities),
+		sorted(constraints.ExcludeTrains),
+		sorted(constraints.PreferTrains),
+	}, ";")
+}
+
+// cachedSearchJourneys 先查缓存，未命中时调用 searchJourneys；超时得到的部分结果不写入缓存
+func (h *HandlerImpl) cachedSearchJourneys(ctx context.Context, query journeyQuery) ([]ResponseSearch, bool, error) {
+	if h.Cache == nil {
+		return h.searchJourneys(ctx, query)
+	}
+	version := service.TimetableVersion()
+	if cachedVersion.Swap(version) != version {
+		h.Cache.Purge()
+	}
+	key := query.cacheKey(version)
+	if value, ok := h.Cache.Get(key); ok {
+		var results []ResponseSearch
+		if err := json.Unmarshal(value, &results); err == nil {
+			return results, false, nil
+		}
+	}
+	results, partial, err := h.searchJourneys(ctx, query)
+	if err != nil || partial {
+		return results, partial, err
+	}
+	if value, err := json.Marshal(results); err == nil {
+		h.Cache.Set(key, value)
+	}
+	return results, false, nil
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net/http"
+	"railway/config"
+	"sync/atomic"
+)
+
+// draining 收到退出信号后置为 true，/readyz 返回 503 以便负载均衡摘除实例
+var draining atomic.Bool
+
+// Run 按配置启动 HTTPS 与可选的明文 HTTP 服务，ctx 取消后停止接收新连接，
+// 并在 ShutdownTimeout 内等待进行中的查询完成
+func Run(ctx context.Context, cfg config.ServerConfig, auth config.AuthConfig) error {
+	handler := NewRouter(auth)
+	servers := make([]*http.Server, 0, 2)
+	if cfg.CertFile != "" && cfg.HTTPSAddr != "" {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return err
+		}
+		server := newServer(cfg, cfg.HTTPSAddr, handler)
+		server.TLSConfig = tlsConfig
+		if !cfg.HTTP2 {
+			// TLSNextProto 非 nil 时 net/http 不再自动启用 HTTP/2
+			server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		}
+		servers = append(servers, server)
+	}
+	if cfg.HTTPAddr != "" {
+		servers = append(servers, newServer(cfg, cfg.HTTPAddr, handler))
+	}
+	if len(servers) == 0 {
+		return errors.New("no listen address configured")
+	}
+
+	errCh := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			var err error
+			if server.TLSConfig != nil {
+				slog.Info("https listening", "addr", server.Addr, "http2", cfg.HTTP2)
+				err = server.ListenAndServeTLS("", "")
+			} else {
+				slog.Info("http listening", "addr", server.Addr)
+				err = server.ListenAndServe()
+			}
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			}
+			errCh <- err
+		}(server)
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-errCh:
+	}
+	draining.Store(true)
+	slog.Info("shutting down", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("shutdown failed", "addr", server.Addr, "err", err)
+			serveErr = errors.Join(serveErr, err)
+		}
+	}
+	return serveErr
+}
+
+func newServer(cfg config.ServerConfig, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  cfg.ReadTimeout.Duration,
+		WriteTimeout: cfg.WriteTimeout.Duration,
+		IdleTimeout:  cfg.IdleTimeout.Duration,
+		ErrorLog:     slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+func newTLSConfig(cfg config.ServerConfig) (*tls.Config, error) {
+	reloader, err := newCertReloader(cfg.CertFile, cfg.KeyFile, cfg.CertCheck.Duration)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if cfg.HTTP2 {
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return tlsConfig, nil
+}
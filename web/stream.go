@@ -0,0 +1,140 @@
+package web
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"railway/dao"
+	"time"
+)
+
+// StageEvent 一个子查询完成后推送的事件
+type StageEvent struct {
+	Stage    string       `json:"stage"`
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Journeys []JourneyDTO `json:"journeys"`
+}
+
+// StageError 子查询失败时推送的事件，其余子查询照常进行
+type StageError struct {
+	Stage string `json:"stage"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Error string `json:"error"`
+}
+
+// StreamSummary 最后推送的汇总事件
+type StreamSummary struct {
+	Journeys  int            `json:"journeys"`
+	Stages    map[string]int `json:"stages"`
+	Errors    int            `json:"errors"`
+	Partial   bool           `json:"partial"`
+	ElapsedMs int64          `json:"elapsed_ms"`
+}
+
+type stationPair struct {
+	departure string
+	mid       string
+	arrival   string
+}
+
+// searchStreamHandler 以 SSE 推送查询结果：先推送所有车站对的直达结果，再推送中转结果，最后推送 summary
+func (h *HandlerImpl) searchStreamHandler(c *gin.Context) {
+	startTime := time.Now()
+	query, err := parseJourneyQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), SearchTimeout)
+	defer cancel()
+	pairs, err := h.stationPairs(ctx, query)
+	if err != nil {
+		writeSearchError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	stages := make([][]searchStage, 0, len(pairs))
+	maxStages := 0
+	for _, pair := range pairs {
+		pairStages := h.searchStages(pair.departure, pair.mid, pair.arrival, query.TrainType, query.SortBy, query.MaxTransfer, query.SeatClasses, query.Date, query.Constraints)
+		stages = append(stages, pairStages)
+		if len(pairStages) > maxStages {
+			maxStages = len(pairStages)
+		}
+	}
+	summary := StreamSummary{Stages: make(map[string]int)}
+	seen := make(map[string]bool)
+	for stageIndex := 0; stageIndex < maxStages; stageIndex++ {
+		for pairIndex, pair := range pairs {
+			if stageIndex >= len(stages[pairIndex]) {
+				continue
+			}
+			// 客户端断开或超时后不再继续查询
+			if ctx.Err() != nil {
+				summary.Partial = true
+				break
+			}
+			stage := stages[pairIndex][stageIndex]
+			results, err := stage.run(ctx)
+			if err != nil {
+				summary.Errors++
+				h.logger(c).Warn("search stage failed", "stage", stage.name, "from", pair.departure, "to", pair.arrival, "err", err)
+				c.SSEvent("error", StageError{Stage: stage.name, From: pair.departure, To: pair.arrival, Error: err.Error()})
+				c.Writer.Flush()
+				continue
+			}
+			fresh := make(map[string][]dao.RailWay)
+			for key, value := range results {
+				if !seen[key] && len(value) > 0 {
+					seen[key] = true
+					fresh[key] = value
+				}
+			}
+			journeys := toJourneyDTOs(sortResponse(turnMapToResponseSlice(fresh, query.Passenger), query.SortBy), query.Date, query.Time)
+			journeys = h.attachSeats(c, journeys, query.OnlyAvailable)
+			summary.Journeys = summary.Journeys + len(journeys)
+			summary.Stages[stage.name] = summary.Stages[stage.name] + len(journeys)
+			c.SSEvent("journeys", StageEvent{Stage: stage.name, From: pair.departure, To: pair.arrival, Journeys: journeys})
+			c.Writer.Flush()
+		}
+	}
+	summary.ElapsedMs = time.Since(startTime).Milliseconds()
+	c.SSEvent("summary", summary)
+	c.Writer.Flush()
+}
+
+// stationPairs 将城市展开为车站，返回需要查询的所有车站组合
+func (h *HandlerImpl) stationPairs(ctx context.Context, query journeyQuery) ([]stationPair, error) {
+	departStations, err := h.getStations(ctx, query.From, query.FromCity)
+	if err != nil {
+		return nil, err
+	}
+	arrivalStations, err := h.getStations(ctx, query.To, query.ToCity)
+	if err != nil {
+		return nil, err
+	}
+	midStations := []string{""}
+	if len(query.Mid) > 0 || len(query.MidCity) > 0 {
+		midStations, err = h.getStations(ctx, query.Mid, query.MidCity)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pairs := make([]stationPair, 0)
+	for _, midStation := range midStations {
+		for _, departStation := range departStations {
+			for _, arrivalStation := range arrivalStations {
+				pairs = append(pairs, stationPair{departure: departStation, mid: midStation, arrival: arrivalStation})
+			}
+		}
+	}
+	return pairs, nil
+}
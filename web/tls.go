@@ -0,0 +1,69 @@
+package web
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader 握手时按需检查证书文件的修改时间，文件变化后重新加载，无需重启服务
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = r.latestModTime()
+	r.checkedAt = time.Now()
+	return nil
+}
+
+// latestModTime 取证书与私钥中较新的修改时间，任一文件变化都会触发重新加载
+func (r *certReloader) latestModTime() time.Time {
+	latest := time.Time{}
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// GetCertificate 用于 tls.Config，重新加载失败时继续使用旧证书
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checkedAt) >= r.interval {
+		r.checkedAt = time.Now()
+		if modTime := r.latestModTime(); modTime.After(r.modTime) {
+			if err := r.load(); err != nil {
+				slog.Error("reload certificate failed", "cert", r.certFile, "err", err)
+			} else {
+				slog.Info("certificate reloaded", "cert", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
@@ -0,0 +1,345 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"railway/fare"
+	"railway/service"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxTripSegments 多段行程最多的段数
+	MaxTripSegments = 6
+	// DefaultTripBundles 默认返回的组合数
+	DefaultTripBundles = 5
+	// MaxTripBundles 最多返回的组合数
+	MaxTripBundles = 10
+	// tripCandidates 每段按优化目标取前多少个行程参与组合
+	tripCandidates = 30
+)
+
+// 多段行程的优化目标
+const (
+	OptimizePrice    = "price"
+	OptimizeDuration = "duration"
+)
+
+// RequestTrip POST /api/v1/trips 的请求体，往返为两段，A→B→C→A 的联程为三段。
+// 各段按自己的日期与出发时段查询，相邻两段之间至少停留 min_stay_minutes
+type RequestTrip struct {
+	Segments    []RequestTripSegment `json:"segments"`
+	Optimize    string               `json:"optimize"`
+	MinStay     int64                `json:"min_stay_minutes"`
+	MaxTransfer string               `json:"max_transfer"`
+	TrainType   string               `json:"train_type"`
+	SeatClasses []string             `json:"seat_classes"`
+	Passenger   string               `json:"passenger"`
+	Limit       int                  `json:"limit"`
+}
+
+// RequestTripSegment 一段行程，MinStay 不为空时代替 RequestTrip.MinStay 作为到达上一段终点后的最短停留
+type RequestTripSegment struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	FromCity   string `json:"from_city"`
+	ToCity     string `json:"to_city"`
+	Date       string `json:"date"`
+	Time       string `json:"time"`        // 最早出发时刻
+	LatestTime string `json:"latest_time"` // 最晚出发时刻
+	MinStay    *int64 `json:"min_stay_minutes"`
+}
+
+// TripDTO 一个多段行程组合，total_minutes 为各段在途时间之和，不含停留
+type TripDTO struct {
+	TotalPrice   float64      `json:"total_price"`
+	TotalPayable float64      `json:"total_payable"`
+	TotalMinutes int64        `json:"total_minutes"`
+	Segments     []JourneyDTO `json:"segments"`
+}
+
+// tripCandidate 一段的一个候选行程，departure 与 arrival 为首段发车与末段到达的时间
+type tripCandidate struct {
+	journey   JourneyDTO
+	departure time.Time
+	arrival   time.Time
+}
+
+// tripBundle 组合到某一段为止的部分结果，picks 为各段所选候选的下标
+type tripBundle struct {
+	payable float64
+	minutes int64
+	picks   []int
+}
+
+// tripsHandler 分别查询每一段，再组合出总价最低（optimize=price）或在途时间最短（optimize=duration）的若干个方案
+func (h *HandlerImpl) tripsHandler(c *gin.Context) {
+	var req RequestTrip
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	queries, minStays, err := parseTripRequest(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	candidates, partial, err := h.searchTripSegments(c.Request.Context(), queries, req.Optimize, req.Segments)
+	if err != nil {
+		writeSearchError(c, err)
+		return
+	}
+	bundles := combineTrip(candidates, minStays, req.Optimize, req.Limit)
+	trips := make([]TripDTO, 0, len(bundles))
+	for _, bundle := range bundles {
+		trip := TripDTO{TotalPayable: bundle.payable, TotalMinutes: bundle.minutes, Segments: make([]JourneyDTO, 0, len(bundle.picks))}
+		for index, pick := range bundle.picks {
+			journey := candidates[index][pick].journey
+			trip.TotalPrice = trip.TotalPrice + journey.TotalPrice
+			trip.Segments = append(trip.Segments, journey)
+		}
+		trips = append(trips, trip)
+	}
+	c.JSON(http.StatusOK, gin.H{"trips": trips, "partial": partial})
+}
+
+// parseTripRequest 校验请求并为每一段生成查询条件，minStays[i] 为第 i 段出发前至少停留的分钟数
+func parseTripRequest(req *RequestTrip) ([]journeyQuery, []int64, error) {
+	if len(req.Segments) == 0 || len(req.Segments) > MaxTripSegments {
+		return nil, nil, fmt.Errorf("body.segments: 1 to %d segments are required", MaxTripSegments)
+	}
+	if req.Optimize == "" {
+		req.Optimize = OptimizePrice
+	}
+	sortBy := service.LowPriceFirst
+	if req.Optimize == OptimizeDuration {
+		sortBy = service.LowRunningTimeFirst
+	}
+	if req.Limit <= 0 || req.Limit > MaxTripBundles {
+		req.Limit = DefaultTripBundles
+	}
+	if req.MinStay < 0 {
+		return nil, nil, errors.New("body.min_stay_minutes: must not be negative")
+	}
+	maxTransfer := int64(1)
+	if req.MaxTransfer != "" {
+		var err error
+		if maxTransfer, err = strconv.ParseInt(req.MaxTransfer, 10, 64); err != nil {
+			return nil, nil, errors.New("body.max_transfer: must be an integer")
+		}
+	}
+	trainType := req.TrainType
+	if trainType == "" {
+		trainType = service.Default
+	}
+	seatClasses, err := service.ParseSeatClasses(strings.Join(req.SeatClasses, ","))
+	if err != nil {
+		return nil, nil, errors.New("body.seat_classes: " + err.Error())
+	}
+	passenger, err := fare.ParsePassengerType(req.Passenger)
+	if err != nil {
+		return nil, nil, errors.New("body.passenger: " + err.Error())
+	}
+	queries := make([]journeyQuery, 0, len(req.Segments))
+	minStays := make([]int64, 0, len(req.Segments))
+	for index, segment := range req.Segments {
+		field := fmt.Sprintf("body.segments[%d]", index)
+		if (segment.From == "") == (segment.FromCity == "") {
+			return nil, nil, errors.New(field + ": exactly one of from and from_city is required")
+		}
+		if (segment.To == "") == (segment.ToCity == "") {
+			return nil, nil, errors.New(field + ": exactly one of to and to_city is required")
+		}
+		if segment.LatestTime != "" && segment.Time != "" {
+			earliest, _ := service.GetTime(segment.Time)
+			latest, _ := service.GetTime(segment.LatestTime)
+			if latest < earliest {
+				return nil, nil, errors.New(field + ".latest_time: must not be earlier than time")
+			}
+		}
+		minStay := req.MinStay
+		if segment.MinStay != nil {
+			if *segment.MinStay < 0 {
+				return nil, nil, errors.New(field + ".min_stay_minutes: must not be negative")
+			}
+			minStay = *segment.MinStay
+		}
+		queries = append(queries, journeyQuery{
+			From:        strings.TrimSpace(segment.From),
+			To:          strings.TrimSpace(segment.To),
+			FromCity:    segment.FromCity,
+			ToCity:      segment.ToCity,
+			Date:        segment.Date,
+			Time:        segment.Time,
+			TrainType:   trainType,
+			SortBy:      sortBy,
+			MaxTransfer: maxTransfer,
+			SeatClasses: seatClasses,
+			Passenger:   passenger,
+		})
+		minStays = append(minStays, minStay)
+	}
+	return queries, minStays, nil
+}
+
+// searchTripSegments 并发查询各段，每段只保留出发时段内、按优化目标排在前 tripCandidates 的行程
+func (h *HandlerImpl) searchTripSegments(ctx context.Context, queries []journeyQuery, optimize string, segments []RequestTripSegment) ([][]tripCandidate, bool, error) {
+	candidates := make([][]tripCandidate, len(queries))
+	partials := make([]bool, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for index, query := range queries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, partial, err := h.cachedSearchJourneys(ctx, query)
+			if err != nil {
+				errs[index] = err
+				return
+			}
+			partials[index] = partial
+			candidates[index] = toTripCandidates(toJourneyDTOs(results, query.Date, query.Time), segments[index].LatestTime, optimize)
+		}()
+	}
+	wg.Wait()
+	partial := false
+	for index := range queries {
+		if errs[index] != nil {
+			return nil, false, errs[index]
+		}
+		partial = partial || partials[index]
+	}
+	return candidates, partial, nil
+}
+
+// toTripCandidates latestTime 不为空时去掉晚于该时刻出发的行程
+func toTripCandidates(journeys []JourneyDTO, latestTime, optimize string) []tripCandidate {
+	latest := int64(1440)
+	if latestTime != "" {
+		latest, _ = service.GetTime(latestTime)
+	}
+	candidates := make([]tripCandidate, 0, len(journeys))
+	for _, journey := range journeys {
+		if len(journey.Legs) == 0 {
+			continue
+		}
+		first, last := journey.Legs[0], journey.Legs[len(journey.Legs)-1]
+		departureTime, _ := service.GetTime(first.DepartureTime)
+		if departureTime > latest {
+			continue
+		}
+		departure, err := legTime(first.DepartureDate, first.DepartureTime)
+		if err != nil {
+			continue
+		}
+		arrival, err := legTime(last.ArrivalDate, last.ArrivalTime)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, tripCandidate{journey: journey, departure: departure, arrival: arrival})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return tripLess(candidateCost(candidates[i].journey, optimize), candidateCost(candidates[j].journey, optimize))
+	})
+	if len(candidates) > tripCandidates {
+		candidates = candidates[:tripCandidates]
+	}
+	return candidates
+}
+
+func legTime(date, clock string) (time.Time, error) {
+	day, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	minutes, err := service.GetTime(clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.Add(time.Duration(minutes) * time.Minute), nil
+}
+
+// combineTrip 逐段组合候选行程，第 i 段须在第 i-1 段到达后至少 minStays[i] 分钟出发；
+// 每个候选只保留以它结尾的前 limit 个部分组合，返回按优化目标排列的前 limit 个完整组合
+func combineTrip(candidates [][]tripCandidate, minStays []int64, optimize string, limit int) []tripBundle {
+	previous := make([][]tripBundle, 0)
+	for segment, segmentCandidates := range candidates {
+		current := make([][]tripBundle, len(segmentCandidates))
+		for index, candidate := range segmentCandidates {
+			journey := candidate.journey
+			if segment == 0 {
+				current[index] = []tripBundle{{payable: journey.TotalPayable, minutes: journey.DurationMinutes, picks: []int{index}}}
+				continue
+			}
+			earliest := time.Duration(minStays[segment]) * time.Minute
+			bundles := make([]tripBundle, 0)
+			for previousIndex, previousCandidate := range candidates[segment-1] {
+				if candidate.departure.Before(previousCandidate.arrival.Add(earliest)) {
+					continue
+				}
+				for _, bundle := range previous[previousIndex] {
+					bundles = append(bundles, tripBundle{
+						payable: bundle.payable + journey.TotalPayable,
+						minutes: bundle.minutes + journey.DurationMinutes,
+						picks:   append(append(make([]int, 0, segment+1), bundle.picks...), index),
+					})
+				}
+			}
+			current[index] = topBundles(bundles, optimize, limit)
+		}
+		previous = current
+	}
+	all := make([]tripBundle, 0)
+	for _, bundles := range previous {
+		all = append(all, bundles...)
+	}
+	return topBundles(all, optimize, limit)
+}
+
+func topBundles(bundles []tripBundle, optimize string, limit int) []tripBundle {
+	sort.Slice(bundles, func(i, j int) bool {
+		a, b := bundleCost(bundles[i], optimize), bundleCost(bundles[j], optimize)
+		if a != b {
+			return tripLess(a, b)
+		}
+		for index := range bundles[i].picks {
+			if bundles[i].picks[index] != bundles[j].picks[index] {
+				return bundles[i].picks[index] < bundles[j].picks[index]
+			}
+		}
+		return false
+	})
+	if len(bundles) > limit {
+		bundles = bundles[:limit]
+	}
+	return bundles
+}
+
+// candidateCost 优化目标在前、另一项在后，用于比较
+func candidateCost(journey JourneyDTO, optimize string) [2]float64 {
+	if optimize == OptimizeDuration {
+		return [2]float64{float64(journey.DurationMinutes), journey.TotalPayable}
+	}
+	return [2]float64{journey.TotalPayable, float64(journey.DurationMinutes)}
+}
+
+func bundleCost(bundle tripBundle, optimize string) [2]float64 {
+	if optimize == OptimizeDuration {
+		return [2]float64{float64(bundle.minutes), bundle.payable}
+	}
+	return [2]float64{bundle.payable, float64(bundle.minutes)}
+}
+
+func tripLess(a, b [2]float64) bool {
+	if a[0] != b[0] {
+		return a[0] < b[0]
+	}
+	return a[1] < b[1]
+}
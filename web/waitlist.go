@@ -0,0 +1,99 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"railway/dao"
+	"railway/fare"
+	"railway/service"
+	"time"
+)
+
+// waitlistTiers tier 参数到 service 中候补优先级的映射
+var waitlistTiers = map[string]int{
+	"standard": service.WaitlistTierStandard,
+	"priority": service.WaitlistTierPriority,
+}
+
+// RequestWaitlist POST /waitlist 的请求体，候补一段；deadline 为空或晚于发车前两小时时截止到发车前两小时
+type RequestWaitlist struct {
+	RequestOrderLeg
+	Passengers []RequestOrderPassenger `json:"passengers"`
+	Tier       string                  `json:"tier"`
+	Deadline   *time.Time              `json:"deadline"`
+}
+
+func (h *HandlerImpl) joinWaitlistHandler(c *gin.Context) {
+	var req RequestWaitlist
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	waitlistReq := service.WaitlistRequest{
+		Leg:        service.OrderLegRequest(req.RequestOrderLeg),
+		Passengers: make([]service.OrderPassengerRequest, 0, len(req.Passengers)),
+		Priority:   waitlistTiers[req.Tier],
+		APIKeyID:   apiKeyID(c),
+	}
+	// 开启鉴权时只有管理员 key 可以加入优先队列
+	value, _ := c.Get(apiKeyCtxKey)
+	if key, ok := value.(*dao.APIKey); ok && !key.Admin && waitlistReq.Priority > service.WaitlistTierStandard {
+		c.JSON(http.StatusForbidden, gin.H{"error": "body.tier: priority requires an admin API key"})
+		return
+	}
+	if req.Deadline != nil {
+		waitlistReq.Deadline = *req.Deadline
+	}
+	for _, passenger := range req.Passengers {
+		passengerType, err := fare.ParsePassengerType(passenger.Type)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "body.passengers.type: " + err.Error()})
+			return
+		}
+		waitlistReq.Passengers = append(waitlistReq.Passengers, service.OrderPassengerRequest{
+			Name:     passenger.Name,
+			IDNumber: passenger.IDNumber,
+			Type:     passengerType,
+		})
+	}
+	entry, err := h.WaitlistService.JoinWaitlist(c.Request.Context(), waitlistReq)
+	if err != nil {
+		writeWaitlistError(c, err)
+		return
+	}
+	// 查询位置失败时不影响已加入的候补，position 为 0
+	_, position, _ := h.WaitlistService.GetWaitlistEntry(c.Request.Context(), entry.ID, entry.APIKeyID)
+	c.JSON(http.StatusCreated, toWaitlistDTO(*entry, position))
+}
+
+func (h *HandlerImpl) getWaitlistHandler(c *gin.Context) {
+	entry, position, err := h.WaitlistService.GetWaitlistEntry(c.Request.Context(), c.Param("id"), apiKeyID(c))
+	if err != nil {
+		writeWaitlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toWaitlistDTO(*entry, position))
+}
+
+func (h *HandlerImpl) cancelWaitlistHandler(c *gin.Context) {
+	entry, err := h.WaitlistService.CancelWaitlistEntry(c.Request.Context(), c.Param("id"), apiKeyID(c))
+	if err != nil {
+		writeWaitlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toWaitlistDTO(*entry, 0))
+}
+
+func writeWaitlistError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrWaitlistNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "waitlist entry not found"})
+	case errors.Is(err, service.ErrSeatsAvailable):
+		c.JSON(http.StatusConflict, gin.H{"error": "seats are available, book the order directly"})
+	case errors.Is(err, dao.ErrWaitlistStateChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "waitlist entry is no longer waiting"})
+	default:
+		writeOrderError(c, err)
+	}
+}